@@ -0,0 +1,102 @@
+package chroma
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// CollectionAPI is the set of operations on a collection, implemented by
+// *Collection. Depend on it instead of *Collection to use fakes in tests,
+// see package chromatest.
+//
+// Reembed is left out since it returns the concrete replacement collection.
+type CollectionAPI interface {
+	Add(ctx context.Context, ids []ID, embeddings []Embedding, metadatas []Metadata, documents []Document) (bool, error)
+	AddOne(ctx context.Context, id ID, embedding Embedding, metadata Metadata, document Document) (bool, error)
+	Upsert(ctx context.Context, ids []ID, embeddings []Embedding, metadatas []Metadata, documents []Document) (bool, error)
+	UpsertOne(ctx context.Context, id ID, embedding Embedding, metadata Metadata, document Document) (bool, error)
+	Update(ctx context.Context, ids []ID, embeddings []Embedding, metadatas []Metadata, documents []Document) (bool, error)
+	UpdateOne(ctx context.Context, id ID, embedding Embedding, metadata Metadata, document Document) (bool, error)
+	Delete(ctx context.Context, ids []ID, where Where, whereDocument Where) ([]ID, error)
+	DeleteAll(ctx context.Context) ([]ID, error)
+	Count(ctx context.Context) (int, error)
+	Query(ctx context.Context, queryTexts []Document, nResults int, opts ...QueryOpts) (*QueryResult, error)
+	QueryEmbeddings(ctx context.Context, queryEmbeddings []Embedding, nResults int, opts ...QueryOpts) (*QueryResult, error)
+	QueryMMR(ctx context.Context, queryTexts []Document, k, fetchK int, lambda float64, opts ...QueryOpts) (*QueryResult, error)
+	QueryEmbeddingsMMR(ctx context.Context, queryEmbeddings []Embedding, k, fetchK int, lambda float64, opts ...QueryOpts) (*QueryResult, error)
+	Get(ctx context.Context, ids []ID, opts ...QueryOpts) (*EmbeddingResponse, error)
+	GetPages(ctx context.Context, pageSize int, fn func(page *EmbeddingResponse) error, opts ...QueryOpts) error
+	Modify(ctx context.Context, name string, metadata Metadata) error
+	Export(ctx context.Context, w io.Writer, format SnapshotFormat, opts ...ExportOpts) error
+	IngestDocuments(ctx context.Context, docs []SourceDoc, opts ...IngestOpts) ([]IngestReport, error)
+	Sync(ctx context.Context, records []SourceDoc, opts ...SyncOpts) (*SyncPlan, error)
+	PlanSync(ctx context.Context, records []SourceDoc, opts ...SyncOpts) (*SyncPlan, error)
+	ApplySync(ctx context.Context, plan *SyncPlan, opts ...SyncOpts) error
+}
+
+var _ CollectionAPI = (*Collection)(nil)
+
+// ClientAPI is the set of operations on a server, with collections returned
+// as CollectionAPI. Use Client.API to get one for a *Client, whose own
+// methods return *Collection.
+type ClientAPI interface {
+	Reset(ctx context.Context) error
+	Version(ctx context.Context) (string, error)
+	Heartbeat(ctx context.Context) (time.Time, error)
+	CreateCollection(ctx context.Context, name string, opts ...CollectionOpts) (CollectionAPI, error)
+	GetOrCreateCollection(ctx context.Context, name string, opts ...CollectionOpts) (CollectionAPI, error)
+	GetCollection(ctx context.Context, name string, opts ...CollectionOpts) (CollectionAPI, error)
+	ListCollections(ctx context.Context) ([]SimpleCollection, error)
+	DeleteCollection(ctx context.Context, name string) error
+	ImportCollection(ctx context.Context, r io.Reader, opts ...ImportOpts) (*ImportAPIResult, error)
+}
+
+// ImportAPIResult is an ImportResult with the collection as CollectionAPI,
+// returned by ClientAPI.ImportCollection.
+type ImportAPIResult struct {
+	Collection CollectionAPI
+	// Imported is the number of records imported, not counting skipped ones.
+	Imported int
+}
+
+var _ ClientAPI = clientAPI{}
+
+// API returns c as a ClientAPI.
+func (c *Client) API() ClientAPI {
+	return clientAPI{c}
+}
+
+// clientAPI adapts *Client to ClientAPI, the methods not returning
+// collections are promoted as they are.
+type clientAPI struct {
+	*Client
+}
+
+func (c clientAPI) CreateCollection(ctx context.Context, name string, opts ...CollectionOpts) (CollectionAPI, error) {
+	return collectionAPIOf(c.Client.CreateCollection(ctx, name, opts...))
+}
+
+func (c clientAPI) GetOrCreateCollection(ctx context.Context, name string, opts ...CollectionOpts) (CollectionAPI, error) {
+	return collectionAPIOf(c.Client.GetOrCreateCollection(ctx, name, opts...))
+}
+
+func (c clientAPI) GetCollection(ctx context.Context, name string, opts ...CollectionOpts) (CollectionAPI, error) {
+	return collectionAPIOf(c.Client.GetCollection(ctx, name, opts...))
+}
+
+func (c clientAPI) ImportCollection(ctx context.Context, r io.Reader, opts ...ImportOpts) (*ImportAPIResult, error) {
+	res, err := c.Client.ImportCollection(ctx, r, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &ImportAPIResult{Collection: res.Collection, Imported: res.Imported}, nil
+}
+
+// collectionAPIOf avoids returning a non-nil interface holding a nil *Collection.
+func collectionAPIOf(coll *Collection, err error) (CollectionAPI, error) {
+	if err != nil {
+		return nil, err
+	}
+	return coll, nil
+}
@@ -0,0 +1,63 @@
+// Package chromatest provides fakes of chroma.ClientAPI and
+// chroma.CollectionAPI for unit tests. Fakes record their calls and return
+// programmable responses: set a method's Func field to control what it
+// returns, or rely on the defaults, which behave like an empty server.
+// Create fakes with NewFakeClient and NewFakeCollection.
+package chromatest
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrNotFound is returned by FakeClient for collections which don't exist.
+var ErrNotFound = errors.New("not found")
+
+// Call is a recorded call to a fake, with its arguments except the context.
+type Call struct {
+	Method string
+	Args   []interface{}
+}
+
+// recorder records calls, it is embedded in the fakes.
+type recorder struct {
+	lock  sync.Locker
+	calls []Call
+}
+
+func newRecorder() recorder {
+	return recorder{lock: &sync.Mutex{}, calls: make([]Call, 0)}
+}
+
+func (r *recorder) record(method string, args ...interface{}) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.calls = append(r.calls, Call{Method: method, Args: args})
+}
+
+// Calls returns every call in order.
+func (r *recorder) Calls() []Call {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return append([]Call{}, r.calls...)
+}
+
+// CallsTo returns the calls to method in order.
+func (r *recorder) CallsTo(method string) []Call {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	calls := make([]Call, 0)
+	for _, c := range r.calls {
+		if c.Method == method {
+			calls = append(calls, c)
+		}
+	}
+	return calls
+}
+
+// ResetCalls forgets all recorded calls.
+func (r *recorder) ResetCalls() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.calls = make([]Call, 0)
+}
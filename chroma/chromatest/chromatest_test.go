@@ -0,0 +1,147 @@
+package chromatest_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kristofferostlund/chroma-go/chroma"
+	"github.com/kristofferostlund/chroma-go/chroma/chromatest"
+)
+
+func TestFakeClient(t *testing.T) {
+	ctx := context.Background()
+	client := chromatest.NewFakeClient()
+	existing := client.AddCollection("existing", chroma.Metadata{"owner": "tests"})
+
+	created, err := client.CreateCollection(ctx, "docs")
+	if err != nil {
+		t.Fatalf("creating collection: %v", err)
+	}
+	if _, err := client.CreateCollection(ctx, "docs"); err == nil {
+		t.Error("got no error creating an existing collection")
+	}
+	if got, err := client.GetOrCreateCollection(ctx, "docs"); err != nil || got != created {
+		t.Errorf("got %v and error %v, want the created collection", got, err)
+	}
+	if got, err := client.GetCollection(ctx, "existing"); err != nil || got != existing {
+		t.Errorf("got %v and error %v, want the added collection", got, err)
+	}
+
+	colls, err := client.ListCollections(ctx)
+	if err != nil {
+		t.Fatalf("listing collections: %v", err)
+	}
+	want := []chroma.SimpleCollection{
+		{ID: "fake-2", Name: "docs", Metadata: nil},
+		{ID: "fake-1", Name: "existing", Metadata: chroma.Metadata{"owner": "tests"}},
+	}
+	if !reflect.DeepEqual(colls, want) {
+		t.Errorf("got collections %v, want %v", colls, want)
+	}
+
+	if err := client.DeleteCollection(ctx, "docs"); err != nil {
+		t.Fatalf("deleting collection: %v", err)
+	}
+	if _, err := client.GetCollection(ctx, "docs"); !errors.Is(err, chromatest.ErrNotFound) {
+		t.Errorf("got error %v getting a deleted collection, want ErrNotFound", err)
+	}
+	if err := client.DeleteCollection(ctx, "docs"); !errors.Is(err, chromatest.ErrNotFound) {
+		t.Errorf("got error %v deleting a missing collection, want ErrNotFound", err)
+	}
+	if err := client.Reset(ctx); err != nil || len(client.Collections) != 0 {
+		t.Errorf("got %d collections and error %v after resetting, want none", len(client.Collections), err)
+	}
+}
+
+func TestFakeClientCalls(t *testing.T) {
+	ctx := context.Background()
+	client := chromatest.NewFakeClient()
+	_, _ = client.Version(ctx)
+	_, _ = client.GetCollection(ctx, "a")
+	_ = client.DeleteCollection(ctx, "b")
+	_, _ = client.GetCollection(ctx, "c")
+
+	var methods []string
+	for _, c := range client.Calls() {
+		methods = append(methods, c.Method)
+	}
+	if want := []string{"Version", "GetCollection", "DeleteCollection", "GetCollection"}; !reflect.DeepEqual(methods, want) {
+		t.Errorf("got calls %v, want %v", methods, want)
+	}
+	gets := client.CallsTo("GetCollection")
+	if len(gets) != 2 || gets[0].Args[0] != "a" || gets[1].Args[0] != "c" {
+		t.Errorf("got calls %v, want GetCollection of a and c", gets)
+	}
+	client.ResetCalls()
+	if got := client.Calls(); len(got) != 0 {
+		t.Errorf("got calls %v after resetting, want none", got)
+	}
+}
+
+func TestFakeClientFuncs(t *testing.T) {
+	ctx := context.Background()
+	client := chromatest.NewFakeClient()
+	imported := chromatest.NewFakeCollection("id", "imported", nil)
+	imported.CountFunc = func(ctx context.Context) (int, error) {
+		return 3, nil
+	}
+	client.ImportCollectionFunc = func(ctx context.Context, r io.Reader, opts ...chroma.ImportOpts) (*chroma.ImportAPIResult, error) {
+		return &chroma.ImportAPIResult{Collection: imported, Imported: 3}, nil
+	}
+	errUnavailable := errors.New("unavailable")
+	client.VersionFunc = func(ctx context.Context) (string, error) {
+		return "", errUnavailable
+	}
+
+	snapshot := strings.NewReader("snapshot")
+	res, err := client.ImportCollection(ctx, snapshot, chroma.WithImportName("imported"))
+	if err != nil {
+		t.Fatalf("importing: %v", err)
+	}
+	if n, err := res.Collection.Count(ctx); err != nil || n != res.Imported {
+		t.Errorf("got count %d and error %v, want %d", n, err, res.Imported)
+	}
+	if calls := imported.CallsTo("Count"); len(calls) != 1 {
+		t.Errorf("got %d calls to Count, want 1", len(calls))
+	}
+	if calls := client.CallsTo("ImportCollection"); len(calls) != 1 || calls[0].Args[0] != snapshot {
+		t.Errorf("got calls %v, want the import of the snapshot", calls)
+	}
+	if _, err := client.Version(ctx); !errors.Is(err, errUnavailable) {
+		t.Errorf("got error %v, want the programmed one", err)
+	}
+}
+
+func TestFakeCollection(t *testing.T) {
+	ctx := context.Background()
+	coll := chromatest.NewFakeCollection("id", "docs", nil)
+
+	if _, err := coll.Add(ctx, []chroma.ID{"a"}, nil, nil, []chroma.Document{"text"}); err != nil {
+		t.Fatalf("adding: %v", err)
+	}
+	res, err := coll.Query(ctx, []chroma.Document{"one", "two"}, 5)
+	if err != nil {
+		t.Fatalf("querying: %v", err)
+	}
+	if len(res.IDs) != 2 || len(res.IDs[0]) != 0 {
+		t.Errorf("got IDs %v, want empty results per query", res.IDs)
+	}
+	if err := coll.Modify(ctx, "renamed", chroma.Metadata{"k": "v"}); err != nil || coll.Name != "renamed" {
+		t.Errorf("got name %q and error %v, want renamed", coll.Name, err)
+	}
+
+	adds := coll.CallsTo("Add")
+	if len(adds) != 1 {
+		t.Fatalf("got %d calls to Add, want 1", len(adds))
+	}
+	if got, want := adds[0].Args[0], []chroma.ID{"a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got IDs %v, want %v", got, want)
+	}
+	if got := len(coll.Calls()); got != 3 {
+		t.Errorf("got %d calls, want 3", got)
+	}
+}
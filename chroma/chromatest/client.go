@@ -0,0 +1,164 @@
+package chromatest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/kristofferostlund/chroma-go/chroma"
+)
+
+var _ chroma.ClientAPI = (*FakeClient)(nil)
+
+// FakeClient is a chroma.ClientAPI recording its calls. Methods call their
+// Func field if set. Otherwise collections are created, listed and deleted
+// in Collections, as FakeCollections without options applied.
+type FakeClient struct {
+	recorder
+
+	lock        sync.Locker
+	nextID      int
+	Collections map[string]*FakeCollection
+
+	ResetFunc                 func(ctx context.Context) error
+	VersionFunc               func(ctx context.Context) (string, error)
+	HeartbeatFunc             func(ctx context.Context) (time.Time, error)
+	CreateCollectionFunc      func(ctx context.Context, name string, opts ...chroma.CollectionOpts) (chroma.CollectionAPI, error)
+	GetOrCreateCollectionFunc func(ctx context.Context, name string, opts ...chroma.CollectionOpts) (chroma.CollectionAPI, error)
+	GetCollectionFunc         func(ctx context.Context, name string, opts ...chroma.CollectionOpts) (chroma.CollectionAPI, error)
+	ListCollectionsFunc       func(ctx context.Context) ([]chroma.SimpleCollection, error)
+	DeleteCollectionFunc      func(ctx context.Context, name string) error
+	ImportCollectionFunc      func(ctx context.Context, r io.Reader, opts ...chroma.ImportOpts) (*chroma.ImportAPIResult, error)
+}
+
+func NewFakeClient() *FakeClient {
+	return &FakeClient{
+		recorder:    newRecorder(),
+		lock:        &sync.Mutex{},
+		nextID:      1,
+		Collections: make(map[string]*FakeCollection),
+	}
+}
+
+// AddCollection adds a fake collection, returning it for programming.
+func (f *FakeClient) AddCollection(name string, metadata chroma.Metadata) *FakeCollection {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.addCollection(name, metadata)
+}
+
+func (f *FakeClient) addCollection(name string, metadata chroma.Metadata) *FakeCollection {
+	coll := NewFakeCollection(fmt.Sprintf("fake-%d", f.nextID), name, metadata)
+	f.nextID++
+	f.Collections[name] = coll
+	return coll
+}
+
+func (f *FakeClient) Reset(ctx context.Context) error {
+	f.record("Reset")
+	if f.ResetFunc != nil {
+		return f.ResetFunc(ctx)
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.Collections = make(map[string]*FakeCollection)
+	return nil
+}
+
+func (f *FakeClient) Version(ctx context.Context) (string, error) {
+	f.record("Version")
+	if f.VersionFunc != nil {
+		return f.VersionFunc(ctx)
+	}
+	return "fake", nil
+}
+
+func (f *FakeClient) Heartbeat(ctx context.Context) (time.Time, error) {
+	f.record("Heartbeat")
+	if f.HeartbeatFunc != nil {
+		return f.HeartbeatFunc(ctx)
+	}
+	return time.Now(), nil
+}
+
+func (f *FakeClient) CreateCollection(ctx context.Context, name string, opts ...chroma.CollectionOpts) (chroma.CollectionAPI, error) {
+	f.record("CreateCollection", name, opts)
+	if f.CreateCollectionFunc != nil {
+		return f.CreateCollectionFunc(ctx, name, opts...)
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if _, ok := f.Collections[name]; ok {
+		return nil, fmt.Errorf("creating collection: %q already exists", name)
+	}
+	return f.addCollection(name, nil), nil
+}
+
+func (f *FakeClient) GetOrCreateCollection(ctx context.Context, name string, opts ...chroma.CollectionOpts) (chroma.CollectionAPI, error) {
+	f.record("GetOrCreateCollection", name, opts)
+	if f.GetOrCreateCollectionFunc != nil {
+		return f.GetOrCreateCollectionFunc(ctx, name, opts...)
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if coll, ok := f.Collections[name]; ok {
+		return coll, nil
+	}
+	return f.addCollection(name, nil), nil
+}
+
+func (f *FakeClient) GetCollection(ctx context.Context, name string, opts ...chroma.CollectionOpts) (chroma.CollectionAPI, error) {
+	f.record("GetCollection", name, opts)
+	if f.GetCollectionFunc != nil {
+		return f.GetCollectionFunc(ctx, name, opts...)
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	coll, ok := f.Collections[name]
+	if !ok {
+		return nil, fmt.Errorf("getting collection: %w: %q", ErrNotFound, name)
+	}
+	return coll, nil
+}
+
+// ListCollections lists Collections sorted by name by default.
+func (f *FakeClient) ListCollections(ctx context.Context) ([]chroma.SimpleCollection, error) {
+	f.record("ListCollections")
+	if f.ListCollectionsFunc != nil {
+		return f.ListCollectionsFunc(ctx)
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	colls := make([]chroma.SimpleCollection, 0, len(f.Collections))
+	for _, c := range f.Collections {
+		colls = append(colls, chroma.SimpleCollection{ID: c.ID, Name: c.Name, Metadata: c.Metadata})
+	}
+	sort.Slice(colls, func(i, j int) bool { return colls[i].Name < colls[j].Name })
+	return colls, nil
+}
+
+func (f *FakeClient) DeleteCollection(ctx context.Context, name string) error {
+	f.record("DeleteCollection", name)
+	if f.DeleteCollectionFunc != nil {
+		return f.DeleteCollectionFunc(ctx, name)
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if _, ok := f.Collections[name]; !ok {
+		return fmt.Errorf("deleting collection: %w: %q", ErrNotFound, name)
+	}
+	delete(f.Collections, name)
+	return nil
+}
+
+// ImportCollection imports nothing by default.
+func (f *FakeClient) ImportCollection(ctx context.Context, r io.Reader, opts ...chroma.ImportOpts) (*chroma.ImportAPIResult, error) {
+	f.record("ImportCollection", r, opts)
+	if f.ImportCollectionFunc != nil {
+		return f.ImportCollectionFunc(ctx, r, opts...)
+	}
+	return &chroma.ImportAPIResult{Collection: nil, Imported: 0}, nil
+}
@@ -0,0 +1,231 @@
+package chromatest
+
+import (
+	"context"
+	"io"
+
+	"github.com/kristofferostlund/chroma-go/chroma"
+)
+
+var _ chroma.CollectionAPI = (*FakeCollection)(nil)
+
+// FakeCollection is a chroma.CollectionAPI recording its calls. Methods
+// call their Func field if set, otherwise they succeed with empty results.
+type FakeCollection struct {
+	recorder
+
+	ID       string
+	Name     string
+	Metadata chroma.Metadata
+
+	AddFunc                func(ctx context.Context, ids []chroma.ID, embeddings []chroma.Embedding, metadatas []chroma.Metadata, documents []chroma.Document) (bool, error)
+	AddOneFunc             func(ctx context.Context, id chroma.ID, embedding chroma.Embedding, metadata chroma.Metadata, document chroma.Document) (bool, error)
+	UpsertFunc             func(ctx context.Context, ids []chroma.ID, embeddings []chroma.Embedding, metadatas []chroma.Metadata, documents []chroma.Document) (bool, error)
+	UpsertOneFunc          func(ctx context.Context, id chroma.ID, embedding chroma.Embedding, metadata chroma.Metadata, document chroma.Document) (bool, error)
+	UpdateFunc             func(ctx context.Context, ids []chroma.ID, embeddings []chroma.Embedding, metadatas []chroma.Metadata, documents []chroma.Document) (bool, error)
+	UpdateOneFunc          func(ctx context.Context, id chroma.ID, embedding chroma.Embedding, metadata chroma.Metadata, document chroma.Document) (bool, error)
+	DeleteFunc             func(ctx context.Context, ids []chroma.ID, where chroma.Where, whereDocument chroma.Where) ([]chroma.ID, error)
+	DeleteAllFunc          func(ctx context.Context) ([]chroma.ID, error)
+	CountFunc              func(ctx context.Context) (int, error)
+	QueryFunc              func(ctx context.Context, queryTexts []chroma.Document, nResults int, opts ...chroma.QueryOpts) (*chroma.QueryResult, error)
+	QueryEmbeddingsFunc    func(ctx context.Context, queryEmbeddings []chroma.Embedding, nResults int, opts ...chroma.QueryOpts) (*chroma.QueryResult, error)
+	QueryMMRFunc           func(ctx context.Context, queryTexts []chroma.Document, k, fetchK int, lambda float64, opts ...chroma.QueryOpts) (*chroma.QueryResult, error)
+	QueryEmbeddingsMMRFunc func(ctx context.Context, queryEmbeddings []chroma.Embedding, k, fetchK int, lambda float64, opts ...chroma.QueryOpts) (*chroma.QueryResult, error)
+	GetFunc                func(ctx context.Context, ids []chroma.ID, opts ...chroma.QueryOpts) (*chroma.EmbeddingResponse, error)
+	GetPagesFunc           func(ctx context.Context, pageSize int, fn func(page *chroma.EmbeddingResponse) error, opts ...chroma.QueryOpts) error
+	ModifyFunc             func(ctx context.Context, name string, metadata chroma.Metadata) error
+	ExportFunc             func(ctx context.Context, w io.Writer, format chroma.SnapshotFormat, opts ...chroma.ExportOpts) error
+	IngestDocumentsFunc    func(ctx context.Context, docs []chroma.SourceDoc, opts ...chroma.IngestOpts) ([]chroma.IngestReport, error)
+	SyncFunc               func(ctx context.Context, records []chroma.SourceDoc, opts ...chroma.SyncOpts) (*chroma.SyncPlan, error)
+	PlanSyncFunc           func(ctx context.Context, records []chroma.SourceDoc, opts ...chroma.SyncOpts) (*chroma.SyncPlan, error)
+	ApplySyncFunc          func(ctx context.Context, plan *chroma.SyncPlan, opts ...chroma.SyncOpts) error
+}
+
+func NewFakeCollection(id, name string, metadata chroma.Metadata) *FakeCollection {
+	return &FakeCollection{recorder: newRecorder(), ID: id, Name: name, Metadata: metadata}
+}
+
+func (f *FakeCollection) Add(ctx context.Context, ids []chroma.ID, embeddings []chroma.Embedding, metadatas []chroma.Metadata, documents []chroma.Document) (bool, error) {
+	f.record("Add", ids, embeddings, metadatas, documents)
+	if f.AddFunc != nil {
+		return f.AddFunc(ctx, ids, embeddings, metadatas, documents)
+	}
+	return true, nil
+}
+
+func (f *FakeCollection) AddOne(ctx context.Context, id chroma.ID, embedding chroma.Embedding, metadata chroma.Metadata, document chroma.Document) (bool, error) {
+	f.record("AddOne", id, embedding, metadata, document)
+	if f.AddOneFunc != nil {
+		return f.AddOneFunc(ctx, id, embedding, metadata, document)
+	}
+	return true, nil
+}
+
+func (f *FakeCollection) Upsert(ctx context.Context, ids []chroma.ID, embeddings []chroma.Embedding, metadatas []chroma.Metadata, documents []chroma.Document) (bool, error) {
+	f.record("Upsert", ids, embeddings, metadatas, documents)
+	if f.UpsertFunc != nil {
+		return f.UpsertFunc(ctx, ids, embeddings, metadatas, documents)
+	}
+	return true, nil
+}
+
+func (f *FakeCollection) UpsertOne(ctx context.Context, id chroma.ID, embedding chroma.Embedding, metadata chroma.Metadata, document chroma.Document) (bool, error) {
+	f.record("UpsertOne", id, embedding, metadata, document)
+	if f.UpsertOneFunc != nil {
+		return f.UpsertOneFunc(ctx, id, embedding, metadata, document)
+	}
+	return true, nil
+}
+
+func (f *FakeCollection) Update(ctx context.Context, ids []chroma.ID, embeddings []chroma.Embedding, metadatas []chroma.Metadata, documents []chroma.Document) (bool, error) {
+	f.record("Update", ids, embeddings, metadatas, documents)
+	if f.UpdateFunc != nil {
+		return f.UpdateFunc(ctx, ids, embeddings, metadatas, documents)
+	}
+	return true, nil
+}
+
+func (f *FakeCollection) UpdateOne(ctx context.Context, id chroma.ID, embedding chroma.Embedding, metadata chroma.Metadata, document chroma.Document) (bool, error) {
+	f.record("UpdateOne", id, embedding, metadata, document)
+	if f.UpdateOneFunc != nil {
+		return f.UpdateOneFunc(ctx, id, embedding, metadata, document)
+	}
+	return true, nil
+}
+
+func (f *FakeCollection) Delete(ctx context.Context, ids []chroma.ID, where chroma.Where, whereDocument chroma.Where) ([]chroma.ID, error) {
+	f.record("Delete", ids, where, whereDocument)
+	if f.DeleteFunc != nil {
+		return f.DeleteFunc(ctx, ids, where, whereDocument)
+	}
+	return []chroma.ID{}, nil
+}
+
+func (f *FakeCollection) DeleteAll(ctx context.Context) ([]chroma.ID, error) {
+	f.record("DeleteAll")
+	if f.DeleteAllFunc != nil {
+		return f.DeleteAllFunc(ctx)
+	}
+	return []chroma.ID{}, nil
+}
+
+func (f *FakeCollection) Count(ctx context.Context) (int, error) {
+	f.record("Count")
+	if f.CountFunc != nil {
+		return f.CountFunc(ctx)
+	}
+	return 0, nil
+}
+
+func (f *FakeCollection) Query(ctx context.Context, queryTexts []chroma.Document, nResults int, opts ...chroma.QueryOpts) (*chroma.QueryResult, error) {
+	f.record("Query", queryTexts, nResults, opts)
+	if f.QueryFunc != nil {
+		return f.QueryFunc(ctx, queryTexts, nResults, opts...)
+	}
+	return emptyQueryResult(len(queryTexts)), nil
+}
+
+func (f *FakeCollection) QueryEmbeddings(ctx context.Context, queryEmbeddings []chroma.Embedding, nResults int, opts ...chroma.QueryOpts) (*chroma.QueryResult, error) {
+	f.record("QueryEmbeddings", queryEmbeddings, nResults, opts)
+	if f.QueryEmbeddingsFunc != nil {
+		return f.QueryEmbeddingsFunc(ctx, queryEmbeddings, nResults, opts...)
+	}
+	return emptyQueryResult(len(queryEmbeddings)), nil
+}
+
+func (f *FakeCollection) QueryMMR(ctx context.Context, queryTexts []chroma.Document, k, fetchK int, lambda float64, opts ...chroma.QueryOpts) (*chroma.QueryResult, error) {
+	f.record("QueryMMR", queryTexts, k, fetchK, lambda, opts)
+	if f.QueryMMRFunc != nil {
+		return f.QueryMMRFunc(ctx, queryTexts, k, fetchK, lambda, opts...)
+	}
+	return emptyQueryResult(len(queryTexts)), nil
+}
+
+func (f *FakeCollection) QueryEmbeddingsMMR(ctx context.Context, queryEmbeddings []chroma.Embedding, k, fetchK int, lambda float64, opts ...chroma.QueryOpts) (*chroma.QueryResult, error) {
+	f.record("QueryEmbeddingsMMR", queryEmbeddings, k, fetchK, lambda, opts)
+	if f.QueryEmbeddingsMMRFunc != nil {
+		return f.QueryEmbeddingsMMRFunc(ctx, queryEmbeddings, k, fetchK, lambda, opts...)
+	}
+	return emptyQueryResult(len(queryEmbeddings)), nil
+}
+
+func (f *FakeCollection) Get(ctx context.Context, ids []chroma.ID, opts ...chroma.QueryOpts) (*chroma.EmbeddingResponse, error) {
+	f.record("Get", ids, opts)
+	if f.GetFunc != nil {
+		return f.GetFunc(ctx, ids, opts...)
+	}
+	return &chroma.EmbeddingResponse{IDs: []string{}, Embeddings: nil, Documents: nil, Metadatas: nil}, nil
+}
+
+func (f *FakeCollection) GetPages(ctx context.Context, pageSize int, fn func(page *chroma.EmbeddingResponse) error, opts ...chroma.QueryOpts) error {
+	f.record("GetPages", pageSize, opts)
+	if f.GetPagesFunc != nil {
+		return f.GetPagesFunc(ctx, pageSize, fn, opts...)
+	}
+	return nil
+}
+
+// Modify renames the fake and replaces its metadata by default, like the
+// real collection.
+func (f *FakeCollection) Modify(ctx context.Context, name string, metadata chroma.Metadata) error {
+	f.record("Modify", name, metadata)
+	if f.ModifyFunc != nil {
+		return f.ModifyFunc(ctx, name, metadata)
+	}
+	if name != "" {
+		f.Name = name
+	}
+	if len(metadata) > 0 {
+		f.Metadata = metadata
+	}
+	return nil
+}
+
+func (f *FakeCollection) Export(ctx context.Context, w io.Writer, format chroma.SnapshotFormat, opts ...chroma.ExportOpts) error {
+	f.record("Export", w, format, opts)
+	if f.ExportFunc != nil {
+		return f.ExportFunc(ctx, w, format, opts...)
+	}
+	return nil
+}
+
+func (f *FakeCollection) IngestDocuments(ctx context.Context, docs []chroma.SourceDoc, opts ...chroma.IngestOpts) ([]chroma.IngestReport, error) {
+	f.record("IngestDocuments", docs, opts)
+	if f.IngestDocumentsFunc != nil {
+		return f.IngestDocumentsFunc(ctx, docs, opts...)
+	}
+	return []chroma.IngestReport{}, nil
+}
+
+func (f *FakeCollection) Sync(ctx context.Context, records []chroma.SourceDoc, opts ...chroma.SyncOpts) (*chroma.SyncPlan, error) {
+	f.record("Sync", records, opts)
+	if f.SyncFunc != nil {
+		return f.SyncFunc(ctx, records, opts...)
+	}
+	return &chroma.SyncPlan{}, nil
+}
+
+func (f *FakeCollection) PlanSync(ctx context.Context, records []chroma.SourceDoc, opts ...chroma.SyncOpts) (*chroma.SyncPlan, error) {
+	f.record("PlanSync", records, opts)
+	if f.PlanSyncFunc != nil {
+		return f.PlanSyncFunc(ctx, records, opts...)
+	}
+	return &chroma.SyncPlan{}, nil
+}
+
+func (f *FakeCollection) ApplySync(ctx context.Context, plan *chroma.SyncPlan, opts ...chroma.SyncOpts) error {
+	f.record("ApplySync", plan, opts)
+	if f.ApplySyncFunc != nil {
+		return f.ApplySyncFunc(ctx, plan, opts...)
+	}
+	return nil
+}
+
+// emptyQueryResult has an empty list of results per query.
+func emptyQueryResult(queries int) *chroma.QueryResult {
+	res := &chroma.QueryResult{IDs: make([][]chroma.ID, queries), Embeddings: nil, Documents: nil, Metadatas: nil, Distances: nil}
+	for i := range res.IDs {
+		res.IDs[i] = []chroma.ID{}
+	}
+	return res
+}
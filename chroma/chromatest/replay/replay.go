@@ -0,0 +1,441 @@
+// Package replay records HTTP interactions into cassette files and replays
+// them offline, so tests of code using chroma.Client and the embedding
+// generators are fast and deterministic.
+//
+// Use a Transport as the transport of the HTTP clients under test:
+//
+//	rec := replay.Start(t, "testdata/add.json")
+//	client := chroma.NewClient(url, chroma.WithHTTPClient(rec.Client()))
+//	gen := openai.NewEmbeddingGenerator(token, openai.HTTPClient(rec.Client()))
+//
+// Requests are matched on method, path, query and body, with JSON bodies
+// compared after normalization so key order and whitespace don't matter.
+// Requests whose body or path is changed by Redact are matched on a hash of
+// the request as it was sent instead, so they still replay.
+package replay
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// ErrUnmatchedRequest is returned in strict mode for requests which aren't
+// in the cassette.
+var ErrUnmatchedRequest = errors.New("unmatched request")
+
+// Mode decides whether requests are sent or replayed.
+type Mode string
+
+const (
+	// ModeAuto replays if the cassette exists, otherwise it records.
+	ModeAuto Mode = "auto"
+	// ModeRecord sends every request and records a new cassette.
+	ModeRecord Mode = "record"
+	// ModeReplay replays the cassette, which must exist.
+	ModeReplay Mode = "replay"
+)
+
+// ModeEnv is the environment variable overriding the mode of every
+// Transport, for example to re-record all cassettes with REPLAY_MODE=record.
+const ModeEnv = "REPLAY_MODE"
+
+const redacted = "REDACTED"
+
+// DefaultRedactedHeaders are replaced before interactions are saved.
+var DefaultRedactedHeaders = []string{
+	"Authorization",
+	"Cookie",
+	"Set-Cookie",
+	"X-Api-Key",
+	"X-Chroma-Token",
+	"Openai-Organization",
+}
+
+type Config struct {
+	mode            Mode
+	strict          bool
+	transport       http.RoundTripper
+	redactedHeaders []string
+	redactedQuery   []string
+	redact          func(*Interaction)
+}
+
+type Opt func(c *Config)
+
+// WithMode sets the mode, ModeAuto by default. $REPLAY_MODE takes precedence.
+func WithMode(mode Mode) Opt {
+	return func(c *Config) {
+		c.mode = mode
+	}
+}
+
+// Strict fails requests which aren't in the cassette with ErrUnmatchedRequest
+// when replaying. Otherwise they are sent and added to the cassette.
+func Strict() Opt {
+	return func(c *Config) {
+		c.strict = true
+	}
+}
+
+// WithTransport sets the transport used to send requests,
+// http.DefaultTransport by default.
+func WithTransport(transport http.RoundTripper) Opt {
+	return func(c *Config) {
+		c.transport = transport
+	}
+}
+
+// RedactHeaders redacts headers in addition to DefaultRedactedHeaders.
+func RedactHeaders(headers ...string) Opt {
+	return func(c *Config) {
+		c.redactedHeaders = append(c.redactedHeaders, headers...)
+	}
+}
+
+// RedactQueryParams redacts query parameters, such as API keys passed in the URL.
+// Redacted parameters are ignored when matching requests.
+func RedactQueryParams(params ...string) Opt {
+	return func(c *Config) {
+		c.redactedQuery = append(c.redactedQuery, params...)
+	}
+}
+
+// Redact is called with every interaction before it is saved, to redact
+// anything else, such as secrets in bodies. If it changes what the request
+// is matched on, the interaction stores the SHA-256 of the unredacted match
+// key instead, so a low entropy secret could be guessed from the cassette.
+func Redact(redact func(*Interaction)) Opt {
+	return func(c *Config) {
+		c.redact = redact
+	}
+}
+
+// Transport is an http.RoundTripper recording or replaying a cassette.
+// It is safe for concurrent use.
+type Transport struct {
+	path string
+	conf *Config
+
+	lock     sync.Locker
+	cassette *Cassette
+	replayed map[*Interaction]bool
+	recorded bool
+}
+
+var _ http.RoundTripper = (*Transport)(nil)
+
+// New returns a Transport for the cassette at path. Recorded interactions
+// are written by Save.
+func New(path string, opts ...Opt) (*Transport, error) {
+	conf := &Config{
+		mode:            ModeAuto,
+		strict:          false,
+		transport:       http.DefaultTransport,
+		redactedHeaders: append([]string{}, DefaultRedactedHeaders...),
+		redactedQuery:   nil,
+		redact:          nil,
+	}
+	for _, opt := range opts {
+		opt(conf)
+	}
+	if mode := os.Getenv(ModeEnv); mode != "" {
+		conf.mode = Mode(mode)
+	}
+
+	cassette := &Cassette{Version: cassetteVersion, Interactions: make([]*Interaction, 0)}
+	switch conf.mode {
+	case ModeRecord:
+	case ModeReplay:
+		c, err := LoadCassette(path)
+		if err != nil {
+			return nil, err
+		}
+		cassette = c
+	case ModeAuto:
+		c, err := LoadCassette(path)
+		if err == nil {
+			conf.mode = ModeReplay
+			cassette = c
+		} else if errors.Is(err, os.ErrNotExist) {
+			conf.mode = ModeRecord
+		} else {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown mode %q", conf.mode)
+	}
+
+	return &Transport{
+		path:     path,
+		conf:     conf,
+		lock:     &sync.Mutex{},
+		cassette: cassette,
+		replayed: make(map[*Interaction]bool),
+		recorded: false,
+	}, nil
+}
+
+// Start returns a Transport for the cassette at path which is saved when the
+// test finishes, failing the test on errors.
+func Start(t testing.TB, path string, opts ...Opt) *Transport {
+	t.Helper()
+	tr, err := New(path, opts...)
+	if err != nil {
+		t.Fatalf("starting replay: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := tr.Save(); err != nil {
+			t.Errorf("saving cassette: %v", err)
+		}
+	})
+	return tr
+}
+
+// Client returns an HTTP client using the Transport.
+func (t *Transport) Client() *http.Client {
+	return &http.Client{Transport: t}
+}
+
+// Mode returns the mode in use, which is never ModeAuto.
+func (t *Transport) Mode() Mode {
+	return t.conf.mode
+}
+
+// Save writes the cassette if anything was recorded.
+func (t *Transport) Save() error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if !t.recorded {
+		return nil
+	}
+	if err := t.cassette.Save(t.path); err != nil {
+		return err
+	}
+	t.recorded = false
+	return nil
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	key, err := t.matchKey(req.Method, req.URL.Path, req.URL.Query(), body)
+	if err != nil {
+		return nil, err
+	}
+
+	if t.conf.mode == ModeReplay {
+		if in := t.match(key); in != nil {
+			return in.Response.httpResponse(req), nil
+		}
+		if t.conf.strict {
+			return nil, fmt.Errorf("%w: %s %s", ErrUnmatchedRequest, req.Method, req.URL.Path)
+		}
+	}
+
+	return t.record(req, body, key)
+}
+
+// match returns the first interaction matching key which hasn't been
+// replayed yet, or the last matching one if all have been replayed, so
+// repeated identical requests replay in recorded order.
+func (t *Transport) match(key string) *Interaction {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	hashed := hashKey(key)
+	var last *Interaction
+	for _, in := range t.cassette.Interactions {
+		if in.MatchKey != "" {
+			if in.MatchKey != hashed {
+				continue
+			}
+		} else if inKey, err := t.interactionKey(in); err != nil || inKey != key {
+			continue
+		}
+		if !t.replayed[in] {
+			t.replayed[in] = true
+			return in
+		}
+		last = in
+	}
+	return last
+}
+
+func (t *Transport) record(req *http.Request, body []byte, key string) (*http.Response, error) {
+	sent := req.Clone(req.Context())
+	if body != nil {
+		sent.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	res, err := t.conf.transport.RoundTrip(sent)
+	if err != nil {
+		return nil, err
+	}
+
+	resBody, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("reading response body: %w", err)
+	}
+	res.Body = io.NopCloser(bytes.NewReader(resBody))
+
+	in := &Interaction{
+		Request: Request{
+			Method: req.Method,
+			URL:    req.URL.String(),
+			Header: req.Header.Clone(),
+			Body:   body,
+		},
+		Response: Response{
+			StatusCode: res.StatusCode,
+			Header:     res.Header.Clone(),
+			Body:       resBody,
+		},
+	}
+	t.redactInteraction(in)
+	if redactedKey, err := t.interactionKey(in); err != nil || redactedKey != key {
+		in.MatchKey = hashKey(key)
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.cassette.Interactions = append(t.cassette.Interactions, in)
+	t.replayed[in] = true
+	t.recorded = true
+
+	return res, nil
+}
+
+func (t *Transport) redactInteraction(in *Interaction) {
+	for _, h := range t.conf.redactedHeaders {
+		if in.Request.Header.Get(h) != "" {
+			in.Request.Header.Set(h, redacted)
+		}
+		if in.Response.Header.Get(h) != "" {
+			in.Response.Header.Set(h, redacted)
+		}
+	}
+	in.Request.URL = redactURL(in.Request.URL, t.conf.redactedQuery)
+	if t.conf.redact != nil {
+		t.conf.redact(in)
+	}
+}
+
+func (t *Transport) interactionKey(in *Interaction) (string, error) {
+	return t.matchKey(in.Request.Method, urlPath(in.Request.URL), urlQuery(in.Request.URL), in.Request.Body)
+}
+
+func hashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// matchKey identifies requests which should get the same response.
+func (t *Transport) matchKey(method, path string, query url.Values, body []byte) (string, error) {
+	for _, p := range t.conf.redactedQuery {
+		delete(query, p)
+	}
+	normalized, err := normalizeBody(body)
+	if err != nil {
+		return "", err
+	}
+	q, err := json.Marshal(query)
+	if err != nil {
+		return "", fmt.Errorf("encoding query: %w", err)
+	}
+	return strings.Join([]string{method, path, string(q), string(normalized)}, "\n"), nil
+}
+
+// normalizeBody re-encodes JSON bodies, which sorts object keys and drops
+// whitespace. Other bodies are compared as they are.
+func normalizeBody(body []byte) ([]byte, error) {
+	if !json.Valid(body) {
+		return body, nil
+	}
+	var v interface{}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	// Keep numbers as written, float64 could round embeddings differently.
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return nil, fmt.Errorf("normalizing body: %w", err)
+	}
+	normalized, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("normalizing body: %w", err)
+	}
+	return normalized, nil
+}
+
+func readRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("reading request body: %w", err)
+	}
+	return body, nil
+}
+
+func (r Response) httpResponse(req *http.Request) *http.Response {
+	header := r.Header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", r.StatusCode, http.StatusText(r.StatusCode)),
+		StatusCode:    r.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(r.Body)),
+		ContentLength: int64(len(r.Body)),
+		Request:       req,
+	}
+}
+
+func urlPath(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	return u.Path
+}
+
+func urlQuery(rawURL string) url.Values {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return url.Values{}
+	}
+	return u.Query()
+}
+
+func redactURL(rawURL string, params []string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || len(params) == 0 {
+		return rawURL
+	}
+	query := u.Query()
+	for _, p := range params {
+		if query.Has(p) {
+			query.Set(p, redacted)
+		}
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
@@ -0,0 +1,280 @@
+package replay_test
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/kristofferostlund/chroma-go/chroma/chromatest/replay"
+)
+
+// echoServer responds with the method, path, query and body of each
+// request and a counter, so responses to repeated requests differ.
+func echoServer(t *testing.T) (*httptest.Server, *int64) {
+	t.Helper()
+	var hits int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt64(&hits, 1)
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Set-Cookie", "session=secret")
+		w.Header().Set("X-Hit", string(rune('0'+n)))
+		_, _ = io.WriteString(w, r.Method+" "+r.URL.Path+"?"+r.URL.RawQuery+" "+string(body))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &hits
+}
+
+// offline fails every request sent while replaying.
+var offline = roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+	return nil, errors.New("sent a request while replaying")
+})
+
+type roundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func do(t *testing.T, client *http.Client, method, url, body string, header http.Header) (*http.Response, string) {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("creating request: %v", err)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, url, err)
+	}
+	defer res.Body.Close()
+	b, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatalf("reading body: %v", err)
+	}
+	return res, string(b)
+}
+
+func newTransport(t *testing.T, path string, opts ...replay.Opt) *replay.Transport {
+	t.Helper()
+	tr, err := replay.New(path, opts...)
+	if err != nil {
+		t.Fatalf("creating transport: %v", err)
+	}
+	return tr
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	srv, hits := echoServer(t)
+	path := filepath.Join(t.TempDir(), "cassettes", "test.json")
+
+	rec := newTransport(t, path)
+	if got := rec.Mode(); got != replay.ModeRecord {
+		t.Fatalf("got mode %s without a cassette, want %s", got, replay.ModeRecord)
+	}
+	_, first := do(t, rec.Client(), http.MethodPost, srv.URL+"/api?x=1", `{"a": 1, "b": [1.10, 2]}`, nil)
+	_, second := do(t, rec.Client(), http.MethodPost, srv.URL+"/api?x=1", `{"a": 1, "b": [1.10, 2]}`, nil)
+	_, binary := do(t, rec.Client(), http.MethodPut, srv.URL+"/bin", "\xff\x00", nil)
+	if err := rec.Save(); err != nil {
+		t.Fatalf("saving: %v", err)
+	}
+	if got := atomic.LoadInt64(hits); got != 3 {
+		t.Fatalf("got %d requests to the server, want 3", got)
+	}
+
+	rep := newTransport(t, path, replay.WithTransport(offline), replay.Strict())
+	if got := rep.Mode(); got != replay.ModeReplay {
+		t.Fatalf("got mode %s with a cassette, want %s", got, replay.ModeReplay)
+	}
+	// Keys in a different order and other whitespace still match, and
+	// repeated requests replay in recorded order.
+	for i, want := range []string{first, second, second} {
+		res, got := do(t, rep.Client(), http.MethodPost, srv.URL+"/api?x=1", `{ "b": [1.10, 2], "a": 1 }`, nil)
+		if got != want {
+			t.Errorf("got body %q replaying request %d, want %q", got, i, want)
+		}
+		if res.StatusCode != http.StatusOK {
+			t.Errorf("got status %d, want 200", res.StatusCode)
+		}
+	}
+	if _, got := do(t, rep.Client(), http.MethodPut, srv.URL+"/bin", "\xff\x00", nil); got != binary {
+		t.Errorf("got binary body %q, want %q", got, binary)
+	}
+	if got := atomic.LoadInt64(hits); got != 3 {
+		t.Errorf("got %d requests to the server after replaying, want 3", got)
+	}
+
+	// Nothing new was recorded, so saving doesn't touch the cassette.
+	before, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading cassette: %v", err)
+	}
+	if err := os.Remove(path); err != nil {
+		t.Fatalf("removing cassette: %v", err)
+	}
+	if err := rep.Save(); err != nil {
+		t.Fatalf("saving: %v", err)
+	}
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("got cassette written after only replaying, error %v", err)
+	}
+	if len(before) == 0 {
+		t.Error("got an empty cassette")
+	}
+}
+
+func TestReplayUnmatched(t *testing.T) {
+	srv, hits := echoServer(t)
+	path := filepath.Join(t.TempDir(), "test.json")
+
+	rec := newTransport(t, path, replay.WithMode(replay.ModeRecord))
+	do(t, rec.Client(), http.MethodPost, srv.URL+"/api", `{"a": 1}`, nil)
+	if err := rec.Save(); err != nil {
+		t.Fatalf("saving: %v", err)
+	}
+
+	strict := newTransport(t, path, replay.WithMode(replay.ModeReplay), replay.WithTransport(offline), replay.Strict())
+	for _, tt := range []struct{ method, path, body string }{
+		{http.MethodPost, "/api", `{"a": 2}`},
+		{http.MethodPost, "/api?x=1", `{"a": 1}`},
+		{http.MethodPost, "/other", `{"a": 1}`},
+		{http.MethodGet, "/api", `{"a": 1}`},
+	} {
+		req, _ := http.NewRequest(tt.method, srv.URL+tt.path, strings.NewReader(tt.body))
+		if _, err := strict.Client().Do(req); !errors.Is(err, replay.ErrUnmatchedRequest) {
+			t.Errorf("got error %v for %s %s %s, want ErrUnmatchedRequest", err, tt.method, tt.path, tt.body)
+		}
+	}
+
+	// Without strict mode unmatched requests are sent and recorded.
+	lenient := newTransport(t, path, replay.WithMode(replay.ModeReplay))
+	if _, got := do(t, lenient.Client(), http.MethodPost, srv.URL+"/api", `{"a": 2}`, nil); got != `POST /api? {"a": 2}` {
+		t.Errorf("got body %q for an unmatched request", got)
+	}
+	if got := atomic.LoadInt64(hits); got != 2 {
+		t.Errorf("got %d requests to the server, want 2", got)
+	}
+	if err := lenient.Save(); err != nil {
+		t.Fatalf("saving: %v", err)
+	}
+	cassette, err := replay.LoadCassette(path)
+	if err != nil {
+		t.Fatalf("loading cassette: %v", err)
+	}
+	if got := len(cassette.Interactions); got != 2 {
+		t.Errorf("got %d interactions, want 2", got)
+	}
+}
+
+func TestRedaction(t *testing.T) {
+	srv, hits := echoServer(t)
+	path := filepath.Join(t.TempDir(), "test.json")
+	opts := []replay.Opt{
+		replay.RedactHeaders("X-Custom-Secret"),
+		replay.RedactQueryParams("api_key"),
+		replay.Redact(func(in *replay.Interaction) {
+			in.Request.Body = []byte(strings.ReplaceAll(string(in.Request.Body), "body-secret", "REDACTED"))
+			// The server echoes the request, secrets and all.
+			in.Response.Body = []byte(strings.NewReplacer("body-secret", "REDACTED", "query-secret", "REDACTED").Replace(string(in.Response.Body)))
+		}),
+	}
+	header := http.Header{
+		"Authorization":   {"Bearer header-secret"},
+		"X-Custom-Secret": {"custom-secret"},
+		"X-Kept":          {"kept"},
+	}
+	const body = `{"password": "body-secret"}`
+
+	rec := newTransport(t, path, append(opts, replay.WithMode(replay.ModeRecord))...)
+	do(t, rec.Client(), http.MethodPost, srv.URL+"/login?api_key=query-secret&user=me", body, header)
+	do(t, rec.Client(), http.MethodPost, srv.URL+"/plain", `{"a": 1}`, header)
+	if err := rec.Save(); err != nil {
+		t.Fatalf("saving: %v", err)
+	}
+
+	saved, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading cassette: %v", err)
+	}
+	for _, secret := range []string{"header-secret", "custom-secret", "query-secret", "body-secret", "session=secret"} {
+		if strings.Contains(string(saved), secret) {
+			t.Errorf("got %q in the cassette", secret)
+		}
+	}
+	for _, kept := range []string{"kept", "user=me"} {
+		if !strings.Contains(string(saved), kept) {
+			t.Errorf("got no %q in the cassette, want only secrets redacted", kept)
+		}
+	}
+	cassette, err := replay.LoadCassette(path)
+	if err != nil {
+		t.Fatalf("loading cassette: %v", err)
+	}
+	if cassette.Interactions[0].MatchKey == "" || cassette.Interactions[1].MatchKey != "" {
+		t.Errorf("got match keys %q and %q, want one only for the request with a redacted body",
+			cassette.Interactions[0].MatchKey, cassette.Interactions[1].MatchKey)
+	}
+
+	// The request with a redacted body and a different API key still matches.
+	rep := newTransport(t, path, append(opts, replay.WithMode(replay.ModeReplay), replay.WithTransport(offline), replay.Strict())...)
+	_, got := do(t, rep.Client(), http.MethodPost, srv.URL+"/login?api_key=other&user=me", body, header)
+	if want := `POST /login?api_key=REDACTED&user=me {"password": "REDACTED"}`; got != want {
+		t.Errorf("got body %q, want %q", got, want)
+	}
+	if _, got := do(t, rep.Client(), http.MethodPost, srv.URL+"/plain", `{"a":1}`, nil); !strings.HasPrefix(got, "POST /plain") {
+		t.Errorf("got body %q for the unredacted request", got)
+	}
+	req, _ := http.NewRequest(http.MethodPost, srv.URL+"/login?user=me", strings.NewReader(`{"password": "wrong"}`))
+	if _, err := rep.Client().Do(req); !errors.Is(err, replay.ErrUnmatchedRequest) {
+		t.Errorf("got error %v for a different body, want ErrUnmatchedRequest", err)
+	}
+	if got := atomic.LoadInt64(hits); got != 2 {
+		t.Errorf("got %d requests to the server, want 2", got)
+	}
+}
+
+func TestModeEnv(t *testing.T) {
+	srv, hits := echoServer(t)
+	path := filepath.Join(t.TempDir(), "test.json")
+
+	if _, err := replay.New(path, replay.WithMode(replay.ModeReplay)); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("got error %v replaying a missing cassette, want os.ErrNotExist", err)
+	}
+	rec := newTransport(t, path)
+	do(t, rec.Client(), http.MethodGet, srv.URL+"/", "", nil)
+	if err := rec.Save(); err != nil {
+		t.Fatalf("saving: %v", err)
+	}
+
+	t.Setenv(replay.ModeEnv, string(replay.ModeRecord))
+	forced := newTransport(t, path, replay.WithMode(replay.ModeReplay))
+	if got := forced.Mode(); got != replay.ModeRecord {
+		t.Errorf("got mode %s, want %s from $%s", got, replay.ModeRecord, replay.ModeEnv)
+	}
+	do(t, forced.Client(), http.MethodGet, srv.URL+"/", "", nil)
+	if got := atomic.LoadInt64(hits); got != 2 {
+		t.Errorf("got %d requests to the server, want the recorded one sent again", got)
+	}
+	if err := forced.Save(); err != nil {
+		t.Fatalf("saving: %v", err)
+	}
+	cassette, err := replay.LoadCassette(path)
+	if err != nil {
+		t.Fatalf("loading cassette: %v", err)
+	}
+	if got := len(cassette.Interactions); got != 1 {
+		t.Errorf("got %d interactions after re-recording, want 1", got)
+	}
+
+	t.Setenv(replay.ModeEnv, "sometimes")
+	if _, err := replay.New(path); err == nil {
+		t.Error("got no error for an unknown mode")
+	}
+}
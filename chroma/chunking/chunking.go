@@ -0,0 +1,271 @@
+// Package chunking splits long documents into chunks small enough to embed,
+// keeping track of where each chunk came from so that it can be traced back
+// to its parent document.
+package chunking
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/kristofferostlund/chroma-go/chroma"
+)
+
+// Metadata keys set on every chunk by Chunk.ChunkMetadata.
+const (
+	MetadataParentID   = chroma.MetadataParentID
+	MetadataChunkIndex = chroma.MetadataChunkIndex
+	MetadataStartByte  = "start_byte"
+	MetadataEndByte    = "end_byte"
+)
+
+// Span is a part of a text, as byte offsets into the text.
+type Span struct {
+	Start int
+	End   int
+	// Metadata is set by splitters which know more about the span, such as
+	// the markdown headers it falls under.
+	Metadata chroma.Metadata
+}
+
+// Splitter splits text into spans. Spans are ordered, may overlap and
+// never include leading or trailing whitespace.
+type Splitter interface {
+	Split(text string) []Span
+}
+
+// Chunk is a part of a parent document.
+type Chunk struct {
+	ID       chroma.ID
+	ParentID chroma.ID
+	Index    int
+	// Start and End are byte offsets into the parent document.
+	Start    int
+	End      int
+	Text     chroma.Document
+	Metadata chroma.Metadata
+}
+
+// ChunkID returns the deterministic ID of the index:th chunk of a document.
+func ChunkID(parentID chroma.ID, index int) chroma.ID {
+	return chroma.ChunkID(parentID, index)
+}
+
+// ChunkDocument splits text with splitter into chunks of the parent document,
+// each with a copy of metadata and the metadata set by the splitter.
+func ChunkDocument(splitter Splitter, parentID chroma.ID, text chroma.Document, metadata chroma.Metadata) Chunks {
+	spans := splitter.Split(text)
+	chunks := make(Chunks, 0, len(spans))
+	for i, span := range spans {
+		meta := make(chroma.Metadata, len(metadata)+len(span.Metadata))
+		for k, v := range metadata {
+			meta[k] = v
+		}
+		for k, v := range span.Metadata {
+			meta[k] = v
+		}
+
+		chunks = append(chunks, Chunk{
+			ID:       ChunkID(parentID, i),
+			ParentID: parentID,
+			Index:    i,
+			Start:    span.Start,
+			End:      span.End,
+			Text:     text[span.Start:span.End],
+			Metadata: meta,
+		})
+	}
+	return chunks
+}
+
+// ChunkFunc adapts splitter for use with Collection.IngestDocuments.
+func ChunkFunc(splitter Splitter) chroma.ChunkFunc {
+	return func(doc chroma.SourceDoc) []chroma.DocumentChunk {
+		chunks := ChunkDocument(splitter, doc.ID, doc.Text, doc.Metadata)
+		out := make([]chroma.DocumentChunk, 0, len(chunks))
+		for _, c := range chunks {
+			out = append(out, chroma.DocumentChunk{ID: c.ID, Text: c.Text, Metadata: c.ChunkMetadata()})
+		}
+		return out
+	}
+}
+
+// ChunkMetadata returns the chunk's metadata along with its lineage.
+func (c Chunk) ChunkMetadata() chroma.Metadata {
+	meta := make(chroma.Metadata, len(c.Metadata)+4)
+	for k, v := range c.Metadata {
+		meta[k] = v
+	}
+	meta[MetadataParentID] = c.ParentID
+	meta[MetadataChunkIndex] = c.Index
+	meta[MetadataStartByte] = c.Start
+	meta[MetadataEndByte] = c.End
+	return meta
+}
+
+type Chunks []Chunk
+
+// IDs returns the IDs of the chunks, ready for Collection.Add.
+func (cs Chunks) IDs() []chroma.ID {
+	ids := make([]chroma.ID, 0, len(cs))
+	for _, c := range cs {
+		ids = append(ids, c.ID)
+	}
+	return ids
+}
+
+// Documents returns the texts of the chunks, ready for Collection.Add.
+func (cs Chunks) Documents() []chroma.Document {
+	docs := make([]chroma.Document, 0, len(cs))
+	for _, c := range cs {
+		docs = append(docs, c.Text)
+	}
+	return docs
+}
+
+// Metadatas returns the metadata of the chunks, including lineage, ready for Collection.Add.
+func (cs Chunks) Metadatas() []chroma.Metadata {
+	metas := make([]chroma.Metadata, 0, len(cs))
+	for _, c := range cs {
+		metas = append(metas, c.ChunkMetadata())
+	}
+	return metas
+}
+
+// LengthFunc measures the length of a text.
+type LengthFunc func(text string) int
+
+// RuneCount measures text in runes.
+func RuneCount(text string) int {
+	return utf8.RuneCountInString(text)
+}
+
+type Config struct {
+	size       int
+	overlap    int
+	length     LengthFunc
+	separators []string
+	tokenizer  Tokenizer
+}
+
+type Opt func(c *Config)
+
+// Size sets the maximum length of a chunk, measured with the length func.
+func Size(size int) Opt {
+	return func(c *Config) {
+		c.size = size
+	}
+}
+
+// Overlap sets the maximum length shared between consecutive chunks.
+func Overlap(overlap int) Opt {
+	return func(c *Config) {
+		c.overlap = overlap
+	}
+}
+
+// Length sets how chunk length is measured, defaults to RuneCount, which
+// is also used if length is nil.
+func Length(length LengthFunc) Opt {
+	return func(c *Config) {
+		c.length = length
+	}
+}
+
+// Separators sets the separators tried in order by the recursive character
+// splitter, the empty string splitting between characters.
+func Separators(separators ...string) Opt {
+	return func(c *Config) {
+		c.separators = separators
+	}
+}
+
+// WithTokenizer sets the tokenizer used by the token splitter, defaults to
+// WordTokenizer, which is also used if tokenizer is nil.
+func WithTokenizer(tokenizer Tokenizer) Opt {
+	return func(c *Config) {
+		c.tokenizer = tokenizer
+	}
+}
+
+func configOf(opts []Opt) Config {
+	conf := Config{
+		size:       1000,
+		overlap:    200,
+		length:     RuneCount,
+		separators: []string{"\n\n", "\n", " ", ""},
+		tokenizer:  WordTokenizer{},
+	}
+	for _, opt := range opts {
+		opt(&conf)
+	}
+	if conf.length == nil {
+		conf.length = RuneCount
+	}
+	if conf.tokenizer == nil {
+		conf.tokenizer = WordTokenizer{}
+	}
+	if conf.size <= 0 {
+		conf.size = 1
+	}
+	if conf.overlap < 0 {
+		conf.overlap = 0
+	}
+	if conf.overlap >= conf.size {
+		conf.overlap = conf.size - 1
+	}
+	return conf
+}
+
+// piece is a contiguous part of a text with a known length.
+type piece struct {
+	start  int
+	end    int
+	length int
+}
+
+// merge packs consecutive pieces into spans of at most size, where each span
+// starts with up to overlap of the previous span's trailing pieces.
+func merge(text string, pieces []piece, size, overlap int) []Span {
+	spans := make([]Span, 0)
+	current := make([]piece, 0)
+	total := 0
+
+	emit := func() {
+		if len(current) == 0 {
+			return
+		}
+		if span, ok := trimmed(text, current[0].start, current[len(current)-1].end); ok {
+			// Overlapping pieces may produce the same span twice.
+			if n := len(spans); n == 0 || spans[n-1].Start != span.Start || spans[n-1].End != span.End {
+				spans = append(spans, span)
+			}
+		}
+	}
+
+	for _, p := range pieces {
+		if total+p.length > size && len(current) > 0 {
+			emit()
+			for len(current) > 0 && (total > overlap || total+p.length > size) {
+				total -= current[0].length
+				current = current[1:]
+			}
+		}
+		current = append(current, p)
+		total += p.length
+	}
+	emit()
+
+	return spans
+}
+
+// trimmed returns the span of text[start:end] without surrounding whitespace.
+func trimmed(text string, start, end int) (Span, bool) {
+	s := text[start:end]
+	left := len(s) - len(strings.TrimLeftFunc(s, unicode.IsSpace))
+	right := len(strings.TrimRightFunc(s, unicode.IsSpace))
+	if right <= left {
+		return Span{}, false
+	}
+	return Span{Start: start + left, End: start + right, Metadata: nil}, true
+}
@@ -0,0 +1,174 @@
+package chunking_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"unicode"
+
+	"github.com/kristofferostlund/chroma-go/chroma"
+	"github.com/kristofferostlund/chroma-go/chroma/chunking"
+)
+
+func texts(text string, spans []chunking.Span) []string {
+	out := make([]string, 0, len(spans))
+	for _, s := range spans {
+		out = append(out, text[s.Start:s.End])
+	}
+	return out
+}
+
+// checkSpans checks that spans are ordered, trimmed, no longer than size and
+// overlap each other by at most overlap.
+func checkSpans(t *testing.T, text string, spans []chunking.Span, size, overlap int) {
+	t.Helper()
+	for i, s := range spans {
+		chunk := text[s.Start:s.End]
+		if n := chunking.RuneCount(chunk); n > size {
+			t.Errorf("got chunk %q of length %d, want at most %d", chunk, n, size)
+		}
+		if strings.TrimFunc(chunk, unicode.IsSpace) != chunk {
+			t.Errorf("got chunk %q with surrounding whitespace", chunk)
+		}
+		if i == 0 {
+			continue
+		}
+		prev := spans[i-1]
+		if s.Start <= prev.Start || s.End <= prev.End {
+			t.Errorf("got chunk %d at %d-%d after %d-%d, want them ordered", i, s.Start, s.End, prev.Start, prev.End)
+		}
+		if s.Start < prev.End {
+			if shared := chunking.RuneCount(text[s.Start:prev.End]); shared > overlap {
+				t.Errorf("got chunks %d and %d sharing %d, want at most %d", i-1, i, shared, overlap)
+			}
+		}
+	}
+}
+
+func TestRecursiveCharacterSplitter(t *testing.T) {
+	text := "The quick brown fox jumps over the lazy dog.\n\nPack my box with five dozen liquor jugs.\nHow vexingly quick daft zebras jump!"
+	spans := chunking.NewRecursiveCharacterSplitter(chunking.Size(20), chunking.Overlap(8)).Split(text)
+	checkSpans(t, text, spans, 20, 8)
+
+	got := texts(text, spans)
+	if got[0] != "The quick brown fox" {
+		t.Errorf("got first chunk %q, want whole words", got[0])
+	}
+	overlapped := false
+	for i := 1; i < len(spans); i++ {
+		overlapped = overlapped || spans[i].Start < spans[i-1].End
+	}
+	if !overlapped {
+		t.Errorf("got no overlapping chunks %q", got)
+	}
+	joined := strings.Join(got, " ")
+	for _, word := range strings.Fields(text) {
+		if !strings.Contains(joined, word) {
+			t.Errorf("got %q missing from the chunks", word)
+		}
+	}
+
+	// Paragraphs fitting the size aren't split.
+	paragraphs := chunking.NewRecursiveCharacterSplitter(chunking.Size(50), chunking.Overlap(0)).Split(text)
+	if want := []string{"The quick brown fox jumps over the lazy dog.", "Pack my box with five dozen liquor jugs.", "How vexingly quick daft zebras jump!"}; !reflect.DeepEqual(texts(text, paragraphs), want) {
+		t.Errorf("got %q, want %q", texts(text, paragraphs), want)
+	}
+
+	// Without separators left, characters are split.
+	word := "abcdefghij"
+	if got, want := texts(word, chunking.NewRecursiveCharacterSplitter(chunking.Size(4), chunking.Overlap(1)).Split(word)), []string{"abcd", "defg", "ghij"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestTokenSplitter(t *testing.T) {
+	text := "one two, three four five six"
+	want := []string{"one two,", ", three four", "four five six"}
+	if got := texts(text, chunking.NewTokenSplitter(chunking.Size(3), chunking.Overlap(1)).Split(text)); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	// A nil tokenizer keeps the default.
+	if got := texts(text, chunking.NewTokenSplitter(chunking.Size(3), chunking.Overlap(1), chunking.WithTokenizer(nil)).Split(text)); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q with a nil tokenizer, want %q", got, want)
+	}
+	if got := chunking.NewTokenSplitter().Split("  "); len(got) != 0 {
+		t.Errorf("got %v for blank text, want no chunks", got)
+	}
+}
+
+func TestSentenceSplitter(t *testing.T) {
+	text := `He said "stop." Then left! Did he? Yes… A very long sentence which must be split.`
+	spans := chunking.NewSentenceSplitter(chunking.Size(20), chunking.Overlap(0)).Split(text)
+	checkSpans(t, text, spans, 20, 0)
+	// Whole sentences are packed together, only the long one is split.
+	want := []string{`He said "stop."`, "Then left! Did he?", "Yes… A very long", "sentence which must", "be split."}
+	if got := texts(text, spans); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// Decimals and blank lines.
+	text = "Pi is 3.14 roughly\n\nNext paragraph"
+	want = []string{"Pi is 3.14 roughly", "Next paragraph"}
+	if got := texts(text, chunking.NewSentenceSplitter(chunking.Size(20), chunking.Overlap(0)).Split(text)); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestMarkdownSplitter(t *testing.T) {
+	text := "intro\n# A\nabout a\n## B\nabout b\n```\n# not a header\n```\n# C #\nabout c and more words\n"
+	spans := chunking.NewMarkdownSplitter(chunking.Size(20), chunking.Overlap(0)).Split(text)
+	checkSpans(t, text, spans, 20, 0)
+
+	type chunk struct {
+		text     string
+		metadata chroma.Metadata
+	}
+	want := []chunk{
+		{"intro", chroma.Metadata{}},
+		{"# A\nabout a", chroma.Metadata{"h1": "A"}},
+		{"## B\nabout b\n```", chroma.Metadata{"h1": "A", "h2": "B"}},
+		{"# not a header\n```", chroma.Metadata{"h1": "A", "h2": "B"}},
+		{"# C #\nabout c and", chroma.Metadata{"h1": "C"}},
+		{"more words", chroma.Metadata{"h1": "C"}},
+	}
+	got := make([]chunk, 0, len(spans))
+	for _, s := range spans {
+		got = append(got, chunk{text[s.Start:s.End], s.Metadata})
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestChunkDocument(t *testing.T) {
+	text := "# Title\nfirst part\n# Other\nsecond part"
+	metadata := chroma.Metadata{"source": "a.md", "h1": "overridden"}
+	chunks := chunking.ChunkDocument(chunking.NewMarkdownSplitter(), "doc", text, metadata)
+
+	if got, want := chunks.IDs(), []chroma.ID{chunking.ChunkID("doc", 0), chunking.ChunkID("doc", 1)}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got IDs %v, want %v", got, want)
+	}
+	if got, want := chunks.Documents(), []chroma.Document{"# Title\nfirst part", "# Other\nsecond part"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got documents %q, want %q", got, want)
+	}
+	for i, c := range chunks {
+		if text[c.Start:c.End] != c.Text {
+			t.Errorf("got chunk %d at %d-%d, not its text %q", i, c.Start, c.End, c.Text)
+		}
+	}
+	want := []chroma.Metadata{
+		{"source": "a.md", "h1": "Title", chunking.MetadataParentID: "doc", chunking.MetadataChunkIndex: 0, chunking.MetadataStartByte: 0, chunking.MetadataEndByte: 18},
+		{"source": "a.md", "h1": "Other", chunking.MetadataParentID: "doc", chunking.MetadataChunkIndex: 1, chunking.MetadataStartByte: 19, chunking.MetadataEndByte: 38},
+	}
+	if got := chunks.Metadatas(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got metadata %v, want %v", got, want)
+	}
+	if metadata["h1"] != "overridden" || len(metadata) != 2 {
+		t.Errorf("got the parent metadata modified to %v", metadata)
+	}
+
+	out := chunking.ChunkFunc(chunking.NewMarkdownSplitter())(chroma.SourceDoc{ID: "doc", Text: text, Metadata: metadata})
+	if len(out) != 2 || out[1].ID != chunks[1].ID || out[1].Text != chunks[1].Text || !reflect.DeepEqual(out[1].Metadata, want[1]) {
+		t.Errorf("got %+v from ChunkFunc, want the chunks", out)
+	}
+}
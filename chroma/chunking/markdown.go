@@ -0,0 +1,115 @@
+package chunking
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/kristofferostlund/chroma-go/chroma"
+)
+
+var _ Splitter = (*MarkdownSplitter)(nil)
+
+// MarkdownSplitter never lets a chunk cross a markdown header. Each chunk
+// gets the headers it falls under as metadata, keyed h1 through h6, and
+// sections longer than the chunk size are split further.
+type MarkdownSplitter struct {
+	conf     Config
+	fallback *RecursiveCharacterSplitter
+}
+
+func NewMarkdownSplitter(opts ...Opt) *MarkdownSplitter {
+	conf := configOf(opts)
+	return &MarkdownSplitter{conf: conf, fallback: &RecursiveCharacterSplitter{conf: conf}}
+}
+
+func (s *MarkdownSplitter) Split(text string) []Span {
+	spans := make([]Span, 0)
+	for _, section := range markdownSections(text) {
+		pieces := s.fallback.pieces(text, section.start, section.end, s.conf.separators)
+		for _, span := range merge(text, pieces, s.conf.size, s.conf.overlap) {
+			span.Metadata = section.headers
+			spans = append(spans, span)
+		}
+	}
+	return spans
+}
+
+type markdownSection struct {
+	start   int
+	end     int
+	headers chroma.Metadata
+}
+
+// markdownSections splits text into sections starting at each ATX header,
+// ignoring header-like lines in fenced code blocks.
+func markdownSections(text string) []markdownSection {
+	sections := make([]markdownSection, 0)
+	headers := [6]string{}
+	current := markdownSection{start: 0, end: 0, headers: chroma.Metadata{}}
+	inFence := false
+
+	for offset := 0; offset < len(text); {
+		lineEnd := strings.IndexByte(text[offset:], '\n')
+		if lineEnd < 0 {
+			lineEnd = len(text)
+		} else {
+			lineEnd += offset + 1
+		}
+		line := strings.TrimRight(text[offset:lineEnd], "\r\n")
+
+		trimmedLine := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmedLine, "```") || strings.HasPrefix(trimmedLine, "~~~") {
+			inFence = !inFence
+		}
+
+		if level, title, ok := markdownHeader(line); ok && !inFence {
+			if offset > current.start {
+				current.end = offset
+				sections = append(sections, current)
+			}
+
+			headers[level-1] = title
+			for i := level; i < len(headers); i++ {
+				headers[i] = ""
+			}
+			meta := chroma.Metadata{}
+			for i, h := range headers {
+				if h != "" {
+					meta[fmt.Sprintf("h%d", i+1)] = h
+				}
+			}
+			current = markdownSection{start: offset, end: 0, headers: meta}
+		}
+
+		offset = lineEnd
+	}
+
+	if len(text) > current.start {
+		current.end = len(text)
+		sections = append(sections, current)
+	}
+	return sections
+}
+
+func markdownHeader(line string) (int, string, bool) {
+	// Up to three spaces of indentation are allowed.
+	trimmedLine := strings.TrimLeft(line, " ")
+	if len(line)-len(trimmedLine) > 3 {
+		return 0, "", false
+	}
+
+	level := 0
+	for level < len(trimmedLine) && trimmedLine[level] == '#' {
+		level++
+	}
+	if level == 0 || level > 6 {
+		return 0, "", false
+	}
+	if level < len(trimmedLine) && trimmedLine[level] != ' ' && trimmedLine[level] != '\t' {
+		return 0, "", false
+	}
+
+	title := strings.TrimSpace(trimmedLine[level:])
+	title = strings.TrimSpace(strings.TrimRight(title, "#"))
+	return level, title, true
+}
@@ -0,0 +1,80 @@
+package chunking
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+var _ Splitter = (*RecursiveCharacterSplitter)(nil)
+
+// RecursiveCharacterSplitter splits text on the first separator which occurs
+// in it, recursing with the following separators into parts which are still
+// too long. With the default separators, it splits on paragraphs, then lines,
+// then words and finally between characters.
+type RecursiveCharacterSplitter struct {
+	conf Config
+}
+
+func NewRecursiveCharacterSplitter(opts ...Opt) *RecursiveCharacterSplitter {
+	return &RecursiveCharacterSplitter{conf: configOf(opts)}
+}
+
+func (s *RecursiveCharacterSplitter) Split(text string) []Span {
+	return merge(text, s.pieces(text, 0, len(text), s.conf.separators), s.conf.size, s.conf.overlap)
+}
+
+// pieces splits text[start:end] into pieces no longer than size, where possible.
+func (s *RecursiveCharacterSplitter) pieces(text string, start, end int, separators []string) []piece {
+	sep, rest := "", []string(nil)
+	for i, candidate := range separators {
+		if candidate == "" || strings.Contains(text[start:end], candidate) {
+			sep, rest = candidate, separators[i+1:]
+			break
+		}
+	}
+
+	pieces := make([]piece, 0)
+	for _, part := range splitKeepingSeparator(text, start, end, sep) {
+		length := s.conf.length(text[part.start:part.end])
+		if length <= s.conf.size || sep == "" {
+			pieces = append(pieces, piece{part.start, part.end, length})
+			continue
+		}
+		if len(rest) == 0 {
+			// Nothing left to split on, keep the part even though it's too long.
+			pieces = append(pieces, piece{part.start, part.end, length})
+			continue
+		}
+		pieces = append(pieces, s.pieces(text, part.start, part.end, rest)...)
+	}
+	return pieces
+}
+
+// splitKeepingSeparator splits text[start:end] after each occurrence of sep,
+// so that the parts are contiguous. An empty sep splits between runes.
+func splitKeepingSeparator(text string, start, end int, sep string) []piece {
+	parts := make([]piece, 0)
+	if sep == "" {
+		for i := start; i < end; {
+			_, size := utf8.DecodeRuneInString(text[i:end])
+			parts = append(parts, piece{i, i + size, 0})
+			i += size
+		}
+		return parts
+	}
+
+	from := start
+	for from < end {
+		idx := strings.Index(text[from:end], sep)
+		if idx < 0 {
+			break
+		}
+		to := from + idx + len(sep)
+		parts = append(parts, piece{from, to, 0})
+		from = to
+	}
+	if from < end {
+		parts = append(parts, piece{from, end, 0})
+	}
+	return parts
+}
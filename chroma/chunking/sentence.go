@@ -0,0 +1,80 @@
+package chunking
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+var _ Splitter = (*SentenceSplitter)(nil)
+
+// SentenceSplitter packs whole sentences into chunks, only splitting a
+// sentence if it alone is longer than the chunk size.
+type SentenceSplitter struct {
+	conf     Config
+	fallback *RecursiveCharacterSplitter
+}
+
+func NewSentenceSplitter(opts ...Opt) *SentenceSplitter {
+	conf := configOf(opts)
+	return &SentenceSplitter{conf: conf, fallback: &RecursiveCharacterSplitter{conf: conf}}
+}
+
+func (s *SentenceSplitter) Split(text string) []Span {
+	pieces := make([]piece, 0)
+	for _, sentence := range sentences(text) {
+		length := s.conf.length(text[sentence.start:sentence.end])
+		if length <= s.conf.size {
+			pieces = append(pieces, piece{sentence.start, sentence.end, length})
+			continue
+		}
+		pieces = append(pieces, s.fallback.pieces(text, sentence.start, sentence.end, s.conf.separators)...)
+	}
+	return merge(text, pieces, s.conf.size, s.conf.overlap)
+}
+
+// sentences splits text into contiguous sentences, ending each after
+// terminal punctuation followed by whitespace, or after a blank line.
+func sentences(text string) []piece {
+	parts := make([]piece, 0)
+	start := 0
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		i += size
+
+		end := false
+		switch {
+		case r == '.' || r == '!' || r == '?' || r == '…' || r == '。':
+			// Include closing quotes and brackets in the sentence.
+			for i < len(text) {
+				next, nextSize := utf8.DecodeRuneInString(text[i:])
+				if next != '"' && next != '\'' && next != ')' && next != ']' && next != '”' && next != '’' {
+					break
+				}
+				i += nextSize
+			}
+			next, _ := utf8.DecodeRuneInString(text[i:])
+			end = i >= len(text) || unicode.IsSpace(next)
+		case r == '\n':
+			next, _ := utf8.DecodeRuneInString(text[i:])
+			end = next == '\n'
+		}
+		if !end {
+			continue
+		}
+
+		// Keep the trailing whitespace with the sentence so pieces are contiguous.
+		for i < len(text) {
+			next, nextSize := utf8.DecodeRuneInString(text[i:])
+			if !unicode.IsSpace(next) {
+				break
+			}
+			i += nextSize
+		}
+		parts = append(parts, piece{start, i, 0})
+		start = i
+	}
+	if start < len(text) {
+		parts = append(parts, piece{start, len(text), 0})
+	}
+	return parts
+}
@@ -0,0 +1,67 @@
+package chunking
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+var _ Splitter = (*TokenSplitter)(nil)
+
+// Tokenizer splits text into tokens, as byte offsets into the text.
+type Tokenizer interface {
+	Tokenize(text string) []Span
+}
+
+// WordTokenizer approximates model tokenizers by treating runs of letters
+// and digits as one token and every other non-space rune as its own token.
+// Use a model's own tokenizer for exact counts.
+type WordTokenizer struct{}
+
+func (WordTokenizer) Tokenize(text string) []Span {
+	tokens := make([]Span, 0)
+	inWord := false
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		isWord := unicode.IsLetter(r) || unicode.IsDigit(r)
+		switch {
+		case isWord && inWord:
+			tokens[len(tokens)-1].End = i + size
+		case isWord:
+			tokens = append(tokens, Span{Start: i, End: i + size, Metadata: nil})
+		case !unicode.IsSpace(r):
+			tokens = append(tokens, Span{Start: i, End: i + size, Metadata: nil})
+		}
+		inWord = isWord
+		i += size
+	}
+	return tokens
+}
+
+// TokenSplitter splits text into windows of at most size tokens, where
+// consecutive windows share overlap tokens.
+type TokenSplitter struct {
+	conf Config
+}
+
+func NewTokenSplitter(opts ...Opt) *TokenSplitter {
+	return &TokenSplitter{conf: configOf(opts)}
+}
+
+func (s *TokenSplitter) Split(text string) []Span {
+	tokens := s.conf.tokenizer.Tokenize(text)
+	spans := make([]Span, 0)
+	stride := s.conf.size - s.conf.overlap
+	for start := 0; start < len(tokens); start += stride {
+		end := start + s.conf.size
+		if end > len(tokens) {
+			end = len(tokens)
+		}
+		if span, ok := trimmed(text, tokens[start].Start, tokens[end-1].End); ok {
+			spans = append(spans, span)
+		}
+		if end == len(tokens) {
+			break
+		}
+	}
+	return spans
+}
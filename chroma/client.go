@@ -5,10 +5,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"time"
 
 	"github.com/kristofferostlund/chroma-go/chroma/chromaclient"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type SimpleCollection struct {
@@ -22,29 +25,120 @@ type (
 	ID        = string
 	Document  = string
 	Metadata  = map[string]interface{}
+	Where     = map[string]interface{}
 )
 
 type EmbeddingGenerator interface {
 	Generate(ctx context.Context, documents []Document) ([]Embedding, error)
 }
 
+// EmbeddingPurpose describes what generated embeddings will be used for.
+type EmbeddingPurpose string
+
+const (
+	// EmbeddingPurposeDocument is used for documents which will be stored in a collection.
+	EmbeddingPurposeDocument EmbeddingPurpose = "document"
+	// EmbeddingPurposeQuery is used for queries which will be searched for in a collection.
+	EmbeddingPurposeQuery EmbeddingPurpose = "query"
+)
+
+// PurposeEmbeddingGenerator is an optional extension of EmbeddingGenerator
+// for models which embed documents and queries differently, such as Cohere's
+// input_type. Generate must behave like GenerateFor with EmbeddingPurposeDocument.
+type PurposeEmbeddingGenerator interface {
+	EmbeddingGenerator
+	GenerateFor(ctx context.Context, purpose EmbeddingPurpose, documents []Document) ([]Embedding, error)
+}
+
+// DimensionReporter is optionally implemented by embedding generators which
+// know the dimension of their embeddings up front. Zero means unknown.
+type DimensionReporter interface {
+	Dimension() int
+}
+
+// ModelReporter is optionally implemented by embedding generators which can
+// identify the model producing their embeddings. Embeddings from different
+// models are not comparable, even if they have the same dimension.
+// An empty string means unknown.
+type ModelReporter interface {
+	Model() string
+}
+
+// EmbeddingDimension returns the dimension reported by gen, or 0 if unknown.
+func EmbeddingDimension(gen EmbeddingGenerator) int {
+	if dr, ok := gen.(DimensionReporter); ok {
+		return dr.Dimension()
+	}
+	return 0
+}
+
+// EmbeddingModel returns the model reported by gen, or "" if unknown.
+func EmbeddingModel(gen EmbeddingGenerator) string {
+	if mr, ok := gen.(ModelReporter); ok {
+		return mr.Model()
+	}
+	return ""
+}
+
+// GenerateEmbeddings generates embeddings for the given purpose if the generator
+// supports it, otherwise it falls back to Generate.
+func GenerateEmbeddings(ctx context.Context, gen EmbeddingGenerator, purpose EmbeddingPurpose, documents []Document) ([]Embedding, error) {
+	if pg, ok := gen.(PurposeEmbeddingGenerator); ok {
+		return pg.GenerateFor(ctx, purpose, documents)
+	}
+	return gen.Generate(ctx, documents)
+}
+
 type Client struct {
-	api chromaclient.ClientInterface
+	api       chromaclient.ClientInterface
+	telemetry *telemetry
+}
+
+type clientOpts struct {
+	httpClient     Doer
+	middlewares    []Middleware
+	tracerProvider trace.TracerProvider
+	meterProvider  metric.MeterProvider
+	logger         *slog.Logger
 }
 
-func NewClient(path string) *Client {
+type ClientOpts func(*clientOpts)
+
+// WithHTTPClient sets the HTTP client used to talk to the server,
+// http.DefaultClient by default.
+func WithHTTPClient(httpClient Doer) ClientOpts {
+	return func(c *clientOpts) {
+		c.httpClient = httpClient
+	}
+}
+
+func NewClient(path string, opts ...ClientOpts) *Client {
 	if path == "" {
 		path = "http://localhost:8000"
 	}
-	api, err := chromaclient.NewClient(path)
+	cOpts := &clientOpts{httpClient: http.DefaultClient, middlewares: nil, tracerProvider: nil, meterProvider: nil, logger: nil}
+	for _, opt := range opts {
+		opt(cOpts)
+	}
+
+	tel, err := newTelemetry(cOpts.tracerProvider, cOpts.meterProvider, cOpts.logger)
+	if err != nil {
+		panic(fmt.Errorf("creating client: %w", err))
+	}
+	// Logging and tracing come last, to see every attempt of retried requests.
+	doer := chain(requestLogger{next: statusRecorder{cOpts.httpClient}, logger: nil}, cOpts.middlewares...)
+	api, err := chromaclient.NewClient(path, chromaclient.WithHTTPClient(doer))
 	if err != nil {
 		panic(fmt.Errorf("creating client: %w", err))
 	}
 
-	return &Client{api}
+	return &Client{api: api, telemetry: tel}
 }
 
-func (c *Client) Reset(ctx context.Context) error {
+func (c *Client) Reset(ctx context.Context) (err error) {
+	ctx, op := c.telemetry.start(ctx, "Client.Reset")
+	defer func() { op.end(err) }()
+
 	if _, err := handleResponse(c.api.Reset(ctx)); err != nil {
 		return fmt.Errorf("resetting: %w", err)
 	}
@@ -52,7 +146,10 @@ func (c *Client) Reset(ctx context.Context) error {
 	return nil
 }
 
-func (c *Client) Version(ctx context.Context) (string, error) {
+func (c *Client) Version(ctx context.Context) (_ string, err error) {
+	ctx, op := c.telemetry.start(ctx, "Client.Version")
+	defer func() { op.end(err) }()
+
 	h, err := handleResponse(c.api.Version(ctx))
 	if err != nil {
 		return "", fmt.Errorf("getting version: %w", err)
@@ -66,7 +163,10 @@ func (c *Client) Version(ctx context.Context) (string, error) {
 	return version, nil
 }
 
-func (c *Client) Heartbeat(ctx context.Context) (time.Time, error) {
+func (c *Client) Heartbeat(ctx context.Context) (_ time.Time, err error) {
+	ctx, op := c.telemetry.start(ctx, "Client.Heartbeat")
+	defer func() { op.end(err) }()
+
 	h, err := handleResponse(c.api.Heartbeat(ctx))
 	if err != nil {
 		return time.Time{}, fmt.Errorf("sending heartbeat: %w", err)
@@ -83,9 +183,12 @@ func (c *Client) Heartbeat(ctx context.Context) (time.Time, error) {
 }
 
 type collectionOpts struct {
-	createOrGet   bool
-	metadata      Metadata
-	embeddingFunc EmbeddingGenerator
+	createOrGet        bool
+	metadata           Metadata
+	embeddingFunc      EmbeddingGenerator
+	queryEmbeddingFunc EmbeddingGenerator
+	idGenerator        IDGenerator
+	dedup              DedupMode
 }
 
 type CollectionOpts func(*collectionOpts)
@@ -102,6 +205,30 @@ func WithEmbeddingFunc(embeddingFunc EmbeddingGenerator) CollectionOpts {
 	}
 }
 
+// WithQueryEmbeddingFunc sets the generator used to embed query texts, for
+// models which need queries embedded differently from documents, such as
+// instruction-prefixed E5 or BGE models. Defaults to the embedding func.
+func WithQueryEmbeddingFunc(embeddingFunc EmbeddingGenerator) CollectionOpts {
+	return func(c *collectionOpts) {
+		c.queryEmbeddingFunc = embeddingFunc
+	}
+}
+
+// WithIDGenerator sets how IDs are generated for documents added or upserted
+// with empty IDs, such as UUIDv4IDs, ULIDIDs or ContentHashIDs.
+func WithIDGenerator(idGenerator IDGenerator) CollectionOpts {
+	return func(c *collectionOpts) {
+		c.idGenerator = idGenerator
+	}
+}
+
+// WithDedup sets how duplicate documents are handled by Add and Upsert.
+func WithDedup(mode DedupMode) CollectionOpts {
+	return func(c *collectionOpts) {
+		c.dedup = mode
+	}
+}
+
 func (c *Client) CreateCollection(ctx context.Context, name string, opts ...CollectionOpts) (*Collection, error) {
 	collOpts := collOptsOf(opts)
 	// This is the explicit create function, we want to fail if the collection already exists.
@@ -117,7 +244,10 @@ func (c *Client) GetOrCreateCollection(ctx context.Context, name string, opts ..
 	return c.createOrGetCollection(ctx, name, collOpts)
 }
 
-func (c *Client) GetCollection(ctx context.Context, name string, opts ...CollectionOpts) (*Collection, error) {
+func (c *Client) GetCollection(ctx context.Context, name string, opts ...CollectionOpts) (_ *Collection, err error) {
+	ctx, op := c.telemetry.start(ctx, "Client.GetCollection", AttributeCollectionName.String(name))
+	defer func() { op.end(err) }()
+
 	collOpts := collOptsOf(opts)
 	if len(collOpts.metadata) > 0 {
 		return nil, fmt.Errorf("cannot set metadata when getting collection, use GetOrCreateCollection to update the metadata")
@@ -136,7 +266,10 @@ func (c *Client) GetCollection(ctx context.Context, name string, opts ...Collect
 	return c.collectionOf(simpleColl, collOpts), nil
 }
 
-func (c *Client) ListCollections(ctx context.Context) ([]SimpleCollection, error) {
+func (c *Client) ListCollections(ctx context.Context) (_ []SimpleCollection, err error) {
+	ctx, op := c.telemetry.start(ctx, "Client.ListCollections")
+	defer func() { op.end(err) }()
+
 	r, err := handleResponse(c.api.ListCollections(ctx))
 	if err != nil {
 		return nil, fmt.Errorf("listing collections: %w", err)
@@ -147,17 +280,28 @@ func (c *Client) ListCollections(ctx context.Context) ([]SimpleCollection, error
 		return nil, fmt.Errorf("decoding JSON: %w", err)
 	}
 
+	op.setResultCount(len(collections))
 	return collections, nil
 }
 
-func (c *Client) DeleteCollection(ctx context.Context, name string) error {
+func (c *Client) DeleteCollection(ctx context.Context, name string) (err error) {
+	ctx, op := c.telemetry.start(ctx, "Client.DeleteCollection", AttributeCollectionName.String(name))
+	defer func() { op.end(err) }()
+
 	if _, err := handleResponse(c.api.DeleteCollection(ctx, name)); err != nil {
 		return fmt.Errorf("deleting collection: %w", err)
 	}
 	return nil
 }
 
-func (c *Client) createOrGetCollection(ctx context.Context, name string, collOpts *collectionOpts) (*Collection, error) {
+func (c *Client) createOrGetCollection(ctx context.Context, name string, collOpts *collectionOpts) (_ *Collection, err error) {
+	opName := "Client.CreateCollection"
+	if collOpts.createOrGet {
+		opName = "Client.GetOrCreateCollection"
+	}
+	ctx, op := c.telemetry.start(ctx, opName, AttributeCollectionName.String(name))
+	defer func() { op.end(err) }()
+
 	body := chromaclient.CreateCollection{
 		Name:        name,
 		Metadata:    &collOpts.metadata,
@@ -183,14 +327,22 @@ func (c *Client) collectionOf(simpleColl SimpleCollection, collOpts *collectionO
 		Name:     simpleColl.Name,
 		Metadata: simpleColl.Metadata,
 
-		api:          c.api,
-		embeddingGen: nil,
+		api:               c.api,
+		client:            c,
+		embeddingGen:      nil,
+		queryEmbeddingGen: nil,
+		idGen:             collOpts.idGenerator,
+		dedup:             collOpts.dedup,
 	}
 
 	// embeddingGen is optional.
 	if collOpts.embeddingFunc != nil {
 		coll.embeddingGen = collOpts.embeddingFunc
 	}
+	// queryEmbeddingGen is optional, falling back to embeddingGen.
+	if collOpts.queryEmbeddingFunc != nil {
+		coll.queryEmbeddingGen = collOpts.queryEmbeddingFunc
+	}
 	return coll
 }
 
@@ -221,6 +373,7 @@ func handleResponse(res *http.Response, err error) (*requestWrapper, error) {
 func (h *requestWrapper) decodeJSON(out interface{}) error {
 	defer h.res.Body.Close()
 	if err := json.NewDecoder(h.res.Body).Decode(&out); err != nil {
+		responseLogger(h.res).Error("decoding chroma response", "status", h.res.StatusCode, "error", err)
 		return fmt.Errorf("decoding response: %w", err)
 	}
 	return nil
@@ -234,9 +387,10 @@ func tryWrapErrorFromBody(res *http.Response, originalErr error) error {
 	defer res.Body.Close()
 	b, err := io.ReadAll(res.Body)
 	if err != nil {
-		// Ignore err, maybe consider logging?
+		responseLogger(res).Warn("reading chroma error response", "status", res.StatusCode, "error", err)
 		return originalErr
 	}
+	responseLogger(res).Debug("chroma error response", "status", res.StatusCode, "body", redactedBody(b))
 
 	return fmt.Errorf("%w: response: %s", originalErr, string(b))
 }
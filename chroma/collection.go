@@ -1,7 +1,9 @@
 package chroma
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 
@@ -22,12 +24,31 @@ type Collection struct {
 	Name     string
 	Metadata Metadata
 
-	api          chromaclient.ClientInterface
-	embeddingGen EmbeddingGenerator
+	api               chromaclient.ClientInterface
+	client            *Client
+	embeddingGen      EmbeddingGenerator
+	queryEmbeddingGen EmbeddingGenerator
+	idGen             IDGenerator
+	dedup             DedupMode
 }
 
-func (c *Collection) Add(ctx context.Context, ids []ID, embeddings []Embedding, metadatas []Metadata, documents []Document) (bool, error) {
-	b, err := c.validatedSetEmbeddingRequest(ctx, ids, embeddings, metadatas, documents)
+// Add adds embeddings, generating them from documents if not given. Empty
+// IDs are generated with the collection's ID generator, and duplicates are
+// dropped according to its dedup mode.
+func (c *Collection) Add(ctx context.Context, ids []ID, embeddings []Embedding, metadatas []Metadata, documents []Document) (_ bool, err error) {
+	ctx, op := c.client.telemetry.start(ctx, "Collection.Add", AttributeCollectionName.String(c.Name), AttributeBatchSize.Int(len(ids)))
+	defer func() { op.end(err) }()
+
+	w, err := c.prepareWrite(ctx, writeBatch{ids, embeddings, metadatas, documents}, true)
+	if err != nil {
+		return false, fmt.Errorf("preparing: %w", err)
+	}
+	if len(w.ids) == 0 {
+		// Everything was a duplicate.
+		return true, nil
+	}
+
+	b, err := c.validatedSetEmbeddingRequest(ctx, w.ids, w.embeddings, w.metadatas, w.documents)
 	if err != nil {
 		return false, fmt.Errorf("validating: %w", err)
 	}
@@ -65,8 +86,23 @@ func (c *Collection) AddOne(ctx context.Context, id ID, embedding Embedding, met
 	return c.Add(ctx, ids, embeddings, metadatas, documents)
 }
 
-func (c *Collection) Upsert(ctx context.Context, ids []ID, embeddings []Embedding, metadatas []Metadata, documents []Document) (bool, error) {
-	b, err := c.validatedSetEmbeddingRequest(ctx, ids, embeddings, metadatas, documents)
+// Upsert adds or updates embeddings, generating them from documents if not
+// given. IDs and duplicates in the batch are handled like in Add, but with
+// DedupExisting existing IDs are written through so changes are stored.
+func (c *Collection) Upsert(ctx context.Context, ids []ID, embeddings []Embedding, metadatas []Metadata, documents []Document) (_ bool, err error) {
+	ctx, op := c.client.telemetry.start(ctx, "Collection.Upsert", AttributeCollectionName.String(c.Name), AttributeBatchSize.Int(len(ids)))
+	defer func() { op.end(err) }()
+
+	w, err := c.prepareWrite(ctx, writeBatch{ids, embeddings, metadatas, documents}, false)
+	if err != nil {
+		return false, fmt.Errorf("preparing: %w", err)
+	}
+	if len(w.ids) == 0 {
+		// Everything was a duplicate.
+		return true, nil
+	}
+
+	b, err := c.validatedSetEmbeddingRequest(ctx, w.ids, w.embeddings, w.metadatas, w.documents)
 	if err != nil {
 		return false, fmt.Errorf("validating: %w", err)
 	}
@@ -104,9 +140,16 @@ func (c *Collection) UpsertOne(ctx context.Context, id ID, embedding Embedding,
 	return c.Upsert(ctx, ids, embeddings, metadatas, documents)
 }
 
-func (c *Collection) Update(ctx context.Context, ids []ID, embeddings []Embedding, metadatas []Metadata, documents []Document) (bool, error) {
-	b, err := c.validatedSetEmbeddingRequest(ctx, ids, embeddings, metadatas, documents)
-	if err != nil {
+// Update updates existing embeddings, generating them from documents if not
+// given. If only metadatas are given, only the metadata is updated.
+func (c *Collection) Update(ctx context.Context, ids []ID, embeddings []Embedding, metadatas []Metadata, documents []Document) (_ bool, err error) {
+	ctx, op := c.client.telemetry.start(ctx, "Collection.Update", AttributeCollectionName.String(c.Name), AttributeBatchSize.Int(len(ids)))
+	defer func() { op.end(err) }()
+
+	var b setEmbedding
+	if len(embeddings) == 0 && len(documents) == 0 && len(metadatas) > 0 {
+		b = setEmbedding{Ids: ids, Metadatas: &metadatas}
+	} else if b, err = c.validatedSetEmbeddingRequest(ctx, ids, embeddings, metadatas, documents); err != nil {
 		return false, fmt.Errorf("validating: %w", err)
 	}
 
@@ -143,7 +186,59 @@ func (c *Collection) UpdateOne(ctx context.Context, id ID, embedding Embedding,
 	return c.Update(ctx, ids, embeddings, metadatas, documents)
 }
 
-func (c *Collection) Count(ctx context.Context) (int, error) {
+// Delete deletes the embeddings matching all of the given filters, any of which
+// may be empty, and returns the IDs of the deleted embeddings.
+func (c *Collection) Delete(ctx context.Context, ids []ID, where Where, whereDocument Where) ([]ID, error) {
+	if len(ids) == 0 && len(where) == 0 && len(whereDocument) == 0 {
+		// The server deletes everything without filters, require that to be explicit.
+		return nil, fmt.Errorf("%w: no ids, where or where document filter, use DeleteAll to delete everything", ErrInvalidInput)
+	}
+
+	return c.delete(ctx, ids, where, whereDocument)
+}
+
+// DeleteAll deletes every embedding in the collection.
+func (c *Collection) DeleteAll(ctx context.Context) ([]ID, error) {
+	return c.delete(ctx, nil, nil, nil)
+}
+
+func (c *Collection) delete(ctx context.Context, ids []ID, where Where, whereDocument Where) (_ []ID, err error) {
+	ctx, op := c.client.telemetry.start(ctx, "Collection.Delete", AttributeCollectionName.String(c.Name), AttributeBatchSize.Int(len(ids)))
+	defer func() { op.end(err) }()
+
+	body := chromaclient.DeleteEmbedding{
+		Ids:           nil, // optional
+		Where:         nil, // optional
+		WhereDocument: nil, // optional
+	}
+	if len(ids) > 0 {
+		body.Ids = &ids
+	}
+	if len(where) > 0 {
+		body.Where = &where
+	}
+	if len(whereDocument) > 0 {
+		body.WhereDocument = &whereDocument
+	}
+
+	r, err := handleResponse(c.api.Delete(ctx, c.ID, body))
+	if err != nil {
+		return nil, fmt.Errorf("deleting: %w", err)
+	}
+
+	var deleted []ID
+	if err := r.decodeJSON(&deleted); err != nil {
+		return nil, fmt.Errorf("decoding response: %w", err)
+	}
+	op.setResultCount(len(deleted))
+
+	return deleted, nil
+}
+
+func (c *Collection) Count(ctx context.Context) (_ int, err error) {
+	ctx, op := c.client.telemetry.start(ctx, "Collection.Count", AttributeCollectionName.String(c.Name))
+	defer func() { op.end(err) }()
+
 	r, err := handleResponse(c.api.Count(ctx, c.ID))
 	if err != nil {
 		return 0, fmt.Errorf("counting: %w", err)
@@ -153,11 +248,277 @@ func (c *Collection) Count(ctx context.Context) (int, error) {
 	if err := r.decodeJSON(&count); err != nil {
 		return 0, fmt.Errorf("decoding response: %w", err)
 	}
+	op.setResultCount(count)
 
 	return count, nil
 }
 
-func (c *Collection) Modify(ctx context.Context, name string, metadata Metadata) error {
+type Include string
+
+const (
+	IncludeDocuments  Include = "documents"
+	IncludeEmbeddings Include = "embeddings"
+	IncludeMetadatas  Include = "metadatas"
+	IncludeDistances  Include = "distances"
+)
+
+// QueryResult holds the results of a query, with one list of results per
+// query embedding. Fields which weren't included are nil.
+type QueryResult struct {
+	IDs        [][]ID        `json:"ids"`
+	Embeddings [][]Embedding `json:"embeddings"`
+	Documents  [][]Document  `json:"documents"`
+	Metadatas  [][]Metadata  `json:"metadatas"`
+	Distances  [][]float64   `json:"distances"`
+}
+
+type queryOpts struct {
+	where         Where
+	whereDocument Where
+	include       []Include
+	limit         int
+	offset        int
+}
+
+type QueryOpts func(*queryOpts)
+
+// WithWhere filters results by metadata, e.g. {"color": {"$eq": "red"}}.
+func WithWhere(where Where) QueryOpts {
+	return func(q *queryOpts) {
+		q.where = where
+	}
+}
+
+// WithWhereDocument filters results by document content, e.g. {"$contains": "red"}.
+func WithWhereDocument(whereDocument Where) QueryOpts {
+	return func(q *queryOpts) {
+		q.whereDocument = whereDocument
+	}
+}
+
+// WithInclude sets which fields to include in the results. When not set,
+// the server's default is used. Set without fields to only get IDs.
+func WithInclude(include ...Include) QueryOpts {
+	return func(q *queryOpts) {
+		q.include = append([]Include{}, include...)
+	}
+}
+
+// WithLimit limits the number of results of Get.
+func WithLimit(limit int) QueryOpts {
+	return func(q *queryOpts) {
+		q.limit = limit
+	}
+}
+
+// WithOffset skips the first offset results of Get, used for paging with WithLimit.
+func WithOffset(offset int) QueryOpts {
+	return func(q *queryOpts) {
+		q.offset = offset
+	}
+}
+
+func queryOptsOf(opts []QueryOpts) *queryOpts {
+	qOpts := &queryOpts{}
+	for _, opt := range opts {
+		opt(qOpts)
+	}
+	return qOpts
+}
+
+// Query embeds queryTexts with the collection's query embedding generator and
+// returns the nResults nearest neighbours of each.
+func (c *Collection) Query(ctx context.Context, queryTexts []Document, nResults int, opts ...QueryOpts) (_ *QueryResult, err error) {
+	ctx, op := c.client.telemetry.start(ctx, "Collection.Query", AttributeCollectionName.String(c.Name), AttributeBatchSize.Int(len(queryTexts)))
+	defer func() { op.end(err) }()
+
+	if len(queryTexts) == 0 {
+		return nil, fmt.Errorf("%w: no query texts", ErrInvalidInput)
+	}
+
+	queryEmbeddings, err := c.generateQueryEmbeddings(ctx, queryTexts)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.QueryEmbeddings(ctx, queryEmbeddings, nResults, opts...)
+}
+
+// QueryEmbeddings returns the nResults nearest neighbours of each of queryEmbeddings.
+func (c *Collection) QueryEmbeddings(ctx context.Context, queryEmbeddings []Embedding, nResults int, opts ...QueryOpts) (_ *QueryResult, err error) {
+	ctx, op := c.client.telemetry.start(ctx, "Collection.QueryEmbeddings", AttributeCollectionName.String(c.Name), AttributeBatchSize.Int(len(queryEmbeddings)))
+	defer func() { op.end(err) }()
+
+	if len(queryEmbeddings) == 0 {
+		return nil, fmt.Errorf("%w: no query embeddings", ErrInvalidInput)
+	}
+	if nResults <= 0 {
+		return nil, fmt.Errorf("%w: nResults must be positive, got %d", ErrInvalidInput, nResults)
+	}
+
+	qOpts := queryOptsOf(opts)
+	body := queryEmbedding{
+		QueryEmbeddings: queryEmbeddings,
+		NResults:        &nResults,
+		Where:           nil, // optional
+		WhereDocument:   nil, // optional
+		Include:         nil, // optional
+	}
+	if len(qOpts.where) > 0 {
+		body.Where = &qOpts.where
+	}
+	if len(qOpts.whereDocument) > 0 {
+		body.WhereDocument = &qOpts.whereDocument
+	}
+	if qOpts.include != nil {
+		body.Include = &qOpts.include
+	}
+
+	b, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("encoding request: %w", err)
+	}
+
+	r, err := handleResponse(c.api.GetNearestNeighborsWithBody(ctx, c.ID, "application/json", bytes.NewReader(b)))
+	if err != nil {
+		return nil, fmt.Errorf("querying: %w", err)
+	}
+
+	var result QueryResult
+	if err := r.decodeJSON(&result); err != nil {
+		return nil, fmt.Errorf("decoding response: %w", err)
+	}
+	results := 0
+	for _, ids := range result.IDs {
+		results += len(ids)
+	}
+	op.setResultCount(results)
+
+	return &result, nil
+}
+
+// Get returns the embeddings with the given IDs, or all embeddings if ids is
+// empty, matching the filters in opts. Use WithLimit and WithOffset to page
+// through large collections.
+func (c *Collection) Get(ctx context.Context, ids []ID, opts ...QueryOpts) (_ *EmbeddingResponse, err error) {
+	ctx, op := c.client.telemetry.start(ctx, "Collection.Get", AttributeCollectionName.String(c.Name), AttributeBatchSize.Int(len(ids)))
+	defer func() { op.end(err) }()
+
+	qOpts := queryOptsOf(opts)
+	body := chromaclient.GetEmbedding{
+		Ids:           nil, // optional
+		Include:       nil, // optional
+		Limit:         nil, // optional
+		Offset:        nil, // optional
+		Sort:          nil, // optional
+		Where:         nil, // optional
+		WhereDocument: nil, // optional
+	}
+	if len(ids) > 0 {
+		body.Ids = &ids
+	}
+	if qOpts.include != nil {
+		include := make([]chromaclient.GetEmbeddingInclude, 0, len(qOpts.include))
+		for _, inc := range qOpts.include {
+			include = append(include, chromaclient.GetEmbeddingInclude(inc))
+		}
+		body.Include = &include
+	}
+	if qOpts.limit > 0 {
+		body.Limit = &qOpts.limit
+	}
+	if qOpts.offset > 0 {
+		body.Offset = &qOpts.offset
+	}
+	if len(qOpts.where) > 0 {
+		body.Where = &qOpts.where
+	}
+	if len(qOpts.whereDocument) > 0 {
+		body.WhereDocument = &qOpts.whereDocument
+	}
+
+	r, err := handleResponse(c.api.Get(ctx, c.ID, body))
+	if err != nil {
+		return nil, fmt.Errorf("getting: %w", err)
+	}
+
+	var result EmbeddingResponse
+	if err := r.decodeJSON(&result); err != nil {
+		return nil, fmt.Errorf("decoding response: %w", err)
+	}
+	op.setResultCount(len(result.IDs))
+
+	return &result, nil
+}
+
+// GetPages calls fn with each page of at most pageSize embeddings matching
+// the filters in opts, until all have been seen or fn returns an error.
+// Only one page is held in memory at a time.
+func (c *Collection) GetPages(ctx context.Context, pageSize int, fn func(page *EmbeddingResponse) error, opts ...QueryOpts) (err error) {
+	ctx, op := c.client.telemetry.start(ctx, "Collection.GetPages", AttributeCollectionName.String(c.Name))
+	defer func() { op.end(err) }()
+
+	if pageSize <= 0 {
+		return fmt.Errorf("%w: page size must be positive, got %d", ErrInvalidInput, pageSize)
+	}
+
+	for offset := 0; ; offset += pageSize {
+		pageOpts := append(append([]QueryOpts{}, opts...), WithLimit(pageSize), WithOffset(offset))
+		page, err := c.Get(ctx, nil, pageOpts...)
+		if err != nil {
+			return fmt.Errorf("getting page at offset %d: %w", offset, err)
+		}
+		if len(page.IDs) == 0 {
+			return nil
+		}
+		if err := fn(page); err != nil {
+			return err
+		}
+		if len(page.IDs) < pageSize {
+			return nil
+		}
+	}
+}
+
+// generateQueryEmbeddings embeds query texts, refusing to do so if the query
+// embeddings can't be compared with the collection's document embeddings.
+func (c *Collection) generateQueryEmbeddings(ctx context.Context, queryTexts []Document) ([]Embedding, error) {
+	gen := c.queryEmbeddingGen
+	if gen == nil {
+		gen = c.embeddingGen
+	}
+	if gen == nil {
+		return nil, fmt.Errorf("%w: no embedding generator", ErrInvalidInput)
+	}
+
+	docDimension := 0
+	if c.embeddingGen != nil {
+		docDimension = EmbeddingDimension(c.embeddingGen)
+	}
+	if queryDimension := EmbeddingDimension(gen); docDimension != 0 && queryDimension != 0 && docDimension != queryDimension {
+		return nil, fmt.Errorf("%w: query embedding dimension %d does not match document embedding dimension %d", ErrInvalidInput, queryDimension, docDimension)
+	}
+
+	embeddings, err := c.client.telemetry.generateEmbeddings(ctx, gen, EmbeddingPurposeQuery, queryTexts)
+	if err != nil {
+		return nil, fmt.Errorf("generating query embeddings: %w", err)
+	}
+
+	if docDimension != 0 {
+		for _, e := range embeddings {
+			if len(e) != docDimension {
+				return nil, fmt.Errorf("%w: query embedding dimension %d does not match document embedding dimension %d", ErrInvalidInput, len(e), docDimension)
+			}
+		}
+	}
+
+	return embeddings, nil
+}
+
+func (c *Collection) Modify(ctx context.Context, name string, metadata Metadata) (err error) {
+	ctx, op := c.client.telemetry.start(ctx, "Collection.Modify", AttributeCollectionName.String(c.Name))
+	defer func() { op.end(err) }()
+
 	body := chromaclient.UpdateCollection{
 		NewMetadata: nil,
 		NewName:     nil,
@@ -194,6 +555,16 @@ type setEmbedding struct {
 	Metadatas      *[]map[string]interface{} `json:"metadatas,omitempty"`
 }
 
+// Copied from types.gen.go, but with QueryEmbeddings typed as embeddings since
+// the generated type is a list of objects.
+type queryEmbedding struct {
+	Include         *[]Include  `json:"include,omitempty"`
+	NResults        *int        `json:"n_results,omitempty"`
+	QueryEmbeddings []Embedding `json:"query_embeddings"`
+	Where           *Where      `json:"where,omitempty"`
+	WhereDocument   *Where      `json:"where_document,omitempty"`
+}
+
 func (c *Collection) validatedSetEmbeddingRequest(ctx context.Context, ids []ID, embeddings []Embedding, metadatas []Metadata, documents []Document) (setEmbedding, error) {
 	if len(embeddings) == 0 && len(documents) == 0 {
 		return setEmbedding{}, fmt.Errorf("%w: no embeddings or documents", ErrInvalidInput)
@@ -204,7 +575,7 @@ func (c *Collection) validatedSetEmbeddingRequest(ctx context.Context, ids []ID,
 			return setEmbedding{}, fmt.Errorf("%w: no embedding generator", ErrInvalidInput)
 		}
 
-		generatedEmbeddings, err := c.embeddingGen.Generate(ctx, documents)
+		generatedEmbeddings, err := c.client.telemetry.generateEmbeddings(ctx, c.embeddingGen, EmbeddingPurposeDocument, documents)
 		if err != nil {
 			return setEmbedding{}, fmt.Errorf("generating embeddings: %w", err)
 		}
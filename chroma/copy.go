@@ -0,0 +1,274 @@
+package chroma
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// CopyProgress is reported after every copied page.
+type CopyProgress struct {
+	Copied int
+	// Total is the number of records in the source when the copy started.
+	Total int
+}
+
+// CopyResult describes a completed copy.
+type CopyResult struct {
+	Collection *Collection
+	Copied     int
+	// Verified is true if verification was enabled and passed.
+	Verified bool
+}
+
+type copyOpts struct {
+	pageSize   int
+	interval   time.Duration
+	onProgress func(CopyProgress)
+	verify     bool
+	sampleSize int
+	reembed    EmbeddingGenerator
+	collOpts   []CollectionOpts
+}
+
+type CopyOpts func(*copyOpts)
+
+// WithCopyPageSize sets how many records are read and written at a time.
+func WithCopyPageSize(pageSize int) CopyOpts {
+	return func(o *copyOpts) {
+		o.pageSize = pageSize
+	}
+}
+
+// WithCopyThrottle waits at least interval between writing pages, to spare
+// the servers or an embedding provider's rate limits.
+func WithCopyThrottle(interval time.Duration) CopyOpts {
+	return func(o *copyOpts) {
+		o.interval = interval
+	}
+}
+
+// WithCopyProgress sets a callback called after every copied page.
+func WithCopyProgress(onProgress func(CopyProgress)) CopyOpts {
+	return func(o *copyOpts) {
+		o.onProgress = onProgress
+	}
+}
+
+// WithCopyVerification checks that the collections have the same count after
+// copying, and that sampleSize randomly sampled records exist in the
+// destination with the same document.
+func WithCopyVerification(sampleSize int) CopyOpts {
+	return func(o *copyOpts) {
+		o.verify = true
+		o.sampleSize = sampleSize
+	}
+}
+
+// WithReembed generates new embeddings from the documents with embeddingFunc
+// instead of copying the stored ones, for migrating to a new model. The
+// destination collection also uses embeddingFunc.
+func WithReembed(embeddingFunc EmbeddingGenerator) CopyOpts {
+	return func(o *copyOpts) {
+		o.reembed = embeddingFunc
+	}
+}
+
+// WithCopyCollectionOpts sets options for the destination collection.
+func WithCopyCollectionOpts(opts ...CollectionOpts) CopyOpts {
+	return func(o *copyOpts) {
+		o.collOpts = append(o.collOpts, opts...)
+	}
+}
+
+// CopyCollection copies the collection srcName on src to a new collection
+// dstName on dst, including its metadata, reading and writing page by page.
+// src and dst may be the same client.
+func CopyCollection(ctx context.Context, src *Client, srcName string, dst *Client, dstName string, opts ...CopyOpts) (_ *CopyResult, err error) {
+	ctx, op := dst.telemetry.start(ctx, "CopyCollection", AttributeCollectionName.String(dstName))
+	defer func() { op.end(err) }()
+
+	cOpts := &copyOpts{pageSize: 500, interval: 0, onProgress: nil, verify: false, sampleSize: 0, reembed: nil, collOpts: nil}
+	for _, opt := range opts {
+		opt(cOpts)
+	}
+	if cOpts.pageSize <= 0 {
+		return nil, fmt.Errorf("%w: page size must be positive, got %d", ErrInvalidInput, cOpts.pageSize)
+	}
+
+	srcColl, err := src.GetCollection(ctx, srcName)
+	if err != nil {
+		return nil, fmt.Errorf("copying: getting source: %w", err)
+	}
+	total, err := srcColl.Count(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("copying: counting source: %w", err)
+	}
+
+	collOpts := append([]CollectionOpts{WithMetadata(srcColl.Metadata)}, cOpts.collOpts...)
+	if cOpts.reembed != nil {
+		collOpts = append(collOpts, WithEmbeddingFunc(cOpts.reembed))
+	}
+	dstColl, err := dst.CreateCollection(ctx, dstName, collOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("copying: creating destination: %w", err)
+	}
+
+	include := []Include{IncludeEmbeddings, IncludeDocuments, IncludeMetadatas}
+	if cOpts.reembed != nil {
+		include = []Include{IncludeDocuments, IncludeMetadatas}
+	}
+
+	copied := 0
+	sample := newIDSample(cOpts.sampleSize)
+	var lastWrite time.Time
+	err = srcColl.GetPages(ctx, cOpts.pageSize, func(page *EmbeddingResponse) error {
+		records := recordsOf(page)
+		if cOpts.reembed != nil {
+			if err := reembedRecords(ctx, dst.telemetry, cOpts.reembed, records); err != nil {
+				return err
+			}
+		}
+
+		if wait := cOpts.interval - time.Since(lastWrite); cOpts.interval > 0 && wait > 0 {
+			select {
+			case <-time.After(wait):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		if err := dstColl.upsertSnapshotRecords(ctx, records); err != nil {
+			return fmt.Errorf("writing records %d to %d: %w", copied, copied+len(records), err)
+		}
+		lastWrite = time.Now()
+
+		for _, r := range records {
+			sample.add(r.ID, r.Document)
+		}
+		copied += len(records)
+		if cOpts.onProgress != nil {
+			cOpts.onProgress(CopyProgress{Copied: copied, Total: total})
+		}
+		return nil
+	}, WithInclude(include...))
+	if err != nil {
+		return nil, fmt.Errorf("copying: %w", err)
+	}
+
+	result := &CopyResult{Collection: dstColl, Copied: copied, Verified: false}
+	if cOpts.verify {
+		if err := verifyCopy(ctx, srcColl, dstColl, sample); err != nil {
+			return result, fmt.Errorf("copying: verifying: %w", err)
+		}
+		result.Verified = true
+	}
+	return result, nil
+}
+
+func recordsOf(page *EmbeddingResponse) []SnapshotRecord {
+	records := make([]SnapshotRecord, 0, len(page.IDs))
+	for i, id := range page.IDs {
+		record := SnapshotRecord{ID: id, Embedding: nil, Document: "", Metadata: nil}
+		if i < len(page.Embeddings) {
+			record.Embedding = page.Embeddings[i]
+		}
+		if i < len(page.Documents) {
+			record.Document = page.Documents[i]
+		}
+		if i < len(page.Metadatas) {
+			record.Metadata = page.Metadatas[i]
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func reembedRecords(ctx context.Context, tel *telemetry, gen EmbeddingGenerator, records []SnapshotRecord) error {
+	docs := make([]Document, 0, len(records))
+	for _, r := range records {
+		if r.Document == "" {
+			return fmt.Errorf("%w: cannot re-embed %q without a document", ErrInvalidInput, r.ID)
+		}
+		docs = append(docs, r.Document)
+	}
+
+	embeddings, err := tel.generateEmbeddings(ctx, gen, EmbeddingPurposeDocument, docs)
+	if err != nil {
+		return fmt.Errorf("generating embeddings: %w", err)
+	}
+	if len(embeddings) != len(records) {
+		return fmt.Errorf("generating embeddings: got %d embeddings, want %d", len(embeddings), len(records))
+	}
+	for i := range records {
+		records[i].Embedding = embeddings[i]
+	}
+	return nil
+}
+
+func verifyCopy(ctx context.Context, src, dst *Collection, sample *idSample) error {
+	srcCount, err := src.Count(ctx)
+	if err != nil {
+		return fmt.Errorf("counting source: %w", err)
+	}
+	dstCount, err := dst.Count(ctx)
+	if err != nil {
+		return fmt.Errorf("counting destination: %w", err)
+	}
+	if srcCount != dstCount {
+		return fmt.Errorf("source has %d records, destination has %d", srcCount, dstCount)
+	}
+
+	if len(sample.ids) == 0 {
+		return nil
+	}
+	got, err := dst.Get(ctx, sample.ids, WithInclude(IncludeDocuments))
+	if err != nil {
+		return fmt.Errorf("getting sample: %w", err)
+	}
+	found := make(map[ID]Document, len(got.IDs))
+	for i, id := range got.IDs {
+		if i < len(got.Documents) {
+			found[id] = got.Documents[i]
+		} else {
+			found[id] = ""
+		}
+	}
+	for i, id := range sample.ids {
+		doc, ok := found[id]
+		if !ok {
+			return fmt.Errorf("sampled record %q missing from destination", id)
+		}
+		if doc != sample.documents[i] {
+			return fmt.Errorf("sampled record %q has a different document in the destination", id)
+		}
+	}
+	return nil
+}
+
+// idSample keeps a uniform random sample of a stream of records using
+// reservoir sampling.
+type idSample struct {
+	size      int
+	seen      int
+	ids       []ID
+	documents []Document
+	rnd       *rand.Rand
+}
+
+func newIDSample(size int) *idSample {
+	return &idSample{size: size, seen: 0, ids: nil, documents: nil, rnd: rand.New(rand.NewSource(time.Now().UnixNano()))}
+}
+
+func (s *idSample) add(id ID, document Document) {
+	s.seen++
+	if len(s.ids) < s.size {
+		s.ids = append(s.ids, id)
+		s.documents = append(s.documents, document)
+		return
+	}
+	if j := s.rnd.Intn(s.seen); j < s.size {
+		s.ids[j] = id
+		s.documents[j] = document
+	}
+}
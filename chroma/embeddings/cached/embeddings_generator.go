@@ -6,9 +6,11 @@ import (
 	"sync"
 
 	"github.com/kristofferostlund/chroma-go/chroma"
+	"github.com/kristofferostlund/chroma-go/chroma/embeddings/postprocess"
+	"go.opentelemetry.io/otel/trace"
 )
 
-var _ chroma.EmbeddingGenerator = (*CachedEmbeddingsGenerator)(nil)
+var _ chroma.PurposeEmbeddingGenerator = (*CachedEmbeddingsGenerator)(nil)
 
 // CachedEmbeddingsGenerator wraps an embedding generator and caches
 // results so that subsequent calls with the same document will return
@@ -18,13 +20,23 @@ type CachedEmbeddingsGenerator struct {
 
 	lock    sync.Locker
 	gen     chan genReq
-	waiting map[chroma.Document][]chan res
+	waiting map[cacheKey][]chan res
 
-	cache map[chroma.Document]chroma.Embedding
+	// cache holds embeddings encoded with codec.
+	cache map[cacheKey]interface{}
+	codec postprocess.Codec
+}
+
+// cacheKey includes the purpose since the same text may be embedded
+// differently as a document and as a query.
+type cacheKey struct {
+	purpose  chroma.EmbeddingPurpose
+	document chroma.Document
 }
 
 type genReq struct {
 	ctx       context.Context
+	purpose   chroma.EmbeddingPurpose
 	documents []chroma.Document
 }
 
@@ -33,13 +45,34 @@ type res struct {
 	err       error
 }
 
-func NewEmbeddingsGenerator(ctx context.Context, generator chroma.EmbeddingGenerator) *CachedEmbeddingsGenerator {
+type Config struct {
+	codec postprocess.Codec
+}
+
+type Opt func(c *Config)
+
+// Storage sets how embeddings are stored in the cache, for example
+// postprocess.Float32Codec to halve the memory used. Defaults to
+// postprocess.Float64Codec, storing embeddings as they are.
+func Storage(codec postprocess.Codec) Opt {
+	return func(c *Config) {
+		c.codec = codec
+	}
+}
+
+func NewEmbeddingsGenerator(ctx context.Context, generator chroma.EmbeddingGenerator, opts ...Opt) *CachedEmbeddingsGenerator {
+	conf := &Config{codec: postprocess.Float64Codec}
+	for _, opt := range opts {
+		opt(conf)
+	}
+
 	gen := &CachedEmbeddingsGenerator{
 		generator: generator,
-		cache:     make(map[chroma.Document]chroma.Embedding),
+		cache:     make(map[cacheKey]interface{}),
+		codec:     conf.codec,
 		gen:       make(chan genReq),
 		lock:      &sync.Mutex{},
-		waiting:   make(map[chroma.Document][]chan res),
+		waiting:   make(map[cacheKey][]chan res),
 	}
 
 	go gen.run(ctx)
@@ -47,8 +80,28 @@ func NewEmbeddingsGenerator(ctx context.Context, generator chroma.EmbeddingGener
 	return gen
 }
 
+// Dimension returns the dimension reported by the wrapped generator.
+func (c *CachedEmbeddingsGenerator) Dimension() int {
+	return chroma.EmbeddingDimension(c.generator)
+}
+
+// Model returns the model reported by the wrapped generator.
+func (c *CachedEmbeddingsGenerator) Model() string {
+	return chroma.EmbeddingModel(c.generator)
+}
+
 func (c *CachedEmbeddingsGenerator) Generate(ctx context.Context, documents []chroma.Document) ([]chroma.Embedding, error) {
-	embeddingChans := c.requestEmbeddings(ctx, documents)
+	return c.GenerateFor(ctx, chroma.EmbeddingPurposeDocument, documents)
+}
+
+// GenerateFor generates embeddings for the given purpose, passing the purpose on
+// to the wrapped generator if it supports it.
+func (c *CachedEmbeddingsGenerator) GenerateFor(ctx context.Context, purpose chroma.EmbeddingPurpose, documents []chroma.Document) ([]chroma.Embedding, error) {
+	embeddingChans, hits := c.requestEmbeddings(ctx, purpose, documents)
+	chroma.LoggerFromContext(ctx).Debug("embedding cache lookup", "purpose", purpose, "documents", len(documents), "hits", hits)
+	if len(documents) > 0 {
+		trace.SpanFromContext(ctx).SetAttributes(chroma.AttributeCacheHitRatio.Float64(float64(hits) / float64(len(documents))))
+	}
 
 	embeddings := make([]chroma.Embedding, len(documents))
 	for i := range embeddingChans {
@@ -63,7 +116,9 @@ func (c *CachedEmbeddingsGenerator) Generate(ctx context.Context, documents []ch
 	return embeddings, nil
 }
 
-func (c *CachedEmbeddingsGenerator) requestEmbeddings(ctx context.Context, docs []chroma.Document) []<-chan res {
+// requestEmbeddings returns a channel per document, and how many of them
+// were already cached.
+func (c *CachedEmbeddingsGenerator) requestEmbeddings(ctx context.Context, purpose chroma.EmbeddingPurpose, docs []chroma.Document) ([]<-chan res, int) {
 	// We lock so we can safely update the cache.
 	// Since we're using channels, the lock is active only when mutating the cache
 	// or when adding channels to the waiting list.
@@ -80,15 +135,18 @@ func (c *CachedEmbeddingsGenerator) requestEmbeddings(ctx context.Context, docs
 	}
 
 	docsToGenerate := make([]chroma.Document, 0)
+	hits := 0
 
 	for i, doc := range docs {
-		if embedding, ok := c.cache[doc]; ok {
+		key := cacheKey{purpose, doc}
+		if encoded, ok := c.cache[key]; ok {
 			// It's in the cache, no need to generate.
-			embeddingChans[i] <- res{embedding, nil}
+			embeddingChans[i] <- res{c.codec.Decode(encoded), nil}
+			hits++
 			continue
 		}
 
-		if _, ok := c.waiting[doc]; !ok {
+		if _, ok := c.waiting[key]; !ok {
 			// If we're not already waiting for this document, we need to generate it.
 			// Since we hold the lock, we know no other goroutine is will want to generate
 			// this document (unless this one fails).
@@ -96,12 +154,12 @@ func (c *CachedEmbeddingsGenerator) requestEmbeddings(ctx context.Context, docs
 		}
 
 		// Add ourselves to the waiting list regardless of whether we're generating or not.
-		c.waiting[doc] = append(c.waiting[doc], embeddingChans[i])
+		c.waiting[key] = append(c.waiting[key], embeddingChans[i])
 	}
 
 	if len(docsToGenerate) > 0 {
 		// Dispatch a goroutine to generate the embeddings
-		go func() { c.gen <- genReq{ctx, docsToGenerate} }()
+		go func() { c.gen <- genReq{ctx, purpose, docsToGenerate} }()
 	}
 
 	// We can't cast a slice of channels to a slice of receive-only channels,
@@ -110,36 +168,50 @@ func (c *CachedEmbeddingsGenerator) requestEmbeddings(ctx context.Context, docs
 	for _, ch := range embeddingChans {
 		receiveChans = append(receiveChans, ch)
 	}
-	return receiveChans
+	return receiveChans, hits
 }
 
 func (c *CachedEmbeddingsGenerator) handleGen(req genReq) {
 	// We check the error further down so we can fail all waiting docs
 	// in case of error.
-	embeddings, err := c.generator.Generate(req.ctx, req.documents)
+	embeddings, err := chroma.GenerateEmbeddings(req.ctx, c.generator, req.purpose, req.documents)
 	// Error handling within nested loop below.
+	if err == nil && len(embeddings) != len(req.documents) {
+		err = fmt.Errorf("got %d embeddings, want %d", len(embeddings), len(req.documents))
+	}
+	if err != nil {
+		chroma.LoggerFromContext(req.ctx).Error("generating embeddings to cache", "purpose", req.purpose, "documents", len(req.documents), "error", err)
+	}
 
 	// We lock so we can safely update the cache and waiting channels.
 	c.lock.Lock()
 	defer c.lock.Unlock()
 
 	for i, doc := range req.documents {
-		c.cache[doc] = embeddings[i]
+		key := cacheKey{req.purpose, doc}
+		var embedding chroma.Embedding
+		if err == nil {
+			encoded := c.codec.Encode(embeddings[i])
+			c.cache[key] = encoded
+			// Hand out the decoded embedding so callers get the same values
+			// regardless of whether they hit the cache or not.
+			embedding = c.codec.Decode(encoded)
+		}
 
-		if _, ok := c.waiting[doc]; ok {
-			for _, ch := range c.waiting[doc] {
+		if _, ok := c.waiting[key]; ok {
+			for _, ch := range c.waiting[key] {
 				// We need to fail all waiting docs in case of error to ensure
 				// all waiting goroutines receive the error.
 				if err != nil {
 					ch <- res{nil, fmt.Errorf("generating embedding for document: %w", err)}
 					continue
 				} else {
-					ch <- res{embeddings[i], nil}
+					ch <- res{embedding, nil}
 				}
 			}
 
 			// Clean up the waiting list.
-			delete(c.waiting, doc)
+			delete(c.waiting, key)
 		}
 	}
 }
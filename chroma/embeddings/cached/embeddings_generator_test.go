@@ -0,0 +1,73 @@
+package cached_test
+
+import (
+	"context"
+	"reflect"
+	"sync/atomic"
+	"testing"
+
+	"github.com/kristofferostlund/chroma-go/chroma"
+	"github.com/kristofferostlund/chroma-go/chroma/embeddings/cached"
+	"github.com/kristofferostlund/chroma-go/chroma/embeddings/postprocess"
+)
+
+// countingGenerator embeds documents by their length and counts the
+// documents it was asked for.
+type countingGenerator struct {
+	documents int64
+}
+
+func (g *countingGenerator) Generate(ctx context.Context, documents []chroma.Document) ([]chroma.Embedding, error) {
+	atomic.AddInt64(&g.documents, int64(len(documents)))
+	embeddings := make([]chroma.Embedding, 0, len(documents))
+	for _, d := range documents {
+		embeddings = append(embeddings, chroma.Embedding{float64(len(d)) / 3, -1.0 / 7, 0})
+	}
+	return embeddings, nil
+}
+
+func TestStorage(t *testing.T) {
+	tests := []struct {
+		name  string
+		codec postprocess.Codec
+		step  postprocess.Step
+	}{
+		{"float64", postprocess.Float64Codec, nil},
+		{"float32", postprocess.Float32Codec, postprocess.Float32()},
+		{"int8", postprocess.Int8Codec, postprocess.Int8()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			generator := &countingGenerator{documents: 0}
+			g := cached.NewEmbeddingsGenerator(ctx, generator, cached.Storage(tt.codec))
+
+			documents := []chroma.Document{"a", "bb", "a"}
+			want, err := generator.Generate(ctx, documents)
+			if err != nil {
+				t.Fatalf("generating: %v", err)
+			}
+			atomic.StoreInt64(&generator.documents, 0)
+			if tt.step != nil {
+				for i, e := range want {
+					want[i], _ = tt.step.Apply(e)
+				}
+			}
+
+			// Fresh and cached embeddings both match what the codec keeps.
+			for i := 0; i < 2; i++ {
+				got, err := g.Generate(ctx, documents)
+				if err != nil {
+					t.Fatalf("generating: %v", err)
+				}
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("got %v on call %d, want %v", got, i, want)
+				}
+			}
+			if got := atomic.LoadInt64(&generator.documents); got != 2 {
+				t.Errorf("got %d documents generated, want each distinct one once", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,203 @@
+package cohere
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/kristofferostlund/chroma-go/chroma"
+)
+
+var _ chroma.PurposeEmbeddingGenerator = (*EmbeddingGenerator)(nil)
+
+const (
+	DefaultBaseURL = "https://api.cohere.ai/v1"
+	DefaultModel   = "embed-english-v3.0"
+)
+
+// InputType is Cohere's input_type, telling the model how the embeddings will be used.
+type InputType string
+
+const (
+	InputTypeSearchDocument InputType = "search_document"
+	InputTypeSearchQuery    InputType = "search_query"
+	InputTypeClassification InputType = "classification"
+	InputTypeClustering     InputType = "clustering"
+)
+
+type EmbeddingGenerator struct {
+	client    *http.Client
+	authToken string
+	baseURL   string
+	model     string
+	truncate  string
+}
+
+type Config struct {
+	authToken  string
+	baseURL    string
+	model      string
+	truncate   string
+	httpClient *http.Client
+}
+
+type Opt func(c *Config)
+
+func Model(model string) Opt {
+	return func(c *Config) {
+		c.model = model
+	}
+}
+
+// BaseURL overrides the Cohere API URL, useful for proxies and tests.
+func BaseURL(baseURL string) Opt {
+	return func(c *Config) {
+		c.baseURL = baseURL
+	}
+}
+
+// Truncate sets how inputs longer than the model's maximum are handled,
+// one of NONE, START or END.
+func Truncate(truncate string) Opt {
+	return func(c *Config) {
+		c.truncate = truncate
+	}
+}
+
+func HTTPClient(client *http.Client) Opt {
+	return func(c *Config) {
+		c.httpClient = client
+	}
+}
+
+func NewEmbeddingGenerator(authToken string, opts ...Opt) *EmbeddingGenerator {
+	conf := &Config{
+		authToken:  authToken,
+		baseURL:    DefaultBaseURL,
+		model:      DefaultModel,
+		truncate:   "",
+		httpClient: http.DefaultClient,
+	}
+	for _, opt := range opts {
+		opt(conf)
+	}
+
+	return &EmbeddingGenerator{
+		client:    conf.httpClient,
+		authToken: conf.authToken,
+		baseURL:   strings.TrimSuffix(conf.baseURL, "/"),
+		model:     conf.model,
+		truncate:  conf.truncate,
+	}
+}
+
+// knownDimensions maps models to the dimension of their embeddings.
+var knownDimensions = map[string]int{
+	"embed-english-v3.0":            1024,
+	"embed-multilingual-v3.0":       1024,
+	"embed-english-light-v3.0":      384,
+	"embed-multilingual-light-v3.0": 384,
+	"embed-english-v2.0":            4096,
+	"embed-english-light-v2.0":      1024,
+	"embed-multilingual-v2.0":       768,
+}
+
+// Model returns the Cohere model used to generate embeddings.
+func (e *EmbeddingGenerator) Model() string {
+	return "cohere/" + e.model
+}
+
+// Dimension returns the dimension of the model's embeddings, or 0 if unknown.
+func (e *EmbeddingGenerator) Dimension() int {
+	return knownDimensions[e.model]
+}
+
+// Generate embeds documents for storage, using the search_document input type.
+func (e *EmbeddingGenerator) Generate(ctx context.Context, documents []chroma.Document) ([]chroma.Embedding, error) {
+	return e.GenerateFor(ctx, chroma.EmbeddingPurposeDocument, documents)
+}
+
+func (e *EmbeddingGenerator) GenerateFor(ctx context.Context, purpose chroma.EmbeddingPurpose, documents []chroma.Document) ([]chroma.Embedding, error) {
+	inputType, err := inputTypeOf(purpose)
+	if err != nil {
+		return nil, err
+	}
+
+	return e.GenerateWithInputType(ctx, inputType, documents)
+}
+
+// GenerateWithInputType embeds documents with an explicit input type, for
+// use cases beyond search such as classification or clustering.
+func (e *EmbeddingGenerator) GenerateWithInputType(ctx context.Context, inputType InputType, documents []chroma.Document) ([]chroma.Embedding, error) {
+	body := embedRequest{
+		Texts:     documents,
+		Model:     e.model,
+		InputType: inputType,
+		Truncate:  e.truncate,
+	}
+	b, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("encoding request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.baseURL+"/embed", bytes.NewReader(b))
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Authorization", "Bearer "+e.authToken)
+
+	res, err := e.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("creating embeddings: %w", err)
+	}
+	defer res.Body.Close()
+
+	if got, wantBelow := res.StatusCode, 300; got >= wantBelow {
+		respBody, _ := io.ReadAll(res.Body)
+		return nil, fmt.Errorf("creating embeddings: got status %d, want below %d: response: %s", got, wantBelow, string(respBody))
+	}
+
+	var resp embedResponse
+	if err := json.NewDecoder(res.Body).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("decoding response: %w", err)
+	}
+	if got, want := len(resp.Embeddings), len(documents); got != want {
+		return nil, fmt.Errorf("creating embeddings: got %d embeddings, want %d", got, want)
+	}
+
+	embeddings := make([]chroma.Embedding, 0, len(resp.Embeddings))
+	for _, e := range resp.Embeddings {
+		embeddings = append(embeddings, chroma.Embedding(e))
+	}
+
+	return embeddings, nil
+}
+
+func inputTypeOf(purpose chroma.EmbeddingPurpose) (InputType, error) {
+	switch purpose {
+	case chroma.EmbeddingPurposeDocument:
+		return InputTypeSearchDocument, nil
+	case chroma.EmbeddingPurposeQuery:
+		return InputTypeSearchQuery, nil
+	default:
+		return "", fmt.Errorf("%w: unknown embedding purpose %q", chroma.ErrInvalidInput, purpose)
+	}
+}
+
+type embedRequest struct {
+	Texts     []string  `json:"texts"`
+	Model     string    `json:"model"`
+	InputType InputType `json:"input_type"`
+	Truncate  string    `json:"truncate,omitempty"`
+}
+
+type embedResponse struct {
+	ID         string      `json:"id"`
+	Embeddings [][]float64 `json:"embeddings"`
+}
@@ -0,0 +1,171 @@
+package cohere_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kristofferostlund/chroma-go/chroma"
+	"github.com/kristofferostlund/chroma-go/chroma/embeddings/cohere"
+)
+
+type embedRequest struct {
+	Texts     []string `json:"texts"`
+	Model     string   `json:"model"`
+	InputType string   `json:"input_type"`
+	Truncate  string   `json:"truncate"`
+}
+
+// fakeCohere serves /embed, recording requests and answering with one
+// embedding per text.
+func fakeCohere(t *testing.T, status int) (*httptest.Server, *[]*http.Request, *[]embedRequest) {
+	t.Helper()
+	var (
+		reqs   []*http.Request
+		bodies []embedRequest
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/embed" || r.Method != http.MethodPost {
+			t.Errorf("got request %s %s, want POST /embed", r.Method, r.URL.Path)
+		}
+		var body embedRequest
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decoding request: %v", err)
+		}
+		reqs = append(reqs, r)
+		bodies = append(bodies, body)
+
+		if status != http.StatusOK {
+			w.WriteHeader(status)
+			_, _ = w.Write([]byte(`{"message":"invalid api token"}`))
+			return
+		}
+		embeddings := make([][]float64, 0, len(body.Texts))
+		for i := range body.Texts {
+			embeddings = append(embeddings, []float64{float64(i), 1})
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"id": "1", "embeddings": embeddings})
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &reqs, &bodies
+}
+
+func TestGenerateForInputType(t *testing.T) {
+	tests := []struct {
+		purpose chroma.EmbeddingPurpose
+		want    string
+	}{
+		{chroma.EmbeddingPurposeDocument, "search_document"},
+		{chroma.EmbeddingPurposeQuery, "search_query"},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.purpose), func(t *testing.T) {
+			srv, _, bodies := fakeCohere(t, http.StatusOK)
+			gen := cohere.NewEmbeddingGenerator("token", cohere.BaseURL(srv.URL))
+
+			embeddings, err := gen.GenerateFor(context.Background(), tt.purpose, []chroma.Document{"a", "b"})
+			if err != nil {
+				t.Fatalf("generating: %v", err)
+			}
+			if len(embeddings) != 2 {
+				t.Errorf("got %d embeddings, want 2", len(embeddings))
+			}
+			if got := (*bodies)[0].InputType; got != tt.want {
+				t.Errorf("got input_type %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerateUsesSearchDocument(t *testing.T) {
+	srv, _, bodies := fakeCohere(t, http.StatusOK)
+	gen := cohere.NewEmbeddingGenerator("token", cohere.BaseURL(srv.URL))
+
+	if _, err := gen.Generate(context.Background(), []chroma.Document{"a"}); err != nil {
+		t.Fatalf("generating: %v", err)
+	}
+	if got, want := (*bodies)[0].InputType, "search_document"; got != want {
+		t.Errorf("got input_type %q, want %q", got, want)
+	}
+}
+
+func TestGenerateRequest(t *testing.T) {
+	srv, reqs, bodies := fakeCohere(t, http.StatusOK)
+	gen := cohere.NewEmbeddingGenerator("secret", cohere.BaseURL(srv.URL+"/"), cohere.Model("embed-multilingual-v3.0"), cohere.Truncate("END"))
+
+	if _, err := gen.GenerateWithInputType(context.Background(), cohere.InputTypeClustering, []chroma.Document{"a", "b"}); err != nil {
+		t.Fatalf("generating: %v", err)
+	}
+
+	req, body := (*reqs)[0], (*bodies)[0]
+	if got, want := req.Header.Get("Authorization"), "Bearer secret"; got != want {
+		t.Errorf("got Authorization %q, want %q", got, want)
+	}
+	if got, want := req.Header.Get("Content-Type"), "application/json"; got != want {
+		t.Errorf("got Content-Type %q, want %q", got, want)
+	}
+	if got, want := body.Model, "embed-multilingual-v3.0"; got != want {
+		t.Errorf("got model %q, want %q", got, want)
+	}
+	if got, want := body.Truncate, "END"; got != want {
+		t.Errorf("got truncate %q, want %q", got, want)
+	}
+	if got, want := body.InputType, "clustering"; got != want {
+		t.Errorf("got input_type %q, want %q", got, want)
+	}
+	if got, want := strings.Join(body.Texts, ","), "a,b"; got != want {
+		t.Errorf("got texts %q, want %q", got, want)
+	}
+}
+
+func TestGenerateDefaults(t *testing.T) {
+	srv, _, bodies := fakeCohere(t, http.StatusOK)
+	gen := cohere.NewEmbeddingGenerator("token", cohere.BaseURL(srv.URL))
+
+	if _, err := gen.Generate(context.Background(), []chroma.Document{"a"}); err != nil {
+		t.Fatalf("generating: %v", err)
+	}
+	if got, want := (*bodies)[0].Model, cohere.DefaultModel; got != want {
+		t.Errorf("got model %q, want %q", got, want)
+	}
+	if got := (*bodies)[0].Truncate; got != "" {
+		t.Errorf("got truncate %q, want it omitted", got)
+	}
+	if got, want := gen.Model(), "cohere/"+cohere.DefaultModel; got != want {
+		t.Errorf("got Model() %q, want %q", got, want)
+	}
+	if got, want := gen.Dimension(), 1024; got != want {
+		t.Errorf("got Dimension() %d, want %d", got, want)
+	}
+}
+
+func TestGenerateErrorStatus(t *testing.T) {
+	srv, _, _ := fakeCohere(t, http.StatusUnauthorized)
+	gen := cohere.NewEmbeddingGenerator("bad", cohere.BaseURL(srv.URL))
+
+	_, err := gen.Generate(context.Background(), []chroma.Document{"a"})
+	if err == nil {
+		t.Fatal("got no error, want one for status 401")
+	}
+	for _, want := range []string{"401", "invalid api token"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("got error %q, want it to contain %q", err, want)
+		}
+	}
+}
+
+func TestGenerateForUnknownPurpose(t *testing.T) {
+	srv, reqs, _ := fakeCohere(t, http.StatusOK)
+	gen := cohere.NewEmbeddingGenerator("token", cohere.BaseURL(srv.URL))
+
+	_, err := gen.GenerateFor(context.Background(), chroma.EmbeddingPurpose("rerank"), []chroma.Document{"a"})
+	if err == nil {
+		t.Fatal("got no error, want one for an unknown purpose")
+	}
+	if len(*reqs) != 0 {
+		t.Errorf("got %d requests, want none", len(*reqs))
+	}
+}
@@ -0,0 +1,283 @@
+// Package fallback provides an embedding generator which tries a primary
+// generator and falls back to secondaries when it fails.
+package fallback
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/kristofferostlund/chroma-go/chroma"
+)
+
+var _ chroma.PurposeEmbeddingGenerator = (*EmbeddingGenerator)(nil)
+
+// ErrIncompatibleProviders is returned when providers would produce embeddings
+// which can't be compared with each other.
+var ErrIncompatibleProviders = errors.New("incompatible embedding providers")
+
+// ErrAllProvidersFailed is returned when no provider could serve a call.
+var ErrAllProvidersFailed = errors.New("all embedding providers failed")
+
+// Provider is a named embedding generator.
+type Provider struct {
+	Name      string
+	Generator chroma.EmbeddingGenerator
+}
+
+// Attempt describes a single provider's part in serving a call.
+type Attempt struct {
+	Provider string
+	// Skipped is true if the provider's circuit breaker was open.
+	Skipped bool
+	Err     error
+}
+
+// Report describes how a call was served.
+type Report struct {
+	// Provider is the name of the provider which served the call,
+	// empty if no provider succeeded.
+	Provider string
+	Attempts []Attempt
+}
+
+// ErrorClassifier decides whether an error from a provider should cause a
+// fall back to the next provider.
+type ErrorClassifier func(err error) bool
+
+// DefaultErrorClassifier falls back on all errors except cancellations,
+// deadlines and invalid input, which no other provider would do better with.
+func DefaultErrorClassifier(err error) bool {
+	switch {
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return false
+	case errors.Is(err, chroma.ErrInvalidInput):
+		return false
+	default:
+		return true
+	}
+}
+
+// EmbeddingGenerator tries each provider in order, moving on to the next one
+// when a provider fails with an error the classifier allows falling back on.
+// Each provider has its own circuit breaker so a provider which keeps failing
+// is skipped for a while.
+type EmbeddingGenerator struct {
+	providers []provider
+	conf      Config
+
+	// dimension is the dimension of the first successful response, used to
+	// catch incompatible providers which don't report their dimension.
+	lock      sync.Locker
+	dimension int
+}
+
+type provider struct {
+	Provider
+	breaker *breaker
+}
+
+type Config struct {
+	allowIncompatible bool
+	classifier        ErrorClassifier
+	failureThreshold  int
+	cooldown          time.Duration
+	onReport          func(Report)
+	now               func() time.Time
+}
+
+type Opt func(c *Config)
+
+// AllowIncompatible allows falling back between providers reporting different
+// dimensions or models. Only use this if the embeddings are never compared
+// with each other, such as when each provider writes to its own collection.
+func AllowIncompatible() Opt {
+	return func(c *Config) {
+		c.allowIncompatible = true
+	}
+}
+
+// Classifier sets which errors cause a fall back, defaults to DefaultErrorClassifier.
+func Classifier(classifier ErrorClassifier) Opt {
+	return func(c *Config) {
+		c.classifier = classifier
+	}
+}
+
+// CircuitBreaker opens a provider's circuit after failureThreshold consecutive
+// failures, skipping it until cooldown has passed. A threshold of 0 disables it.
+func CircuitBreaker(failureThreshold int, cooldown time.Duration) Opt {
+	return func(c *Config) {
+		c.failureThreshold = failureThreshold
+		c.cooldown = cooldown
+	}
+}
+
+// OnReport sets a callback which is called with the report of every call,
+// including those made through Generate.
+func OnReport(onReport func(Report)) Opt {
+	return func(c *Config) {
+		c.onReport = onReport
+	}
+}
+
+// NewEmbeddingGenerator creates a generator which tries primary first, then
+// each of the secondaries in order. It fails if the providers report different
+// dimensions or models, unless AllowIncompatible is given.
+func NewEmbeddingGenerator(primary Provider, secondaries []Provider, opts ...Opt) (*EmbeddingGenerator, error) {
+	conf := Config{
+		allowIncompatible: false,
+		classifier:        DefaultErrorClassifier,
+		failureThreshold:  5,
+		cooldown:          30 * time.Second,
+		onReport:          nil,
+		now:               time.Now,
+	}
+	for _, opt := range opts {
+		opt(&conf)
+	}
+
+	all := append([]Provider{primary}, secondaries...)
+	providers := make([]provider, 0, len(all))
+	names := make(map[string]struct{}, len(all))
+	for _, p := range all {
+		if p.Generator == nil {
+			return nil, fmt.Errorf("%w: provider %q has no generator", chroma.ErrInvalidInput, p.Name)
+		}
+		if _, ok := names[p.Name]; ok {
+			return nil, fmt.Errorf("%w: duplicate provider name %q", chroma.ErrInvalidInput, p.Name)
+		}
+		names[p.Name] = struct{}{}
+
+		if !conf.allowIncompatible {
+			if err := checkCompatible(primary, p); err != nil {
+				return nil, err
+			}
+		}
+
+		providers = append(providers, provider{p, newBreaker(conf.failureThreshold, conf.cooldown, conf.now)})
+	}
+
+	return &EmbeddingGenerator{
+		providers: providers,
+		conf:      conf,
+		lock:      &sync.Mutex{},
+		dimension: chroma.EmbeddingDimension(primary.Generator),
+	}, nil
+}
+
+func checkCompatible(primary, secondary Provider) error {
+	if a, b := chroma.EmbeddingDimension(primary.Generator), chroma.EmbeddingDimension(secondary.Generator); a != 0 && b != 0 && a != b {
+		return fmt.Errorf("%w: %q has dimension %d, %q has dimension %d", ErrIncompatibleProviders, primary.Name, a, secondary.Name, b)
+	}
+	if a, b := chroma.EmbeddingModel(primary.Generator), chroma.EmbeddingModel(secondary.Generator); a != "" && b != "" && a != b {
+		return fmt.Errorf("%w: %q uses model %q, %q uses model %q", ErrIncompatibleProviders, primary.Name, a, secondary.Name, b)
+	}
+	return nil
+}
+
+// Dimension returns the dimension of the primary provider.
+func (g *EmbeddingGenerator) Dimension() int {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	return g.dimension
+}
+
+// Model returns the model of the primary provider.
+func (g *EmbeddingGenerator) Model() string {
+	return chroma.EmbeddingModel(g.providers[0].Generator)
+}
+
+// OpenProviders returns the names of providers whose circuit is currently open.
+func (g *EmbeddingGenerator) OpenProviders() []string {
+	open := make([]string, 0)
+	for _, p := range g.providers {
+		if p.breaker.isOpen() {
+			open = append(open, p.Name)
+		}
+	}
+	return open
+}
+
+func (g *EmbeddingGenerator) Generate(ctx context.Context, documents []chroma.Document) ([]chroma.Embedding, error) {
+	return g.GenerateFor(ctx, chroma.EmbeddingPurposeDocument, documents)
+}
+
+func (g *EmbeddingGenerator) GenerateFor(ctx context.Context, purpose chroma.EmbeddingPurpose, documents []chroma.Document) ([]chroma.Embedding, error) {
+	embeddings, _, err := g.GenerateWithReport(ctx, purpose, documents)
+	return embeddings, err
+}
+
+// GenerateWithReport generates embeddings and reports which provider served them.
+func (g *EmbeddingGenerator) GenerateWithReport(ctx context.Context, purpose chroma.EmbeddingPurpose, documents []chroma.Document) ([]chroma.Embedding, Report, error) {
+	report := Report{Provider: "", Attempts: make([]Attempt, 0, len(g.providers))}
+	if g.conf.onReport != nil {
+		defer func() { g.conf.onReport(report) }()
+	}
+
+	logger := chroma.LoggerFromContext(ctx)
+	var lastErr error
+	for _, p := range g.providers {
+		if !p.breaker.allow() {
+			logger.Debug("skipping embedding provider with open circuit", "provider", p.Name)
+			report.Attempts = append(report.Attempts, Attempt{Provider: p.Name, Skipped: true, Err: nil})
+			continue
+		}
+
+		embeddings, err := chroma.GenerateEmbeddings(ctx, p.Generator, purpose, documents)
+		if err == nil {
+			err = g.checkDimension(embeddings, len(documents))
+		}
+		report.Attempts = append(report.Attempts, Attempt{Provider: p.Name, Skipped: false, Err: err})
+
+		if err == nil {
+			p.breaker.success()
+			report.Provider = p.Name
+			return embeddings, report, nil
+		}
+
+		if !g.conf.classifier(err) || ctx.Err() != nil {
+			p.breaker.release()
+			return nil, report, fmt.Errorf("generating embeddings with %q: %w", p.Name, err)
+		}
+
+		p.breaker.failure()
+		logger.Warn("embedding provider failed, falling back", "provider", p.Name, "error", err)
+		lastErr = fmt.Errorf("generating embeddings with %q: %w", p.Name, err)
+	}
+	logger.Error("all embedding providers failed", "documents", len(documents), "error", lastErr)
+
+	if lastErr == nil {
+		return nil, report, fmt.Errorf("%w: all circuits open", ErrAllProvidersFailed)
+	}
+	return nil, report, fmt.Errorf("%w: %w", ErrAllProvidersFailed, lastErr)
+}
+
+// checkDimension makes sure every provider returns embeddings of the same
+// dimension, even those which don't report one up front.
+func (g *EmbeddingGenerator) checkDimension(embeddings []chroma.Embedding, want int) error {
+	if len(embeddings) != want {
+		return fmt.Errorf("got %d embeddings, want %d", len(embeddings), want)
+	}
+	if len(embeddings) == 0 || g.conf.allowIncompatible {
+		return nil
+	}
+
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	dimension := g.dimension
+	if dimension == 0 {
+		dimension = len(embeddings[0])
+	}
+	for _, e := range embeddings {
+		if len(e) != dimension {
+			return fmt.Errorf("%w: got dimension %d, want %d", ErrIncompatibleProviders, len(e), dimension)
+		}
+	}
+	g.dimension = dimension
+	return nil
+}
@@ -0,0 +1,291 @@
+package fallback
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/kristofferostlund/chroma-go/chroma"
+)
+
+// fakeGenerator returns err, or embeddings of dimension dim.
+type fakeGenerator struct {
+	dim   int
+	err   error
+	calls int
+}
+
+func (g *fakeGenerator) Generate(ctx context.Context, documents []chroma.Document) ([]chroma.Embedding, error) {
+	g.calls++
+	if g.err != nil {
+		return nil, g.err
+	}
+	embeddings := make([]chroma.Embedding, 0, len(documents))
+	for range documents {
+		embeddings = append(embeddings, make(chroma.Embedding, g.dim))
+	}
+	return embeddings, nil
+}
+
+// dimensionGenerator also reports its dimension.
+type dimensionGenerator struct {
+	*fakeGenerator
+}
+
+func (g dimensionGenerator) Dimension() int { return g.dim }
+
+type clock struct {
+	t time.Time
+}
+
+func (c *clock) now() time.Time { return c.t }
+
+func withClock(c *clock) Opt {
+	return func(conf *Config) {
+		conf.now = c.now
+	}
+}
+
+var errDown = errors.New("provider down")
+
+func newGenerator(t *testing.T, primary *fakeGenerator, secondary *fakeGenerator, opts ...Opt) *EmbeddingGenerator {
+	t.Helper()
+	g, err := NewEmbeddingGenerator(
+		Provider{Name: "primary", Generator: primary},
+		[]Provider{{Name: "secondary", Generator: secondary}},
+		opts...,
+	)
+	if err != nil {
+		t.Fatalf("creating generator: %v", err)
+	}
+	return g
+}
+
+func attemptsOf(report Report) []string {
+	attempts := make([]string, 0, len(report.Attempts))
+	for _, a := range report.Attempts {
+		switch {
+		case a.Skipped:
+			attempts = append(attempts, a.Provider+":skipped")
+		case a.Err != nil:
+			attempts = append(attempts, a.Provider+":failed")
+		default:
+			attempts = append(attempts, a.Provider+":ok")
+		}
+	}
+	return attempts
+}
+
+func TestFailover(t *testing.T) {
+	primary, secondary := &fakeGenerator{dim: 2, err: errDown}, &fakeGenerator{dim: 2, err: nil}
+	var reported []Report
+	g := newGenerator(t, primary, secondary, OnReport(func(r Report) { reported = append(reported, r) }))
+
+	embeddings, report, err := g.GenerateWithReport(context.Background(), chroma.EmbeddingPurposeDocument, []chroma.Document{"a", "b"})
+	if err != nil {
+		t.Fatalf("generating: %v", err)
+	}
+	if len(embeddings) != 2 {
+		t.Errorf("got %d embeddings, want 2", len(embeddings))
+	}
+	if report.Provider != "secondary" {
+		t.Errorf("got provider %q, want secondary", report.Provider)
+	}
+	if got, want := attemptsOf(report), []string{"primary:failed", "secondary:ok"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got attempts %v, want %v", got, want)
+	}
+	if !errors.Is(report.Attempts[0].Err, errDown) {
+		t.Errorf("got primary error %v, want %v", report.Attempts[0].Err, errDown)
+	}
+	if _, err := g.Generate(context.Background(), []chroma.Document{"c"}); err != nil {
+		t.Fatalf("generating: %v", err)
+	}
+	if len(reported) != 2 {
+		t.Errorf("got %d reports, want one for each call", len(reported))
+	}
+
+	secondary.err = errDown
+	_, report, err = g.GenerateWithReport(context.Background(), chroma.EmbeddingPurposeDocument, []chroma.Document{"a"})
+	if !errors.Is(err, ErrAllProvidersFailed) || !errors.Is(err, errDown) {
+		t.Errorf("got error %v, want ErrAllProvidersFailed wrapping the last error", err)
+	}
+	if report.Provider != "" {
+		t.Errorf("got provider %q, want none", report.Provider)
+	}
+}
+
+func TestFailoverOnDimensionMismatch(t *testing.T) {
+	// The primary reports no dimension up front, so the first response sets it.
+	primary, secondary := &fakeGenerator{dim: 2, err: nil}, &fakeGenerator{dim: 3, err: nil}
+	g := newGenerator(t, primary, secondary)
+	if _, err := g.Generate(context.Background(), []chroma.Document{"a"}); err != nil {
+		t.Fatalf("generating: %v", err)
+	}
+
+	primary.err = errDown
+	_, err := g.Generate(context.Background(), []chroma.Document{"a"})
+	if !errors.Is(err, ErrIncompatibleProviders) {
+		t.Errorf("got error %v, want ErrIncompatibleProviders", err)
+	}
+	if got := g.Dimension(); got != 2 {
+		t.Errorf("got dimension %d, want 2", got)
+	}
+}
+
+func TestErrorClassification(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		err      error
+		opts     []Opt
+		fallback bool
+	}{
+		{"other errors", context.Background(), errDown, nil, true},
+		{"invalid input", context.Background(), fmt.Errorf("bad: %w", chroma.ErrInvalidInput), nil, false},
+		{"canceled", context.Background(), context.Canceled, nil, false},
+		{"deadline", context.Background(), fmt.Errorf("slow: %w", context.DeadlineExceeded), nil, false},
+		{"canceled context", canceled, errDown, nil, false},
+		{"classifier", context.Background(), errDown, []Opt{Classifier(func(err error) bool { return false })}, false},
+		{"classifier allowing invalid input", context.Background(), chroma.ErrInvalidInput, []Opt{Classifier(func(err error) bool { return true })}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			primary, secondary := &fakeGenerator{dim: 2, err: tt.err}, &fakeGenerator{dim: 2, err: nil}
+			g := newGenerator(t, primary, secondary, tt.opts...)
+
+			_, err := g.Generate(tt.ctx, []chroma.Document{"a"})
+			if fellBack := secondary.calls > 0; fellBack != tt.fallback {
+				t.Errorf("got fall back %v, want %v", fellBack, tt.fallback)
+			}
+			if tt.fallback && err != nil {
+				t.Errorf("got error %v after falling back", err)
+			}
+			if !tt.fallback && !errors.Is(err, tt.err) {
+				t.Errorf("got error %v, want %v", err, tt.err)
+			}
+			// Errors which don't fall back don't count towards the breaker.
+			if !tt.fallback && primary.calls == 1 {
+				if _, err := g.Generate(tt.ctx, []chroma.Document{"a"}); primary.calls != 2 {
+					t.Errorf("got primary skipped after an error not falling back, error %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	c := &clock{t: time.Unix(0, 0)}
+	primary, secondary := &fakeGenerator{dim: 2, err: errDown}, &fakeGenerator{dim: 2, err: nil}
+	g := newGenerator(t, primary, secondary, CircuitBreaker(2, time.Minute), withClock(c))
+
+	generate := func() []string {
+		t.Helper()
+		_, report, err := g.GenerateWithReport(context.Background(), chroma.EmbeddingPurposeDocument, []chroma.Document{"a"})
+		if err != nil {
+			t.Fatalf("generating: %v", err)
+		}
+		return attemptsOf(report)
+	}
+	check := func(got, want []string, open ...string) {
+		t.Helper()
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("got attempts %v, want %v", got, want)
+		}
+		if got := g.OpenProviders(); !reflect.DeepEqual(got, append([]string{}, open...)) {
+			t.Errorf("got open providers %v, want %v", got, open)
+		}
+	}
+
+	// Closed until two consecutive failures.
+	check(generate(), []string{"primary:failed", "secondary:ok"})
+	check(generate(), []string{"primary:failed", "secondary:ok"}, "primary")
+
+	// Open, skipped until the cooldown has passed.
+	c.t = c.t.Add(time.Minute - time.Second)
+	check(generate(), []string{"primary:skipped", "secondary:ok"}, "primary")
+
+	// Half-open, a failed trial opens it again right away.
+	c.t = c.t.Add(time.Second)
+	check(generate(), []string{"primary:failed", "secondary:ok"}, "primary")
+	check(generate(), []string{"primary:skipped", "secondary:ok"}, "primary")
+
+	// Half-open, a successful trial closes it.
+	c.t = c.t.Add(time.Minute)
+	primary.err = nil
+	check(generate(), []string{"primary:ok"})
+	primary.err = errDown
+	check(generate(), []string{"primary:failed", "secondary:ok"})
+	if primary.calls != 5 {
+		t.Errorf("got %d calls to the primary, want 5", primary.calls)
+	}
+
+	// All circuits open.
+	secondary.err = errDown
+	for i := 0; i < 3; i++ {
+		_, _ = g.Generate(context.Background(), []chroma.Document{"a"})
+	}
+	if got := g.OpenProviders(); !reflect.DeepEqual(got, []string{"primary", "secondary"}) {
+		t.Errorf("got open providers %v, want both", got)
+	}
+	if _, err := g.Generate(context.Background(), []chroma.Document{"a"}); !errors.Is(err, ErrAllProvidersFailed) {
+		t.Errorf("got error %v with all circuits open, want ErrAllProvidersFailed", err)
+	}
+}
+
+func TestBreakerSingleTrial(t *testing.T) {
+	c := &clock{t: time.Unix(0, 0)}
+	b := newBreaker(1, time.Minute, c.now)
+	b.failure()
+	if b.allow() {
+		t.Fatal("got an open breaker allowing a call")
+	}
+
+	c.t = c.t.Add(time.Minute)
+	if !b.allow() {
+		t.Fatal("got no trial call after the cooldown")
+	}
+	if b.allow() {
+		t.Error("got a second call allowed during the trial")
+	}
+	// A trial ending in an error not counted lets the next call try again.
+	b.release()
+	if !b.allow() {
+		t.Error("got no trial call after releasing the last one")
+	}
+	b.success()
+	if !b.allow() || !b.allow() {
+		t.Error("got calls refused by a closed breaker")
+	}
+
+	disabled := newBreaker(0, time.Minute, c.now)
+	for i := 0; i < 10; i++ {
+		disabled.failure()
+	}
+	if !disabled.allow() {
+		t.Error("got calls refused with the breaker disabled")
+	}
+}
+
+func TestIncompatibleProviders(t *testing.T) {
+	primary := Provider{Name: "primary", Generator: dimensionGenerator{&fakeGenerator{dim: 2, err: nil}}}
+	secondary := Provider{Name: "secondary", Generator: dimensionGenerator{&fakeGenerator{dim: 3, err: nil}}}
+
+	if _, err := NewEmbeddingGenerator(primary, []Provider{secondary}); !errors.Is(err, ErrIncompatibleProviders) {
+		t.Errorf("got error %v, want ErrIncompatibleProviders", err)
+	}
+	if _, err := NewEmbeddingGenerator(primary, []Provider{secondary}, AllowIncompatible()); err != nil {
+		t.Errorf("got error %v allowing incompatible providers", err)
+	}
+	if _, err := NewEmbeddingGenerator(primary, []Provider{{Name: "primary", Generator: secondary.Generator}}, AllowIncompatible()); !errors.Is(err, chroma.ErrInvalidInput) {
+		t.Errorf("got error %v for duplicate names, want ErrInvalidInput", err)
+	}
+	if _, err := NewEmbeddingGenerator(primary, []Provider{{Name: "none", Generator: nil}}); !errors.Is(err, chroma.ErrInvalidInput) {
+		t.Errorf("got error %v for a provider without a generator, want ErrInvalidInput", err)
+	}
+}
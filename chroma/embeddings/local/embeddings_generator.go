@@ -0,0 +1,165 @@
+// Package local provides embedding generators which run fully in-process
+// without any network access. The embeddings are deterministic, making
+// them suitable for tests, CI and offline demos, but they carry no semantic
+// understanding beyond shared words and word fragments.
+package local
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"github.com/kristofferostlund/chroma-go/chroma"
+)
+
+var _ chroma.EmbeddingGenerator = (*EmbeddingGenerator)(nil)
+
+const DefaultDimension = 384
+
+// EmbeddingGenerator embeds documents by hashing word and character n-grams
+// into a fixed number of dimensions, weighted by term frequency and
+// L2-normalized.
+type EmbeddingGenerator struct {
+	conf Config
+}
+
+type Config struct {
+	dimension   int
+	wordMin     int
+	wordMax     int
+	charMin     int
+	charMax     int
+	charWeight  float64
+	sublinearTF bool
+}
+
+type Opt func(c *Config)
+
+// Dimension sets the number of dimensions of the generated embeddings.
+func Dimension(dimension int) Opt {
+	return func(c *Config) {
+		c.dimension = dimension
+	}
+}
+
+// WordNGrams sets the range of word n-grams to use, 0, 0 disables them.
+func WordNGrams(min, max int) Opt {
+	return func(c *Config) {
+		c.wordMin, c.wordMax = min, max
+	}
+}
+
+// CharNGrams sets the range of character n-grams to use, 0, 0 disables them.
+func CharNGrams(min, max int) Opt {
+	return func(c *Config) {
+		c.charMin, c.charMax = min, max
+	}
+}
+
+// CharWeight sets the weight of character n-grams relative to word n-grams.
+func CharWeight(weight float64) Opt {
+	return func(c *Config) {
+		c.charWeight = weight
+	}
+}
+
+// SublinearTF uses 1 + log(tf) instead of the raw term frequency.
+func SublinearTF(sublinear bool) Opt {
+	return func(c *Config) {
+		c.sublinearTF = sublinear
+	}
+}
+
+func NewEmbeddingGenerator(opts ...Opt) *EmbeddingGenerator {
+	conf := Config{
+		dimension:   DefaultDimension,
+		wordMin:     1,
+		wordMax:     2,
+		charMin:     3,
+		charMax:     5,
+		charWeight:  0.5,
+		sublinearTF: true,
+	}
+	for _, opt := range opts {
+		opt(&conf)
+	}
+	if conf.dimension <= 0 {
+		conf.dimension = DefaultDimension
+	}
+
+	return &EmbeddingGenerator{conf: conf}
+}
+
+// Dimension returns the number of dimensions of the generated embeddings.
+func (e *EmbeddingGenerator) Dimension() int {
+	return e.conf.dimension
+}
+
+// Model identifies the hashing configuration, since embeddings are only
+// comparable between generators with the same configuration.
+func (e *EmbeddingGenerator) Model() string {
+	c := e.conf
+	return fmt.Sprintf("local/hashing-d%d-w%d.%d-c%d.%d-cw%g-s%t", c.dimension, c.wordMin, c.wordMax, c.charMin, c.charMax, c.charWeight, c.sublinearTF)
+}
+
+func (e *EmbeddingGenerator) Generate(ctx context.Context, documents []chroma.Document) ([]chroma.Embedding, error) {
+	embeddings := make([]chroma.Embedding, 0, len(documents))
+	for _, doc := range documents {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		embeddings = append(embeddings, e.embed(doc))
+	}
+	return embeddings, nil
+}
+
+func (e *EmbeddingGenerator) embed(doc chroma.Document) chroma.Embedding {
+	ws := words(doc)
+	counts := make(map[uint64]float64)
+	for _, g := range wordNGrams(ws, e.conf.wordMin, e.conf.wordMax) {
+		counts[hashFeature('w', g)]++
+	}
+
+	charCounts := make(map[uint64]float64)
+	for _, g := range charNGrams(ws, e.conf.charMin, e.conf.charMax) {
+		charCounts[hashFeature('c', g)]++
+	}
+
+	embedding := make(chroma.Embedding, e.conf.dimension)
+	e.accumulate(embedding, counts, 1)
+	e.accumulate(embedding, charCounts, e.conf.charWeight)
+
+	return normalize(embedding)
+}
+
+func (e *EmbeddingGenerator) accumulate(embedding chroma.Embedding, counts map[uint64]float64, weight float64) {
+	dim := uint64(len(embedding))
+	for h, tf := range counts {
+		if e.conf.sublinearTF {
+			tf = 1 + math.Log(tf)
+		}
+		// Use a separate bit of the hash as the sign so collisions tend to
+		// cancel out rather than accumulate.
+		sign := 1.0
+		if (h>>63)&1 == 1 {
+			sign = -1.0
+		}
+		embedding[h%dim] += sign * tf * weight
+	}
+}
+
+func normalize(embedding chroma.Embedding) chroma.Embedding {
+	var sum float64
+	for _, v := range embedding {
+		sum += v * v
+	}
+	if sum == 0 {
+		return embedding
+	}
+
+	norm := math.Sqrt(sum)
+	for i := range embedding {
+		embedding[i] /= norm
+	}
+	return embedding
+}
@@ -0,0 +1,159 @@
+package local_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/kristofferostlund/chroma-go/chroma"
+	"github.com/kristofferostlund/chroma-go/chroma/embeddings/local"
+	"github.com/kristofferostlund/chroma-go/chroma/vecmath"
+)
+
+var corpus = []chroma.Document{
+	"The cat sat on the mat.",
+	"A cat sat on a mat.",
+	"Quarterly revenue grew by ten percent.",
+	"Revenue for the quarter increased ten percent.",
+	"Bake the bread at two hundred degrees.",
+}
+
+func generate(t *testing.T, g chroma.EmbeddingGenerator, documents ...chroma.Document) []chroma.Embedding {
+	t.Helper()
+	embeddings, err := g.Generate(context.Background(), documents)
+	if err != nil {
+		t.Fatalf("generating: %v", err)
+	}
+	if len(embeddings) != len(documents) {
+		t.Fatalf("got %d embeddings, want %d", len(embeddings), len(documents))
+	}
+	return embeddings
+}
+
+// checkEmbeddings checks that embeddings are deterministic, of dimension and
+// normalized, and that similar texts are nearer than unrelated ones.
+func checkEmbeddings(t *testing.T, g chroma.EmbeddingGenerator, dimension int) {
+	t.Helper()
+	embeddings := generate(t, g, corpus...)
+	if again := generate(t, g, corpus...); !reflect.DeepEqual(again, embeddings) {
+		t.Error("got different embeddings for the same input")
+	}
+
+	for i, e := range embeddings {
+		if len(e) != dimension {
+			t.Errorf("got dimension %d for %q, want %d", len(e), corpus[i], dimension)
+		}
+		if norm := vecmath.Norm(e); math.Abs(norm-1) > 1e-9 {
+			t.Errorf("got norm %g for %q, want 1", norm, corpus[i])
+		}
+	}
+	if got := chroma.EmbeddingDimension(g); got != dimension {
+		t.Errorf("got reported dimension %d, want %d", got, dimension)
+	}
+
+	// Each text is nearest the other one about the same topic.
+	for i, similar := range map[int]int{0: 1, 1: 0, 2: 3, 3: 2} {
+		e := embeddings[i]
+		for j, other := range embeddings {
+			if j == i || j == similar {
+				continue
+			}
+			if near, far := vecmath.Cosine(e, embeddings[similar]), vecmath.Cosine(e, other); near >= far {
+				t.Errorf("got %q nearer %q (%g) than %q (%g)", corpus[i], corpus[j], far, corpus[similar], near)
+			}
+		}
+	}
+}
+
+func TestEmbeddingGenerator(t *testing.T) {
+	checkEmbeddings(t, local.NewEmbeddingGenerator(), local.DefaultDimension)
+	checkEmbeddings(t, local.NewEmbeddingGenerator(local.Dimension(64)), 64)
+
+	// A new generator with the same configuration gives the same embeddings.
+	a := generate(t, local.NewEmbeddingGenerator(local.Dimension(64)), corpus[0])
+	b := generate(t, local.NewEmbeddingGenerator(local.Dimension(64)), corpus[0])
+	if !reflect.DeepEqual(a, b) {
+		t.Error("got different embeddings from generators with the same configuration")
+	}
+	if local.NewEmbeddingGenerator().Model() == local.NewEmbeddingGenerator(local.CharNGrams(0, 0)).Model() {
+		t.Error("got the same model for different configurations")
+	}
+
+	empty := generate(t, local.NewEmbeddingGenerator(local.Dimension(8)), "")
+	if !reflect.DeepEqual(empty[0], make(chroma.Embedding, 8)) {
+		t.Errorf("got %v for an empty document, want zeros", empty[0])
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := local.NewEmbeddingGenerator().Generate(ctx, corpus); !errors.Is(err, context.Canceled) {
+		t.Errorf("got error %v with a cancelled context, want context.Canceled", err)
+	}
+}
+
+func TestTFIDFEmbeddingGenerator(t *testing.T) {
+	g, err := local.FitTFIDF(corpus)
+	if err != nil {
+		t.Fatalf("fitting: %v", err)
+	}
+	checkEmbeddings(t, g, g.Dimension())
+
+	limited, err := local.FitTFIDF(corpus, local.MaxFeatures(5), local.MinDF(2))
+	if err != nil {
+		t.Fatalf("fitting: %v", err)
+	}
+	if got := limited.Dimension(); got == 0 || got > 5 {
+		t.Errorf("got dimension %d, want at most 5", got)
+	}
+	if unknown := generate(t, g, "zebra xylophone"); !reflect.DeepEqual(unknown[0], make(chroma.Embedding, g.Dimension())) {
+		t.Errorf("got %v for terms outside the vocabulary, want zeros", unknown[0])
+	}
+
+	for _, bad := range [][]chroma.Document{nil, {"", "!"}} {
+		if _, err := local.FitTFIDF(bad); !errors.Is(err, chroma.ErrInvalidInput) {
+			t.Errorf("got error %v fitting %q, want ErrInvalidInput", err, bad)
+		}
+	}
+}
+
+func TestTFIDFSaveLoad(t *testing.T) {
+	g, err := local.FitTFIDF(corpus, local.TFIDFWordNGrams(1, 2))
+	if err != nil {
+		t.Fatalf("fitting: %v", err)
+	}
+	want := generate(t, g, corpus...)
+
+	var buf bytes.Buffer
+	if err := g.Save(&buf); err != nil {
+		t.Fatalf("saving: %v", err)
+	}
+	loaded, err := local.LoadTFIDF(&buf)
+	if err != nil {
+		t.Fatalf("loading: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "tfidf.json")
+	if err := g.SaveFile(path); err != nil {
+		t.Fatalf("saving file: %v", err)
+	}
+	loadedFile, err := local.LoadTFIDFFile(path)
+	if err != nil {
+		t.Fatalf("loading file: %v", err)
+	}
+
+	for _, l := range []*local.TFIDFEmbeddingGenerator{loaded, loadedFile} {
+		if l.Model() != g.Model() || l.Dimension() != g.Dimension() {
+			t.Errorf("got model %s of dimension %d, want %s of %d", l.Model(), l.Dimension(), g.Model(), g.Dimension())
+		}
+		if got := generate(t, l, corpus...); !reflect.DeepEqual(got, want) {
+			t.Error("got different embeddings after loading")
+		}
+	}
+
+	if _, err := local.LoadTFIDF(bytes.NewBufferString(`{"version": 1, "vocabulary": ["a", "b"], "idf": [1]}`)); err == nil {
+		t.Error("got no error loading a model with mismatched idf values")
+	}
+}
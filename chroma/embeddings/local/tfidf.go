@@ -0,0 +1,247 @@
+package local
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"os"
+	"sort"
+
+	"github.com/kristofferostlund/chroma-go/chroma"
+)
+
+var _ chroma.EmbeddingGenerator = (*TFIDFEmbeddingGenerator)(nil)
+
+// TFIDFEmbeddingGenerator embeds documents as TF-IDF weighted vectors over a
+// vocabulary fitted on a corpus. Unlike EmbeddingGenerator it has no hash
+// collisions, but it has to be fitted up front and terms outside of the
+// vocabulary are ignored.
+type TFIDFEmbeddingGenerator struct {
+	model tfidfModel
+	index map[string]int
+}
+
+// tfidfModel is the serialized form of the generator.
+type tfidfModel struct {
+	Version     int       `json:"version"`
+	WordMin     int       `json:"word_min"`
+	WordMax     int       `json:"word_max"`
+	SublinearTF bool      `json:"sublinear_tf"`
+	Vocabulary  []string  `json:"vocabulary"`
+	IDF         []float64 `json:"idf"`
+}
+
+const tfidfModelVersion = 1
+
+type TFIDFConfig struct {
+	maxFeatures int
+	minDF       int
+	wordMin     int
+	wordMax     int
+	sublinearTF bool
+}
+
+type TFIDFOpt func(c *TFIDFConfig)
+
+// MaxFeatures limits the vocabulary to the given number of terms with the
+// highest document frequency, which is also the embedding dimension.
+func MaxFeatures(maxFeatures int) TFIDFOpt {
+	return func(c *TFIDFConfig) {
+		c.maxFeatures = maxFeatures
+	}
+}
+
+// MinDF ignores terms which occur in fewer than minDF documents.
+func MinDF(minDF int) TFIDFOpt {
+	return func(c *TFIDFConfig) {
+		c.minDF = minDF
+	}
+}
+
+// TFIDFWordNGrams sets the range of word n-grams in the vocabulary.
+func TFIDFWordNGrams(min, max int) TFIDFOpt {
+	return func(c *TFIDFConfig) {
+		c.wordMin, c.wordMax = min, max
+	}
+}
+
+// TFIDFSublinearTF uses 1 + log(tf) instead of the raw term frequency.
+func TFIDFSublinearTF(sublinear bool) TFIDFOpt {
+	return func(c *TFIDFConfig) {
+		c.sublinearTF = sublinear
+	}
+}
+
+// FitTFIDF builds a vocabulary and inverse document frequencies from corpus.
+func FitTFIDF(corpus []chroma.Document, opts ...TFIDFOpt) (*TFIDFEmbeddingGenerator, error) {
+	conf := TFIDFConfig{
+		maxFeatures: 4096,
+		minDF:       1,
+		wordMin:     1,
+		wordMax:     1,
+		sublinearTF: true,
+	}
+	for _, opt := range opts {
+		opt(&conf)
+	}
+
+	if len(corpus) == 0 {
+		return nil, fmt.Errorf("%w: empty corpus", chroma.ErrInvalidInput)
+	}
+
+	df := make(map[string]int)
+	for _, doc := range corpus {
+		seen := make(map[string]struct{})
+		for _, term := range wordNGrams(words(doc), conf.wordMin, conf.wordMax) {
+			if _, ok := seen[term]; ok {
+				continue
+			}
+			seen[term] = struct{}{}
+			df[term]++
+		}
+	}
+
+	terms := make([]string, 0, len(df))
+	for term, n := range df {
+		if n >= conf.minDF {
+			terms = append(terms, term)
+		}
+	}
+	// Sort by document frequency, then alphabetically, so fitting is deterministic.
+	sort.Slice(terms, func(i, j int) bool {
+		if df[terms[i]] != df[terms[j]] {
+			return df[terms[i]] > df[terms[j]]
+		}
+		return terms[i] < terms[j]
+	})
+	if conf.maxFeatures > 0 && len(terms) > conf.maxFeatures {
+		terms = terms[:conf.maxFeatures]
+	}
+	if len(terms) == 0 {
+		return nil, fmt.Errorf("%w: no terms in corpus", chroma.ErrInvalidInput)
+	}
+	sort.Strings(terms)
+
+	n := float64(len(corpus))
+	idf := make([]float64, len(terms))
+	for i, term := range terms {
+		// Smoothed idf, as if an extra document contained every term once.
+		idf[i] = math.Log((1+n)/(1+float64(df[term]))) + 1
+	}
+
+	return newTFIDF(tfidfModel{
+		Version:     tfidfModelVersion,
+		WordMin:     conf.wordMin,
+		WordMax:     conf.wordMax,
+		SublinearTF: conf.sublinearTF,
+		Vocabulary:  terms,
+		IDF:         idf,
+	})
+}
+
+// LoadTFIDF reads a generator previously written with Save.
+func LoadTFIDF(r io.Reader) (*TFIDFEmbeddingGenerator, error) {
+	var model tfidfModel
+	if err := json.NewDecoder(r).Decode(&model); err != nil {
+		return nil, fmt.Errorf("decoding model: %w", err)
+	}
+	return newTFIDF(model)
+}
+
+// LoadTFIDFFile reads a generator previously written with SaveFile.
+func LoadTFIDFFile(path string) (*TFIDFEmbeddingGenerator, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening model: %w", err)
+	}
+	defer f.Close()
+
+	return LoadTFIDF(f)
+}
+
+func newTFIDF(model tfidfModel) (*TFIDFEmbeddingGenerator, error) {
+	if model.Version != tfidfModelVersion {
+		return nil, fmt.Errorf("unsupported model version %d, want %d", model.Version, tfidfModelVersion)
+	}
+	if len(model.Vocabulary) != len(model.IDF) {
+		return nil, fmt.Errorf("invalid model: %d terms but %d idf values", len(model.Vocabulary), len(model.IDF))
+	}
+
+	index := make(map[string]int, len(model.Vocabulary))
+	for i, term := range model.Vocabulary {
+		index[term] = i
+	}
+	return &TFIDFEmbeddingGenerator{model: model, index: index}, nil
+}
+
+// Save writes the fitted vocabulary and idf values as JSON.
+func (t *TFIDFEmbeddingGenerator) Save(w io.Writer) error {
+	if err := json.NewEncoder(w).Encode(t.model); err != nil {
+		return fmt.Errorf("encoding model: %w", err)
+	}
+	return nil
+}
+
+// SaveFile writes the fitted vocabulary and idf values to path.
+func (t *TFIDFEmbeddingGenerator) SaveFile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("creating model file: %w", err)
+	}
+
+	if err := t.Save(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Dimension returns the number of dimensions of the generated embeddings,
+// which is the size of the vocabulary.
+func (t *TFIDFEmbeddingGenerator) Dimension() int {
+	return len(t.model.Vocabulary)
+}
+
+// Model identifies the fitted vocabulary, since embeddings are only
+// comparable between generators fitted on the same corpus.
+func (t *TFIDFEmbeddingGenerator) Model() string {
+	h := fnv.New64a()
+	for _, term := range t.model.Vocabulary {
+		h.Write([]byte(term))
+		h.Write([]byte{0})
+	}
+	return fmt.Sprintf("local/tfidf-%x", h.Sum64())
+}
+
+func (t *TFIDFEmbeddingGenerator) Generate(ctx context.Context, documents []chroma.Document) ([]chroma.Embedding, error) {
+	embeddings := make([]chroma.Embedding, 0, len(documents))
+	for _, doc := range documents {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		embeddings = append(embeddings, t.embed(doc))
+	}
+	return embeddings, nil
+}
+
+func (t *TFIDFEmbeddingGenerator) embed(doc chroma.Document) chroma.Embedding {
+	counts := make(map[int]float64)
+	for _, term := range wordNGrams(words(doc), t.model.WordMin, t.model.WordMax) {
+		if i, ok := t.index[term]; ok {
+			counts[i]++
+		}
+	}
+
+	embedding := make(chroma.Embedding, len(t.model.Vocabulary))
+	for i, tf := range counts {
+		if t.model.SublinearTF {
+			tf = 1 + math.Log(tf)
+		}
+		embedding[i] = tf * t.model.IDF[i]
+	}
+
+	return normalize(embedding)
+}
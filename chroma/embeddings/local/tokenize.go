@@ -0,0 +1,56 @@
+package local
+
+import (
+	"hash/fnv"
+	"strings"
+	"unicode"
+)
+
+// words splits text into lower-cased runs of letters and digits.
+func words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// wordNGrams returns all word n-grams of length min through max.
+func wordNGrams(ws []string, min, max int) []string {
+	if min <= 0 || max < min {
+		return nil
+	}
+
+	grams := make([]string, 0, len(ws)*(max-min+1))
+	for n := min; n <= max; n++ {
+		for i := 0; i+n <= len(ws); i++ {
+			grams = append(grams, strings.Join(ws[i:i+n], " "))
+		}
+	}
+	return grams
+}
+
+// charNGrams returns all character n-grams of length min through max for each
+// word, padded with < and > so prefixes and suffixes get their own features.
+func charNGrams(ws []string, min, max int) []string {
+	if min <= 0 || max < min {
+		return nil
+	}
+
+	grams := make([]string, 0)
+	for _, w := range ws {
+		runes := []rune("<" + w + ">")
+		for n := min; n <= max; n++ {
+			for i := 0; i+n <= len(runes); i++ {
+				grams = append(grams, string(runes[i:i+n]))
+			}
+		}
+	}
+	return grams
+}
+
+func hashFeature(prefix byte, feature string) uint64 {
+	h := fnv.New64a()
+	// The prefix keeps word and char features from colliding on identical strings.
+	h.Write([]byte{prefix})
+	h.Write([]byte(feature))
+	return h.Sum64()
+}
@@ -3,6 +3,7 @@ package openai
 import (
 	"context"
 	"fmt"
+	"net/http"
 
 	"github.com/kristofferostlund/chroma-go/chroma"
 	"github.com/sashabaranov/go-openai"
@@ -16,9 +17,10 @@ type EmbeddingGenerator struct {
 }
 
 type Config struct {
-	authToken string
-	orgID     string
-	model     openai.EmbeddingModel
+	authToken  string
+	orgID      string
+	model      openai.EmbeddingModel
+	httpClient *http.Client
 }
 
 func (c *Config) OpenAIConfig() openai.ClientConfig {
@@ -26,6 +28,9 @@ func (c *Config) OpenAIConfig() openai.ClientConfig {
 	if c.orgID != "" {
 		conf.OrgID = c.orgID
 	}
+	if c.httpClient != nil {
+		conf.HTTPClient = c.httpClient
+	}
 	return conf
 }
 
@@ -43,11 +48,18 @@ func OrgID(orgID string) Opt {
 	}
 }
 
+func HTTPClient(client *http.Client) Opt {
+	return func(c *Config) {
+		c.httpClient = client
+	}
+}
+
 func NewEmbeddingGenerator(authToken string, opts ...Opt) *EmbeddingGenerator {
 	conf := &Config{
-		authToken: authToken,
-		orgID:     "",
-		model:     openai.AdaEmbeddingV2,
+		authToken:  authToken,
+		orgID:      "",
+		model:      openai.AdaEmbeddingV2,
+		httpClient: nil,
 	}
 	for _, opt := range opts {
 		opt(conf)
@@ -56,6 +68,21 @@ func NewEmbeddingGenerator(authToken string, opts ...Opt) *EmbeddingGenerator {
 	return &EmbeddingGenerator{openai: openai.NewClientWithConfig(conf.OpenAIConfig()), model: conf.model}
 }
 
+// knownDimensions maps models to the dimension of their embeddings.
+var knownDimensions = map[openai.EmbeddingModel]int{
+	openai.AdaEmbeddingV2: 1536,
+}
+
+// Model returns the OpenAI model used to generate embeddings.
+func (e *EmbeddingGenerator) Model() string {
+	return "openai/" + e.model.String()
+}
+
+// Dimension returns the dimension of the model's embeddings, or 0 if unknown.
+func (e *EmbeddingGenerator) Dimension() int {
+	return knownDimensions[e.model]
+}
+
 func (e *EmbeddingGenerator) Generate(ctx context.Context, documents []chroma.Document) ([]chroma.Embedding, error) {
 	resp, err := e.openai.CreateEmbeddings(ctx, openai.EmbeddingRequest{
 		Input: documents,
@@ -0,0 +1,107 @@
+package postprocess
+
+import (
+	"math"
+
+	"github.com/kristofferostlund/chroma-go/chroma"
+)
+
+// Codec converts embeddings to and from a compact in-memory representation,
+// for example for storage in caches. Decoded embeddings are always widened
+// back to chroma.Embedding.
+type Codec interface {
+	Encode(embedding chroma.Embedding) interface{}
+	Decode(encoded interface{}) chroma.Embedding
+}
+
+var (
+	// Float64Codec stores embeddings as they are.
+	Float64Codec Codec = float64Codec{}
+	// Float32Codec stores embeddings as float32, halving their size.
+	// Most providers, including OpenAI, produce float32 values anyway.
+	Float32Codec Codec = float32Codec{}
+	// Int8Codec stores embeddings with 8 bit scalar quantization, using
+	// about an eighth of the size at the cost of precision.
+	Int8Codec Codec = int8Codec{}
+)
+
+type float64Codec struct{}
+
+func (float64Codec) Encode(embedding chroma.Embedding) interface{} { return embedding }
+func (float64Codec) Decode(encoded interface{}) chroma.Embedding   { return encoded.(chroma.Embedding) }
+
+type float32Codec struct{}
+
+func (float32Codec) Encode(embedding chroma.Embedding) interface{} {
+	return ToFloat32(embedding)
+}
+
+func (float32Codec) Decode(encoded interface{}) chroma.Embedding {
+	return FromFloat32(encoded.([]float32))
+}
+
+type int8Codec struct{}
+
+func (int8Codec) Encode(embedding chroma.Embedding) interface{} {
+	return QuantizeInt8(embedding)
+}
+
+func (int8Codec) Decode(encoded interface{}) chroma.Embedding {
+	return encoded.(Int8Embedding).Dequantize()
+}
+
+// ToFloat32 narrows an embedding to float32.
+func ToFloat32(embedding chroma.Embedding) []float32 {
+	out := make([]float32, len(embedding))
+	for i, v := range embedding {
+		out[i] = float32(v)
+	}
+	return out
+}
+
+// FromFloat32 widens a float32 embedding to chroma.Embedding.
+func FromFloat32(embedding []float32) chroma.Embedding {
+	out := make(chroma.Embedding, len(embedding))
+	for i, v := range embedding {
+		out[i] = float64(v)
+	}
+	return out
+}
+
+// Int8Embedding is an embedding quantized to 8 bits, where each value is
+// approximately Values[i] * Scale.
+type Int8Embedding struct {
+	Values []int8
+	Scale  float32
+}
+
+// QuantizeInt8 quantizes embedding symmetrically around zero, scaled by its
+// largest absolute value.
+func QuantizeInt8(embedding chroma.Embedding) Int8Embedding {
+	var maxAbs float64
+	for _, v := range embedding {
+		maxAbs = math.Max(maxAbs, math.Abs(v))
+	}
+
+	q := Int8Embedding{Values: make([]int8, len(embedding)), Scale: 0}
+	if maxAbs == 0 {
+		return q
+	}
+
+	q.Scale = float32(maxAbs / math.MaxInt8)
+	for i, v := range embedding {
+		// Clamp since the float32 scale may round down slightly.
+		r := math.Max(-math.MaxInt8, math.Min(math.MaxInt8, math.Round(v/float64(q.Scale))))
+		q.Values[i] = int8(r)
+	}
+	return q
+}
+
+// Dequantize widens the quantized embedding back to chroma.Embedding.
+func (q Int8Embedding) Dequantize() chroma.Embedding {
+	out := make(chroma.Embedding, len(q.Values))
+	for i, v := range q.Values {
+		out[i] = float64(v) * float64(q.Scale)
+	}
+	return out
+}
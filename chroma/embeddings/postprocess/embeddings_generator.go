@@ -0,0 +1,99 @@
+// Package postprocess provides an embedding generator wrapper which
+// transforms embeddings after they're generated, such as normalizing,
+// truncating or quantizing them, and codecs for storing embeddings compactly.
+package postprocess
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/kristofferostlund/chroma-go/chroma"
+)
+
+var _ chroma.PurposeEmbeddingGenerator = (*EmbeddingGenerator)(nil)
+
+// EmbeddingGenerator applies steps in order to every embedding generated by
+// the wrapped generator.
+type EmbeddingGenerator struct {
+	generator chroma.EmbeddingGenerator
+	steps     []Step
+}
+
+// NewEmbeddingGenerator wraps generator, failing with chroma.ErrInvalidInput
+// if a step's parameters are invalid.
+func NewEmbeddingGenerator(generator chroma.EmbeddingGenerator, steps ...Step) (*EmbeddingGenerator, error) {
+	for _, s := range steps {
+		if v, ok := s.(validator); ok {
+			if err := v.validate(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return &EmbeddingGenerator{generator: generator, steps: steps}, nil
+}
+
+// validator is implemented by steps with parameters which can be invalid.
+type validator interface {
+	validate() error
+}
+
+// Dimension returns the dimension after all steps, or 0 if unknown.
+func (e *EmbeddingGenerator) Dimension() int {
+	d := chroma.EmbeddingDimension(e.generator)
+	for _, s := range e.steps {
+		d = s.Dimension(d)
+	}
+	return d
+}
+
+// Model returns the wrapped generator's model followed by the steps,
+// or "" if the wrapped model is unknown.
+func (e *EmbeddingGenerator) Model() string {
+	model := chroma.EmbeddingModel(e.generator)
+	if model == "" || len(e.steps) == 0 {
+		return model
+	}
+
+	names := make([]string, 0, len(e.steps))
+	for _, s := range e.steps {
+		names = append(names, s.Name())
+	}
+	return model + "+" + strings.Join(names, "+")
+}
+
+func (e *EmbeddingGenerator) Generate(ctx context.Context, documents []chroma.Document) ([]chroma.Embedding, error) {
+	return e.GenerateFor(ctx, chroma.EmbeddingPurposeDocument, documents)
+}
+
+func (e *EmbeddingGenerator) GenerateFor(ctx context.Context, purpose chroma.EmbeddingPurpose, documents []chroma.Document) ([]chroma.Embedding, error) {
+	embeddings, err := chroma.GenerateEmbeddings(ctx, e.generator, purpose, documents)
+	if err != nil {
+		return nil, err
+	}
+
+	processed, err := e.Apply(embeddings)
+	if err != nil {
+		chroma.LoggerFromContext(ctx).Error("post-processing embeddings", "model", e.Model(), "error", err)
+		return nil, err
+	}
+	return processed, nil
+}
+
+// Apply runs the steps on already generated embeddings, for example ones
+// passed explicitly to Collection.Add.
+func (e *EmbeddingGenerator) Apply(embeddings []chroma.Embedding) ([]chroma.Embedding, error) {
+	out := make([]chroma.Embedding, 0, len(embeddings))
+	for i, embedding := range embeddings {
+		// Copy so the caller's embeddings aren't modified in place.
+		processed := append(chroma.Embedding(nil), embedding...)
+		for _, s := range e.steps {
+			var err error
+			if processed, err = s.Apply(processed); err != nil {
+				return nil, fmt.Errorf("processing embedding %d with %s: %w", i, s.Name(), err)
+			}
+		}
+		out = append(out, processed)
+	}
+	return out, nil
+}
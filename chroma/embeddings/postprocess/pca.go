@@ -0,0 +1,223 @@
+package postprocess
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"math/rand"
+	"os"
+
+	"github.com/kristofferostlund/chroma-go/chroma"
+)
+
+// Projection is a linear projection to fewer dimensions, typically fitted
+// with FitPCA on a sample of embeddings. It has to be stored alongside the
+// collection, since every embedding, including queries, must be projected
+// with the same projection.
+type Projection struct {
+	Mean       []float64   `json:"mean"`
+	Components [][]float64 `json:"components"`
+}
+
+type PCAConfig struct {
+	iterations int
+	seed       int64
+}
+
+type PCAOpt func(c *PCAConfig)
+
+// Iterations sets the number of subspace iterations used to find the components.
+func Iterations(iterations int) PCAOpt {
+	return func(c *PCAConfig) {
+		c.iterations = iterations
+	}
+}
+
+// Seed sets the seed of the random starting point, fitting is deterministic
+// for a given seed and sample.
+func Seed(seed int64) PCAOpt {
+	return func(c *PCAConfig) {
+		c.seed = seed
+	}
+}
+
+// FitPCA finds the dimension principal components of embeddings using
+// subspace iteration. It is meant to be run offline on a representative sample.
+func FitPCA(embeddings []chroma.Embedding, dimension int, opts ...PCAOpt) (*Projection, error) {
+	conf := PCAConfig{iterations: 30, seed: 1}
+	for _, opt := range opts {
+		opt(&conf)
+	}
+
+	if len(embeddings) == 0 {
+		return nil, fmt.Errorf("%w: no embeddings", chroma.ErrInvalidInput)
+	}
+	d := len(embeddings[0])
+	if dimension <= 0 || dimension > d {
+		return nil, fmt.Errorf("%w: dimension must be between 1 and %d, got %d", chroma.ErrInvalidInput, d, dimension)
+	}
+
+	mean := make([]float64, d)
+	for _, e := range embeddings {
+		if len(e) != d {
+			return nil, fmt.Errorf("%w: mixed dimensions %d and %d", chroma.ErrInvalidInput, d, len(e))
+		}
+		for i, v := range e {
+			mean[i] += v
+		}
+	}
+	for i := range mean {
+		mean[i] /= float64(len(embeddings))
+	}
+
+	centered := make([][]float64, len(embeddings))
+	for j, e := range embeddings {
+		centered[j] = make([]float64, d)
+		for i, v := range e {
+			centered[j][i] = v - mean[i]
+		}
+	}
+
+	rnd := rand.New(rand.NewSource(conf.seed))
+	basis := make([][]float64, dimension)
+	for k := range basis {
+		basis[k] = make([]float64, d)
+		for i := range basis[k] {
+			basis[k][i] = rnd.NormFloat64()
+		}
+	}
+	orthonormalize(basis)
+
+	for it := 0; it < conf.iterations; it++ {
+		// basis = X^T X basis, without forming the covariance matrix.
+		next := make([][]float64, dimension)
+		for k := range next {
+			next[k] = make([]float64, d)
+		}
+		for _, x := range centered {
+			for k, b := range basis {
+				p := dot(x, b)
+				for i, v := range x {
+					next[k][i] += p * v
+				}
+			}
+		}
+		orthonormalize(next)
+		basis = next
+	}
+
+	return &Projection{Mean: mean, Components: basis}, nil
+}
+
+// LoadProjection reads a projection previously written with Save.
+func LoadProjection(r io.Reader) (*Projection, error) {
+	var p Projection
+	if err := json.NewDecoder(r).Decode(&p); err != nil {
+		return nil, fmt.Errorf("decoding projection: %w", err)
+	}
+	for _, c := range p.Components {
+		if len(c) != len(p.Mean) {
+			return nil, fmt.Errorf("invalid projection: component of dimension %d, want %d", len(c), len(p.Mean))
+		}
+	}
+	return &p, nil
+}
+
+// LoadProjectionFile reads a projection previously written with SaveFile.
+func LoadProjectionFile(path string) (*Projection, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening projection: %w", err)
+	}
+	defer f.Close()
+
+	return LoadProjection(f)
+}
+
+// Save writes the projection as JSON.
+func (p *Projection) Save(w io.Writer) error {
+	if err := json.NewEncoder(w).Encode(p); err != nil {
+		return fmt.Errorf("encoding projection: %w", err)
+	}
+	return nil
+}
+
+// SaveFile writes the projection to path.
+func (p *Projection) SaveFile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("creating projection file: %w", err)
+	}
+
+	if err := p.Save(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Dimension returns the output dimension of the projection.
+func (p *Projection) Dimension() int {
+	return len(p.Components)
+}
+
+// ID identifies the projection by a hash of its values.
+func (p *Projection) ID() string {
+	h := fnv.New64a()
+	buf := make([]byte, 8)
+	write := func(vs []float64) {
+		for _, v := range vs {
+			bits := math.Float64bits(v)
+			for i := range buf {
+				buf[i] = byte(bits >> (8 * i))
+			}
+			h.Write(buf)
+		}
+	}
+	write(p.Mean)
+	for _, c := range p.Components {
+		write(c)
+	}
+	return fmt.Sprintf("%x", h.Sum64())
+}
+
+// Project centers embedding and projects it onto the components.
+func (p *Projection) Project(embedding chroma.Embedding) (chroma.Embedding, error) {
+	if len(embedding) != len(p.Mean) {
+		return nil, fmt.Errorf("%w: cannot project embedding of dimension %d, want %d", chroma.ErrInvalidInput, len(embedding), len(p.Mean))
+	}
+
+	centered := make([]float64, len(embedding))
+	for i, v := range embedding {
+		centered[i] = v - p.Mean[i]
+	}
+
+	out := make(chroma.Embedding, len(p.Components))
+	for k, c := range p.Components {
+		out[k] = dot(centered, c)
+	}
+	return out, nil
+}
+
+// orthonormalize applies modified Gram-Schmidt to vs in place.
+func orthonormalize(vs [][]float64) {
+	for k := range vs {
+		for j := 0; j < k; j++ {
+			p := dot(vs[k], vs[j])
+			for i := range vs[k] {
+				vs[k][i] -= p * vs[j][i]
+			}
+		}
+		normalize(vs[k])
+	}
+}
+
+func dot(a, b []float64) float64 {
+	var sum float64
+	for i := range a {
+		sum += a[i] * b[i]
+	}
+	return sum
+}
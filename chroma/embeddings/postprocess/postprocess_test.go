@@ -0,0 +1,241 @@
+package postprocess_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/kristofferostlund/chroma-go/chroma"
+	"github.com/kristofferostlund/chroma-go/chroma/embeddings/postprocess"
+)
+
+// fixedGenerator returns a copy of embedding for every document.
+type fixedGenerator struct {
+	embedding chroma.Embedding
+}
+
+func (g fixedGenerator) Generate(ctx context.Context, documents []chroma.Document) ([]chroma.Embedding, error) {
+	embeddings := make([]chroma.Embedding, 0, len(documents))
+	for range documents {
+		embeddings = append(embeddings, append(chroma.Embedding(nil), g.embedding...))
+	}
+	return embeddings, nil
+}
+
+func (g fixedGenerator) Dimension() int { return len(g.embedding) }
+func (g fixedGenerator) Model() string  { return "fixed" }
+
+func TestEmbeddingGenerator(t *testing.T) {
+	g, err := postprocess.NewEmbeddingGenerator(fixedGenerator{chroma.Embedding{3, 4, 12}}, postprocess.Truncate(2), postprocess.Normalize())
+	if err != nil {
+		t.Fatalf("creating generator: %v", err)
+	}
+	if got, want := g.Model(), "fixed+truncate2+l2"; got != want {
+		t.Errorf("got model %q, want %q", got, want)
+	}
+	if got := g.Dimension(); got != 2 {
+		t.Errorf("got dimension %d, want 2", got)
+	}
+	embeddings, err := g.Generate(context.Background(), []chroma.Document{"a"})
+	if err != nil {
+		t.Fatalf("generating: %v", err)
+	}
+	if want := (chroma.Embedding{0.6, 0.8}); !reflect.DeepEqual(embeddings[0], want) {
+		t.Errorf("got %v, want %v", embeddings[0], want)
+	}
+
+	input := []chroma.Embedding{{3, 4, 0}}
+	if _, err := g.Apply(input); err != nil {
+		t.Fatalf("applying: %v", err)
+	}
+	if want := (chroma.Embedding{3, 4, 0}); !reflect.DeepEqual(input[0], want) {
+		t.Errorf("got input modified to %v", input[0])
+	}
+	if _, err := g.Apply([]chroma.Embedding{{1}}); !errors.Is(err, chroma.ErrInvalidInput) {
+		t.Errorf("got error %v truncating a short embedding, want ErrInvalidInput", err)
+	}
+}
+
+func TestInvalidSteps(t *testing.T) {
+	for name, step := range map[string]postprocess.Step{
+		"truncate to zero":  postprocess.Truncate(0),
+		"nil projection":    postprocess.Project(nil),
+		"negative truncate": postprocess.Truncate(-1),
+	} {
+		if _, err := postprocess.NewEmbeddingGenerator(fixedGenerator{chroma.Embedding{1, 2}}, step); !errors.Is(err, chroma.ErrInvalidInput) {
+			t.Errorf("got error %v for %s, want ErrInvalidInput", err, name)
+		}
+		// Steps used without the generator fail rather than panic.
+		if _, err := step.Apply(chroma.Embedding{1, 2}); !errors.Is(err, chroma.ErrInvalidInput) {
+			t.Errorf("got error %v applying %s, want ErrInvalidInput", err, name)
+		}
+		_, _ = step.Name(), step.Dimension(2)
+	}
+}
+
+func randomEmbedding(rnd *rand.Rand, dim int) chroma.Embedding {
+	e := make(chroma.Embedding, dim)
+	for i := range e {
+		e[i] = rnd.NormFloat64()
+	}
+	return e
+}
+
+func TestCodecs(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		e := randomEmbedding(rnd, 64)
+
+		if got := postprocess.Float64Codec.Decode(postprocess.Float64Codec.Encode(e)); !reflect.DeepEqual(got, e) {
+			t.Fatalf("got %v from the float64 codec, want %v", got, e)
+		}
+
+		f32 := postprocess.Float32Codec.Decode(postprocess.Float32Codec.Encode(e))
+		step, _ := postprocess.Float32().Apply(append(chroma.Embedding(nil), e...))
+		if !reflect.DeepEqual(f32, step) {
+			t.Fatalf("got %v from the float32 codec, want the Float32 step's %v", f32, step)
+		}
+		for j, v := range f32 {
+			if diff := math.Abs(v - e[j]); diff > math.Abs(e[j])*1e-7 {
+				t.Fatalf("got float32 error %g for %g", diff, e[j])
+			}
+		}
+
+		encoded := postprocess.Int8Codec.Encode(e)
+		i8 := postprocess.Int8Codec.Decode(encoded)
+		step, _ = postprocess.Int8().Apply(append(chroma.Embedding(nil), e...))
+		if !reflect.DeepEqual(i8, step) {
+			t.Fatalf("got %v from the int8 codec, want the Int8 step's %v", i8, step)
+		}
+		// Rounding to the nearest step is off by at most half a step.
+		scale := float64(encoded.(postprocess.Int8Embedding).Scale)
+		for j, v := range i8 {
+			if diff := math.Abs(v - e[j]); diff > scale/2+1e-9 {
+				t.Fatalf("got int8 error %g for %g, want at most %g", diff, e[j], scale/2)
+			}
+		}
+	}
+}
+
+func TestQuantizeInt8(t *testing.T) {
+	q := postprocess.QuantizeInt8(chroma.Embedding{-2, 0, 1, 2})
+	if want := []int8{-127, 0, 64, 127}; !reflect.DeepEqual(q.Values, want) {
+		t.Errorf("got values %v, want %v", q.Values, want)
+	}
+	if got := q.Dequantize(); got[0] != -got[3] || math.Abs(got[3]-2) > 1e-6 {
+		t.Errorf("got %v, want the extremes kept", got)
+	}
+
+	zero := postprocess.QuantizeInt8(chroma.Embedding{0, 0})
+	if got := zero.Dequantize(); !reflect.DeepEqual(got, chroma.Embedding{0, 0}) {
+		t.Errorf("got %v for a zero embedding", got)
+	}
+}
+
+func TestFitPCA(t *testing.T) {
+	// Points spread along direction with a little noise, so the first
+	// component is direction and projecting recovers the offsets from
+	// their mean.
+	rnd := rand.New(rand.NewSource(1))
+	direction := chroma.Embedding{2.0 / 3, -2.0 / 3, 1.0 / 3}
+	mean := chroma.Embedding{1, 2, 3}
+	offsets := make([]float64, 0, 200)
+	embeddings := make([]chroma.Embedding, 0, 200)
+	for i := 0; i < 200; i++ {
+		offset := rnd.NormFloat64() * 10
+		e := make(chroma.Embedding, 3)
+		for j := range e {
+			e[j] = mean[j] + offset*direction[j] + rnd.NormFloat64()*0.01
+		}
+		offsets = append(offsets, offset)
+		embeddings = append(embeddings, e)
+	}
+
+	p, err := postprocess.FitPCA(embeddings, 2, postprocess.Seed(7))
+	if err != nil {
+		t.Fatalf("fitting: %v", err)
+	}
+	if p.Dimension() != 2 {
+		t.Errorf("got dimension %d, want 2", p.Dimension())
+	}
+	var cos float64
+	for i, v := range p.Components[0] {
+		cos += v * direction[i]
+	}
+	if math.Abs(math.Abs(cos)-1) > 1e-4 {
+		t.Errorf("got first component %v, want parallel to %v", p.Components[0], direction)
+	}
+	var orth float64
+	for i, v := range p.Components[0] {
+		orth += v * p.Components[1][i]
+	}
+	if math.Abs(orth) > 1e-9 {
+		t.Errorf("got components with dot product %g, want orthogonal", orth)
+	}
+
+	var meanOffset float64
+	for _, o := range offsets {
+		meanOffset += o / float64(len(offsets))
+	}
+	for i, e := range embeddings[:10] {
+		got, err := postprocess.Project(p).Apply(e)
+		if err != nil {
+			t.Fatalf("projecting: %v", err)
+		}
+		if want := math.Copysign(1, cos) * (offsets[i] - meanOffset); math.Abs(got[0]-want) > 0.05 || math.Abs(got[1]) > 0.05 {
+			t.Errorf("got projection %v, want [%g 0]", got, want)
+		}
+	}
+
+	again, err := postprocess.FitPCA(embeddings, 2, postprocess.Seed(7))
+	if err != nil {
+		t.Fatalf("fitting: %v", err)
+	}
+	if again.ID() != p.ID() {
+		t.Error("got different projections fitting with the same seed")
+	}
+
+	var buf bytes.Buffer
+	if err := p.Save(&buf); err != nil {
+		t.Fatalf("saving: %v", err)
+	}
+	loaded, err := postprocess.LoadProjection(&buf)
+	if err != nil {
+		t.Fatalf("loading: %v", err)
+	}
+	if loaded.ID() != p.ID() {
+		t.Error("got a different projection after loading")
+	}
+	if got, want := postprocess.Project(loaded).Name(), "proj-"+p.ID(); got != want {
+		t.Errorf("got step name %q, want %q", got, want)
+	}
+	if _, err := p.Project(chroma.Embedding{1, 2}); !errors.Is(err, chroma.ErrInvalidInput) {
+		t.Errorf("got error %v projecting the wrong dimension, want ErrInvalidInput", err)
+	}
+}
+
+func TestFitPCAInvalid(t *testing.T) {
+	tests := []struct {
+		name       string
+		embeddings []chroma.Embedding
+		dimension  int
+	}{
+		{"no embeddings", nil, 1},
+		{"zero dimension", []chroma.Embedding{{1, 2}}, 0},
+		{"too many dimensions", []chroma.Embedding{{1, 2}}, 3},
+		{"mixed dimensions", []chroma.Embedding{{1, 2}, {1}}, 1},
+	}
+	for _, tt := range tests {
+		if _, err := postprocess.FitPCA(tt.embeddings, tt.dimension); !errors.Is(err, chroma.ErrInvalidInput) {
+			t.Errorf("got error %v for %s, want ErrInvalidInput", err, tt.name)
+		}
+	}
+
+	if _, err := postprocess.LoadProjection(bytes.NewBufferString(`{"mean": [0, 0], "components": [[1]]}`)); err == nil {
+		t.Error("got no error loading a projection with mismatched dimensions")
+	}
+}
@@ -0,0 +1,153 @@
+package postprocess
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/kristofferostlund/chroma-go/chroma"
+)
+
+// Step transforms a single embedding.
+type Step interface {
+	// Apply transforms embedding, it may modify embedding in place.
+	Apply(embedding chroma.Embedding) (chroma.Embedding, error)
+	// Name identifies the step and its parameters. It is part of the wrapped
+	// generator's model identity, since embeddings processed differently
+	// can't be compared.
+	Name() string
+	// Dimension returns the output dimension for the given input dimension,
+	// 0 if unknown.
+	Dimension(in int) int
+}
+
+// Normalize scales embeddings to unit length, making cosine distance and
+// inner product equivalent.
+func Normalize() Step {
+	return normalizeStep{}
+}
+
+type normalizeStep struct{}
+
+func (normalizeStep) Apply(embedding chroma.Embedding) (chroma.Embedding, error) {
+	return normalize(embedding), nil
+}
+
+func (normalizeStep) Name() string         { return "l2" }
+func (normalizeStep) Dimension(in int) int { return in }
+
+func normalize(embedding chroma.Embedding) chroma.Embedding {
+	var sum float64
+	for _, v := range embedding {
+		sum += v * v
+	}
+	if sum == 0 {
+		return embedding
+	}
+
+	norm := math.Sqrt(sum)
+	for i := range embedding {
+		embedding[i] /= norm
+	}
+	return embedding
+}
+
+// Truncate keeps the first dimension values of each embedding, as supported
+// by models trained with Matryoshka representation learning. Follow it with
+// Normalize to restore unit length.
+func Truncate(dimension int) Step {
+	return truncateStep{dimension}
+}
+
+type truncateStep struct {
+	dimension int
+}
+
+func (t truncateStep) validate() error {
+	if t.dimension <= 0 {
+		return fmt.Errorf("%w: truncate dimension must be positive, got %d", chroma.ErrInvalidInput, t.dimension)
+	}
+	return nil
+}
+
+func (t truncateStep) Apply(embedding chroma.Embedding) (chroma.Embedding, error) {
+	if err := t.validate(); err != nil {
+		return nil, err
+	}
+	if len(embedding) < t.dimension {
+		return nil, fmt.Errorf("%w: cannot truncate embedding of dimension %d to %d", chroma.ErrInvalidInput, len(embedding), t.dimension)
+	}
+	return embedding[:t.dimension:t.dimension], nil
+}
+
+func (t truncateStep) Name() string         { return fmt.Sprintf("truncate%d", t.dimension) }
+func (t truncateStep) Dimension(in int) int { return t.dimension }
+
+// Float32 rounds every value to float32 precision, matching what is kept by
+// float32 storage so that cached and freshly generated embeddings are identical.
+func Float32() Step {
+	return float32Step{}
+}
+
+type float32Step struct{}
+
+func (float32Step) Apply(embedding chroma.Embedding) (chroma.Embedding, error) {
+	for i, v := range embedding {
+		embedding[i] = float64(float32(v))
+	}
+	return embedding, nil
+}
+
+func (float32Step) Name() string         { return "f32" }
+func (float32Step) Dimension(in int) int { return in }
+
+// Int8 applies scalar quantization to 8 bits and back, matching what is kept
+// by Int8Codec.
+func Int8() Step {
+	return int8Step{}
+}
+
+type int8Step struct{}
+
+func (int8Step) Apply(embedding chroma.Embedding) (chroma.Embedding, error) {
+	return QuantizeInt8(embedding).Dequantize(), nil
+}
+
+func (int8Step) Name() string         { return "i8" }
+func (int8Step) Dimension(in int) int { return in }
+
+// Project applies a fitted projection, such as one from FitPCA.
+func Project(projection *Projection) Step {
+	return projectStep{projection}
+}
+
+type projectStep struct {
+	projection *Projection
+}
+
+func (p projectStep) validate() error {
+	if p.projection == nil {
+		return fmt.Errorf("%w: nil projection", chroma.ErrInvalidInput)
+	}
+	return nil
+}
+
+func (p projectStep) Apply(embedding chroma.Embedding) (chroma.Embedding, error) {
+	if err := p.validate(); err != nil {
+		return nil, err
+	}
+	return p.projection.Project(embedding)
+}
+
+func (p projectStep) Name() string {
+	if p.projection == nil {
+		return "proj"
+	}
+	return "proj-" + p.projection.ID()
+}
+
+func (p projectStep) Dimension(in int) int {
+	if p.projection == nil {
+		return 0
+	}
+	return p.projection.Dimension()
+}
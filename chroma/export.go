@@ -0,0 +1,61 @@
+package chroma
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+)
+
+type exportOpts struct {
+	pageSize int
+}
+
+type ExportOpts func(*exportOpts)
+
+// WithExportPageSize sets how many records are read from the collection at a time.
+func WithExportPageSize(pageSize int) ExportOpts {
+	return func(o *exportOpts) {
+		o.pageSize = pageSize
+	}
+}
+
+// Export writes a snapshot of every record in the collection, along with the
+// collection's name and metadata, to w. Records are read page by page, so
+// the collection is never held in memory as a whole.
+func (c *Collection) Export(ctx context.Context, w io.Writer, format SnapshotFormat, opts ...ExportOpts) (err error) {
+	ctx, op := c.client.telemetry.start(ctx, "Collection.Export", AttributeCollectionName.String(c.Name))
+	defer func() { op.end(err) }()
+
+	eOpts := &exportOpts{pageSize: 500}
+	for _, opt := range opts {
+		opt(eOpts)
+	}
+
+	sw, err := NewSnapshotWriter(w, format, SnapshotHeader{
+		Version:    snapshotVersion,
+		Name:       c.Name,
+		Metadata:   c.Metadata,
+		ExportedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		return fmt.Errorf("exporting: %w", err)
+	}
+
+	err = c.GetPages(ctx, eOpts.pageSize, func(page *EmbeddingResponse) error {
+		for _, record := range recordsOf(page) {
+			if err := sw.WriteRecord(record); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, WithInclude(IncludeEmbeddings, IncludeDocuments, IncludeMetadatas))
+	if err != nil {
+		return fmt.Errorf("exporting: %w", err)
+	}
+
+	if err := sw.Close(); err != nil {
+		return fmt.Errorf("exporting: %w", err)
+	}
+	return nil
+}
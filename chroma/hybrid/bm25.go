@@ -0,0 +1,236 @@
+package hybrid
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/kristofferostlund/chroma-go/chroma"
+)
+
+// Index is an in-memory BM25 index of documents. It is safe for concurrent use.
+type Index struct {
+	conf indexConfig
+
+	lock sync.Locker
+	docs map[chroma.ID]indexedDoc
+	// postings maps terms to the documents containing them and how often.
+	postings    map[string]map[chroma.ID]int
+	totalLength int
+}
+
+type indexedDoc struct {
+	document chroma.Document
+	length   int
+	terms    map[string]int
+}
+
+type indexConfig struct {
+	k1       float64
+	b        float64
+	tokenize func(text string) []string
+}
+
+type IndexOpt func(c *indexConfig)
+
+// K1 sets how quickly repeated terms stop adding to the score, 1.2 by default.
+func K1(k1 float64) IndexOpt {
+	return func(c *indexConfig) {
+		c.k1 = k1
+	}
+}
+
+// B sets how much scores are normalized by document length, from 0 for not
+// at all to 1 for fully, 0.75 by default.
+func B(b float64) IndexOpt {
+	return func(c *indexConfig) {
+		c.b = b
+	}
+}
+
+// Tokenizer replaces Tokenize, which is used for both documents and queries.
+func Tokenizer(tokenize func(text string) []string) IndexOpt {
+	return func(c *indexConfig) {
+		c.tokenize = tokenize
+	}
+}
+
+func NewIndex(opts ...IndexOpt) *Index {
+	conf := indexConfig{k1: 1.2, b: 0.75, tokenize: Tokenize}
+	for _, opt := range opts {
+		opt(&conf)
+	}
+	return &Index{
+		conf:        conf,
+		lock:        &sync.Mutex{},
+		docs:        make(map[chroma.ID]indexedDoc),
+		postings:    make(map[string]map[chroma.ID]int),
+		totalLength: 0,
+	}
+}
+
+// BuildIndex indexes all documents of coll, getting pageSize at a time.
+func BuildIndex(ctx context.Context, coll chroma.CollectionAPI, pageSize int, opts ...IndexOpt) (*Index, error) {
+	index := NewIndex(opts...)
+	err := coll.GetPages(ctx, pageSize, func(page *chroma.EmbeddingResponse) error {
+		return index.Add(page.IDs, page.Documents)
+	}, chroma.WithInclude(chroma.IncludeDocuments))
+	if err != nil {
+		return nil, fmt.Errorf("indexing collection: %w", err)
+	}
+	return index, nil
+}
+
+// Add indexes documents, replacing those already indexed with the same IDs.
+// Call it along with adding them to the collection to keep the index up to
+// date without rebuilding it.
+func (i *Index) Add(ids []chroma.ID, documents []chroma.Document) error {
+	if len(ids) != len(documents) {
+		return fmt.Errorf("%w: got %d documents for %d IDs", chroma.ErrInvalidInput, len(documents), len(ids))
+	}
+
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	for j, id := range ids {
+		i.remove(id)
+
+		terms := make(map[string]int)
+		length := 0
+		for _, t := range i.conf.tokenize(documents[j]) {
+			terms[t]++
+			length++
+		}
+		for t, n := range terms {
+			if i.postings[t] == nil {
+				i.postings[t] = make(map[chroma.ID]int)
+			}
+			i.postings[t][id] = n
+		}
+		i.docs[id] = indexedDoc{document: documents[j], length: length, terms: terms}
+		i.totalLength += length
+	}
+	return nil
+}
+
+// Remove removes documents from the index, ignoring unknown IDs.
+func (i *Index) Remove(ids ...chroma.ID) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	for _, id := range ids {
+		i.remove(id)
+	}
+}
+
+func (i *Index) remove(id chroma.ID) {
+	doc, ok := i.docs[id]
+	if !ok {
+		return
+	}
+	for t := range doc.terms {
+		delete(i.postings[t], id)
+		if len(i.postings[t]) == 0 {
+			delete(i.postings, t)
+		}
+	}
+	delete(i.docs, id)
+	i.totalLength -= doc.length
+}
+
+// Len returns the number of indexed documents.
+func (i *Index) Len() int {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	return len(i.docs)
+}
+
+// Document returns an indexed document.
+func (i *Index) Document(id chroma.ID) (chroma.Document, bool) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	doc, ok := i.docs[id]
+	return doc.document, ok
+}
+
+// LexicalHit is a document matching a query, higher scores matching better.
+type LexicalHit struct {
+	ID    chroma.ID
+	Score float64
+}
+
+// Search returns the k documents scoring highest for query, ties by ID.
+// Documents without any of the query's terms are never returned.
+func (i *Index) Search(query string, k int) []LexicalHit {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	if len(i.docs) == 0 || k <= 0 {
+		return []LexicalHit{}
+	}
+	n := float64(len(i.docs))
+	avgLength := float64(i.totalLength) / n
+
+	scores := make(map[chroma.ID]float64)
+	seen := make(map[string]bool)
+	for _, t := range i.conf.tokenize(query) {
+		if seen[t] {
+			continue
+		}
+		seen[t] = true
+
+		postings := i.postings[t]
+		if len(postings) == 0 {
+			continue
+		}
+		df := float64(len(postings))
+		// Lucene's idf, which unlike the original is never negative.
+		idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+		for id, tf := range postings {
+			length := float64(i.docs[id].length)
+			norm := i.conf.k1 * (1 - i.conf.b + i.conf.b*length/avgLength)
+			scores[id] += idf * float64(tf) * (i.conf.k1 + 1) / (float64(tf) + norm)
+		}
+	}
+
+	hits := make([]LexicalHit, 0, len(scores))
+	for id, score := range scores {
+		hits = append(hits, LexicalHit{ID: id, Score: score})
+	}
+	sort.Slice(hits, func(a, b int) bool {
+		if hits[a].Score != hits[b].Score {
+			return hits[a].Score > hits[b].Score
+		}
+		return hits[a].ID < hits[b].ID
+	})
+	if len(hits) > k {
+		hits = hits[:k]
+	}
+	return hits
+}
+
+// Tokenize splits text into lower-cased runs of letters and digits. Runs
+// joined by _, -, ., : or /, such as ERR_CONN_RESET or v1.2.3, are also
+// kept whole, so identifiers and error codes match exactly as well as by
+// their parts.
+func Tokenize(text string) []string {
+	text = strings.ToLower(text)
+	isWord := func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }
+	isJoiner := func(r rune) bool { return strings.ContainsRune("_-.:/", r) }
+
+	tokens := make([]string, 0)
+	for _, field := range strings.FieldsFunc(text, func(r rune) bool { return !isWord(r) && !isJoiner(r) }) {
+		parts := strings.FieldsFunc(field, func(r rune) bool { return !isWord(r) })
+		tokens = append(tokens, parts...)
+
+		whole := strings.TrimFunc(field, func(r rune) bool { return !isWord(r) })
+		if len(parts) > 1 {
+			tokens = append(tokens, whole)
+		}
+	}
+	return tokens
+}
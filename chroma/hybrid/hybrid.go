@@ -0,0 +1,280 @@
+// Package hybrid combines vector search in a collection with lexical BM25
+// search over its documents, which finds exact identifiers and error codes
+// that embeddings tend to miss.
+//
+// The BM25 index is kept client-side, built from the collection with
+// BuildIndex or kept up to date with Index.Add when ingesting:
+//
+//	index, err := hybrid.BuildIndex(ctx, coll, 500)
+//	retriever, err := hybrid.New(coll, index, hybrid.ReciprocalRankFusion(60))
+//	results, err := retriever.Search(ctx, "ERR_CONN_RESET after upgrade", 10)
+package hybrid
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/kristofferostlund/chroma-go/chroma"
+	"golang.org/x/sync/errgroup"
+)
+
+// Source is a retriever contributing to hybrid results.
+type Source string
+
+const (
+	SourceVector  Source = "vector"
+	SourceLexical Source = "lexical"
+)
+
+// Result is a document found by either or both retrievers.
+type Result struct {
+	ID       chroma.ID
+	Document chroma.Document
+	Metadata chroma.Metadata
+	// Score is the fused score, higher being better.
+	Score float64
+
+	// VectorRank is the 1-based rank in the vector results, 0 if not found
+	// by vector search.
+	VectorRank     int
+	VectorDistance float64
+	// LexicalRank is the 1-based rank in the BM25 results, 0 if not found
+	// by lexical search.
+	LexicalRank  int
+	LexicalScore float64
+}
+
+// Sources returns the retrievers which found the result.
+func (r Result) Sources() []Source {
+	sources := make([]Source, 0, 2)
+	if r.VectorRank > 0 {
+		sources = append(sources, SourceVector)
+	}
+	if r.LexicalRank > 0 {
+		sources = append(sources, SourceLexical)
+	}
+	return sources
+}
+
+// Retriever searches a collection and a BM25 index of its documents and
+// fuses the results.
+type Retriever struct {
+	coll  chroma.CollectionAPI
+	index *Index
+	conf  Config
+}
+
+type Config struct {
+	fetchK int
+	// rrfK is the k of ReciprocalRankFusion, unless weighted is set.
+	rrfK         int
+	weighted     bool
+	vectorWeight float64
+}
+
+type Opt func(c *Config)
+
+// FetchK sets the number of results fetched from each retriever before
+// fusing them, 3 times the number of results by default.
+func FetchK(fetchK int) Opt {
+	return func(c *Config) {
+		c.fetchK = fetchK
+	}
+}
+
+// ReciprocalRankFusion scores results by the sum of 1/(k+rank) over the
+// retrievers finding them, which only depends on ranks and so needs no
+// tuning of scores. It is the default, with k 60. k must not be negative.
+func ReciprocalRankFusion(k int) Opt {
+	return func(c *Config) {
+		c.weighted = false
+		c.rrfK = k
+	}
+}
+
+// WeightedFusion scores results by the weighted sum of their scores from
+// each retriever, min-max normalized to between 0 and 1, with the nearest
+// vector result and the best BM25 result scoring 1. Results not found by a
+// retriever score 0 for it. The lexical weight is 1 - vectorWeight, so
+// vectorWeight must be between 0 and 1.
+func WeightedFusion(vectorWeight float64) Opt {
+	return func(c *Config) {
+		c.weighted = true
+		c.vectorWeight = vectorWeight
+	}
+}
+
+// fuse sets Score of results.
+func (c Config) fuse(results []*Result) {
+	if c.weighted {
+		vector := normalizer(results, func(r *Result) (float64, bool) { return -r.VectorDistance, r.VectorRank > 0 })
+		lexical := normalizer(results, func(r *Result) (float64, bool) { return r.LexicalScore, r.LexicalRank > 0 })
+		for _, r := range results {
+			r.Score = c.vectorWeight*vector(r) + (1-c.vectorWeight)*lexical(r)
+		}
+		return
+	}
+
+	for _, r := range results {
+		r.Score = 0
+		if r.VectorRank > 0 {
+			r.Score += 1 / float64(c.rrfK+r.VectorRank)
+		}
+		if r.LexicalRank > 0 {
+			r.Score += 1 / float64(c.rrfK+r.LexicalRank)
+		}
+	}
+}
+
+// normalizer returns a function scaling the scores of results found by a
+// retriever to between 0 and 1, and 0 for others.
+func normalizer(results []*Result, score func(r *Result) (float64, bool)) func(r *Result) float64 {
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, r := range results {
+		if s, ok := score(r); ok {
+			lo, hi = math.Min(lo, s), math.Max(hi, s)
+		}
+	}
+	return func(r *Result) float64 {
+		s, ok := score(r)
+		if !ok {
+			return 0
+		}
+		if hi == lo {
+			return 1
+		}
+		return (s - lo) / (hi - lo)
+	}
+}
+
+func New(coll chroma.CollectionAPI, index *Index, opts ...Opt) (*Retriever, error) {
+	conf := Config{fetchK: 0, rrfK: 60, weighted: false, vectorWeight: 0}
+	for _, opt := range opts {
+		opt(&conf)
+	}
+	if conf.weighted && !(conf.vectorWeight >= 0 && conf.vectorWeight <= 1) {
+		return nil, fmt.Errorf("%w: vector weight must be between 0 and 1, got %v", chroma.ErrInvalidInput, conf.vectorWeight)
+	}
+	if !conf.weighted && conf.rrfK < 0 {
+		return nil, fmt.Errorf("%w: reciprocal rank fusion k must not be negative, got %d", chroma.ErrInvalidInput, conf.rrfK)
+	}
+	return &Retriever{coll: coll, index: index, conf: conf}, nil
+}
+
+// Search returns the k best results for query by fused score. The query is
+// embedded by the collection's query embedding generator for vector search.
+// Filters in opts, see chroma.WithWhere and chroma.WithWhereDocument, apply
+// to both retrievers.
+func (r *Retriever) Search(ctx context.Context, query string, k int, opts ...chroma.QueryOpts) ([]Result, error) {
+	if k <= 0 {
+		return nil, fmt.Errorf("%w: k must be positive, got %d", chroma.ErrInvalidInput, k)
+	}
+	fetchK := r.conf.fetchK
+	if fetchK <= 0 {
+		fetchK = 3 * k
+	}
+
+	lexicalHits := r.index.Search(query, fetchK)
+	lexicalIDs := make([]chroma.ID, 0, len(lexicalHits))
+	for _, h := range lexicalHits {
+		lexicalIDs = append(lexicalIDs, h.ID)
+	}
+
+	var (
+		vectorRes  *chroma.QueryResult
+		lexicalRes *chroma.EmbeddingResponse
+	)
+	eg, egCtx := errgroup.WithContext(ctx)
+	eg.Go(func() error {
+		queryOpts := append(append([]chroma.QueryOpts{}, opts...), chroma.WithInclude(chroma.IncludeDocuments, chroma.IncludeMetadatas, chroma.IncludeDistances))
+		res, err := r.coll.Query(egCtx, []chroma.Document{query}, fetchK, queryOpts...)
+		if err != nil {
+			return fmt.Errorf("vector search: %w", err)
+		}
+		vectorRes = res
+		return nil
+	})
+	if len(lexicalIDs) > 0 {
+		// Get the lexical hits from the collection for their metadata and
+		// to apply the filters.
+		eg.Go(func() error {
+			getOpts := append(append([]chroma.QueryOpts{}, opts...), chroma.WithInclude(chroma.IncludeDocuments, chroma.IncludeMetadatas))
+			res, err := r.coll.Get(egCtx, lexicalIDs, getOpts...)
+			if err != nil {
+				return fmt.Errorf("getting lexical results: %w", err)
+			}
+			lexicalRes = res
+			return nil
+		})
+	}
+	if err := eg.Wait(); err != nil {
+		return nil, err
+	}
+
+	byID := make(map[chroma.ID]*Result)
+	results := make([]*Result, 0, 2*fetchK)
+	resultOf := func(id chroma.ID) *Result {
+		if res, ok := byID[id]; ok {
+			return res
+		}
+		res := &Result{ID: id}
+		byID[id] = res
+		results = append(results, res)
+		return res
+	}
+
+	if len(vectorRes.IDs) > 0 {
+		for i, id := range vectorRes.IDs[0] {
+			res := resultOf(id)
+			res.VectorRank = i + 1
+			if len(vectorRes.Distances) > 0 {
+				res.VectorDistance = vectorRes.Distances[0][i]
+			}
+			if len(vectorRes.Documents) > 0 {
+				res.Document = vectorRes.Documents[0][i]
+			}
+			if len(vectorRes.Metadatas) > 0 {
+				res.Metadata = vectorRes.Metadatas[0][i]
+			}
+		}
+	}
+
+	if lexicalRes != nil {
+		found := make(map[chroma.ID]int, len(lexicalRes.IDs))
+		for i, id := range lexicalRes.IDs {
+			found[id] = i
+		}
+		rank := 0
+		for _, h := range lexicalHits {
+			i, ok := found[h.ID]
+			if !ok {
+				// Filtered out, or deleted from the collection but not the index.
+				continue
+			}
+			rank++
+			res := resultOf(h.ID)
+			res.LexicalRank = rank
+			res.LexicalScore = h.Score
+			if i < len(lexicalRes.Documents) {
+				res.Document = lexicalRes.Documents[i]
+			}
+			if i < len(lexicalRes.Metadatas) {
+				res.Metadata = lexicalRes.Metadatas[i]
+			}
+		}
+	}
+
+	r.conf.fuse(results)
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+	if len(results) > k {
+		results = results[:k]
+	}
+
+	fused := make([]Result, 0, len(results))
+	for _, res := range results {
+		fused = append(fused, *res)
+	}
+	return fused, nil
+}
@@ -0,0 +1,333 @@
+package hybrid_test
+
+import (
+	"context"
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kristofferostlund/chroma-go/chroma"
+	"github.com/kristofferostlund/chroma-go/chroma/hybrid"
+	"github.com/kristofferostlund/chroma-go/chroma/memstore"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"Hello, World!", []string{"hello", "world"}},
+		{"ERR_CONN_RESET", []string{"err", "conn", "reset", "err_conn_reset"}},
+		{"upgrade to v1.2.3.", []string{"upgrade", "to", "v1", "2", "3", "v1.2.3"}},
+		{"see http://host/path", []string{"see", "http", "host", "path", "http://host/path"}},
+		{"end. -dash- _", []string{"end", "dash"}},
+		{"Größe 42", []string{"größe", "42"}},
+		{"", []string{}},
+	}
+	for _, tt := range tests {
+		if got := hybrid.Tokenize(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Tokenize(%q): got %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func hitIDs(hits []hybrid.LexicalHit) []chroma.ID {
+	ids := make([]chroma.ID, 0, len(hits))
+	for _, h := range hits {
+		ids = append(ids, h.ID)
+	}
+	return ids
+}
+
+func TestIndexSearch(t *testing.T) {
+	index := hybrid.NewIndex()
+	err := index.Add(
+		[]chroma.ID{"short", "long", "repeated", "other", "tie"},
+		[]chroma.Document{
+			"connection reset",
+			"the connection was reset by the peer after a long while of nothing",
+			"reset reset reset the connection",
+			"disk full",
+			"connection reset",
+		},
+	)
+	if err != nil {
+		t.Fatalf("adding: %v", err)
+	}
+
+	hits := index.Search("connection reset", 10)
+	// Longer documents score lower, ties are by ID and documents without
+	// any of the terms aren't returned.
+	if got, want := hitIDs(hits), []chroma.ID{"short", "tie", "repeated", "long"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	for i := 1; i < len(hits); i++ {
+		if hits[i].Score > hits[i-1].Score {
+			t.Errorf("got hits out of order by score: %v", hits)
+		}
+	}
+	if hits[0].Score != hits[1].Score {
+		t.Errorf("got scores %v and %v for identical documents", hits[0].Score, hits[1].Score)
+	}
+	// Repeated terms score higher than in documents of the same length.
+	if got := hitIDs(index.Search("reset", 10)); got[0] != "repeated" {
+		t.Errorf("got %v, want the document repeating the term first", got)
+	}
+
+	if got := index.Search("connection reset", 2); len(got) != 2 {
+		t.Errorf("got %d hits, want 2", len(got))
+	}
+	for _, k := range []int{0, -1} {
+		if got := index.Search("connection", k); len(got) != 0 {
+			t.Errorf("got %d hits for k %d, want none", len(got), k)
+		}
+	}
+	if got := index.Search("nothing matches this", 10); len(got) != 1 || got[0].ID != "long" {
+		t.Errorf("got %v, want only the document containing nothing", got)
+	}
+	// Rare terms weigh more than common ones.
+	if got := index.Search("disk connection", 1); len(got) != 1 || got[0].ID != "other" {
+		t.Errorf("got %v, want the document with the rare term first", got)
+	}
+}
+
+func TestIndexReplaceAndRemove(t *testing.T) {
+	index := hybrid.NewIndex()
+	if err := index.Add([]chroma.ID{"a", "b"}, []chroma.Document{"alpha beta", "beta gamma"}); err != nil {
+		t.Fatalf("adding: %v", err)
+	}
+	if err := index.Add([]chroma.ID{"a"}, []chroma.Document{"delta"}); err != nil {
+		t.Fatalf("replacing: %v", err)
+	}
+
+	if got := index.Len(); got != 2 {
+		t.Errorf("got %d documents, want 2", got)
+	}
+	if got := index.Search("alpha", 10); len(got) != 0 {
+		t.Errorf("got %v for a replaced term, want none", got)
+	}
+	if got := hitIDs(index.Search("beta", 10)); !reflect.DeepEqual(got, []chroma.ID{"b"}) {
+		t.Errorf("got %v, want only b", got)
+	}
+	if got := hitIDs(index.Search("delta", 10)); !reflect.DeepEqual(got, []chroma.ID{"a"}) {
+		t.Errorf("got %v, want only a", got)
+	}
+	if doc, ok := index.Document("a"); !ok || doc != "delta" {
+		t.Errorf("got document %q, %v, want the replacement", doc, ok)
+	}
+
+	index.Remove("b", "unknown")
+	if got := index.Len(); got != 1 {
+		t.Errorf("got %d documents after removing, want 1", got)
+	}
+	if got := index.Search("gamma beta", 10); len(got) != 0 {
+		t.Errorf("got %v for removed terms, want none", got)
+	}
+	if _, ok := index.Document("b"); ok {
+		t.Error("got the removed document")
+	}
+
+	// A single remaining document still scores, so lengths were kept right.
+	if got := index.Search("delta", 10); len(got) != 1 || got[0].Score <= 0 || math.IsNaN(got[0].Score) {
+		t.Errorf("got %v, want a positive score", got)
+	}
+
+	if err := index.Add([]chroma.ID{"x"}, nil); !errors.Is(err, chroma.ErrInvalidInput) {
+		t.Errorf("got error %v for mismatched lengths, want ErrInvalidInput", err)
+	}
+}
+
+// keywordEmbeddings embeds documents by how often they mention each
+// keyword, with a constant last dimension so no embedding is zero.
+type keywordEmbeddings struct{}
+
+var keywords = []string{"network", "disk", "memory"}
+
+func (keywordEmbeddings) Generate(ctx context.Context, documents []chroma.Document) ([]chroma.Embedding, error) {
+	embeddings := make([]chroma.Embedding, 0, len(documents))
+	for _, d := range documents {
+		e := make(chroma.Embedding, 0, len(keywords)+1)
+		for _, k := range keywords {
+			e = append(e, float64(strings.Count(d, k)))
+		}
+		embeddings = append(embeddings, append(e, 1))
+	}
+	return embeddings, nil
+}
+
+const query = "network ERR_CONN_RESET"
+
+// newRetrieverParts returns a collection and index where, for query, vector
+// search ranks n1, e1, d1, n2 and lexical search ranks e1, n2, n1.
+func newRetrieverParts(t *testing.T) (*chroma.Collection, *hybrid.Index) {
+	t.Helper()
+	ctx := context.Background()
+	store, err := memstore.New(memstore.ExactSearch())
+	if err != nil {
+		t.Fatalf("creating store: %v", err)
+	}
+	coll, err := memstore.NewClient(store).CreateCollection(ctx, "logs", chroma.WithEmbeddingFunc(keywordEmbeddings{}))
+	if err != nil {
+		t.Fatalf("creating collection: %v", err)
+	}
+
+	ids := []chroma.ID{"n1", "n2", "e1", "d1"}
+	docs := []chroma.Document{
+		"network timeout while connecting",
+		"network network network slow",
+		"got ERR_CONN_RESET in logs",
+		"disk full",
+	}
+	metas := []chroma.Metadata{{"kind": "log"}, {"kind": "log"}, {"kind": "error"}, {"kind": "log"}}
+	if _, err := coll.Add(ctx, ids, nil, metas, docs); err != nil {
+		t.Fatalf("adding: %v", err)
+	}
+	index, err := hybrid.BuildIndex(ctx, coll, 2)
+	if err != nil {
+		t.Fatalf("building index: %v", err)
+	}
+	if got := index.Len(); got != len(ids) {
+		t.Fatalf("got %d indexed documents, want %d", got, len(ids))
+	}
+	return coll, index
+}
+
+func newRetriever(t *testing.T, coll chroma.CollectionAPI, index *hybrid.Index, opts ...hybrid.Opt) *hybrid.Retriever {
+	t.Helper()
+	r, err := hybrid.New(coll, index, opts...)
+	if err != nil {
+		t.Fatalf("creating retriever: %v", err)
+	}
+	return r
+}
+
+func resultIDs(results []hybrid.Result) []chroma.ID {
+	ids := make([]chroma.ID, 0, len(results))
+	for _, r := range results {
+		ids = append(ids, r.ID)
+	}
+	return ids
+}
+
+func TestReciprocalRankFusion(t *testing.T) {
+	coll, index := newRetrieverParts(t)
+	results, err := newRetriever(t, coll, index).Search(context.Background(), query, 4)
+	if err != nil {
+		t.Fatalf("searching: %v", err)
+	}
+
+	if got, want := resultIDs(results), []chroma.ID{"e1", "n1", "n2", "d1"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	e1 := results[0]
+	if e1.VectorRank != 2 || e1.LexicalRank != 1 {
+		t.Errorf("got ranks %d and %d, want 2 and 1", e1.VectorRank, e1.LexicalRank)
+	}
+	if want := 1.0/62 + 1.0/61; math.Abs(e1.Score-want) > 1e-12 {
+		t.Errorf("got score %v, want %v", e1.Score, want)
+	}
+	if e1.Document != "got ERR_CONN_RESET in logs" || e1.Metadata["kind"] != "error" {
+		t.Errorf("got document %q and metadata %v", e1.Document, e1.Metadata)
+	}
+	if want := []hybrid.Source{hybrid.SourceVector, hybrid.SourceLexical}; !reflect.DeepEqual(e1.Sources(), want) {
+		t.Errorf("got sources %v, want %v", e1.Sources(), want)
+	}
+	d1 := results[3]
+	if want := []hybrid.Source{hybrid.SourceVector}; !reflect.DeepEqual(d1.Sources(), want) || d1.LexicalRank != 0 {
+		t.Errorf("got sources %v and lexical rank %d, want only vector", d1.Sources(), d1.LexicalRank)
+	}
+
+	results, err = newRetriever(t, coll, index, hybrid.ReciprocalRankFusion(0)).Search(context.Background(), query, 1)
+	if err != nil {
+		t.Fatalf("searching: %v", err)
+	}
+	if len(results) != 1 || results[0].ID != "e1" || results[0].Score != 1.0/2+1.0/1 {
+		t.Errorf("got %+v, want e1 scoring 1.5 with k 0", results)
+	}
+}
+
+func TestWeightedFusion(t *testing.T) {
+	tests := []struct {
+		weight float64
+		want   []chroma.ID
+	}{
+		{1, []chroma.ID{"n1", "e1", "d1", "n2"}},
+		{0, []chroma.ID{"e1", "n2", "n1", "d1"}},
+		{0.5, []chroma.ID{"e1", "n1", "d1", "n2"}},
+	}
+	for _, tt := range tests {
+		coll, index := newRetrieverParts(t)
+		results, err := newRetriever(t, coll, index, hybrid.WeightedFusion(tt.weight)).Search(context.Background(), query, 4)
+		if err != nil {
+			t.Fatalf("searching: %v", err)
+		}
+		if got := resultIDs(results); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("got %v with vector weight %v, want %v", got, tt.weight, tt.want)
+		}
+		for _, r := range results {
+			if r.Score < 0 || r.Score > 1 {
+				t.Errorf("got score %v for %s, want between 0 and 1", r.Score, r.ID)
+			}
+		}
+	}
+}
+
+func TestSearchFiltered(t *testing.T) {
+	ctx := context.Background()
+	coll, index := newRetrieverParts(t)
+	r := newRetriever(t, coll, index)
+
+	results, err := r.Search(ctx, query, 4, chroma.WithWhere(chroma.Where{"kind": "log"}))
+	if err != nil {
+		t.Fatalf("searching: %v", err)
+	}
+	if got, want := resultIDs(results), []chroma.ID{"n1", "n2", "d1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	// Lexical ranks skip the filtered out hit.
+	for _, res := range results {
+		if want := map[chroma.ID]int{"n1": 2, "n2": 1, "d1": 0}[res.ID]; res.LexicalRank != want {
+			t.Errorf("got lexical rank %d for %s, want %d", res.LexicalRank, res.ID, want)
+		}
+	}
+
+	// Documents deleted from the collection but not the index are skipped too.
+	if _, err := coll.Delete(ctx, []chroma.ID{"e1"}, nil, nil); err != nil {
+		t.Fatalf("deleting: %v", err)
+	}
+	results, err = r.Search(ctx, "ERR_CONN_RESET", 4)
+	if err != nil {
+		t.Fatalf("searching: %v", err)
+	}
+	for _, res := range results {
+		if res.ID == "e1" {
+			t.Errorf("got deleted %+v", res)
+		}
+		if res.LexicalRank != 0 {
+			t.Errorf("got lexical rank %d for %s, want no lexical hits", res.LexicalRank, res.ID)
+		}
+	}
+}
+
+func TestNewInvalid(t *testing.T) {
+	coll, index := newRetrieverParts(t)
+	for name, opt := range map[string]hybrid.Opt{
+		"negative k":                   hybrid.ReciprocalRankFusion(-1),
+		"very negative k":              hybrid.ReciprocalRankFusion(-60),
+		"negative weight":              hybrid.WeightedFusion(-0.1),
+		"weight above one":             hybrid.WeightedFusion(1.5),
+		"NaN weight":                   hybrid.WeightedFusion(math.NaN()),
+		"negative k replacing weights": hybrid.ReciprocalRankFusion(-2),
+	} {
+		if _, err := hybrid.New(coll, index, hybrid.WeightedFusion(0.5), opt); !errors.Is(err, chroma.ErrInvalidInput) {
+			t.Errorf("%s: got error %v, want ErrInvalidInput", name, err)
+		}
+	}
+
+	r := newRetriever(t, coll, index)
+	if _, err := r.Search(context.Background(), query, 0); !errors.Is(err, chroma.ErrInvalidInput) {
+		t.Errorf("got error %v for k 0, want ErrInvalidInput", err)
+	}
+}
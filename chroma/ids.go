@@ -0,0 +1,282 @@
+package chroma
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// IDGenerator generates the ID of a document which is added without one.
+type IDGenerator func(document Document, metadata Metadata) (ID, error)
+
+// UUIDv4IDs generates random UUIDv4 IDs.
+func UUIDv4IDs(document Document, metadata Metadata) (ID, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", fmt.Errorf("reading random bytes: %w", err)
+	}
+	b[6] = (b[6] & 0x0f) | 0x40 // version 4
+	b[8] = (b[8] & 0x3f) | 0x80 // variant 10
+
+	h := hex.EncodeToString(b[:])
+	return h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:32], nil
+}
+
+// ulidState keeps ULIDs generated within the same millisecond increasing.
+var ulidState = struct {
+	sync.Mutex
+	ms      uint64
+	entropy [10]byte
+}{}
+
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// ULIDIDs generates ULIDs, which sort by the time they were generated.
+// IDs generated within the same millisecond are monotonically increasing.
+func ULIDIDs(document Document, metadata Metadata) (ID, error) {
+	ulidState.Lock()
+	defer ulidState.Unlock()
+
+	ms := uint64(time.Now().UnixMilli())
+	if ms == ulidState.ms {
+		// Increment the entropy as a big-endian number.
+		i := len(ulidState.entropy) - 1
+		for ; i >= 0; i-- {
+			ulidState.entropy[i]++
+			if ulidState.entropy[i] != 0 {
+				break
+			}
+		}
+		if i < 0 {
+			return "", fmt.Errorf("ULID entropy overflow within millisecond %d", ms)
+		}
+	} else {
+		if _, err := rand.Read(ulidState.entropy[:]); err != nil {
+			return "", fmt.Errorf("reading random bytes: %w", err)
+		}
+		ulidState.ms = ms
+	}
+
+	var b [16]byte
+	var t [8]byte
+	binary.BigEndian.PutUint64(t[:], ms)
+	copy(b[:6], t[2:])
+	copy(b[6:], ulidState.entropy[:])
+
+	// 128 bits encode to 26 base32 characters, with 2 leading padding bits.
+	out := make([]byte, 26)
+	hi, lo := binary.BigEndian.Uint64(b[:8]), binary.BigEndian.Uint64(b[8:])
+	for i := 25; i >= 0; i-- {
+		out[i] = crockford[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(out), nil
+}
+
+// ContentHashIDs generates the hex encoded SHA-256 of the document and the
+// given metadata keys as IDs, so the same content always gets the same ID.
+func ContentHashIDs(metadataKeys ...string) IDGenerator {
+	keys := append([]string{}, metadataKeys...)
+	sort.Strings(keys)
+
+	return func(document Document, metadata Metadata) (ID, error) {
+		h := sha256.New()
+		// Length prefixes keep different splits of the same bytes apart.
+		writeField := func(b []byte) {
+			var l [8]byte
+			binary.BigEndian.PutUint64(l[:], uint64(len(b)))
+			h.Write(l[:])
+			h.Write(b)
+		}
+
+		writeField([]byte(document))
+		for _, k := range keys {
+			v, err := json.Marshal(metadata[k])
+			if err != nil {
+				return "", fmt.Errorf("encoding metadata %q: %w", k, err)
+			}
+			writeField([]byte(k))
+			writeField(v)
+		}
+		return hex.EncodeToString(h.Sum(nil)), nil
+	}
+}
+
+// DedupMode sets how Add and Upsert handle duplicate documents.
+type DedupMode int
+
+const (
+	// DedupNone writes everything as given.
+	DedupNone DedupMode = iota
+	// DedupBatch drops documents with the same document and metadata as an
+	// earlier document in the same batch, whatever their IDs. Documents
+	// without text are compared by embedding as well. An ID repeated with
+	// different content is an error.
+	DedupBatch
+	// DedupExisting also makes Add skip documents whose ID already exists in
+	// the collection, which is meant to be used with ContentHashIDs so that
+	// unchanged documents aren't embedded again. Upsert still writes them.
+	DedupExisting
+)
+
+// writeBatch holds the parallel slices of an Add or Upsert.
+type writeBatch struct {
+	ids        []ID
+	embeddings []Embedding
+	metadatas  []Metadata
+	documents  []Document
+}
+
+// prepareWrite generates missing IDs and drops duplicates according to the
+// collection's dedup mode, before any embeddings are generated. Existing IDs
+// are only skipped if skipExisting is set, for Add.
+func (c *Collection) prepareWrite(ctx context.Context, b writeBatch, skipExisting bool) (writeBatch, error) {
+	n := len(b.ids)
+	for _, l := range []int{len(b.embeddings), len(b.metadatas), len(b.documents)} {
+		if l > n {
+			n = l
+		}
+	}
+	if n == 0 {
+		return writeBatch{}, fmt.Errorf("%w: no ids, embeddings or documents", ErrInvalidInput)
+	}
+	if len(b.ids) == 0 {
+		b.ids = make([]ID, n)
+	}
+	if len(b.ids) != n {
+		return writeBatch{}, fmt.Errorf("%w: got %d ids for %d entries", ErrInvalidInput, len(b.ids), n)
+	}
+	// Copy so generated IDs aren't written to the caller's slice.
+	b.ids = append([]ID{}, b.ids...)
+
+	for i, id := range b.ids {
+		if id != "" {
+			continue
+		}
+		if c.idGen == nil {
+			return writeBatch{}, fmt.Errorf("%w: missing id at index %d and no id generator", ErrInvalidInput, i)
+		}
+
+		var (
+			doc  Document
+			meta Metadata
+		)
+		if i < len(b.documents) {
+			doc = b.documents[i]
+		}
+		if i < len(b.metadatas) {
+			meta = b.metadatas[i]
+		}
+		generated, err := c.idGen(doc, meta)
+		if err != nil {
+			return writeBatch{}, fmt.Errorf("generating id at index %d: %w", i, err)
+		}
+		b.ids[i] = generated
+	}
+
+	if c.dedup == DedupNone {
+		return b, nil
+	}
+
+	keep := make([]bool, n)
+	seen := make(map[string]struct{}, n)
+	contentOf := make(map[ID]string, n)
+	for i, id := range b.ids {
+		key, err := dedupKey(b, i)
+		if err != nil {
+			return writeBatch{}, err
+		}
+		if prev, ok := contentOf[id]; ok {
+			if prev != key {
+				return writeBatch{}, fmt.Errorf("%w: id %q repeated with different content at index %d", ErrInvalidInput, id, i)
+			}
+			continue
+		}
+		contentOf[id] = key
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		keep[i] = true
+	}
+
+	if c.dedup == DedupExisting && skipExisting {
+		candidates := make([]ID, 0, n)
+		for i, id := range b.ids {
+			if keep[i] {
+				candidates = append(candidates, id)
+			}
+		}
+		if len(candidates) > 0 {
+			existing, err := c.Get(ctx, candidates, WithInclude())
+			if err != nil {
+				return writeBatch{}, fmt.Errorf("getting existing ids: %w", err)
+			}
+			exists := make(map[ID]struct{}, len(existing.IDs))
+			for _, id := range existing.IDs {
+				exists[id] = struct{}{}
+			}
+			for i, id := range b.ids {
+				if _, ok := exists[id]; ok {
+					keep[i] = false
+				}
+			}
+		}
+	}
+
+	return b.filter(keep), nil
+}
+
+// dedupKey identifies the content of the i:th document, its text and
+// metadata, or its embedding and metadata if it has no text.
+func dedupKey(b writeBatch, i int) (string, error) {
+	key := struct {
+		Document  Document  `json:"document"`
+		Metadata  Metadata  `json:"metadata"`
+		Embedding Embedding `json:"embedding,omitempty"`
+	}{}
+	if i < len(b.documents) {
+		key.Document = b.documents[i]
+	}
+	if i < len(b.metadatas) {
+		key.Metadata = b.metadatas[i]
+	}
+	if key.Document == "" && i < len(b.embeddings) {
+		key.Embedding = b.embeddings[i]
+	}
+
+	// Maps are encoded with sorted keys, so equal metadata gives equal keys.
+	k, err := json.Marshal(key)
+	if err != nil {
+		return "", fmt.Errorf("encoding dedup key: %w", err)
+	}
+	return string(k), nil
+}
+
+func (b writeBatch) filter(keep []bool) writeBatch {
+	out := writeBatch{}
+	for i, k := range keep {
+		if !k {
+			continue
+		}
+		out.ids = append(out.ids, b.ids[i])
+		if i < len(b.embeddings) {
+			out.embeddings = append(out.embeddings, b.embeddings[i])
+		}
+		if i < len(b.metadatas) {
+			out.metadatas = append(out.metadatas, b.metadatas[i])
+		}
+		if i < len(b.documents) {
+			out.documents = append(out.documents, b.documents[i])
+		}
+	}
+	return out
+}
@@ -0,0 +1,306 @@
+package chroma
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/kristofferostlund/chroma-go/chroma/chromaclient"
+	"golang.org/x/sync/errgroup"
+)
+
+// ImportError is returned when an import fails part way. Importing again
+// with WithResumeOffset(Offset) continues where it left off.
+type ImportError struct {
+	// Offset is the index of the first record which might not have been imported.
+	Offset int
+	Err    error
+}
+
+func (e *ImportError) Error() string {
+	return fmt.Sprintf("importing from offset %d: %v", e.Offset, e.Err)
+}
+
+func (e *ImportError) Unwrap() error {
+	return e.Err
+}
+
+// ImportResult describes a completed import.
+type ImportResult struct {
+	Collection *Collection
+	// Imported is the number of records imported, not counting skipped ones.
+	Imported int
+}
+
+type importOpts struct {
+	name        string
+	batchSize   int
+	concurrency int
+	offset      int
+	collOpts    []CollectionOpts
+}
+
+type ImportOpts func(*importOpts)
+
+// WithImportName imports the collection under a new name.
+func WithImportName(name string) ImportOpts {
+	return func(o *importOpts) {
+		o.name = name
+	}
+}
+
+// WithImportBatchSize sets how many records are upserted per request.
+func WithImportBatchSize(batchSize int) ImportOpts {
+	return func(o *importOpts) {
+		o.batchSize = batchSize
+	}
+}
+
+// WithImportConcurrency sets how many batches are upserted concurrently.
+func WithImportConcurrency(concurrency int) ImportOpts {
+	return func(o *importOpts) {
+		o.concurrency = concurrency
+	}
+}
+
+// WithResumeOffset skips the first offset records, typically the offset of
+// an ImportError. The collection is expected to exist already.
+func WithResumeOffset(offset int) ImportOpts {
+	return func(o *importOpts) {
+		o.offset = offset
+	}
+}
+
+// WithImportCollectionOpts sets options for the imported collection, such
+// as the embedding func to use for queries after importing. The snapshot's
+// embeddings are always used as they are.
+func WithImportCollectionOpts(opts ...CollectionOpts) ImportOpts {
+	return func(o *importOpts) {
+		o.collOpts = append(o.collOpts, opts...)
+	}
+}
+
+type importBatch struct {
+	start   int
+	records []SnapshotRecord
+}
+
+// ImportCollection recreates a collection from a snapshot written by
+// Collection.Export. The snapshot's embeddings are reused and never
+// generated again. The collection must not exist, unless resuming.
+func (c *Client) ImportCollection(ctx context.Context, r io.Reader, opts ...ImportOpts) (_ *ImportResult, err error) {
+	ctx, op := c.telemetry.start(ctx, "Client.ImportCollection")
+	defer func() { op.end(err) }()
+
+	iOpts := &importOpts{name: "", batchSize: 100, concurrency: 4, offset: 0, collOpts: nil}
+	for _, opt := range opts {
+		opt(iOpts)
+	}
+	if iOpts.batchSize <= 0 || iOpts.concurrency <= 0 || iOpts.offset < 0 {
+		return nil, fmt.Errorf("%w: batch size and concurrency must be positive and offset non-negative", ErrInvalidInput)
+	}
+
+	sr, err := NewSnapshotReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("importing: %w", err)
+	}
+	header := sr.Header()
+	name := header.Name
+	if iOpts.name != "" {
+		name = iOpts.name
+	}
+
+	collOpts := append([]CollectionOpts{WithMetadata(header.Metadata)}, iOpts.collOpts...)
+	var coll *Collection
+	if iOpts.offset > 0 {
+		coll, err = c.GetOrCreateCollection(ctx, name, collOpts...)
+	} else {
+		coll, err = c.CreateCollection(ctx, name, collOpts...)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("importing: %w", err)
+	}
+
+	tracker := newImportTracker(iOpts.offset)
+	batches := make(chan importBatch)
+	eg, egCtx := errgroup.WithContext(ctx)
+
+	eg.Go(func() error {
+		defer close(batches)
+		return readImportBatches(egCtx, sr, iOpts.offset, iOpts.batchSize, tracker, batches)
+	})
+	for i := 0; i < iOpts.concurrency; i++ {
+		eg.Go(func() error {
+			for batch := range batches {
+				if err := coll.upsertSnapshotRecords(egCtx, batch.records); err != nil {
+					return fmt.Errorf("upserting records %d to %d: %w", batch.start, batch.start+len(batch.records), err)
+				}
+				tracker.done(batch.start, len(batch.records))
+			}
+			return nil
+		})
+	}
+
+	if err := eg.Wait(); err != nil {
+		return nil, &ImportError{Offset: tracker.resumeOffset(), Err: err}
+	}
+
+	return &ImportResult{Collection: coll, Imported: tracker.imported()}, nil
+}
+
+func readImportBatches(ctx context.Context, sr SnapshotReader, offset, batchSize int, tracker *importTracker, batches chan<- importBatch) error {
+	batch := importBatch{start: offset, records: make([]SnapshotRecord, 0, batchSize)}
+	send := func() error {
+		if len(batch.records) == 0 {
+			return nil
+		}
+		tracker.started(batch.start)
+		select {
+		case batches <- batch:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		batch = importBatch{start: batch.start + len(batch.records), records: make([]SnapshotRecord, 0, batchSize)}
+		return nil
+	}
+
+	for index := 0; ; index++ {
+		record, err := sr.Next()
+		if errors.Is(err, io.EOF) {
+			return send()
+		}
+		if err != nil {
+			return fmt.Errorf("reading record %d: %w", index, err)
+		}
+		if index < offset {
+			continue
+		}
+		if len(record.Embedding) == 0 {
+			return fmt.Errorf("%w: record %d (%q) has no embedding", ErrInvalidInput, index, record.ID)
+		}
+
+		batch.records = append(batch.records, record)
+		if len(batch.records) == batchSize {
+			if err := send(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// upsertSnapshotRecords upserts records with their stored embeddings and
+// IDs as they are, without the deduplication and ID generation of Upsert,
+// so that every record counted as imported is written. A request has
+// documents for all records or none, so records without one are upserted
+// separately instead of being given empty documents.
+func (c *Collection) upsertSnapshotRecords(ctx context.Context, records []SnapshotRecord) error {
+	withDocuments := make([]SnapshotRecord, 0, len(records))
+	withoutDocuments := make([]SnapshotRecord, 0)
+	for _, r := range records {
+		if r.Document != "" {
+			withDocuments = append(withDocuments, r)
+		} else {
+			withoutDocuments = append(withoutDocuments, r)
+		}
+	}
+
+	for _, group := range [][]SnapshotRecord{withDocuments, withoutDocuments} {
+		if len(group) == 0 {
+			continue
+		}
+		ids := make([]ID, 0, len(group))
+		embeddings := make([]Embedding, 0, len(group))
+		documents := make([]Document, 0, len(group))
+		metadatas := make([]Metadata, 0, len(group))
+		hasMetadatas := false
+		for _, r := range group {
+			ids = append(ids, r.ID)
+			embeddings = append(embeddings, r.Embedding)
+			if r.Document != "" {
+				documents = append(documents, r.Document)
+			}
+			metadata := r.Metadata
+			if metadata == nil {
+				metadata = Metadata{}
+			}
+			metadatas = append(metadatas, metadata)
+			hasMetadatas = hasMetadatas || len(r.Metadata) > 0
+		}
+		if !hasMetadatas {
+			metadatas = nil
+		}
+
+		b, err := c.validatedSetEmbeddingRequest(ctx, ids, embeddings, metadatas, documents)
+		if err != nil {
+			return fmt.Errorf("validating: %w", err)
+		}
+		r, err := handleResponse(c.api.Upsert(ctx, c.ID, chromaclient.AddEmbedding(b)))
+		if err != nil {
+			return fmt.Errorf("upserting: %w", err)
+		}
+		var success bool
+		if err := r.decodeJSON(&success); err != nil {
+			return fmt.Errorf("decoding response: %w", err)
+		}
+	}
+	return nil
+}
+
+// importTracker keeps track of which batches are done, so that a failed
+// import can be resumed from the first batch which might not be.
+type importTracker struct {
+	lock    sync.Locker
+	offset  int
+	pending map[int]struct{}
+	count   int
+	next    int
+}
+
+func newImportTracker(offset int) *importTracker {
+	return &importTracker{lock: &sync.Mutex{}, offset: offset, pending: make(map[int]struct{}), count: 0, next: offset}
+}
+
+func (t *importTracker) started(start int) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.pending[start] = struct{}{}
+}
+
+func (t *importTracker) done(start, n int) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	delete(t.pending, start)
+	t.count += n
+	if start+n > t.next {
+		t.next = start + n
+	}
+}
+
+func (t *importTracker) imported() int {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	return t.count
+}
+
+// resumeOffset returns the start of the first batch which isn't done, or the
+// end of the last done batch if all started batches are done.
+func (t *importTracker) resumeOffset() int {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if len(t.pending) == 0 {
+		return t.next
+	}
+	starts := make([]int, 0, len(t.pending))
+	for start := range t.pending {
+		starts = append(starts, start)
+	}
+	sort.Ints(starts)
+	return starts[0]
+}
@@ -0,0 +1,228 @@
+package chroma_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/kristofferostlund/chroma-go/chroma"
+	"github.com/kristofferostlund/chroma-go/chroma/memstore"
+)
+
+func newMemClient(t *testing.T, opts ...chroma.ClientOpts) *chroma.Client {
+	t.Helper()
+	store, err := memstore.New()
+	if err != nil {
+		t.Fatalf("creating store: %v", err)
+	}
+	return memstore.NewClient(store, opts...)
+}
+
+// exportSource has records with and without documents and metadata, and two
+// records with the same content under different IDs.
+func exportSource(t *testing.T, client *chroma.Client) *chroma.Collection {
+	t.Helper()
+	ctx := context.Background()
+	coll, err := client.CreateCollection(ctx, "source", chroma.WithMetadata(chroma.Metadata{"owner": "tests"}))
+	if err != nil {
+		t.Fatalf("creating collection: %v", err)
+	}
+	if _, err := coll.Add(ctx,
+		[]chroma.ID{"a", "b", "c"},
+		[]chroma.Embedding{{1, 2}, {3, 4}, {3, 4}},
+		[]chroma.Metadata{{"n": int64(1)}, {"n": int64(2)}, {"n": int64(2)}},
+		[]chroma.Document{"first", "same", "same"},
+	); err != nil {
+		t.Fatalf("adding: %v", err)
+	}
+	if _, err := coll.Add(ctx, []chroma.ID{"d", "e"}, []chroma.Embedding{{5, 6}, {0.5, 0.25}}, nil, nil); err != nil {
+		t.Fatalf("adding: %v", err)
+	}
+	return coll
+}
+
+type record struct {
+	ID        chroma.ID
+	Embedding chroma.Embedding
+	Document  chroma.Document
+	Metadata  chroma.Metadata
+}
+
+func allRecords(t *testing.T, coll *chroma.Collection) []record {
+	t.Helper()
+	got, err := coll.Get(context.Background(), nil, chroma.WithInclude(chroma.IncludeEmbeddings, chroma.IncludeDocuments, chroma.IncludeMetadatas))
+	if err != nil {
+		t.Fatalf("getting records: %v", err)
+	}
+	records := make([]record, 0, len(got.IDs))
+	for i, id := range got.IDs {
+		r := record{ID: id, Embedding: got.Embeddings[i], Document: "", Metadata: nil}
+		if got.Documents != nil {
+			r.Document = got.Documents[i]
+		}
+		if got.Metadatas != nil && len(got.Metadatas[i]) > 0 {
+			r.Metadata = got.Metadatas[i]
+		}
+		records = append(records, r)
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].ID < records[j].ID })
+	return records
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	for _, format := range []chroma.SnapshotFormat{chroma.SnapshotJSONL, chroma.SnapshotBinary} {
+		t.Run(string(format), func(t *testing.T) {
+			ctx := context.Background()
+			src := exportSource(t, newMemClient(t))
+
+			var buf bytes.Buffer
+			if err := src.Export(ctx, &buf, format, chroma.WithExportPageSize(2)); err != nil {
+				t.Fatalf("exporting: %v", err)
+			}
+
+			// Deduplication of the collection mustn't drop records of the snapshot.
+			result, err := newMemClient(t).ImportCollection(ctx, &buf,
+				chroma.WithImportName("imported"),
+				chroma.WithImportCollectionOpts(chroma.WithDedup(chroma.DedupBatch)),
+			)
+			if err != nil {
+				t.Fatalf("importing: %v", err)
+			}
+			if result.Imported != 5 {
+				t.Errorf("got %d imported, want 5", result.Imported)
+			}
+			if got, want := result.Collection.Metadata, (chroma.Metadata{"owner": "tests"}); !reflect.DeepEqual(got, want) {
+				t.Errorf("got collection metadata %v, want %v", got, want)
+			}
+			if got, want := allRecords(t, result.Collection), allRecords(t, src); !reflect.DeepEqual(got, want) {
+				t.Errorf("got records\n%+v\nwant\n%+v", got, want)
+			}
+		})
+	}
+}
+
+func TestImportResume(t *testing.T) {
+	ctx := context.Background()
+	src := exportSource(t, newMemClient(t))
+	var buf bytes.Buffer
+	if err := src.Export(ctx, &buf, chroma.SnapshotJSONL); err != nil {
+		t.Fatalf("exporting: %v", err)
+	}
+	snapshot := buf.Bytes()
+
+	upserts, failAt := 0, 2
+	dst := newMemClient(t, chroma.WithMiddleware(func(next chroma.Doer) chroma.Doer {
+		return chroma.DoerFunc(func(req *http.Request) (*http.Response, error) {
+			if strings.HasSuffix(req.URL.Path, "/upsert") {
+				upserts++
+				if upserts == failAt {
+					return &http.Response{StatusCode: http.StatusInternalServerError, Body: io.NopCloser(strings.NewReader("{}")), Request: req}, nil
+				}
+			}
+			return next.Do(req)
+		})
+	}))
+
+	// One batch at a time, so the first batch is imported before the second fails.
+	opts := []chroma.ImportOpts{chroma.WithImportBatchSize(2), chroma.WithImportConcurrency(1)}
+	_, err := dst.ImportCollection(ctx, bytes.NewReader(snapshot), opts...)
+	var importErr *chroma.ImportError
+	if !errors.As(err, &importErr) {
+		t.Fatalf("got error %v, want an ImportError", err)
+	}
+	if importErr.Offset != 2 {
+		t.Errorf("got offset %d, want 2", importErr.Offset)
+	}
+
+	result, err := dst.ImportCollection(ctx, bytes.NewReader(snapshot), append(opts, chroma.WithResumeOffset(importErr.Offset))...)
+	if err != nil {
+		t.Fatalf("resuming: %v", err)
+	}
+	if result.Imported != 3 {
+		t.Errorf("got %d imported when resuming, want 3", result.Imported)
+	}
+	if got, want := allRecords(t, result.Collection), allRecords(t, src); !reflect.DeepEqual(got, want) {
+		t.Errorf("got records\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestImportExistingCollection(t *testing.T) {
+	ctx := context.Background()
+	client := newMemClient(t)
+	src := exportSource(t, client)
+	var buf bytes.Buffer
+	if err := src.Export(ctx, &buf, chroma.SnapshotBinary); err != nil {
+		t.Fatalf("exporting: %v", err)
+	}
+
+	if _, err := client.ImportCollection(ctx, &buf); err == nil {
+		t.Error("got no error importing over an existing collection")
+	}
+}
+
+func TestImportAPI(t *testing.T) {
+	ctx := context.Background()
+	src := exportSource(t, newMemClient(t))
+	var buf bytes.Buffer
+	if err := src.Export(ctx, &buf, chroma.SnapshotJSONL); err != nil {
+		t.Fatalf("exporting: %v", err)
+	}
+
+	result, err := newMemClient(t).API().ImportCollection(ctx, &buf)
+	if err != nil {
+		t.Fatalf("importing: %v", err)
+	}
+	if n, err := result.Collection.Count(ctx); err != nil || n != result.Imported {
+		t.Errorf("got count %d and error %v, want %d", n, err, result.Imported)
+	}
+}
+
+func TestImportTruncated(t *testing.T) {
+	ctx := context.Background()
+	src := exportSource(t, newMemClient(t))
+	for _, format := range []chroma.SnapshotFormat{chroma.SnapshotJSONL, chroma.SnapshotBinary} {
+		t.Run(string(format), func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := src.Export(ctx, &buf, format); err != nil {
+				t.Fatalf("exporting: %v", err)
+			}
+			snapshot := buf.Bytes()
+
+			// Cut after the header and first record, which for JSONL is at a
+			// line boundary.
+			end := bytes.IndexByte(snapshot, '\n') + 1
+			end += bytes.IndexByte(snapshot[end:], '\n') + 1
+			cuts := map[string][]byte{
+				"truncated":   snapshot[:end],
+				"no end":      snapshot[:len(snapshot)-2],
+				"extra lines": append(append([]byte{}, snapshot...), snapshot[end:]...),
+			}
+			if format == chroma.SnapshotBinary {
+				delete(cuts, "extra lines")
+			}
+			for name, cut := range cuts {
+				if _, err := newMemClient(t).ImportCollection(ctx, bytes.NewReader(cut)); err == nil {
+					t.Errorf("got no error importing a %s snapshot", name)
+				}
+			}
+		})
+	}
+
+	var buf bytes.Buffer
+	if err := src.Export(ctx, &buf, chroma.SnapshotJSONL); err != nil {
+		t.Fatalf("exporting: %v", err)
+	}
+	tampered := bytes.Replace(buf.Bytes(), []byte(`{"end":{"records":5}}`), []byte(`{"end":{"records":4}}`), 1)
+	if bytes.Equal(tampered, buf.Bytes()) {
+		t.Fatalf("got no trailer in %s", buf.Bytes())
+	}
+	if _, err := newMemClient(t).ImportCollection(ctx, bytes.NewReader(tampered)); err == nil {
+		t.Error("got no error importing a snapshot with the wrong record count")
+	}
+}
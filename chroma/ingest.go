@@ -0,0 +1,235 @@
+package chroma
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Metadata keys tracking which source document a chunk came from.
+const (
+	MetadataParentID   = "parent_id"
+	MetadataChunkIndex = "chunk_index"
+)
+
+// SourceDoc is a whole source document to be ingested.
+type SourceDoc struct {
+	ID       ID
+	Text     Document
+	Metadata Metadata
+}
+
+// DocumentChunk is a part of a source document, as produced by a ChunkFunc.
+type DocumentChunk struct {
+	ID       ID
+	Text     Document
+	Metadata Metadata
+}
+
+// ChunkFunc splits a source document into chunks. The chunks must have
+// deterministic IDs and must have MetadataParentID and MetadataChunkIndex set,
+// with indexes counting from 0, for stale chunks to be cleaned up.
+// See chunking.ChunkFunc.
+type ChunkFunc func(doc SourceDoc) []DocumentChunk
+
+// WholeDocument is a ChunkFunc which keeps the whole document as a single chunk.
+func WholeDocument(doc SourceDoc) []DocumentChunk {
+	meta := make(Metadata, len(doc.Metadata)+2)
+	for k, v := range doc.Metadata {
+		meta[k] = v
+	}
+	meta[MetadataParentID] = doc.ID
+	meta[MetadataChunkIndex] = 0
+
+	return []DocumentChunk{{ID: ChunkID(doc.ID, 0), Text: doc.Text, Metadata: meta}}
+}
+
+// ChunkID returns the deterministic ID of the index:th chunk of a document.
+func ChunkID(parentID ID, index int) ID {
+	return fmt.Sprintf("%s#%d", parentID, index)
+}
+
+// IngestReport describes the outcome of ingesting a single source document.
+type IngestReport struct {
+	ID ID
+	// Chunks is the number of chunks upserted.
+	Chunks int
+	// Deleted is the number of stale chunks deleted from earlier ingestions.
+	Deleted int
+	Err     error
+}
+
+type ingestOpts struct {
+	chunkFunc   ChunkFunc
+	batchSize   int
+	deleteStale bool
+}
+
+type IngestOpts func(*ingestOpts)
+
+// WithChunkFunc sets how documents are split into chunks, defaults to WholeDocument.
+func WithChunkFunc(chunkFunc ChunkFunc) IngestOpts {
+	return func(o *ingestOpts) {
+		o.chunkFunc = chunkFunc
+	}
+}
+
+// WithBatchSize sets the maximum number of chunks upserted per request, defaults to 100.
+func WithBatchSize(batchSize int) IngestOpts {
+	return func(o *ingestOpts) {
+		o.batchSize = batchSize
+	}
+}
+
+// WithoutStaleChunkDeletion keeps chunks from earlier ingestions of a document
+// which had more chunks than the current one.
+func WithoutStaleChunkDeletion() IngestOpts {
+	return func(o *ingestOpts) {
+		o.deleteStale = false
+	}
+}
+
+// IngestDocuments chunks, embeds and upserts whole source documents, returning
+// a report per document in the same order. When a document is re-ingested
+// with fewer chunks than before, the stale chunks are deleted, so ingesting
+// the same documents again is idempotent. Documents must have unique IDs.
+//
+// Embeddings are generated with the collection's embedding generator. The
+// returned error joins the errors of all failed documents.
+func (c *Collection) IngestDocuments(ctx context.Context, docs []SourceDoc, opts ...IngestOpts) (_ []IngestReport, err error) {
+	ctx, op := c.client.telemetry.start(ctx, "Collection.IngestDocuments", AttributeCollectionName.String(c.Name), AttributeBatchSize.Int(len(docs)))
+	defer func() { op.end(err) }()
+
+	iOpts := &ingestOpts{chunkFunc: WholeDocument, batchSize: 100, deleteStale: true}
+	for _, opt := range opts {
+		opt(iOpts)
+	}
+	if iOpts.batchSize <= 0 {
+		return nil, fmt.Errorf("%w: batch size must be positive, got %d", ErrInvalidInput, iOpts.batchSize)
+	}
+
+	seen := make(map[ID]struct{}, len(docs))
+	for _, doc := range docs {
+		if doc.ID == "" {
+			continue
+		}
+		if _, ok := seen[doc.ID]; ok {
+			return nil, fmt.Errorf("%w: duplicate document ID %q", ErrInvalidInput, doc.ID)
+		}
+		seen[doc.ID] = struct{}{}
+	}
+
+	reports := make([]IngestReport, len(docs))
+	type chunkRef struct {
+		doc   int
+		chunk DocumentChunk
+	}
+	chunks := make([]chunkRef, 0, len(docs))
+	for i, doc := range docs {
+		reports[i].ID = doc.ID
+		if doc.ID == "" {
+			reports[i].Err = fmt.Errorf("%w: document %d has no ID", ErrInvalidInput, i)
+			continue
+		}
+		for _, chunk := range iOpts.chunkFunc(doc) {
+			chunks = append(chunks, chunkRef{i, chunk})
+		}
+	}
+
+	for start := 0; start < len(chunks); start += iOpts.batchSize {
+		end := start + iOpts.batchSize
+		if end > len(chunks) {
+			end = len(chunks)
+		}
+		batch := chunks[start:end]
+
+		ids := make([]ID, 0, len(batch))
+		documents := make([]Document, 0, len(batch))
+		metadatas := make([]Metadata, 0, len(batch))
+		for _, ref := range batch {
+			ids = append(ids, ref.chunk.ID)
+			documents = append(documents, ref.chunk.Text)
+			metadatas = append(metadatas, ref.chunk.Metadata)
+		}
+
+		if _, err := c.Upsert(ctx, ids, nil, metadatas, documents); err != nil {
+			for _, ref := range batch {
+				if reports[ref.doc].Err == nil {
+					reports[ref.doc].Err = fmt.Errorf("upserting chunks: %w", err)
+				}
+			}
+			continue
+		}
+		for _, ref := range batch {
+			reports[ref.doc].Chunks++
+		}
+	}
+
+	if iOpts.deleteStale {
+		// Only clean up after a complete ingestion, the chunks we'd delete
+		// might be the only ones left.
+		complete := make([]int, 0, len(reports))
+		for i := range reports {
+			if reports[i].Err == nil {
+				complete = append(complete, i)
+			}
+		}
+		for start := 0; start < len(complete); start += iOpts.batchSize {
+			c.deleteStaleChunks(ctx, reports, complete[start:minInt(start+iOpts.batchSize, len(complete))])
+		}
+	}
+
+	errs := make([]error, 0)
+	for _, r := range reports {
+		if r.Err != nil {
+			errs = append(errs, fmt.Errorf("ingesting %q: %w", r.ID, r.Err))
+		}
+	}
+	return reports, errors.Join(errs...)
+}
+
+// deleteStaleChunks deletes the chunks of the reported documents beyond the
+// ones just upserted, getting and deleting those of all documents at once.
+func (c *Collection) deleteStaleChunks(ctx context.Context, reports []IngestReport, docs []int) {
+	fail := func(err error) {
+		for _, i := range docs {
+			reports[i].Err = fmt.Errorf("deleting stale chunks: %w", err)
+		}
+	}
+
+	clauses := make([]Where, 0, len(docs))
+	byParent := make(map[ID]int, len(docs))
+	for _, i := range docs {
+		clauses = append(clauses, Where{"$and": []Where{
+			{MetadataParentID: Where{"$eq": reports[i].ID}},
+			{MetadataChunkIndex: Where{"$gte": reports[i].Chunks}},
+		}})
+		byParent[reports[i].ID] = i
+	}
+	where := clauses[0]
+	if len(clauses) > 1 {
+		where = Where{"$or": clauses}
+	}
+
+	stale, err := c.Get(ctx, nil, WithWhere(where), WithInclude(IncludeMetadatas))
+	if err != nil {
+		fail(err)
+		return
+	}
+	if len(stale.IDs) == 0 {
+		return
+	}
+	if _, err := c.Delete(ctx, stale.IDs, nil, nil); err != nil {
+		fail(err)
+		return
+	}
+	for j := range stale.IDs {
+		if j >= len(stale.Metadatas) {
+			break
+		}
+		parentID, _ := stale.Metadatas[j][MetadataParentID].(string)
+		if i, ok := byParent[parentID]; ok {
+			reports[i].Deleted++
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package loaders
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/kristofferostlund/chroma-go/chroma"
+)
+
+var _ Loader = (*CSVLoader)(nil)
+
+// CSVLoader loads every row of a CSV file with a header row as a document.
+type CSVLoader struct {
+	conf CSVConfig
+}
+
+type CSVConfig struct {
+	textColumns     []string
+	metadataColumns []string
+	idColumn        string
+	comma           rune
+}
+
+type CSVOpt func(c *CSVConfig)
+
+// MetadataColumns adds the given columns to each row's metadata.
+func MetadataColumns(columns ...string) CSVOpt {
+	return func(c *CSVConfig) {
+		c.metadataColumns = columns
+	}
+}
+
+// IDColumn sets the column holding each row's ID, which defaults to the
+// file path followed by the row number.
+func IDColumn(column string) CSVOpt {
+	return func(c *CSVConfig) {
+		c.idColumn = column
+	}
+}
+
+// Comma sets the field delimiter, defaults to ','.
+func Comma(comma rune) CSVOpt {
+	return func(c *CSVConfig) {
+		c.comma = comma
+	}
+}
+
+// NewCSVLoader creates a loader using the given columns as the text of each
+// row, joined by newlines when there are several.
+func NewCSVLoader(textColumns []string, opts ...CSVOpt) *CSVLoader {
+	conf := CSVConfig{textColumns: textColumns, metadataColumns: nil, idColumn: "", comma: ','}
+	for _, opt := range opts {
+		opt(&conf)
+	}
+	return &CSVLoader{conf: conf}
+}
+
+func (l *CSVLoader) Load(ctx context.Context, path string) (Documents, error) {
+	content, fileMeta, err := readFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	r := csv.NewReader(strings.NewReader(string(content)))
+	r.Comma = l.conf.comma
+	r.ReuseRecord = false
+
+	header, err := r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading header: %w", err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.TrimSpace(name)] = i
+	}
+
+	lookup := func(names ...string) ([]int, error) {
+		idx := make([]int, 0, len(names))
+		for _, name := range names {
+			i, ok := columns[name]
+			if !ok {
+				return nil, fmt.Errorf("%w: no column %q", chroma.ErrInvalidInput, name)
+			}
+			idx = append(idx, i)
+		}
+		return idx, nil
+	}
+	textIdx, err := lookup(l.conf.textColumns...)
+	if err != nil {
+		return nil, err
+	}
+	metaIdx, err := lookup(l.conf.metadataColumns...)
+	if err != nil {
+		return nil, err
+	}
+	idIdx := -1
+	if l.conf.idColumn != "" {
+		idx, err := lookup(l.conf.idColumn)
+		if err != nil {
+			return nil, err
+		}
+		idIdx = idx[0]
+	}
+
+	docs := make(Documents, 0)
+	for row := 1; ; row++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading row %d: %w", row, err)
+		}
+
+		texts := make([]string, 0, len(textIdx))
+		for _, i := range textIdx {
+			texts = append(texts, record[i])
+		}
+		text := strings.Join(texts, "\n")
+
+		meta := rowMetadata(fileMeta, text, row)
+		for j, i := range metaIdx {
+			meta[l.conf.metadataColumns[j]] = record[i]
+		}
+
+		id := filepath.ToSlash(path) + "#" + strconv.Itoa(row)
+		if idIdx >= 0 {
+			id = record[idIdx]
+		}
+		docs = append(docs, chroma.SourceDoc{ID: id, Text: text, Metadata: meta})
+	}
+
+	return docs, nil
+}
+
+// rowMetadata returns the file's metadata for a single row, with the
+// content hash of the row rather than the whole file.
+func rowMetadata(fileMeta chroma.Metadata, text string, row int) chroma.Metadata {
+	meta := make(chroma.Metadata, len(fileMeta)+2)
+	for k, v := range fileMeta {
+		meta[k] = v
+	}
+	meta[MetadataContentHash] = ContentHash([]byte(text))
+	meta[MetadataRow] = row
+	return meta
+}
@@ -0,0 +1,158 @@
+package loaders
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+var _ Loader = (*HTMLLoader)(nil)
+
+// HTMLLoader loads an HTML file as a single document of its visible text.
+// Scripts, styles, navigation, headers, footers, sidebars and forms are
+// stripped, and the title is taken from the title element.
+type HTMLLoader struct{}
+
+func NewHTMLLoader() *HTMLLoader {
+	return &HTMLLoader{}
+}
+
+func (l *HTMLLoader) Load(ctx context.Context, path string) (Documents, error) {
+	content, meta, err := readFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	root, err := html.Parse(bytes.NewReader(content))
+	if err != nil {
+		return nil, fmt.Errorf("parsing HTML: %w", err)
+	}
+
+	if title := strings.TrimSpace(htmlTitle(root)); title != "" {
+		meta[MetadataTitle] = title
+	}
+
+	// Prefer the main content when the page marks it up.
+	body := findElement(root, atom.Main)
+	if body == nil {
+		body = findElement(root, atom.Body)
+	}
+	if body == nil {
+		body = root
+	}
+
+	var sb strings.Builder
+	writeText(&sb, body)
+
+	return Documents{{ID: filepath.ToSlash(path), Text: collapseWhitespace(sb.String()), Metadata: meta}}, nil
+}
+
+// boilerplate are elements whose content isn't part of the page's text.
+var boilerplate = map[atom.Atom]bool{
+	atom.Script:   true,
+	atom.Style:    true,
+	atom.Noscript: true,
+	atom.Template: true,
+	atom.Nav:      true,
+	atom.Header:   true,
+	atom.Footer:   true,
+	atom.Aside:    true,
+	atom.Form:     true,
+	atom.Iframe:   true,
+	atom.Svg:      true,
+	atom.Head:     true,
+}
+
+// blocks are elements which break lines.
+var blocks = map[atom.Atom]bool{
+	atom.P: true, atom.Div: true, atom.Br: true, atom.Li: true, atom.Tr: true,
+	atom.H1: true, atom.H2: true, atom.H3: true, atom.H4: true, atom.H5: true, atom.H6: true,
+	atom.Section: true, atom.Article: true, atom.Blockquote: true, atom.Pre: true,
+	atom.Table: true, atom.Ul: true, atom.Ol: true, atom.Dl: true, atom.Dt: true, atom.Dd: true,
+	atom.Hr: true, atom.Main: true, atom.Figure: true, atom.Figcaption: true,
+}
+
+func writeText(sb *strings.Builder, n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		// Whitespace around text separates it from neighbouring inline
+		// elements, runs of it are collapsed by collapseWhitespace.
+		if r, _ := utf8.DecodeRuneInString(n.Data); unicode.IsSpace(r) {
+			sb.WriteString(" ")
+		}
+		sb.WriteString(strings.Join(strings.Fields(n.Data), " "))
+		if r, _ := utf8.DecodeLastRuneInString(n.Data); unicode.IsSpace(r) {
+			sb.WriteString(" ")
+		}
+		return
+	case html.ElementNode:
+		if boilerplate[n.DataAtom] {
+			return
+		}
+	}
+
+	block := n.Type == html.ElementNode && blocks[n.DataAtom]
+	if block {
+		sb.WriteString("\n")
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		writeText(sb, c)
+	}
+	if block {
+		sb.WriteString("\n")
+	}
+}
+
+// collapseWhitespace collapses runs of spaces within lines and runs of
+// blank lines into one.
+func collapseWhitespace(text string) string {
+	lines := strings.Split(text, "\n")
+	out := make([]string, 0, len(lines))
+	blank := false
+	for _, line := range lines {
+		line = strings.Join(strings.Fields(line), " ")
+		if line == "" {
+			blank = len(out) > 0
+			continue
+		}
+		if blank {
+			out = append(out, "")
+			blank = false
+		}
+		out = append(out, line)
+	}
+	return strings.Join(out, "\n")
+}
+
+func htmlTitle(root *html.Node) string {
+	title := findElement(root, atom.Title)
+	if title == nil {
+		return ""
+	}
+	var sb strings.Builder
+	for c := title.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.TextNode {
+			sb.WriteString(c.Data)
+		}
+	}
+	return sb.String()
+}
+
+func findElement(n *html.Node, a atom.Atom) *html.Node {
+	if n.Type == html.ElementNode && n.DataAtom == a {
+		return n
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if found := findElement(c, a); found != nil {
+			return found
+		}
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package loaders
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/kristofferostlund/chroma-go/chroma"
+)
+
+var _ Loader = (*JSONLLoader)(nil)
+
+// JSONLLoader loads every line of a JSON lines file as a document. Fields
+// are addressed by dotted paths, such as "body.text".
+type JSONLLoader struct {
+	conf JSONLConfig
+}
+
+type JSONLConfig struct {
+	textField      string
+	metadataFields map[string]string
+	idField        string
+}
+
+type JSONLOpt func(c *JSONLConfig)
+
+// MetadataField adds the value of field to each document's metadata under key.
+// Only strings, numbers and booleans are added.
+func MetadataField(key, field string) JSONLOpt {
+	return func(c *JSONLConfig) {
+		c.metadataFields[key] = field
+	}
+}
+
+// IDField sets the field holding each document's ID, which defaults to the
+// file path followed by the line number.
+func IDField(field string) JSONLOpt {
+	return func(c *JSONLConfig) {
+		c.idField = field
+	}
+}
+
+func NewJSONLLoader(textField string, opts ...JSONLOpt) *JSONLLoader {
+	conf := JSONLConfig{textField: textField, metadataFields: map[string]string{}, idField: ""}
+	for _, opt := range opts {
+		opt(&conf)
+	}
+	return &JSONLLoader{conf: conf}
+}
+
+func (l *JSONLLoader) Load(ctx context.Context, path string) (Documents, error) {
+	content, fileMeta, err := readFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	docs := make(Documents, 0)
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+
+		var record map[string]interface{}
+		decoder := json.NewDecoder(bytes.NewReader(scanner.Bytes()))
+		decoder.UseNumber()
+		if err := decoder.Decode(&record); err != nil {
+			return nil, fmt.Errorf("decoding line %d: %w", line, err)
+		}
+
+		text, ok := lookupField(record, l.conf.textField).(string)
+		if !ok {
+			return nil, fmt.Errorf("%w: line %d has no string field %q", chroma.ErrInvalidInput, line, l.conf.textField)
+		}
+
+		meta := rowMetadata(fileMeta, text, line)
+		for key, field := range l.conf.metadataFields {
+			if v, ok := metadataValue(lookupField(record, field)); ok {
+				meta[key] = v
+			}
+		}
+
+		id := filepath.ToSlash(path) + "#" + strconv.Itoa(line)
+		if l.conf.idField != "" {
+			v, ok := metadataValue(lookupField(record, l.conf.idField))
+			if !ok {
+				return nil, fmt.Errorf("%w: line %d has no ID field %q", chroma.ErrInvalidInput, line, l.conf.idField)
+			}
+			id = fmt.Sprint(v)
+		}
+
+		docs = append(docs, chroma.SourceDoc{ID: id, Text: text, Metadata: meta})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading lines: %w", err)
+	}
+
+	return docs, nil
+}
+
+func lookupField(record map[string]interface{}, field string) interface{} {
+	var current interface{} = record
+	for _, part := range strings.Split(field, ".") {
+		obj, ok := current.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		current = obj[part]
+	}
+	return current
+}
+
+// metadataValue converts a decoded JSON value to a metadata value.
+func metadataValue(v interface{}) (interface{}, bool) {
+	switch v := v.(type) {
+	case string:
+		return v, true
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i, true
+		}
+		f, err := v.Float64()
+		return f, err == nil
+	case bool:
+		// Metadata values are limited to strings and numbers.
+		return strconv.FormatBool(v), true
+	default:
+		return nil, false
+	}
+}
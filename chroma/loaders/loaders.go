@@ -0,0 +1,186 @@
+// Package loaders turns local files into source documents with metadata,
+// ready for Collection.Add, Collection.Upsert or Collection.IngestDocuments.
+package loaders
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kristofferostlund/chroma-go/chroma"
+)
+
+// Metadata keys set on every loaded document.
+const (
+	MetadataSource      = "source"
+	MetadataMTime       = "mtime"
+	MetadataContentHash = chroma.MetadataContentHash
+	MetadataTitle       = "title"
+	// MetadataRow is the 1-based row or line number of documents loaded from
+	// CSV and JSONL files, not counting the header.
+	MetadataRow = "row"
+)
+
+// Loader loads the documents of a single file.
+type Loader interface {
+	Load(ctx context.Context, path string) (Documents, error)
+}
+
+// LoaderFunc adapts a function to a Loader.
+type LoaderFunc func(ctx context.Context, path string) (Documents, error)
+
+func (f LoaderFunc) Load(ctx context.Context, path string) (Documents, error) {
+	return f(ctx, path)
+}
+
+type Documents []chroma.SourceDoc
+
+// IDs returns the IDs of the documents, ready for Collection.Add.
+func (ds Documents) IDs() []chroma.ID {
+	ids := make([]chroma.ID, 0, len(ds))
+	for _, d := range ds {
+		ids = append(ids, d.ID)
+	}
+	return ids
+}
+
+// Documents returns the texts of the documents, ready for Collection.Add.
+func (ds Documents) Documents() []chroma.Document {
+	docs := make([]chroma.Document, 0, len(ds))
+	for _, d := range ds {
+		docs = append(docs, d.Text)
+	}
+	return docs
+}
+
+// Metadatas returns the metadata of the documents, ready for Collection.Add.
+func (ds Documents) Metadatas() []chroma.Metadata {
+	metas := make([]chroma.Metadata, 0, len(ds))
+	for _, d := range ds {
+		metas = append(metas, d.Metadata)
+	}
+	return metas
+}
+
+// ContentHash returns the hex encoded SHA-256 of content.
+func ContentHash(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// readFile reads the file at path along with the metadata every document gets.
+func readFile(path string) ([]byte, chroma.Metadata, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading file info: %w", err)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading file: %w", err)
+	}
+
+	meta := chroma.Metadata{
+		MetadataSource:      filepath.ToSlash(path),
+		MetadataMTime:       info.ModTime().Unix(),
+		MetadataContentHash: ContentHash(content),
+		MetadataTitle:       strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)),
+	}
+	return content, meta, nil
+}
+
+// DefaultLoaders returns the loaders used by LoadDir by file extension.
+// CSV and JSONL files aren't included since they need to know which
+// column or field holds the text.
+func DefaultLoaders() map[string]Loader {
+	return map[string]Loader{
+		".txt":      NewTextLoader(),
+		".text":     NewTextLoader(),
+		".md":       NewMarkdownLoader(),
+		".markdown": NewMarkdownLoader(),
+		".html":     NewHTMLLoader(),
+		".htm":      NewHTMLLoader(),
+	}
+}
+
+type dirOpts struct {
+	loaders       map[string]Loader
+	skipUnknown   bool
+	includeHidden bool
+}
+
+type DirOpt func(*dirOpts)
+
+// WithLoader sets the loader used for files with the given extension,
+// including the leading dot.
+func WithLoader(ext string, loader Loader) DirOpt {
+	return func(o *dirOpts) {
+		o.loaders[strings.ToLower(ext)] = loader
+	}
+}
+
+// FailOnUnknown makes LoadDir fail on files without a loader instead of skipping them.
+func FailOnUnknown() DirOpt {
+	return func(o *dirOpts) {
+		o.skipUnknown = false
+	}
+}
+
+// IncludeHidden makes LoadDir include files and directories starting with a dot.
+func IncludeHidden() DirOpt {
+	return func(o *dirOpts) {
+		o.includeHidden = true
+	}
+}
+
+// LoadDir loads every file in the directory tree at root with the loader for
+// its extension, in lexical order.
+func LoadDir(ctx context.Context, root string, opts ...DirOpt) (Documents, error) {
+	dOpts := &dirOpts{loaders: DefaultLoaders(), skipUnknown: true, includeHidden: false}
+	for _, opt := range opts {
+		opt(dOpts)
+	}
+
+	docs := make(Documents, 0)
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if path != root && !dOpts.includeHidden && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		loader, ok := dOpts.loaders[strings.ToLower(filepath.Ext(path))]
+		if !ok {
+			if dOpts.skipUnknown {
+				return nil
+			}
+			return fmt.Errorf("no loader for %s", path)
+		}
+
+		loaded, err := loader.Load(ctx, path)
+		if err != nil {
+			return fmt.Errorf("loading %s: %w", path, err)
+		}
+		docs = append(docs, loaded...)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("walking %s: %w", root, err)
+	}
+
+	return docs, nil
+}
@@ -0,0 +1,141 @@
+package loaders_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kristofferostlund/chroma-go/chroma/loaders"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("writing %s: %v", name, err)
+	}
+	return path
+}
+
+func loadOne(t *testing.T, loader loaders.Loader, path string) (string, map[string]interface{}) {
+	t.Helper()
+	docs, err := loader.Load(context.Background(), path)
+	if err != nil {
+		t.Fatalf("loading: %v", err)
+	}
+	if len(docs) != 1 {
+		t.Fatalf("got %d documents, want 1", len(docs))
+	}
+	return docs[0].Text, docs[0].Metadata
+}
+
+func TestHTMLLoaderInlineMarkup(t *testing.T) {
+	tests := []struct {
+		name, html, want string
+	}{
+		{"inline elements", `<p><b>Hello</b> world, <a href=x>link</a> here</p>`, "Hello world, link here"},
+		{"leading whitespace", `<p>one<i>	two</i>three</p>`, "one twothree"},
+		{"trailing tab", `<p><span>one	</span>two</p>`, "one two"},
+		{"no whitespace", `<p>un<b>break</b>able</p>`, "unbreakable"},
+		{"runs of whitespace", "<p>a \n\t <b> b </b>  c</p>", "a b c"},
+		{"blocks", `<div>one</div><div>two</div><p></p><p></p><p>three<br>four</p>`, "one\n\ntwo\n\nthree\n\nfour"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeFile(t, "page.html", "<html><body>"+tt.html+"</body></html>")
+			if got, _ := loadOne(t, loaders.NewHTMLLoader(), path); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHTMLLoaderBoilerplate(t *testing.T) {
+	path := writeFile(t, "page.html", `<!doctype html>
+<html>
+<head><title> The Title </title><style>p { color: red }</style></head>
+<body>
+<header>Site header</header>
+<nav><a href="/">Home</a></nav>
+<script>var tracking = true;</script>
+<p>Body text.</p>
+<aside>Sidebar</aside>
+<form><label>Search</label></form>
+<footer>Copyright</footer>
+</body>
+</html>`)
+
+	text, meta := loadOne(t, loaders.NewHTMLLoader(), path)
+	if want := "Body text."; text != want {
+		t.Errorf("got %q, want %q", text, want)
+	}
+	if got, want := meta[loaders.MetadataTitle], "The Title"; got != want {
+		t.Errorf("got title %q, want %q", got, want)
+	}
+}
+
+func TestHTMLLoaderPrefersMain(t *testing.T) {
+	path := writeFile(t, "page.html", `<html><body><div>Outside</div><main><h1>Heading</h1><p>Inside</p></main></body></html>`)
+
+	text, meta := loadOne(t, loaders.NewHTMLLoader(), path)
+	if want := "Heading\n\nInside"; text != want {
+		t.Errorf("got %q, want %q", text, want)
+	}
+	if got, want := meta[loaders.MetadataTitle], "page"; got != want {
+		t.Errorf("got title %q, want the file name %q", got, want)
+	}
+}
+
+func TestMarkdownLoaderFrontMatter(t *testing.T) {
+	content := `---
+title: "Front Matter Title"
+author: someone
+year: 2023
+source: elsewhere
+content_hash: abc
+mtime: 1
+---
+# Header Title
+
+Body.
+`
+	path := writeFile(t, "doc.md", content)
+
+	text, meta := loadOne(t, loaders.NewMarkdownLoader(), path)
+	if want := "# Header Title\n\nBody.\n"; text != want {
+		t.Errorf("got text %q, want %q", text, want)
+	}
+	for key, want := range map[string]interface{}{
+		loaders.MetadataTitle:       "Front Matter Title",
+		"author":                    "someone",
+		"year":                      int64(2023),
+		loaders.MetadataSource:      filepath.ToSlash(path),
+		loaders.MetadataContentHash: loaders.ContentHash([]byte(content)),
+	} {
+		if got := meta[key]; got != want {
+			t.Errorf("got %s %#v, want %#v", key, got, want)
+		}
+	}
+	if got := meta[loaders.MetadataMTime]; got == int64(1) {
+		t.Errorf("got mtime %v from the front matter, want the file's", got)
+	}
+}
+
+func TestMarkdownLoaderTitle(t *testing.T) {
+	tests := []struct {
+		name, content, want string
+	}{
+		{"first header", "intro\n## Sub\n# Main #\n", "Main"},
+		{"header in fence", "```\n# not a title\n```\n", "notes"},
+		{"unclosed front matter", "---\ntitle: x\n# Header\n", "Header"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeFile(t, "notes.md", tt.content)
+			if _, meta := loadOne(t, loaders.NewMarkdownLoader(), path); meta[loaders.MetadataTitle] != tt.want {
+				t.Errorf("got title %q, want %q", meta[loaders.MetadataTitle], tt.want)
+			}
+		})
+	}
+}
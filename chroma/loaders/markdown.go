@@ -0,0 +1,116 @@
+package loaders
+
+import (
+	"context"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/kristofferostlund/chroma-go/chroma"
+)
+
+var _ Loader = (*MarkdownLoader)(nil)
+
+// MarkdownLoader loads a markdown file as a single document. Front matter
+// between --- lines is removed from the text and its flat key: value pairs
+// are added to the metadata, except for the source, mtime and content hash
+// which always describe the file. The title is taken from the front matter,
+// the first header or the file name, in that order.
+type MarkdownLoader struct{}
+
+func NewMarkdownLoader() *MarkdownLoader {
+	return &MarkdownLoader{}
+}
+
+func (l *MarkdownLoader) Load(ctx context.Context, path string) (Documents, error) {
+	content, meta, err := readFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	frontMatter, body := splitFrontMatter(string(content))
+	if title := firstHeader(body); title != "" {
+		meta[MetadataTitle] = title
+	}
+	for k, v := range frontMatter {
+		if fileMetadata[k] {
+			continue
+		}
+		meta[k] = v
+	}
+
+	return Documents{{ID: filepath.ToSlash(path), Text: body, Metadata: meta}}, nil
+}
+
+// fileMetadata are the keys front matter can't override, since change
+// detection such as Collection.IngestDocuments relies on them.
+var fileMetadata = map[string]bool{
+	MetadataSource:      true,
+	MetadataMTime:       true,
+	MetadataContentHash: true,
+}
+
+// splitFrontMatter parses simple YAML front matter: one key: value per line,
+// where lists, nested values and comments are skipped.
+func splitFrontMatter(content string) (chroma.Metadata, string) {
+	content = strings.TrimPrefix(content, "\ufeff")
+	if !strings.HasPrefix(content, "---\n") && !strings.HasPrefix(content, "---\r\n") {
+		return nil, content
+	}
+
+	lines := strings.SplitAfter(content, "\n")
+	meta := chroma.Metadata{}
+	for i := 1; i < len(lines); i++ {
+		line := strings.TrimRight(lines[i], "\r\n")
+		if line == "---" || line == "..." {
+			return meta, strings.Join(lines[i+1:], "")
+		}
+		if strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if key == "" || value == "" || strings.HasPrefix(value, "[") || strings.HasPrefix(value, "{") {
+			continue
+		}
+		meta[key] = frontMatterValue(value)
+	}
+
+	// No closing line, so it wasn't front matter after all.
+	return nil, content
+}
+
+func frontMatterValue(value string) interface{} {
+	if unquoted, err := strconv.Unquote(value); err == nil {
+		return unquoted
+	}
+	if len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'' {
+		return strings.ReplaceAll(value[1:len(value)-1], "''", "'")
+	}
+	if i, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return i
+	}
+	if f, err := strconv.ParseFloat(value, 64); err == nil {
+		return f
+	}
+	return value
+}
+
+func firstHeader(body string) string {
+	inFence := false
+	for _, line := range strings.Split(body, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			inFence = !inFence
+			continue
+		}
+		if !inFence && strings.HasPrefix(trimmed, "# ") {
+			return strings.TrimSpace(strings.TrimRight(trimmed[2:], "#"))
+		}
+	}
+	return ""
+}
@@ -0,0 +1,24 @@
+package loaders
+
+import (
+	"context"
+	"path/filepath"
+)
+
+var _ Loader = (*TextLoader)(nil)
+
+// TextLoader loads a plain text file as a single document.
+type TextLoader struct{}
+
+func NewTextLoader() *TextLoader {
+	return &TextLoader{}
+}
+
+func (l *TextLoader) Load(ctx context.Context, path string) (Documents, error) {
+	content, meta, err := readFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return Documents{{ID: filepath.ToSlash(path), Text: string(content), Metadata: meta}}, nil
+}
@@ -0,0 +1,156 @@
+package chroma
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WithLogger logs requests at debug level, retries at warn level and
+// decoding failures at error level. The logger is passed on to embedding
+// generators through the context, see LoggerFromContext. Nothing is logged
+// by default.
+func WithLogger(logger *slog.Logger) ClientOpts {
+	return func(c *clientOpts) {
+		c.logger = logger
+	}
+}
+
+type loggerKey struct{}
+
+// ContextWithLogger returns a context carrying logger, which takes precedence
+// over the client's logger for operations using the context.
+func ContextWithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// LoggerFromContext returns the logger carried by ctx, or a logger discarding
+// everything if there is none. Client and collection operations put the
+// client's logger in the context they pass on to embedding generators.
+func LoggerFromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok && logger != nil {
+		return logger
+	}
+	return discardLogger
+}
+
+var discardLogger = slog.New(discardHandler{})
+
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (d discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return d }
+func (d discardHandler) WithGroup(string) slog.Handler           { return d }
+
+// requestLogger logs every request at debug level, with the logger from the
+// request's context unless it has one of its own.
+type requestLogger struct {
+	next   Doer
+	logger *slog.Logger
+}
+
+func (l requestLogger) Do(req *http.Request) (*http.Response, error) {
+	logger := l.logger
+	if logger == nil {
+		logger = LoggerFromContext(req.Context())
+	}
+	if !logger.Enabled(req.Context(), slog.LevelDebug) {
+		return l.next.Do(req)
+	}
+
+	start := time.Now()
+	res, err := l.next.Do(req)
+	attrs := []slog.Attr{
+		slog.String("method", req.Method),
+		slog.String("path", req.URL.Path),
+		slog.Duration("duration", time.Since(start)),
+		slog.Int64("request_size", req.ContentLength),
+		slog.Any("headers", redactedHeaders(req.Header)),
+	}
+	if err != nil {
+		attrs = append(attrs, slog.String("error", err.Error()))
+	} else {
+		attrs = append(attrs, slog.Int("status", res.StatusCode))
+		// The size is unknown, -1, for chunked responses.
+		if res.ContentLength >= 0 {
+			attrs = append(attrs, slog.Int64("response_size", res.ContentLength))
+		}
+	}
+	logger.LogAttrs(req.Context(), slog.LevelDebug, "chroma request", attrs...)
+	return res, err
+}
+
+// sensitiveHeaders are never logged.
+var sensitiveHeaders = map[string]bool{
+	"Authorization":  true,
+	"Cookie":         true,
+	"X-Api-Key":      true,
+	"X-Chroma-Token": true,
+}
+
+// redactedHeaders logs headers with credentials replaced.
+type redactedHeaders http.Header
+
+func (h redactedHeaders) LogValue() slog.Value {
+	attrs := make([]slog.Attr, 0, len(h))
+	for k, v := range h {
+		value := strings.Join(v, ", ")
+		if sensitiveHeaders[http.CanonicalHeaderKey(k)] {
+			value = "REDACTED"
+		}
+		attrs = append(attrs, slog.String(k, value))
+	}
+	return slog.GroupValue(attrs...)
+}
+
+// redactedBody logs a JSON body with embeddings replaced by their count,
+// since they are large and may leak the embedded content.
+type redactedBody []byte
+
+func (b redactedBody) LogValue() slog.Value {
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return slog.StringValue(string(b))
+	}
+	redacted, err := json.Marshal(redactEmbeddings(v))
+	if err != nil {
+		return slog.StringValue("REDACTED")
+	}
+	return slog.StringValue(string(redacted))
+}
+
+func redactEmbeddings(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, child := range v {
+			if k == "embeddings" || k == "query_embeddings" {
+				if list, ok := child.([]interface{}); ok {
+					v[k] = "REDACTED " + strconv.Itoa(len(list)) + " embeddings"
+					continue
+				}
+				v[k] = "REDACTED"
+				continue
+			}
+			v[k] = redactEmbeddings(child)
+		}
+	case []interface{}:
+		for i, child := range v {
+			v[i] = redactEmbeddings(child)
+		}
+	}
+	return v
+}
+
+// responseLogger returns the logger of the request behind res, with the
+// request's method and path.
+func responseLogger(res *http.Response) *slog.Logger {
+	if res.Request == nil {
+		return discardLogger
+	}
+	return LoggerFromContext(res.Request.Context()).With("method", res.Request.Method, "path", res.Request.URL.Path)
+}
@@ -0,0 +1,119 @@
+package chroma_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kristofferostlund/chroma-go/chroma"
+)
+
+// logLines decodes the JSON log lines written to buf.
+func logLines(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	t.Helper()
+	lines := make([]map[string]interface{}, 0)
+	dec := json.NewDecoder(buf)
+	for {
+		var line map[string]interface{}
+		if err := dec.Decode(&line); err == io.EOF {
+			return lines
+		} else if err != nil {
+			t.Fatalf("decoding log line: %v", err)
+		}
+		lines = append(lines, line)
+	}
+}
+
+func TestLoggingRequests(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/heartbeat":
+			_, _ = io.WriteString(w, `{"nanosecond heartbeat": 1}`)
+		case "/api/v1/version":
+			// Flushing before the end makes the response chunked.
+			_, _ = io.WriteString(w, `"0.`)
+			w.(http.Flusher).Flush()
+			_, _ = io.WriteString(w, `4.0"`)
+		}
+	}))
+	defer srv.Close()
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	auth := func(next chroma.Doer) chroma.Doer {
+		return chroma.DoerFunc(func(req *http.Request) (*http.Response, error) {
+			req.Header.Set("Authorization", "Bearer secret-token")
+			req.Header.Set("X-Chroma-Token", "other-secret")
+			req.Header.Set("X-Tenant", "visible")
+			return next.Do(req)
+		})
+	}
+	client := chroma.NewClient(srv.URL, chroma.WithLogger(logger), chroma.WithMiddleware(auth))
+
+	ctx := context.Background()
+	if _, err := client.Heartbeat(ctx); err != nil {
+		t.Fatalf("sending heartbeat: %v", err)
+	}
+	if version, err := client.Version(ctx); err != nil || version != "0.4.0" {
+		t.Fatalf("got version %q and error %v", version, err)
+	}
+
+	logged := buf.String()
+	for _, secret := range []string{"secret-token", "other-secret"} {
+		if strings.Contains(logged, secret) {
+			t.Errorf("got %q logged", secret)
+		}
+	}
+	sizes := make(map[string]interface{})
+	for _, line := range logLines(t, &buf) {
+		if line["msg"] != "chroma request" {
+			continue
+		}
+		headers, _ := line["headers"].(map[string]interface{})
+		if headers["Authorization"] != "REDACTED" || headers["X-Tenant"] != "visible" {
+			t.Errorf("got headers %v, want credentials redacted and the rest kept", headers)
+		}
+		if size, ok := line["response_size"]; ok {
+			sizes[line["path"].(string)] = size
+		}
+	}
+	if got := sizes["/api/v1/heartbeat"]; got != float64(len(`{"nanosecond heartbeat": 1}`)) {
+		t.Errorf("got response size %v, want the content length", got)
+	}
+	if got, ok := sizes["/api/v1/version"]; ok {
+		t.Errorf("got response size %v for a chunked response, want none", got)
+	}
+}
+
+func TestLoggingRedactsEmbeddings(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = io.WriteString(w, `{"error": "bad", "embeddings": [[1.5, 2.5], [3.5, 4.5]], "nested": {"query_embeddings": [[5.5]], "other": "kept"}}`)
+	}))
+	defer srv.Close()
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	client := chroma.NewClient(srv.URL, chroma.WithLogger(logger))
+	if _, err := client.CreateCollection(context.Background(), "docs"); err == nil {
+		t.Fatal("got no error for a bad request")
+	}
+
+	logged := buf.String()
+	for _, v := range []string{"1.5", "5.5"} {
+		if strings.Contains(logged, v) {
+			t.Errorf("got embedding value %s logged", v)
+		}
+	}
+	for _, want := range []string{"REDACTED 2 embeddings", "REDACTED 1 embeddings", "kept"} {
+		if !strings.Contains(logged, want) {
+			t.Errorf("got no %q in the log %s", want, logged)
+		}
+	}
+}
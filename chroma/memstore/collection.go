@@ -0,0 +1,562 @@
+package memstore
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/kristofferostlund/chroma-go/chroma"
+	"github.com/kristofferostlund/chroma-go/chroma/vecmath"
+)
+
+// record is an embedding with its document and metadata.
+type record struct {
+	ID        chroma.ID        `json:"id"`
+	Embedding chroma.Embedding `json:"embedding"`
+	Document  chroma.Document  `json:"document,omitempty"`
+	Metadata  chroma.Metadata  `json:"metadata,omitempty"`
+}
+
+// Collection is a collection in a Store. Its methods are safe for
+// concurrent use.
+type Collection struct {
+	store *Store
+
+	id       string
+	name     string
+	metadata chroma.Metadata
+	space    Space
+	distance vecmath.DistanceFunc
+	params   hnswParams
+	// dimension is set by the first embedding added.
+	dimension int
+	// records are kept in insertion order, which Get returns them in.
+	records []*record
+	byID    map[chroma.ID]*record
+	// index is nil if the store uses exact search.
+	index *hnswIndex
+}
+
+func newCollection(store *Store, id, name string, metadata chroma.Metadata) (*Collection, error) {
+	space, distance, err := spaceOf(metadata)
+	if err != nil {
+		return nil, err
+	}
+	params, err := hnswParamsOf(metadata)
+	if err != nil {
+		return nil, err
+	}
+	c := &Collection{
+		store:     store,
+		id:        id,
+		name:      name,
+		metadata:  metadata,
+		space:     space,
+		distance:  distance,
+		params:    params,
+		dimension: 0,
+		records:   make([]*record, 0),
+		byID:      make(map[chroma.ID]*record),
+		index:     nil,
+	}
+	if !store.conf.exact {
+		c.index = newHNSWIndex(distance, params)
+	}
+	return c, nil
+}
+
+func (c *Collection) ID() string {
+	return c.id
+}
+
+func (c *Collection) Name() string {
+	c.store.lock.Lock()
+	defer c.store.lock.Unlock()
+	return c.name
+}
+
+func (c *Collection) Metadata() chroma.Metadata {
+	c.store.lock.Lock()
+	defer c.store.lock.Unlock()
+	return c.metadata
+}
+
+// Space returns the collection's distance function, which can't be changed.
+func (c *Collection) Space() Space {
+	return c.space
+}
+
+// setMetadata replaces the metadata, of which only the search ef of the
+// index can be changed.
+func (c *Collection) setMetadata(metadata chroma.Metadata) error {
+	params, err := hnswParamsOf(metadata)
+	if err != nil {
+		return err
+	}
+	c.metadata = metadata
+	c.params.efSearch = params.efSearch
+	if c.index != nil {
+		c.index.setSearchEF(params.efSearch)
+	}
+	return nil
+}
+
+// rebuildIndex replaces the index with one without tombstones.
+func (c *Collection) rebuildIndex() {
+	if c.index == nil {
+		return
+	}
+	c.index = newHNSWIndex(c.distance, c.params)
+	if len(c.records) > 0 {
+		c.index.insertAll(c.records)
+	}
+}
+
+func (c *Collection) simple() chroma.SimpleCollection {
+	return chroma.SimpleCollection{ID: c.id, Name: c.name, Metadata: c.metadata}
+}
+
+// Modify renames the collection and replaces its metadata, skipping empty ones.
+func (c *Collection) Modify(name string, metadata chroma.Metadata) error {
+	c.store.lock.Lock()
+	defer c.store.lock.Unlock()
+
+	if name != "" && name != c.name {
+		if _, ok := c.store.collections[name]; ok {
+			return fmt.Errorf("renaming collection: %w: %q", ErrAlreadyExists, name)
+		}
+		delete(c.store.collections, c.name)
+		c.name = name
+		c.store.collections[name] = c
+	}
+	if len(metadata) > 0 {
+		if err := c.setMetadata(metadata); err != nil {
+			return fmt.Errorf("modifying collection: %w", err)
+		}
+	}
+	return c.store.persist()
+}
+
+// Add adds embeddings, failing if any of the IDs already exist.
+func (c *Collection) Add(ids []chroma.ID, embeddings []chroma.Embedding, metadatas []chroma.Metadata, documents []chroma.Document) error {
+	return c.write("adding", ids, embeddings, metadatas, documents, func(existing *record) error {
+		if existing != nil {
+			return ErrAlreadyExists
+		}
+		return nil
+	})
+}
+
+// Upsert adds embeddings, replacing the given fields of existing ones.
+func (c *Collection) Upsert(ids []chroma.ID, embeddings []chroma.Embedding, metadatas []chroma.Metadata, documents []chroma.Document) error {
+	return c.write("upserting", ids, embeddings, metadatas, documents, func(*record) error { return nil })
+}
+
+// Update replaces the given fields of existing embeddings, failing if any
+// of the IDs don't exist.
+func (c *Collection) Update(ids []chroma.ID, embeddings []chroma.Embedding, metadatas []chroma.Metadata, documents []chroma.Document) error {
+	return c.write("updating", ids, embeddings, metadatas, documents, func(existing *record) error {
+		if existing == nil {
+			return ErrNotFound
+		}
+		return nil
+	})
+}
+
+// write validates a whole batch with check before writing any of it.
+func (c *Collection) write(op string, ids []chroma.ID, embeddings []chroma.Embedding, metadatas []chroma.Metadata, documents []chroma.Document, check func(existing *record) error) error {
+	c.store.lock.Lock()
+	defer c.store.lock.Unlock()
+
+	if err := c.validateBatch(ids, embeddings, metadatas, documents); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	seen := make(map[chroma.ID]bool, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			return fmt.Errorf("%s: %w: duplicate ID %q in batch", op, chroma.ErrInvalidInput, id)
+		}
+		seen[id] = true
+
+		existing := c.byID[id]
+		if err := check(existing); err != nil {
+			return fmt.Errorf("%s %q: %w", op, id, err)
+		}
+		if existing == nil && len(embeddings) == 0 {
+			return fmt.Errorf("%s %q: %w: no embedding", op, id, chroma.ErrInvalidInput)
+		}
+	}
+
+	embedded := make([]*record, 0, len(embeddings))
+	for i, id := range ids {
+		r := c.byID[id]
+		if r == nil {
+			r = &record{ID: id, Embedding: nil, Document: "", Metadata: nil}
+			c.records = append(c.records, r)
+			c.byID[id] = r
+		}
+		if len(embeddings) > 0 {
+			r.Embedding = append(chroma.Embedding(nil), embeddings[i]...)
+			if c.dimension == 0 {
+				c.dimension = len(r.Embedding)
+			}
+			embedded = append(embedded, r)
+		}
+		if len(metadatas) > 0 {
+			r.Metadata = metadatas[i]
+		}
+		if len(documents) > 0 {
+			r.Document = documents[i]
+		}
+	}
+	if c.index != nil && len(embedded) > 0 {
+		// Replaced embeddings are tombstoned by the index, so repeatedly
+		// updating embeddings needs compaction just like deleting does.
+		c.index.insertAll(embedded)
+		if c.index.needsCompaction() {
+			c.rebuildIndex()
+		}
+	}
+	return c.store.persist()
+}
+
+func (c *Collection) validateBatch(ids []chroma.ID, embeddings []chroma.Embedding, metadatas []chroma.Metadata, documents []chroma.Document) error {
+	if len(ids) == 0 {
+		return fmt.Errorf("%w: no IDs", chroma.ErrInvalidInput)
+	}
+	for name, n := range map[string]int{"embeddings": len(embeddings), "metadatas": len(metadatas), "documents": len(documents)} {
+		if n > 0 && n != len(ids) {
+			return fmt.Errorf("%w: got %d %s for %d IDs", chroma.ErrInvalidInput, n, name, len(ids))
+		}
+	}
+
+	dimension := c.dimension
+	for i, e := range embeddings {
+		if len(e) == 0 {
+			return fmt.Errorf("%w: empty embedding for %q", chroma.ErrInvalidInput, ids[i])
+		}
+		if dimension == 0 {
+			dimension = len(e)
+		}
+		if len(e) != dimension {
+			return fmt.Errorf("%w: embedding dimension %d for %q, want %d", chroma.ErrInvalidInput, len(e), ids[i], dimension)
+		}
+	}
+	return nil
+}
+
+// GetRequest selects embeddings, the IDs and both filters must all match.
+type GetRequest struct {
+	// IDs selects embeddings by ID, all if empty.
+	IDs           []chroma.ID
+	Where         chroma.Where
+	WhereDocument chroma.Where
+	// Limit is the maximum number of embeddings returned, unlimited if 0.
+	Limit  int
+	Offset int
+	// Include selects the returned fields, documents and metadatas if nil.
+	// IDs are always returned.
+	Include []chroma.Include
+}
+
+// Get returns embeddings in insertion order.
+func (c *Collection) Get(req GetRequest) (*chroma.EmbeddingResponse, error) {
+	include := req.Include
+	if include == nil {
+		include = []chroma.Include{chroma.IncludeDocuments, chroma.IncludeMetadatas}
+	}
+	inc, err := includesOf(include, false)
+	if err != nil {
+		return nil, err
+	}
+
+	c.store.lock.Lock()
+	defer c.store.lock.Unlock()
+
+	matched, err := c.filter(req.IDs, req.Where, req.WhereDocument)
+	if err != nil {
+		return nil, err
+	}
+	if req.Offset > 0 {
+		matched = matched[minInt(req.Offset, len(matched)):]
+	}
+	if req.Limit > 0 {
+		matched = matched[:minInt(req.Limit, len(matched))]
+	}
+
+	res := &chroma.EmbeddingResponse{IDs: make([]string, 0, len(matched)), Embeddings: nil, Documents: nil, Metadatas: nil}
+	if inc.embeddings {
+		res.Embeddings = make([]chroma.Embedding, 0, len(matched))
+	}
+	if inc.documents {
+		res.Documents = make([]chroma.Document, 0, len(matched))
+	}
+	if inc.metadatas {
+		res.Metadatas = make([]chroma.Metadata, 0, len(matched))
+	}
+	for _, r := range matched {
+		res.IDs = append(res.IDs, r.ID)
+		if inc.embeddings {
+			res.Embeddings = append(res.Embeddings, append(chroma.Embedding(nil), r.Embedding...))
+		}
+		if inc.documents {
+			res.Documents = append(res.Documents, r.Document)
+		}
+		if inc.metadatas {
+			res.Metadatas = append(res.Metadatas, r.Metadata)
+		}
+	}
+	return res, nil
+}
+
+// Delete deletes the embeddings matching the IDs and both filters, all of
+// them if none are given, and returns the deleted IDs.
+func (c *Collection) Delete(ids []chroma.ID, where, whereDocument chroma.Where) ([]chroma.ID, error) {
+	c.store.lock.Lock()
+	defer c.store.lock.Unlock()
+
+	matched, err := c.filter(ids, where, whereDocument)
+	if err != nil {
+		return nil, err
+	}
+
+	deleted := make([]chroma.ID, 0, len(matched))
+	for _, r := range matched {
+		delete(c.byID, r.ID)
+		if c.index != nil {
+			c.index.delete(r.ID)
+		}
+		deleted = append(deleted, r.ID)
+	}
+	kept := make([]*record, 0, len(c.records)-len(deleted))
+	for _, r := range c.records {
+		if _, ok := c.byID[r.ID]; ok {
+			kept = append(kept, r)
+		}
+	}
+	c.records = kept
+	if len(c.records) == 0 {
+		c.dimension = 0
+	}
+	if len(c.records) == 0 || (c.index != nil && c.index.needsCompaction()) {
+		c.rebuildIndex()
+	}
+	return deleted, c.store.persist()
+}
+
+func (c *Collection) Count() int {
+	c.store.lock.Lock()
+	defer c.store.lock.Unlock()
+	return len(c.records)
+}
+
+// QueryRequest finds the nearest neighbours of embeddings among those
+// matching both filters.
+type QueryRequest struct {
+	Embeddings    []chroma.Embedding
+	NResults      int
+	Where         chroma.Where
+	WhereDocument chroma.Where
+	// Include selects the returned fields, documents, metadatas and
+	// distances if nil. IDs are always returned.
+	Include []chroma.Include
+}
+
+// Query returns the NResults nearest neighbours of each embedding, nearest
+// first, or fewer if fewer match. The search is approximate using the HNSW
+// index, except if the store uses exact search or filters match few
+// embeddings.
+func (c *Collection) Query(req QueryRequest) (*chroma.QueryResult, error) {
+	if len(req.Embeddings) == 0 {
+		return nil, fmt.Errorf("%w: no query embeddings", chroma.ErrInvalidInput)
+	}
+	if req.NResults <= 0 {
+		return nil, fmt.Errorf("%w: nResults must be positive, got %d", chroma.ErrInvalidInput, req.NResults)
+	}
+	include := req.Include
+	if include == nil {
+		include = []chroma.Include{chroma.IncludeDocuments, chroma.IncludeMetadatas, chroma.IncludeDistances}
+	}
+	inc, err := includesOf(include, true)
+	if err != nil {
+		return nil, err
+	}
+
+	c.store.lock.Lock()
+	defer c.store.lock.Unlock()
+
+	for _, e := range req.Embeddings {
+		if c.dimension != 0 && len(e) != c.dimension {
+			return nil, fmt.Errorf("%w: query embedding dimension %d, want %d", chroma.ErrInvalidInput, len(e), c.dimension)
+		}
+	}
+	filtered := len(req.Where) > 0 || len(req.WhereDocument) > 0
+	var candidates []*record
+	if filtered || c.index == nil {
+		candidates, err = c.filter(nil, req.Where, req.WhereDocument)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	n := len(req.Embeddings)
+	res := &chroma.QueryResult{IDs: make([][]chroma.ID, 0, n), Embeddings: nil, Documents: nil, Metadatas: nil, Distances: nil}
+	if inc.embeddings {
+		res.Embeddings = make([][]chroma.Embedding, 0, n)
+	}
+	if inc.documents {
+		res.Documents = make([][]chroma.Document, 0, n)
+	}
+	if inc.metadatas {
+		res.Metadatas = make([][]chroma.Metadata, 0, n)
+	}
+	if inc.distances {
+		res.Distances = make([][]float64, 0, n)
+	}
+
+	for _, query := range req.Embeddings {
+		var hits []hit
+		if c.index != nil && (!filtered || len(candidates) > exactSearchLimit) {
+			hits = c.search(query, candidates, filtered, req.NResults)
+		} else {
+			hits = c.nearest(query, candidates, req.NResults)
+		}
+
+		ids := make([]chroma.ID, 0, len(hits))
+		embeddings := make([]chroma.Embedding, 0, len(hits))
+		documents := make([]chroma.Document, 0, len(hits))
+		metadatas := make([]chroma.Metadata, 0, len(hits))
+		distances := make([]float64, 0, len(hits))
+		for _, h := range hits {
+			ids = append(ids, h.record.ID)
+			embeddings = append(embeddings, append(chroma.Embedding(nil), h.record.Embedding...))
+			documents = append(documents, h.record.Document)
+			metadatas = append(metadatas, h.record.Metadata)
+			distances = append(distances, h.distance)
+		}
+
+		res.IDs = append(res.IDs, ids)
+		if inc.embeddings {
+			res.Embeddings = append(res.Embeddings, embeddings)
+		}
+		if inc.documents {
+			res.Documents = append(res.Documents, documents)
+		}
+		if inc.metadatas {
+			res.Metadatas = append(res.Metadatas, metadatas)
+		}
+		if inc.distances {
+			res.Distances = append(res.Distances, distances)
+		}
+	}
+	return res, nil
+}
+
+type hit struct {
+	record   *record
+	distance float64
+}
+
+// exactSearchLimit is the number of embeddings matching a query's filters
+// up to which they are searched exactly, since the index would traverse
+// mostly embeddings not matching.
+const exactSearchLimit = 1000
+
+// search returns the n embeddings nearest to query using the index, only
+// returning candidates if filtered.
+func (c *Collection) search(query chroma.Embedding, candidates []*record, filtered bool, n int) []hit {
+	var allow func(id chroma.ID) bool
+	if filtered {
+		allowed := make(map[chroma.ID]bool, len(candidates))
+		for _, r := range candidates {
+			allowed[r.ID] = true
+		}
+		allow = func(id chroma.ID) bool { return allowed[id] }
+	}
+
+	found := c.index.search(query, n, allow)
+	if filtered && len(found) < minInt(n, len(candidates)) {
+		// The index can miss matches in regions it didn't reach.
+		return c.nearest(query, candidates, n)
+	}
+	hits := make([]hit, 0, len(found))
+	for _, h := range found {
+		hits = append(hits, hit{record: c.byID[h.id], distance: h.distance})
+	}
+	return hits
+}
+
+// nearest returns the n candidates nearest to query, ties in insertion order.
+func (c *Collection) nearest(query chroma.Embedding, candidates []*record, n int) []hit {
+	hits := make([]hit, 0, len(candidates))
+	for _, r := range candidates {
+		hits = append(hits, hit{record: r, distance: c.distance(query, r.Embedding)})
+	}
+	sort.SliceStable(hits, func(i, j int) bool { return hits[i].distance < hits[j].distance })
+	return hits[:minInt(n, len(hits))]
+}
+
+// filter returns the records matching ids, where and whereDocument, in
+// insertion order. Unknown IDs are ignored.
+func (c *Collection) filter(ids []chroma.ID, where, whereDocument chroma.Where) ([]*record, error) {
+	matchWhere, err := compileWhere(where)
+	if err != nil {
+		return nil, err
+	}
+	matchDocument, err := compileWhereDocument(whereDocument)
+	if err != nil {
+		return nil, err
+	}
+
+	var wanted map[chroma.ID]bool
+	if len(ids) > 0 {
+		wanted = make(map[chroma.ID]bool, len(ids))
+		for _, id := range ids {
+			wanted[id] = true
+		}
+	}
+
+	matched := make([]*record, 0)
+	for _, r := range c.records {
+		if wanted != nil && !wanted[r.ID] {
+			continue
+		}
+		if matchWhere(r) && matchDocument(r) {
+			matched = append(matched, r)
+		}
+	}
+	return matched, nil
+}
+
+type includes struct {
+	embeddings, documents, metadatas, distances bool
+}
+
+func includesOf(include []chroma.Include, allowDistances bool) (includes, error) {
+	var inc includes
+	for _, i := range include {
+		switch i {
+		case chroma.IncludeEmbeddings:
+			inc.embeddings = true
+		case chroma.IncludeDocuments:
+			inc.documents = true
+		case chroma.IncludeMetadatas:
+			inc.metadatas = true
+		case chroma.IncludeDistances:
+			if !allowDistances {
+				return inc, fmt.Errorf("%w: distances can only be included in queries", chroma.ErrInvalidInput)
+			}
+			inc.distances = true
+		default:
+			return inc, fmt.Errorf("%w: unknown include %q", chroma.ErrInvalidInput, i)
+		}
+	}
+	return inc, nil
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,36 @@
+package memstore
+
+import (
+	"fmt"
+
+	"github.com/kristofferostlund/chroma-go/chroma"
+	"github.com/kristofferostlund/chroma-go/chroma/vecmath"
+)
+
+// MetadataSpace is the collection metadata key selecting the distance
+// function, like Chroma's HNSW index.
+const MetadataSpace = "hnsw:space"
+
+// Space is a distance function, see vecmath.Space.
+type Space = vecmath.Space
+
+const (
+	SpaceL2     = vecmath.SpaceL2
+	SpaceCosine = vecmath.SpaceCosine
+	SpaceIP     = vecmath.SpaceIP
+)
+
+// spaceOf returns the space selected by collection metadata and its
+// distance function, SpaceL2 by default.
+func spaceOf(metadata chroma.Metadata) (Space, vecmath.DistanceFunc, error) {
+	v, ok := metadata[MetadataSpace]
+	if !ok {
+		return SpaceL2, vecmath.L2, nil
+	}
+	s, _ := v.(string)
+	distance, err := vecmath.Distance(Space(s))
+	if err != nil {
+		return "", nil, fmt.Errorf("%w: unknown %s %v, want one of l2, cosine, ip", chroma.ErrInvalidInput, MetadataSpace, v)
+	}
+	return Space(s), distance, nil
+}
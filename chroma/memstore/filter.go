@@ -0,0 +1,295 @@
+package memstore
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/kristofferostlund/chroma-go/chroma"
+)
+
+// predicate reports whether a record matches a filter.
+type predicate func(r *record) bool
+
+func matchAll(*record) bool { return true }
+
+// compileWhere compiles a metadata filter, such as
+//
+//	{"$and": [{"source": "a.md"}, {"chunk_index": {"$gte": 2}}]}
+//
+// Fields compare with $eq, $ne, $gt, $gte, $lt, $lte, $in and $nin, a plain
+// value meaning $eq. Several fields in one filter must all match.
+func compileWhere(where chroma.Where) (predicate, error) {
+	if len(where) == 0 {
+		return matchAll, nil
+	}
+
+	preds := make([]predicate, 0, len(where))
+	for key, value := range where {
+		var (
+			p   predicate
+			err error
+		)
+		switch key {
+		case "$and", "$or":
+			p, err = compileLogical(key, value, func(v interface{}) (predicate, error) {
+				w, ok := v.(map[string]interface{})
+				if !ok {
+					return nil, fmt.Errorf("%w: %s operands must be objects, got %T", chroma.ErrInvalidInput, key, v)
+				}
+				return compileWhere(w)
+			})
+		default:
+			if strings.HasPrefix(key, "$") {
+				return nil, fmt.Errorf("%w: unknown where operator %q", chroma.ErrInvalidInput, key)
+			}
+			p, err = compileField(key, value)
+		}
+		if err != nil {
+			return nil, err
+		}
+		preds = append(preds, p)
+	}
+	return and(preds), nil
+}
+
+// compileWhereDocument compiles a document filter, such as
+//
+//	{"$or": [{"$contains": "chroma"}, {"$not_contains": "pinecone"}]}
+func compileWhereDocument(where chroma.Where) (predicate, error) {
+	if len(where) == 0 {
+		return matchAll, nil
+	}
+
+	preds := make([]predicate, 0, len(where))
+	for key, value := range where {
+		var (
+			p   predicate
+			err error
+		)
+		switch key {
+		case "$and", "$or":
+			p, err = compileLogical(key, value, func(v interface{}) (predicate, error) {
+				w, ok := v.(map[string]interface{})
+				if !ok {
+					return nil, fmt.Errorf("%w: %s operands must be objects, got %T", chroma.ErrInvalidInput, key, v)
+				}
+				return compileWhereDocument(w)
+			})
+		case "$contains", "$not_contains":
+			s, ok := value.(string)
+			if !ok {
+				return nil, fmt.Errorf("%w: %s operand must be a string, got %T", chroma.ErrInvalidInput, key, value)
+			}
+			contains := key == "$contains"
+			p = func(r *record) bool { return strings.Contains(r.Document, s) == contains }
+		default:
+			return nil, fmt.Errorf("%w: unknown where document operator %q", chroma.ErrInvalidInput, key)
+		}
+		if err != nil {
+			return nil, err
+		}
+		preds = append(preds, p)
+	}
+	return and(preds), nil
+}
+
+func compileLogical(op string, value interface{}, compile func(interface{}) (predicate, error)) (predicate, error) {
+	operands, ok := toList(value)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s operand must be a list, got %T", chroma.ErrInvalidInput, op, value)
+	}
+	if len(operands) < 2 {
+		return nil, fmt.Errorf("%w: %s needs at least two operands, got %d", chroma.ErrInvalidInput, op, len(operands))
+	}
+
+	preds := make([]predicate, 0, len(operands))
+	for _, operand := range operands {
+		p, err := compile(operand)
+		if err != nil {
+			return nil, err
+		}
+		preds = append(preds, p)
+	}
+	if op == "$or" {
+		return or(preds), nil
+	}
+	return and(preds), nil
+}
+
+func compileField(key string, value interface{}) (predicate, error) {
+	ops, ok := value.(map[string]interface{})
+	if !ok {
+		return compileComparison(key, "$eq", value)
+	}
+	if len(ops) != 1 {
+		return nil, fmt.Errorf("%w: %q must have exactly one operator, got %d", chroma.ErrInvalidInput, key, len(ops))
+	}
+	for op, operand := range ops {
+		return compileComparison(key, op, operand)
+	}
+	panic("unreachable")
+}
+
+func compileComparison(key, op string, operand interface{}) (predicate, error) {
+	field := func(r *record) (interface{}, bool) {
+		v, ok := r.Metadata[key]
+		return v, ok
+	}
+
+	switch op {
+	case "$eq", "$ne":
+		if !isScalar(operand) {
+			return nil, fmt.Errorf("%w: %s operand of %q must be a string, number or bool, got %T", chroma.ErrInvalidInput, op, key, operand)
+		}
+		want := op == "$eq"
+		return func(r *record) bool {
+			v, ok := field(r)
+			return ok && equal(v, operand) == want
+		}, nil
+	case "$gt", "$gte", "$lt", "$lte":
+		bound, ok := toFloat(operand)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s operand of %q must be a number, got %T", chroma.ErrInvalidInput, op, key, operand)
+		}
+		return func(r *record) bool {
+			v, ok := field(r)
+			if !ok {
+				return false
+			}
+			f, ok := toFloat(v)
+			if !ok {
+				return false
+			}
+			switch op {
+			case "$gt":
+				return f > bound
+			case "$gte":
+				return f >= bound
+			case "$lt":
+				return f < bound
+			default:
+				return f <= bound
+			}
+		}, nil
+	case "$in", "$nin":
+		list, ok := toList(operand)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s operand of %q must be a list, got %T", chroma.ErrInvalidInput, op, key, operand)
+		}
+		want := op == "$in"
+		return func(r *record) bool {
+			v, ok := field(r)
+			if !ok {
+				return false
+			}
+			for _, item := range list {
+				if equal(v, item) {
+					return want
+				}
+			}
+			return !want
+		}, nil
+	default:
+		return nil, fmt.Errorf("%w: unknown operator %q for %q", chroma.ErrInvalidInput, op, key)
+	}
+}
+
+func and(preds []predicate) predicate {
+	if len(preds) == 1 {
+		return preds[0]
+	}
+	return func(r *record) bool {
+		for _, p := range preds {
+			if !p(r) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+func or(preds []predicate) predicate {
+	return func(r *record) bool {
+		for _, p := range preds {
+			if p(r) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func isScalar(v interface{}) bool {
+	switch v.(type) {
+	case string, bool:
+		return true
+	default:
+		_, ok := toFloat(v)
+		return ok
+	}
+}
+
+// equal compares metadata values, numbers by value regardless of their type.
+func equal(a, b interface{}) bool {
+	if fa, ok := toFloat(a); ok {
+		fb, ok := toFloat(b)
+		return ok && fa == fb
+	}
+	if !isScalar(a) || !isScalar(b) {
+		return false
+	}
+	return a == b
+}
+
+// toList accepts any slice, since filters built in Go may use typed slices
+// such as []chroma.Where or []string.
+func toList(v interface{}) ([]interface{}, bool) {
+	if list, ok := v.([]interface{}); ok {
+		return list, true
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice {
+		return nil, false
+	}
+	list := make([]interface{}, 0, rv.Len())
+	for i := 0; i < rv.Len(); i++ {
+		list = append(list, rv.Index(i).Interface())
+	}
+	return list, true
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int8:
+		return float64(v), true
+	case int16:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	case uint8:
+		return float64(v), true
+	case uint16:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,136 @@
+package memstore
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/kristofferostlund/chroma-go/chroma"
+)
+
+func filterRecords() []*record {
+	return []*record{
+		{ID: "a", Embedding: nil, Document: "chroma is a vector store", Metadata: chroma.Metadata{"n": int64(1), "tag": "x", "ok": true}},
+		{ID: "b", Embedding: nil, Document: "pinecone is one too", Metadata: chroma.Metadata{"n": 2.5, "tag": "y"}},
+		{ID: "c", Embedding: nil, Document: "chroma and pinecone", Metadata: chroma.Metadata{"n": json.Number("3"), "tag": "x", "ok": false}},
+		{ID: "d", Embedding: nil, Document: "", Metadata: nil},
+	}
+}
+
+func matching(p predicate) []chroma.ID {
+	ids := []chroma.ID{}
+	for _, r := range filterRecords() {
+		if p(r) {
+			ids = append(ids, r.ID)
+		}
+	}
+	return ids
+}
+
+func TestCompileWhere(t *testing.T) {
+	tests := []struct {
+		name  string
+		where chroma.Where
+		want  []chroma.ID
+	}{
+		{"empty", nil, []chroma.ID{"a", "b", "c", "d"}},
+		{"plain value", chroma.Where{"tag": "x"}, []chroma.ID{"a", "c"}},
+		{"bool", chroma.Where{"ok": true}, []chroma.ID{"a"}},
+		{"$eq across number types", chroma.Where{"n": map[string]interface{}{"$eq": 3}}, []chroma.ID{"c"}},
+		{"$ne skips missing fields", chroma.Where{"tag": map[string]interface{}{"$ne": "x"}}, []chroma.ID{"b"}},
+		{"$gt", chroma.Where{"n": map[string]interface{}{"$gt": 2.5}}, []chroma.ID{"c"}},
+		{"$gte", chroma.Where{"n": map[string]interface{}{"$gte": 2.5}}, []chroma.ID{"b", "c"}},
+		{"$lt", chroma.Where{"n": map[string]interface{}{"$lt": json.Number("2.5")}}, []chroma.ID{"a"}},
+		{"$lte", chroma.Where{"n": map[string]interface{}{"$lte": int64(1)}}, []chroma.ID{"a"}},
+		{"$gt on strings", chroma.Where{"tag": map[string]interface{}{"$gt": 0}}, []chroma.ID{}},
+		{"$in", chroma.Where{"n": map[string]interface{}{"$in": []interface{}{1, 3.0}}}, []chroma.ID{"a", "c"}},
+		{"$in typed slice", chroma.Where{"tag": map[string]interface{}{"$in": []string{"y", "z"}}}, []chroma.ID{"b"}},
+		{"$nin", chroma.Where{"tag": map[string]interface{}{"$nin": []string{"y"}}}, []chroma.ID{"a", "c"}},
+		{"several fields", chroma.Where{"tag": "x", "n": map[string]interface{}{"$gt": 1}}, []chroma.ID{"c"}},
+		{"$and", chroma.Where{"$and": []chroma.Where{{"tag": "x"}, {"ok": false}}}, []chroma.ID{"c"}},
+		{"$or", chroma.Where{"$or": []interface{}{
+			map[string]interface{}{"tag": "y"},
+			map[string]interface{}{"n": map[string]interface{}{"$lt": 2}},
+		}}, []chroma.ID{"a", "b"}},
+		{"nested", chroma.Where{"$or": []chroma.Where{
+			{"$and": []chroma.Where{{"tag": "x"}, {"n": map[string]interface{}{"$gte": 2}}}},
+			{"tag": "y"},
+		}}, []chroma.ID{"b", "c"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := compileWhere(tt.where)
+			if err != nil {
+				t.Fatalf("compiling: %v", err)
+			}
+			if got := matching(p); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompileWhereInvalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		where chroma.Where
+	}{
+		{"unknown operator", chroma.Where{"n": map[string]interface{}{"$like": 1}}},
+		{"unknown logical operator", chroma.Where{"$not": []chroma.Where{{"n": 1}, {"n": 2}}}},
+		{"several operators", chroma.Where{"n": map[string]interface{}{"$gt": 1, "$lt": 3}}},
+		{"$eq list", chroma.Where{"n": map[string]interface{}{"$eq": []int{1}}}},
+		{"plain list", chroma.Where{"n": []int{1}}},
+		{"$gt string", chroma.Where{"n": map[string]interface{}{"$gt": "1"}}},
+		{"$in scalar", chroma.Where{"n": map[string]interface{}{"$in": 1}}},
+		{"$and not a list", chroma.Where{"$and": chroma.Where{"n": 1}}},
+		{"$and one operand", chroma.Where{"$and": []chroma.Where{{"n": 1}}}},
+		{"$or operand not an object", chroma.Where{"$or": []interface{}{"a", "b"}}},
+		{"nested invalid", chroma.Where{"$or": []chroma.Where{{"n": 1}, {"n": map[string]interface{}{"$bad": 1}}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := compileWhere(tt.where); !errors.Is(err, chroma.ErrInvalidInput) {
+				t.Errorf("got error %v, want ErrInvalidInput", err)
+			}
+		})
+	}
+}
+
+func TestCompileWhereDocument(t *testing.T) {
+	tests := []struct {
+		name  string
+		where chroma.Where
+		want  []chroma.ID
+	}{
+		{"empty", nil, []chroma.ID{"a", "b", "c", "d"}},
+		{"$contains", chroma.Where{"$contains": "chroma"}, []chroma.ID{"a", "c"}},
+		{"$contains is case sensitive", chroma.Where{"$contains": "Chroma"}, []chroma.ID{}},
+		{"$not_contains", chroma.Where{"$not_contains": "pinecone"}, []chroma.ID{"a", "d"}},
+		{"$and", chroma.Where{"$and": []chroma.Where{{"$contains": "chroma"}, {"$contains": "pinecone"}}}, []chroma.ID{"c"}},
+		{"$or", chroma.Where{"$or": []chroma.Where{{"$contains": "vector"}, {"$contains": "too"}}}, []chroma.ID{"a", "b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := compileWhereDocument(tt.where)
+			if err != nil {
+				t.Fatalf("compiling: %v", err)
+			}
+			if got := matching(p); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	for _, where := range []chroma.Where{
+		{"$contains": 1},
+		{"$regex": "chroma"},
+		{"source": "a.md"},
+		{"$or": []chroma.Where{{"$contains": "a"}}},
+		{"$and": []interface{}{"a", "b"}},
+	} {
+		if _, err := compileWhereDocument(where); !errors.Is(err, chroma.ErrInvalidInput) {
+			t.Errorf("got error %v for %v, want ErrInvalidInput", err, where)
+		}
+	}
+}
@@ -0,0 +1,526 @@
+package memstore
+
+import (
+	"container/heap"
+	"fmt"
+	"math"
+	"math/rand"
+	"runtime"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/kristofferostlund/chroma-go/chroma"
+	"github.com/kristofferostlund/chroma-go/chroma/vecmath"
+)
+
+// Collection metadata keys tuning the HNSW index, like Chroma's. Only the
+// search ef can be changed after the collection is created.
+const (
+	MetadataM              = "hnsw:M"
+	MetadataConstructionEF = "hnsw:construction_ef"
+	MetadataSearchEF       = "hnsw:search_ef"
+	MetadataNumThreads     = "hnsw:num_threads"
+)
+
+type hnswParams struct {
+	// m is the number of neighbours per node and level, twice that on level 0.
+	m              int
+	efConstruction int
+	efSearch       int
+	// numThreads is the number of goroutines inserting a batch.
+	numThreads int
+}
+
+// hnswParamsOf returns the index parameters set by collection metadata,
+// defaulting to Chroma's defaults.
+func hnswParamsOf(metadata chroma.Metadata) (hnswParams, error) {
+	params := hnswParams{m: 16, efConstruction: 100, efSearch: 10, numThreads: runtime.NumCPU()}
+	for key, p := range map[string]*int{
+		MetadataM:              &params.m,
+		MetadataConstructionEF: &params.efConstruction,
+		MetadataSearchEF:       &params.efSearch,
+		MetadataNumThreads:     &params.numThreads,
+	} {
+		v, ok := metadata[key]
+		if !ok {
+			continue
+		}
+		f, ok := toFloat(v)
+		if !ok || f < 1 || f != math.Trunc(f) {
+			return hnswParams{}, fmt.Errorf("%w: %s must be a positive integer, got %v", chroma.ErrInvalidInput, key, v)
+		}
+		*p = int(f)
+	}
+	if params.m < 2 {
+		return hnswParams{}, fmt.Errorf("%w: %s must be at least 2, got %d", chroma.ErrInvalidInput, MetadataM, params.m)
+	}
+	return params, nil
+}
+
+// hnswIndex is a Hierarchical Navigable Small World graph, see
+// https://arxiv.org/abs/1603.09320. It is safe for concurrent use, inserts
+// only lock the nodes they link. Deleted nodes are tombstoned, they are
+// still traversed but never returned.
+type hnswIndex struct {
+	distance  vecmath.DistanceFunc
+	levelMult float64
+
+	// lock guards params, nodes, byID, entry, maxLevel and tombstones.
+	lock       sync.RWMutex
+	params     hnswParams
+	nodes      []*hnswNode
+	byID       map[chroma.ID]int32
+	entry      int32
+	maxLevel   int
+	tombstones int
+}
+
+type hnswNode struct {
+	id     chroma.ID
+	vector chroma.Embedding
+	level  int
+
+	// lock guards neighbors. It may be held while taking the index's
+	// lock, but not the other way around.
+	lock sync.Mutex
+	// neighbors are the nodes linked on each level up to level.
+	neighbors [][]int32
+	deleted   atomic.Bool
+}
+
+func newHNSWIndex(distance vecmath.DistanceFunc, params hnswParams) *hnswIndex {
+	return &hnswIndex{
+		distance:   distance,
+		levelMult:  1 / math.Log(float64(params.m)),
+		lock:       sync.RWMutex{},
+		params:     params,
+		nodes:      make([]*hnswNode, 0),
+		byID:       make(map[chroma.ID]int32),
+		entry:      -1,
+		maxLevel:   0,
+		tombstones: 0,
+	}
+}
+
+func (h *hnswIndex) setSearchEF(ef int) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.params.efSearch = ef
+}
+
+// insertAll inserts the records concurrently with params.numThreads goroutines.
+func (h *hnswIndex) insertAll(records []*record) {
+	h.lock.RLock()
+	threads := h.params.numThreads
+	h.lock.RUnlock()
+	if threads > len(records) {
+		threads = len(records)
+	}
+
+	work := make(chan *record)
+	var wg sync.WaitGroup
+	for i := 0; i < threads; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for r := range work {
+				h.insert(r.ID, r.Embedding)
+			}
+		}()
+	}
+	for _, r := range records {
+		work <- r
+	}
+	close(work)
+	wg.Wait()
+}
+
+// insert adds vector as id, tombstoning the node previously added as id.
+func (h *hnswIndex) insert(id chroma.ID, vector chroma.Embedding) {
+	level := int(math.Floor(-math.Log(1-rand.Float64()) * h.levelMult))
+	node := &hnswNode{id: id, vector: vector, level: level, lock: sync.Mutex{}, neighbors: make([][]int32, level+1)}
+
+	h.lock.Lock()
+	h.tombstoneLocked(id)
+	n := int32(len(h.nodes))
+	h.nodes = append(h.nodes, node)
+	h.byID[id] = n
+	entry, maxLevel, params := h.entry, h.maxLevel, h.params
+	if entry < 0 {
+		h.entry, h.maxLevel = n, level
+		h.lock.Unlock()
+		return
+	}
+	h.lock.Unlock()
+
+	ep := candidate{node: entry, distance: h.distance(vector, h.node(entry).vector)}
+	for l := maxLevel; l > level; l-- {
+		ep = h.greedy(vector, ep, l)
+	}
+
+	entries := []candidate{ep}
+	top := minInt(level, maxLevel)
+	links := make([][]int32, top+1)
+	for l := top; l >= 0; l-- {
+		found := h.searchLayer(vector, entries, params.efConstruction, l, nil)
+		links[l] = nodesOf(h.selectNeighbors(found, params.m))
+		node.lock.Lock()
+		node.neighbors[l] = append([]int32(nil), links[l]...)
+		node.lock.Unlock()
+		entries = found
+	}
+	// Linked to only once linked on every level, as a concurrent insert
+	// reaching the node on a level it isn't linked on yet would get stuck.
+	for l := top; l >= 0; l-- {
+		maxNeighbors := params.m
+		if l == 0 {
+			maxNeighbors = 2 * params.m
+		}
+		for _, from := range links[l] {
+			h.link(from, n, l, maxNeighbors)
+		}
+	}
+
+	if level > maxLevel {
+		h.lock.Lock()
+		if level > h.maxLevel {
+			h.entry, h.maxLevel = n, level
+		}
+		h.lock.Unlock()
+	}
+}
+
+// link adds a link from node from to node to on level, pruning the links
+// of from to the nearest maxNeighbors if needed.
+func (h *hnswIndex) link(from, to int32, level, maxNeighbors int) {
+	node := h.node(from)
+	node.lock.Lock()
+	defer node.lock.Unlock()
+
+	neighbors := append(node.neighbors[level], to)
+	if len(neighbors) > maxNeighbors {
+		candidates := make([]candidate, 0, len(neighbors))
+		for _, n := range neighbors {
+			candidates = append(candidates, candidate{node: n, distance: h.distance(node.vector, h.node(n).vector)})
+		}
+		sortCandidates(candidates)
+		neighbors = nodesOf(h.selectNeighbors(candidates, maxNeighbors))
+	}
+	node.neighbors[level] = neighbors
+}
+
+// selectNeighbors picks up to m of the candidates, sorted by distance,
+// skipping those nearer to an already picked one than to the query so
+// links spread out in all directions.
+func (h *hnswIndex) selectNeighbors(candidates []candidate, m int) []candidate {
+	selected := make([]candidate, 0, m)
+	for _, c := range candidates {
+		if len(selected) == m {
+			break
+		}
+		vector := h.node(c.node).vector
+		diverse := true
+		for _, s := range selected {
+			if h.distance(vector, h.node(s.node).vector) < c.distance {
+				diverse = false
+				break
+			}
+		}
+		if diverse {
+			selected = append(selected, c)
+		}
+	}
+	return selected
+}
+
+// delete tombstones the node added as id.
+func (h *hnswIndex) delete(id chroma.ID) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.tombstoneLocked(id)
+}
+
+func (h *hnswIndex) tombstoneLocked(id chroma.ID) {
+	n, ok := h.byID[id]
+	if !ok {
+		return
+	}
+	delete(h.byID, id)
+	h.tombstones++
+
+	h.nodes[n].deleted.Store(true)
+}
+
+// needsCompaction reports whether tombstones outnumber live nodes, making
+// searches traverse mostly deleted nodes.
+func (h *hnswIndex) needsCompaction() bool {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	return h.tombstones > 1000 && h.tombstones > len(h.byID)
+}
+
+// search returns the ids of the k nodes nearest to query, nearest first,
+// only considering nodes for which allow returns true if it isn't nil.
+func (h *hnswIndex) search(query chroma.Embedding, k int, allow func(id chroma.ID) bool) []hnswHit {
+	h.lock.RLock()
+	entry, maxLevel, ef := h.entry, h.maxLevel, h.params.efSearch
+	h.lock.RUnlock()
+	if entry < 0 {
+		return nil
+	}
+	if ef < k {
+		ef = k
+	}
+
+	ep := candidate{node: entry, distance: h.distance(query, h.node(entry).vector)}
+	for l := maxLevel; l > 0; l-- {
+		ep = h.greedy(query, ep, l)
+	}
+
+	found := h.searchLayer(query, []candidate{ep}, ef, 0, func(node *hnswNode) bool {
+		return !node.deleted.Load() && (allow == nil || allow(node.id))
+	})
+	hits := make([]hnswHit, 0, minInt(k, len(found)))
+	for _, c := range found[:minInt(k, len(found))] {
+		hits = append(hits, hnswHit{id: h.node(c.node).id, distance: c.distance})
+	}
+	return hits
+}
+
+type hnswHit struct {
+	id       chroma.ID
+	distance float64
+}
+
+// greedy walks level towards query from ep, returning the nearest node found.
+func (h *hnswIndex) greedy(query chroma.Embedding, ep candidate, level int) candidate {
+	for changed := true; changed; {
+		changed = false
+		for _, n := range h.neighborsOf(ep.node, level) {
+			if d := h.distance(query, h.node(n).vector); d < ep.distance {
+				ep = candidate{node: n, distance: d}
+				changed = true
+			}
+		}
+	}
+	return ep
+}
+
+// searchLayer returns the ef nodes on level nearest to query found from
+// entries, sorted by distance. Nodes not accepted by result are traversed
+// but not returned, all nodes are returned if it is nil.
+func (h *hnswIndex) searchLayer(query chroma.Embedding, entries []candidate, ef, level int, result func(node *hnswNode) bool) []candidate {
+	visited := newVisitedSet(h.size())
+	toVisit := &minHeap{}
+	found := &maxHeap{}
+	accept := func(c candidate) {
+		if result != nil && !result(h.node(c.node)) {
+			return
+		}
+		heap.Push(found, c)
+		if found.Len() > ef {
+			heap.Pop(found)
+		}
+	}
+
+	for _, c := range entries {
+		visited.add(c.node)
+		heap.Push(toVisit, c)
+		accept(c)
+	}
+
+	for toVisit.Len() > 0 {
+		c := heap.Pop(toVisit).(candidate)
+		if found.Len() >= ef && c.distance > (*found)[0].distance {
+			break
+		}
+		for _, n := range h.neighborsOf(c.node, level) {
+			if !visited.add(n) {
+				continue
+			}
+			d := h.distance(query, h.node(n).vector)
+			if found.Len() < ef || d < (*found)[0].distance {
+				heap.Push(toVisit, candidate{node: n, distance: d})
+				accept(candidate{node: n, distance: d})
+			}
+		}
+	}
+
+	sorted := append([]candidate(nil), *found...)
+	sortCandidates(sorted)
+	return sorted
+}
+
+// size returns the number of nodes, including tombstoned ones.
+func (h *hnswIndex) size() int {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	return len(h.nodes)
+}
+
+// visitedSet is a set of nodes, cheaper than a map since nodes are dense.
+type visitedSet []bool
+
+func newVisitedSet(size int) visitedSet {
+	return make(visitedSet, size)
+}
+
+// add adds node n, reporting whether it wasn't in the set. Nodes inserted
+// concurrently may be beyond the initial size.
+func (v *visitedSet) add(n int32) bool {
+	if int(n) >= len(*v) {
+		*v = append(*v, make([]bool, int(n)+1-len(*v))...)
+	}
+	if (*v)[n] {
+		return false
+	}
+	(*v)[n] = true
+	return true
+}
+
+func (h *hnswIndex) node(n int32) *hnswNode {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	return h.nodes[n]
+}
+
+// neighborsOf returns a copy of the links of node n on level.
+func (h *hnswIndex) neighborsOf(n int32, level int) []int32 {
+	node := h.node(n)
+	node.lock.Lock()
+	defer node.lock.Unlock()
+	if level >= len(node.neighbors) {
+		return nil
+	}
+	return append([]int32(nil), node.neighbors[level]...)
+}
+
+type hnswSnapshot struct {
+	Entry    int32              `json:"entry"`
+	MaxLevel int                `json:"max_level"`
+	Nodes    []hnswNodeSnapshot `json:"nodes"`
+}
+
+type hnswNodeSnapshot struct {
+	ID        chroma.ID `json:"id"`
+	Level     int       `json:"level"`
+	Neighbors [][]int32 `json:"neighbors"`
+	Deleted   bool      `json:"deleted,omitempty"`
+	// Embedding is only saved for deleted nodes, live nodes use the
+	// embedding of their record.
+	Embedding chroma.Embedding `json:"embedding,omitempty"`
+}
+
+func (h *hnswIndex) snapshot() *hnswSnapshot {
+	h.lock.RLock()
+	snap := &hnswSnapshot{Entry: h.entry, MaxLevel: h.maxLevel, Nodes: make([]hnswNodeSnapshot, 0, len(h.nodes))}
+	nodes := h.nodes
+	h.lock.RUnlock()
+
+	for _, node := range nodes {
+		node.lock.Lock()
+		neighbors := make([][]int32, 0, len(node.neighbors))
+		for _, level := range node.neighbors {
+			neighbors = append(neighbors, append([]int32{}, level...))
+		}
+		node.lock.Unlock()
+
+		ns := hnswNodeSnapshot{ID: node.id, Level: node.level, Neighbors: neighbors, Deleted: node.deleted.Load(), Embedding: nil}
+		if ns.Deleted {
+			ns.Embedding = node.vector
+		}
+		snap.Nodes = append(snap.Nodes, ns)
+	}
+	return snap
+}
+
+// restoreHNSWIndex restores an index saved by snapshot, taking embeddings
+// of live nodes from records.
+func restoreHNSWIndex(distance vecmath.DistanceFunc, params hnswParams, snap *hnswSnapshot, records map[chroma.ID]*record) (*hnswIndex, error) {
+	h := newHNSWIndex(distance, params)
+	h.entry, h.maxLevel = snap.Entry, snap.MaxLevel
+	if int(snap.Entry) >= len(snap.Nodes) {
+		return nil, fmt.Errorf("index entry %d out of range", snap.Entry)
+	}
+
+	for i, ns := range snap.Nodes {
+		if len(ns.Neighbors) != ns.Level+1 {
+			return nil, fmt.Errorf("index node %d has %d levels, want %d", i, len(ns.Neighbors), ns.Level+1)
+		}
+		for _, level := range ns.Neighbors {
+			for _, n := range level {
+				if n < 0 || int(n) >= len(snap.Nodes) {
+					return nil, fmt.Errorf("index node %d links to %d out of range", i, n)
+				}
+			}
+		}
+
+		node := &hnswNode{id: ns.ID, vector: ns.Embedding, level: ns.Level, lock: sync.Mutex{}, neighbors: ns.Neighbors}
+		node.deleted.Store(ns.Deleted)
+		if ns.Deleted {
+			h.tombstones++
+		} else {
+			r, ok := records[ns.ID]
+			if !ok {
+				return nil, fmt.Errorf("index node %d has no record %q", i, ns.ID)
+			}
+			node.vector = r.Embedding
+			h.byID[ns.ID] = int32(i)
+		}
+		h.nodes = append(h.nodes, node)
+	}
+	if len(h.byID) != len(records) {
+		return nil, fmt.Errorf("index has %d live nodes for %d records", len(h.byID), len(records))
+	}
+	return h, nil
+}
+
+type candidate struct {
+	node     int32
+	distance float64
+}
+
+func sortCandidates(candidates []candidate) {
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].distance < candidates[j].distance })
+}
+
+func nodesOf(candidates []candidate) []int32 {
+	nodes := make([]int32, 0, len(candidates))
+	for _, c := range candidates {
+		nodes = append(nodes, c.node)
+	}
+	return nodes
+}
+
+type minHeap []candidate
+
+func (h minHeap) Len() int           { return len(h) }
+func (h minHeap) Less(i, j int) bool { return h[i].distance < h[j].distance }
+func (h minHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+func (h *minHeap) Push(x interface{}) {
+	*h = append(*h, x.(candidate))
+}
+
+func (h *minHeap) Pop() interface{} {
+	last := (*h)[len(*h)-1]
+	*h = (*h)[:len(*h)-1]
+	return last
+}
+
+type maxHeap []candidate
+
+func (h maxHeap) Len() int           { return len(h) }
+func (h maxHeap) Less(i, j int) bool { return h[i].distance > h[j].distance }
+func (h maxHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+func (h *maxHeap) Push(x interface{}) {
+	*h = append(*h, x.(candidate))
+}
+
+func (h *maxHeap) Pop() interface{} {
+	last := (*h)[len(*h)-1]
+	*h = (*h)[:len(*h)-1]
+	return last
+}
@@ -0,0 +1,494 @@
+package memstore
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/kristofferostlund/chroma-go/chroma"
+	"github.com/kristofferostlund/chroma-go/chroma/vecmath"
+)
+
+func randomEmbeddings(rng *rand.Rand, n, dim int) []chroma.Embedding {
+	embeddings := make([]chroma.Embedding, 0, n)
+	for i := 0; i < n; i++ {
+		e := make(chroma.Embedding, dim)
+		for j := range e {
+			e[j] = rng.NormFloat64()
+		}
+		embeddings = append(embeddings, e)
+	}
+	return embeddings
+}
+
+func idsOf(n int) []chroma.ID {
+	ids := make([]chroma.ID, 0, n)
+	for i := 0; i < n; i++ {
+		ids = append(ids, fmt.Sprintf("id-%d", i))
+	}
+	return ids
+}
+
+func recordsOf(ids []chroma.ID, embeddings []chroma.Embedding) []*record {
+	records := make([]*record, 0, len(ids))
+	for i, id := range ids {
+		records = append(records, &record{ID: id, Embedding: embeddings[i], Document: "", Metadata: nil})
+	}
+	return records
+}
+
+func newTestCollection(t *testing.T, metadata chroma.Metadata, opts ...Opt) *Collection {
+	t.Helper()
+	s, err := New(opts...)
+	if err != nil {
+		t.Fatalf("creating store: %v", err)
+	}
+	c, err := s.CreateCollection("test", metadata, false)
+	if err != nil {
+		t.Fatalf("creating collection: %v", err)
+	}
+	return c
+}
+
+func TestHNSWRecall(t *testing.T) {
+	const (
+		n       = 3000
+		dim     = 32
+		queries = 50
+		k       = 10
+	)
+	for _, tt := range []struct {
+		space     Space
+		minRecall float64
+	}{
+		{SpaceL2, 0.95},
+		{SpaceCosine, 0.95},
+		{SpaceIP, 0.9},
+	} {
+		t.Run(string(tt.space), func(t *testing.T) {
+			rng := rand.New(rand.NewSource(1))
+			ids, embeddings := idsOf(n), randomEmbeddings(rng, n, dim)
+			metadata := chroma.Metadata{MetadataSpace: string(tt.space), MetadataSearchEF: 100}
+
+			indexed := newTestCollection(t, metadata)
+			exact := newTestCollection(t, metadata, ExactSearch())
+			for _, c := range []*Collection{indexed, exact} {
+				if err := c.Add(ids, embeddings, nil, nil); err != nil {
+					t.Fatalf("adding: %v", err)
+				}
+			}
+
+			req := QueryRequest{Embeddings: randomEmbeddings(rng, queries, dim), NResults: k, Include: []chroma.Include{}}
+			got, err := indexed.Query(req)
+			if err != nil {
+				t.Fatalf("querying index: %v", err)
+			}
+			want, err := exact.Query(req)
+			if err != nil {
+				t.Fatalf("querying exactly: %v", err)
+			}
+
+			found := 0
+			for i := range want.IDs {
+				nearest := make(map[chroma.ID]bool, k)
+				for _, id := range want.IDs[i] {
+					nearest[id] = true
+				}
+				for _, id := range got.IDs[i] {
+					if nearest[id] {
+						found++
+					}
+				}
+			}
+			if recall := float64(found) / float64(queries*k); recall < tt.minRecall {
+				t.Errorf("got recall@%d %.3f, want at least %.2f", k, recall, tt.minRecall)
+			}
+		})
+	}
+}
+
+func TestHNSWConcurrentInsertAll(t *testing.T) {
+	const (
+		batches  = 4
+		perBatch = 500
+		dim      = 16
+	)
+	rng := rand.New(rand.NewSource(2))
+	ids, embeddings := idsOf(batches*perBatch), randomEmbeddings(rng, batches*perBatch, dim)
+	records := recordsOf(ids, embeddings)
+
+	params, err := hnswParamsOf(chroma.Metadata{MetadataNumThreads: 4, MetadataSearchEF: 50})
+	if err != nil {
+		t.Fatalf("getting params: %v", err)
+	}
+	h := newHNSWIndex(vecmath.L2, params)
+
+	// Batches are inserted concurrently with each other and with searches,
+	// on top of insertAll's own goroutines.
+	var wg sync.WaitGroup
+	for b := 0; b < batches; b++ {
+		wg.Add(1)
+		go func(batch []*record) {
+			defer wg.Done()
+			h.insertAll(batch)
+		}(records[b*perBatch : (b+1)*perBatch])
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for _, q := range embeddings[:100] {
+			h.search(q, 5, nil)
+		}
+	}()
+	wg.Wait()
+
+	if got, want := h.size(), len(records); got != want {
+		t.Fatalf("got %d nodes, want %d", got, want)
+	}
+	if got, want := len(h.byID), len(records); got != want {
+		t.Fatalf("got %d live nodes, want %d", got, want)
+	}
+	for n := range h.nodes {
+		for level := 0; level <= h.nodes[n].level; level++ {
+			for _, neighbor := range h.neighborsOf(int32(n), level) {
+				if neighbor < 0 || int(neighbor) >= h.size() || int(neighbor) == n {
+					t.Fatalf("node %d links to %d on level %d", n, neighbor, level)
+				}
+			}
+		}
+	}
+
+	// Every vector should find itself, which fails if concurrent inserts
+	// left parts of the graph unreachable.
+	self := 0
+	for i, r := range records {
+		hits := h.search(r.Embedding, 1, nil)
+		if len(hits) == 1 && hits[0].id == ids[i] {
+			self++
+		}
+	}
+	if ratio := float64(self) / float64(len(records)); ratio < 0.99 {
+		t.Errorf("got %.3f of vectors finding themselves, want at least 0.99", ratio)
+	}
+}
+
+func TestHNSWDelete(t *testing.T) {
+	const (
+		n   = 600
+		dim = 8
+	)
+	rng := rand.New(rand.NewSource(3))
+	ids, embeddings := idsOf(n), randomEmbeddings(rng, n, dim)
+
+	params, err := hnswParamsOf(chroma.Metadata{MetadataSearchEF: 100})
+	if err != nil {
+		t.Fatalf("getting params: %v", err)
+	}
+	h := newHNSWIndex(vecmath.L2, params)
+	h.insertAll(recordsOf(ids, embeddings))
+
+	deleted := make(map[chroma.ID]bool)
+	for i := 0; i < n; i += 2 {
+		h.delete(ids[i])
+		deleted[ids[i]] = true
+	}
+	// Deleting unknown or already deleted IDs does nothing.
+	h.delete("unknown")
+	h.delete(ids[0])
+
+	if got, want := h.tombstones, n/2; got != want {
+		t.Errorf("got %d tombstones, want %d", got, want)
+	}
+	if got, want := len(h.byID), n/2; got != want {
+		t.Errorf("got %d live nodes, want %d", got, want)
+	}
+
+	for i, q := range embeddings {
+		hits := h.search(q, 10, nil)
+		if len(hits) != 10 {
+			t.Fatalf("got %d hits, want 10", len(hits))
+		}
+		for _, hit := range hits {
+			if deleted[hit.id] {
+				t.Fatalf("got deleted %q searching for %q", hit.id, ids[i])
+			}
+		}
+	}
+
+	// Searching for everything returns each live node once.
+	all := h.search(embeddings[1], n, nil)
+	seen := make(map[chroma.ID]bool, len(all))
+	for _, hit := range all {
+		if seen[hit.id] {
+			t.Errorf("got %q twice", hit.id)
+		}
+		seen[hit.id] = true
+	}
+	if len(all) > n/2 {
+		t.Errorf("got %d hits, want at most %d live nodes", len(all), n/2)
+	}
+
+	// Reinserting an ID tombstones its previous node.
+	moved := chroma.Embedding{100, 100, 100, 100, 100, 100, 100, 100}
+	h.insert(ids[1], moved)
+	if got, want := h.tombstones, n/2+1; got != want {
+		t.Errorf("got %d tombstones after reinserting, want %d", got, want)
+	}
+	hits := h.search(moved, 2, nil)
+	if len(hits) != 2 || hits[0].id != ids[1] || hits[0].distance != 0 {
+		t.Fatalf("got hits %v searching for the reinserted vector, want %q first", hits, ids[1])
+	}
+	if hits[1].id == ids[1] {
+		t.Errorf("got the reinserted ID twice")
+	}
+}
+
+func TestHNSWCompaction(t *testing.T) {
+	const (
+		n   = 1200
+		dim = 4
+	)
+	rng := rand.New(rand.NewSource(4))
+	ids := idsOf(n)
+	c := newTestCollection(t, nil)
+
+	if err := c.Add(ids, randomEmbeddings(rng, n, dim), nil, nil); err != nil {
+		t.Fatalf("adding: %v", err)
+	}
+	if err := c.Upsert(ids, randomEmbeddings(rng, n, dim), nil, nil); err != nil {
+		t.Fatalf("upserting: %v", err)
+	}
+	if got, want := c.index.tombstones, n; got != want {
+		t.Fatalf("got %d tombstones after replacing every embedding, want %d", got, want)
+	}
+
+	// Tombstones now outnumber the live nodes, so the index is rebuilt.
+	if err := c.Update(ids, randomEmbeddings(rng, n, dim), nil, nil); err != nil {
+		t.Fatalf("updating: %v", err)
+	}
+	if got := c.index.tombstones; got != 0 {
+		t.Errorf("got %d tombstones after updating, want the index compacted", got)
+	}
+	if got, want := c.index.size(), n; got != want {
+		t.Errorf("got %d nodes after compacting, want %d", got, want)
+	}
+
+	// Compaction needs more than 1000 tombstones.
+	if _, err := c.Delete(ids[:1000], nil, nil); err != nil {
+		t.Fatalf("deleting: %v", err)
+	}
+	if got, want := c.index.tombstones, 1000; got != want {
+		t.Errorf("got %d tombstones after deleting, want %d", got, want)
+	}
+	if _, err := c.Delete(ids[1000:1050], nil, nil); err != nil {
+		t.Fatalf("deleting: %v", err)
+	}
+	if got := c.index.tombstones; got != 0 {
+		t.Errorf("got %d tombstones after deleting, want the index compacted", got)
+	}
+	if got, want := c.index.size(), n-1050; got != want {
+		t.Errorf("got %d nodes after compacting, want %d", got, want)
+	}
+}
+
+func TestHNSWSaveLoad(t *testing.T) {
+	const (
+		n   = 800
+		dim = 16
+	)
+	rng := rand.New(rand.NewSource(5))
+	ids, embeddings := idsOf(n), randomEmbeddings(rng, n, dim)
+
+	s, err := New()
+	if err != nil {
+		t.Fatalf("creating store: %v", err)
+	}
+	c, err := s.CreateCollection("test", chroma.Metadata{MetadataSpace: string(SpaceCosine), MetadataSearchEF: 20}, false)
+	if err != nil {
+		t.Fatalf("creating collection: %v", err)
+	}
+	if err := c.Add(ids, embeddings, nil, nil); err != nil {
+		t.Fatalf("adding: %v", err)
+	}
+	// Tombstoned nodes are saved along with their embeddings.
+	if _, err := c.Delete(ids[:100], nil, nil); err != nil {
+		t.Fatalf("deleting: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := s.Save(&buf); err != nil {
+		t.Fatalf("saving: %v", err)
+	}
+	loaded, err := New()
+	if err != nil {
+		t.Fatalf("creating store: %v", err)
+	}
+	if err := loaded.Load(&buf); err != nil {
+		t.Fatalf("loading: %v", err)
+	}
+	lc, err := loaded.GetCollection("test")
+	if err != nil {
+		t.Fatalf("getting loaded collection: %v", err)
+	}
+
+	if lc.index == nil {
+		t.Fatal("got no index after loading")
+	}
+	if got, want := lc.index.snapshot(), c.index.snapshot(); !reflect.DeepEqual(got, want) {
+		t.Error("got a different graph after loading")
+	}
+	if got, want := lc.index.tombstones, c.index.tombstones; got != want {
+		t.Errorf("got %d tombstones after loading, want %d", got, want)
+	}
+
+	// The same graph gives the same, deterministic, search results.
+	req := QueryRequest{Embeddings: randomEmbeddings(rng, 20, dim), NResults: 5, Include: []chroma.Include{chroma.IncludeDistances}}
+	want, err := c.Query(req)
+	if err != nil {
+		t.Fatalf("querying: %v", err)
+	}
+	got, err := lc.Query(req)
+	if err != nil {
+		t.Fatalf("querying loaded collection: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got query results %v after loading, want %v", got.IDs, want.IDs)
+	}
+}
+
+func TestRestoreHNSWIndexRejectsCorruptGraph(t *testing.T) {
+	records := map[chroma.ID]*record{"a": {ID: "a", Embedding: chroma.Embedding{1}, Document: "", Metadata: nil}}
+	params, err := hnswParamsOf(nil)
+	if err != nil {
+		t.Fatalf("getting params: %v", err)
+	}
+
+	for name, snap := range map[string]*hnswSnapshot{
+		"entry out of range":    {Entry: 1, MaxLevel: 0, Nodes: []hnswNodeSnapshot{{ID: "a", Level: 0, Neighbors: [][]int32{{}}}}},
+		"link out of range":     {Entry: 0, MaxLevel: 0, Nodes: []hnswNodeSnapshot{{ID: "a", Level: 0, Neighbors: [][]int32{{1}}}}},
+		"levels mismatch":       {Entry: 0, MaxLevel: 1, Nodes: []hnswNodeSnapshot{{ID: "a", Level: 1, Neighbors: [][]int32{{}}}}},
+		"node without a record": {Entry: 0, MaxLevel: 0, Nodes: []hnswNodeSnapshot{{ID: "b", Level: 0, Neighbors: [][]int32{{}}}}},
+		"record without a node": {Entry: 0, MaxLevel: 0, Nodes: []hnswNodeSnapshot{{ID: "a", Level: 0, Neighbors: [][]int32{{}}, Deleted: true}}},
+	} {
+		if _, err := restoreHNSWIndex(vecmath.L2, params, snap, records); err == nil {
+			t.Errorf("%s: got no error", name)
+		}
+	}
+}
+
+// Benchmarks run at the size the index is meant for, building it takes
+// minutes. Run them with -run '^$' -bench HNSW -benchtime 1x.
+const (
+	benchN       = 100_000
+	benchDim     = 64
+	benchQueries = 100
+	benchK       = 10
+)
+
+var benchData struct {
+	once       sync.Once
+	ids        []chroma.ID
+	embeddings []chroma.Embedding
+	queries    []chroma.Embedding
+	// nearest are the exact benchK nearest ids of each query.
+	nearest [][]chroma.ID
+
+	indexOnce sync.Once
+	index     *hnswIndex
+}
+
+// benchmarkData generates the benchmark data once for all benchmarks.
+func benchmarkData() {
+	benchData.once.Do(func() {
+		rng := rand.New(rand.NewSource(1))
+		benchData.ids = idsOf(benchN)
+		benchData.embeddings = randomEmbeddings(rng, benchN, benchDim)
+		benchData.queries = randomEmbeddings(rng, benchQueries, benchDim)
+
+		exact := make([]hnswHit, len(benchData.embeddings))
+		for _, q := range benchData.queries {
+			for i, e := range benchData.embeddings {
+				exact[i] = hnswHit{id: benchData.ids[i], distance: vecmath.L2(q, e)}
+			}
+			sort.Slice(exact, func(i, j int) bool { return exact[i].distance < exact[j].distance })
+			nearest := make([]chroma.ID, 0, benchK)
+			for _, hit := range exact[:benchK] {
+				nearest = append(nearest, hit.id)
+			}
+			benchData.nearest = append(benchData.nearest, nearest)
+		}
+	})
+}
+
+// benchmarkIndex returns an index of the benchmark data, built once.
+func benchmarkIndex(b *testing.B) *hnswIndex {
+	b.Helper()
+	benchmarkData()
+	benchData.indexOnce.Do(func() {
+		benchData.index = buildIndex(b, recordsOf(benchData.ids, benchData.embeddings))
+	})
+	return benchData.index
+}
+
+// buildIndex indexes records with the default parameters.
+func buildIndex(b *testing.B, records []*record) *hnswIndex {
+	b.Helper()
+	params, err := hnswParamsOf(nil)
+	if err != nil {
+		b.Fatalf("getting parameters: %v", err)
+	}
+	index := newHNSWIndex(vecmath.L2, params)
+	index.insertAll(records)
+	return index
+}
+
+// recall returns the share of the exact nearest neighbours of the benchmark
+// queries found by index.
+func recall(index *hnswIndex) float64 {
+	found := 0
+	for i, q := range benchData.queries {
+		nearest := make(map[chroma.ID]bool, benchK)
+		for _, id := range benchData.nearest[i] {
+			nearest[id] = true
+		}
+		for _, hit := range index.search(q, benchK, nil) {
+			if nearest[hit.id] {
+				found++
+			}
+		}
+	}
+	return float64(found) / float64(benchQueries*benchK)
+}
+
+func BenchmarkHNSWInsert(b *testing.B) {
+	benchmarkData()
+	records := recordsOf(benchData.ids, benchData.embeddings)
+	b.ResetTimer()
+	var index *hnswIndex
+	for i := 0; i < b.N; i++ {
+		index = buildIndex(b, records)
+	}
+	b.StopTimer()
+	b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N*benchN), "ns/insert")
+	// At the default search ef of 10.
+	b.ReportMetric(recall(index), "recall@10")
+}
+
+func BenchmarkHNSWQuery(b *testing.B) {
+	index := benchmarkIndex(b)
+	for _, ef := range []int{10, 50, 100, 200} {
+		b.Run(fmt.Sprintf("ef=%d", ef), func(b *testing.B) {
+			index.setSearchEF(ef)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				index.search(benchData.queries[i%benchQueries], benchK, nil)
+			}
+			b.StopTimer()
+			b.ReportMetric(recall(index), "recall@10")
+		})
+	}
+}
@@ -0,0 +1,264 @@
+package memstore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/kristofferostlund/chroma-go/chroma"
+)
+
+// Version is returned by the version endpoint of Handler.
+const Version = "memstore"
+
+// NewClient returns a client using s instead of a server, requests never
+// leave the process. Options are applied as for chroma.NewClient, except
+// for chroma.WithHTTPClient.
+func NewClient(s *Store, opts ...chroma.ClientOpts) *chroma.Client {
+	opts = append(append([]chroma.ClientOpts{}, opts...), chroma.WithHTTPClient(s.Doer()))
+	return chroma.NewClient("http://memstore", opts...)
+}
+
+// Doer returns a chroma.Doer serving requests with Handler in-process.
+func (s *Store) Doer() chroma.Doer {
+	h := s.Handler()
+	return chroma.DoerFunc(func(req *http.Request) (*http.Response, error) {
+		if err := req.Context().Err(); err != nil {
+			return nil, err
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		res := rec.Result()
+		res.Request = req
+		return res, nil
+	})
+}
+
+// Handler serves the Chroma REST API under /api/v1. Collections in paths
+// can be given by ID or by name.
+func (s *Store) Handler() http.Handler {
+	return http.HandlerFunc(s.serveHTTP)
+}
+
+func (s *Store) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	path, ok := strings.CutPrefix(r.URL.Path, "/api/v1")
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("unknown path %q", r.URL.Path))
+		return
+	}
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+
+	switch {
+	case len(parts) == 1 && parts[0] == "" && r.Method == http.MethodGet,
+		len(parts) == 1 && parts[0] == "heartbeat" && r.Method == http.MethodGet:
+		writeJSON(w, map[string]int64{"nanosecond heartbeat": time.Now().UnixNano()})
+	case len(parts) == 1 && parts[0] == "version" && r.Method == http.MethodGet:
+		writeJSON(w, Version)
+	case len(parts) == 1 && parts[0] == "reset" && r.Method == http.MethodPost:
+		s.respond(w, true, s.Reset())
+	case len(parts) == 1 && parts[0] == "persist" && r.Method == http.MethodPost:
+		s.respond(w, true, s.Flush())
+	case len(parts) == 1 && parts[0] == "collections":
+		s.serveCollections(w, r)
+	case len(parts) >= 2 && parts[0] == "collections":
+		c, err := s.collectionOf(parts[1])
+		if err != nil {
+			writeError(w, http.StatusNotFound, err)
+			return
+		}
+		if len(parts) == 2 {
+			s.serveCollection(w, r, c)
+		} else if len(parts) == 3 {
+			s.serveRecords(w, r, c, parts[2])
+		} else {
+			writeError(w, http.StatusNotFound, fmt.Errorf("unknown path %q", r.URL.Path))
+		}
+	default:
+		writeError(w, http.StatusNotFound, fmt.Errorf("unknown path %q", r.URL.Path))
+	}
+}
+
+// collectionOf resolves a collection by ID, then by name.
+func (s *Store) collectionOf(idOrName string) (*Collection, error) {
+	if c := s.collectionByID(idOrName); c != nil {
+		return c, nil
+	}
+	return s.GetCollection(idOrName)
+}
+
+func (s *Store) serveCollections(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, s.ListCollections())
+	case http.MethodPost:
+		var body struct {
+			Name        string          `json:"name"`
+			Metadata    chroma.Metadata `json:"metadata"`
+			GetOrCreate bool            `json:"get_or_create"`
+		}
+		if !decodeBody(w, r, &body) {
+			return
+		}
+		c, err := s.CreateCollection(body.Name, body.Metadata, body.GetOrCreate)
+		if err != nil {
+			s.respond(w, nil, err)
+			return
+		}
+		writeJSON(w, c.simple())
+	default:
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+	}
+}
+
+func (s *Store) serveCollection(w http.ResponseWriter, r *http.Request, c *Collection) {
+	switch r.Method {
+	case http.MethodGet:
+		s.lock.Lock()
+		simple := c.simple()
+		s.lock.Unlock()
+		writeJSON(w, simple)
+	case http.MethodDelete:
+		s.respond(w, nil, s.DeleteCollection(c.Name()))
+	case http.MethodPut:
+		var body struct {
+			NewName     string          `json:"new_name"`
+			NewMetadata chroma.Metadata `json:"new_metadata"`
+		}
+		if !decodeBody(w, r, &body) {
+			return
+		}
+		s.respond(w, nil, c.Modify(body.NewName, body.NewMetadata))
+	default:
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+	}
+}
+
+func (s *Store) serveRecords(w http.ResponseWriter, r *http.Request, c *Collection, op string) {
+	if op == "count" {
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+			return
+		}
+		writeJSON(w, c.Count())
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+		return
+	}
+
+	switch op {
+	case "add", "upsert", "update":
+		var body struct {
+			IDs        []chroma.ID        `json:"ids"`
+			Embeddings []chroma.Embedding `json:"embeddings"`
+			Metadatas  []chroma.Metadata  `json:"metadatas"`
+			Documents  []chroma.Document  `json:"documents"`
+		}
+		if !decodeBody(w, r, &body) {
+			return
+		}
+		write := map[string]func([]chroma.ID, []chroma.Embedding, []chroma.Metadata, []chroma.Document) error{
+			"add":    c.Add,
+			"upsert": c.Upsert,
+			"update": c.Update,
+		}[op]
+		s.respond(w, true, write(body.IDs, body.Embeddings, body.Metadatas, body.Documents))
+	case "get":
+		var body struct {
+			IDs           []chroma.ID       `json:"ids"`
+			Where         chroma.Where      `json:"where"`
+			WhereDocument chroma.Where      `json:"where_document"`
+			Limit         int               `json:"limit"`
+			Offset        int               `json:"offset"`
+			Include       *[]chroma.Include `json:"include"`
+		}
+		if !decodeBody(w, r, &body) {
+			return
+		}
+		req := GetRequest{IDs: body.IDs, Where: body.Where, WhereDocument: body.WhereDocument, Limit: body.Limit, Offset: body.Offset, Include: nil}
+		if body.Include != nil {
+			req.Include = append([]chroma.Include{}, *body.Include...)
+		}
+		res, err := c.Get(req)
+		s.respond(w, res, err)
+	case "delete":
+		var body struct {
+			IDs           []chroma.ID  `json:"ids"`
+			Where         chroma.Where `json:"where"`
+			WhereDocument chroma.Where `json:"where_document"`
+		}
+		if !decodeBody(w, r, &body) {
+			return
+		}
+		deleted, err := c.Delete(body.IDs, body.Where, body.WhereDocument)
+		s.respond(w, deleted, err)
+	case "query":
+		var body struct {
+			QueryEmbeddings []chroma.Embedding `json:"query_embeddings"`
+			NResults        *int               `json:"n_results"`
+			Where           chroma.Where       `json:"where"`
+			WhereDocument   chroma.Where       `json:"where_document"`
+			Include         *[]chroma.Include  `json:"include"`
+		}
+		if !decodeBody(w, r, &body) {
+			return
+		}
+		// Chroma defaults to 10 results.
+		req := QueryRequest{Embeddings: body.QueryEmbeddings, NResults: 10, Where: body.Where, WhereDocument: body.WhereDocument, Include: nil}
+		if body.NResults != nil {
+			req.NResults = *body.NResults
+		}
+		if body.Include != nil {
+			req.Include = append([]chroma.Include{}, *body.Include...)
+		}
+		res, err := c.Query(req)
+		s.respond(w, res, err)
+	case "create_index":
+		s.respond(w, true, nil)
+	default:
+		writeError(w, http.StatusNotFound, fmt.Errorf("unknown path %q", r.URL.Path))
+	}
+}
+
+// respond writes v, or err with a status matching it.
+func (s *Store) respond(w http.ResponseWriter, v interface{}, err error) {
+	switch {
+	case err == nil:
+		writeJSON(w, v)
+	case errors.Is(err, chroma.ErrInvalidInput):
+		writeError(w, http.StatusBadRequest, err)
+	case errors.Is(err, ErrNotFound):
+		writeError(w, http.StatusNotFound, err)
+	case errors.Is(err, ErrAlreadyExists):
+		writeError(w, http.StatusConflict, err)
+	default:
+		writeError(w, http.StatusInternalServerError, err)
+	}
+}
+
+func decodeBody(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	dec := json.NewDecoder(r.Body)
+	dec.UseNumber()
+	if err := dec.Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("decoding request: %w", err))
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes errors the way Chroma does, as {"error": "..."}.
+func writeError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
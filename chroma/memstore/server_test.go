@@ -0,0 +1,124 @@
+package memstore_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kristofferostlund/chroma-go/chroma/memstore"
+)
+
+func newServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	store, err := memstore.New()
+	if err != nil {
+		t.Fatalf("creating store: %v", err)
+	}
+	srv := httptest.NewServer(store.Handler())
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func request(t *testing.T, srv *httptest.Server, method, path, body string) (int, string) {
+	t.Helper()
+	req, err := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("creating request: %v", err)
+	}
+	res, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer res.Body.Close()
+	b, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatalf("reading body: %v", err)
+	}
+	return res.StatusCode, strings.TrimSpace(string(b))
+}
+
+func TestHandler(t *testing.T) {
+	srv := newServer(t)
+
+	code, body := request(t, srv, http.MethodPost, "/api/v1/collections", `{"name": "docs", "metadata": {"owner": "tests"}}`)
+	if code != http.StatusOK {
+		t.Fatalf("got status %d creating a collection, body %s", code, body)
+	}
+	var created struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal([]byte(body), &created); err != nil {
+		t.Fatalf("decoding collection: %v", err)
+	}
+
+	tests := []struct {
+		method, path, body string
+		want               int
+	}{
+		{http.MethodGet, "/api/v1", "", http.StatusOK},
+		{http.MethodGet, "/api/v1/heartbeat", "", http.StatusOK},
+		{http.MethodGet, "/api/v1/version", "", http.StatusOK},
+		{http.MethodPost, "/api/v1/persist", "", http.StatusOK},
+		{http.MethodGet, "/api/v2/collections", "", http.StatusNotFound},
+		{http.MethodGet, "/api/v1/unknown", "", http.StatusNotFound},
+		{http.MethodPost, "/api/v1/version", "", http.StatusNotFound},
+
+		{http.MethodGet, "/api/v1/collections", "", http.StatusOK},
+		{http.MethodPost, "/api/v1/collections", `{"name": "docs"}`, http.StatusConflict},
+		{http.MethodPost, "/api/v1/collections", `{"name": "docs", "get_or_create": true}`, http.StatusOK},
+		{http.MethodPost, "/api/v1/collections", `{"name": ""}`, http.StatusBadRequest},
+		{http.MethodPost, "/api/v1/collections", `{"name": `, http.StatusBadRequest},
+		{http.MethodPatch, "/api/v1/collections", "", http.StatusMethodNotAllowed},
+
+		{http.MethodGet, "/api/v1/collections/docs", "", http.StatusOK},
+		{http.MethodGet, "/api/v1/collections/" + created.ID, "", http.StatusOK},
+		{http.MethodGet, "/api/v1/collections/missing", "", http.StatusNotFound},
+		{http.MethodPost, "/api/v1/collections/docs", "", http.StatusMethodNotAllowed},
+		{http.MethodGet, "/api/v1/collections/docs/add/more", "", http.StatusNotFound},
+
+		{http.MethodPost, "/api/v1/collections/docs/add", `{"ids": ["a", "b"], "embeddings": [[1, 0], [0, 1]], "documents": ["one", "two"]}`, http.StatusOK},
+		{http.MethodPost, "/api/v1/collections/docs/add", `{"ids": ["a"], "embeddings": [[1, 0]]}`, http.StatusConflict},
+		{http.MethodPost, "/api/v1/collections/docs/add", `{"ids": ["c"], "embeddings": [[1, 0, 0]]}`, http.StatusBadRequest},
+		{http.MethodPost, "/api/v1/collections/docs/update", `{"ids": ["missing"], "documents": ["x"]}`, http.StatusNotFound},
+		{http.MethodPost, "/api/v1/collections/docs/upsert", `{"ids": ["c"], "embeddings": [[1, 1]]}`, http.StatusOK},
+		{http.MethodGet, "/api/v1/collections/docs/add", "", http.StatusMethodNotAllowed},
+		{http.MethodGet, "/api/v1/collections/docs/count", "", http.StatusOK},
+		{http.MethodPost, "/api/v1/collections/docs/count", "", http.StatusMethodNotAllowed},
+		{http.MethodPost, "/api/v1/collections/docs/get", `{"where": {"n": {"$like": 1}}}`, http.StatusBadRequest},
+		{http.MethodPost, "/api/v1/collections/docs/query", `{"query_embeddings": [[1, 0]], "n_results": 1}`, http.StatusOK},
+		{http.MethodPost, "/api/v1/collections/docs/query", `{"query_embeddings": [[1, 0]], "include": ["bogus"]}`, http.StatusBadRequest},
+		{http.MethodPost, "/api/v1/collections/docs/unknown", "{}", http.StatusNotFound},
+		{http.MethodPut, "/api/v1/collections/docs", `{"new_name": "docs"}`, http.StatusOK},
+	}
+	for _, tt := range tests {
+		if code, body := request(t, srv, tt.method, tt.path, tt.body); code != tt.want {
+			t.Errorf("got status %d for %s %s, want %d, body %s", code, tt.method, tt.path, tt.want, body)
+		}
+	}
+
+	if _, body := request(t, srv, http.MethodGet, "/api/v1/collections/docs/count", ""); body != "3" {
+		t.Errorf("got count %s, want 3", body)
+	}
+	code, body = request(t, srv, http.MethodPost, "/api/v1/collections/docs/query", `{"query_embeddings": [[1, 0]], "n_results": 1}`)
+	if code != http.StatusOK || !strings.Contains(body, `"ids":[["a"]]`) {
+		t.Errorf("got status %d and body %s querying, want a", code, body)
+	}
+	code, body = request(t, srv, http.MethodGet, "/api/v1/unknown", "")
+	if !strings.Contains(body, `"error"`) {
+		t.Errorf("got status %d with body %s, want an error object", code, body)
+	}
+
+	if code, _ := request(t, srv, http.MethodDelete, "/api/v1/collections/docs", ""); code != http.StatusOK {
+		t.Errorf("got status %d deleting the collection", code)
+	}
+	if code, _ := request(t, srv, http.MethodDelete, "/api/v1/collections/docs", ""); code != http.StatusNotFound {
+		t.Errorf("got status %d deleting a deleted collection, want 404", code)
+	}
+	if code, _ := request(t, srv, http.MethodPost, "/api/v1/reset", ""); code != http.StatusOK {
+		t.Errorf("got status %d resetting", code)
+	}
+}
@@ -0,0 +1,389 @@
+// Package memstore is an in-process vector store implementing the same
+// collection operations as a Chroma server, for unit tests and small
+// deployments without a server. Searches use an HNSW index tuned by the
+// hnsw:* collection metadata like Chroma's, or are exact with ExactSearch.
+//
+// Use NewClient to talk to a Store through chroma.Client:
+//
+//	store, err := memstore.New(memstore.Persist("chroma.json"))
+//	client := memstore.NewClient(store)
+//
+// or serve it over HTTP with Handler for other clients.
+package memstore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/kristofferostlund/chroma-go/chroma"
+)
+
+var (
+	ErrNotFound      = errors.New("not found")
+	ErrAlreadyExists = errors.New("already exists")
+	// ErrNotPersisted is returned when a change was made in memory but
+	// saving the store failed, so the file lags behind the store until a
+	// later save succeeds.
+	ErrNotPersisted = errors.New("change not persisted")
+)
+
+const snapshotVersion = 1
+
+// Store holds collections in memory. It is safe for concurrent use.
+type Store struct {
+	conf Config
+
+	lock        sync.Locker
+	collections map[string]*Collection
+
+	// dirty is set while the file lags behind the store.
+	dirty bool
+	// saveTimer is the pending save with a persist interval, and
+	// persistErr the error of the last such save.
+	saveTimer  *time.Timer
+	persistErr error
+}
+
+type Config struct {
+	path            string
+	exact           bool
+	persistInterval time.Duration
+}
+
+type Opt func(c *Config)
+
+// Persist loads the store from the file at path if it exists, and saves it
+// there after every change. Every save encodes the whole store, indexes
+// included, while holding the store's lock, so writes slow down as the store
+// grows; use PersistInterval to save less often.
+//
+// A change that can't be saved is kept in memory and its error wraps
+// ErrNotPersisted. The next change, or Flush, tries again.
+func Persist(path string) Opt {
+	return func(c *Config) {
+		c.path = path
+	}
+}
+
+// PersistInterval saves a persisted store at most once per interval d
+// instead of after every change, batching the changes in between. Changes
+// are lost if the process exits before they are saved, call Flush first.
+// A failed save is returned by the next change.
+func PersistInterval(d time.Duration) Opt {
+	return func(c *Config) {
+		c.persistInterval = d
+	}
+}
+
+// ExactSearch searches by comparing queries with every embedding instead of
+// using an index, which is slower but always finds the nearest neighbours.
+func ExactSearch() Opt {
+	return func(c *Config) {
+		c.exact = true
+	}
+}
+
+func New(opts ...Opt) (*Store, error) {
+	conf := Config{path: "", exact: false, persistInterval: 0}
+	for _, opt := range opts {
+		opt(&conf)
+	}
+	s := &Store{
+		conf:        conf,
+		lock:        &sync.Mutex{},
+		collections: make(map[string]*Collection),
+		dirty:       false,
+		saveTimer:   nil,
+		persistErr:  nil,
+	}
+
+	if conf.path == "" {
+		return s, nil
+	}
+	f, err := os.Open(conf.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("opening store: %w", err)
+	}
+	defer f.Close()
+	if err := s.Load(f); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// CreateCollection creates a collection, failing with ErrAlreadyExists if
+// it exists unless getOrCreate is set. Getting an existing collection with
+// metadata replaces its metadata, but not its space or index parameters
+// other than hnsw:search_ef.
+func (s *Store) CreateCollection(name string, metadata chroma.Metadata, getOrCreate bool) (*Collection, error) {
+	if name == "" {
+		return nil, fmt.Errorf("%w: empty collection name", chroma.ErrInvalidInput)
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if c, ok := s.collections[name]; ok {
+		if !getOrCreate {
+			return nil, fmt.Errorf("creating collection %q: %w", name, ErrAlreadyExists)
+		}
+		if len(metadata) > 0 {
+			if err := c.setMetadata(metadata); err != nil {
+				return nil, fmt.Errorf("getting collection %q: %w", name, err)
+			}
+			if err := s.persist(); err != nil {
+				return nil, err
+			}
+		}
+		return c, nil
+	}
+
+	id, err := chroma.UUIDv4IDs("", nil)
+	if err != nil {
+		return nil, fmt.Errorf("generating collection ID: %w", err)
+	}
+	c, err := newCollection(s, id, name, metadata)
+	if err != nil {
+		return nil, fmt.Errorf("creating collection %q: %w", name, err)
+	}
+	s.collections[name] = c
+	return c, s.persist()
+}
+
+func (s *Store) GetCollection(name string) (*Collection, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	c, ok := s.collections[name]
+	if !ok {
+		return nil, fmt.Errorf("getting collection %q: %w", name, ErrNotFound)
+	}
+	return c, nil
+}
+
+// collectionByID returns the collection with the given ID, or nil.
+func (s *Store) collectionByID(id string) *Collection {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, c := range s.collections {
+		if c.id == id {
+			return c
+		}
+	}
+	return nil
+}
+
+// ListCollections returns the collections sorted by name.
+func (s *Store) ListCollections() []chroma.SimpleCollection {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	list := make([]chroma.SimpleCollection, 0, len(s.collections))
+	for _, c := range s.collections {
+		list = append(list, c.simple())
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+func (s *Store) DeleteCollection(name string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if _, ok := s.collections[name]; !ok {
+		return fmt.Errorf("deleting collection %q: %w", name, ErrNotFound)
+	}
+	delete(s.collections, name)
+	return s.persist()
+}
+
+// Reset deletes all collections.
+func (s *Store) Reset() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.collections = make(map[string]*Collection)
+	return s.persist()
+}
+
+type snapshot struct {
+	Version     int                  `json:"version"`
+	Collections []collectionSnapshot `json:"collections"`
+}
+
+type collectionSnapshot struct {
+	ID       string          `json:"id"`
+	Name     string          `json:"name"`
+	Metadata chroma.Metadata `json:"metadata,omitempty"`
+	Records  []*record       `json:"records"`
+	// Index is nil for stores using exact search.
+	Index *hnswSnapshot `json:"index,omitempty"`
+}
+
+// Save writes all collections as JSON.
+func (s *Store) Save(w io.Writer) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.save(w)
+}
+
+func (s *Store) save(w io.Writer) error {
+	snap := snapshot{Version: snapshotVersion, Collections: make([]collectionSnapshot, 0, len(s.collections))}
+	for _, c := range s.collections {
+		cs := collectionSnapshot{
+			ID:       c.id,
+			Name:     c.name,
+			Metadata: c.metadata,
+			Records:  c.records,
+			Index:    nil,
+		}
+		if c.index != nil {
+			cs.Index = c.index.snapshot()
+		}
+		snap.Collections = append(snap.Collections, cs)
+	}
+	sort.Slice(snap.Collections, func(i, j int) bool { return snap.Collections[i].Name < snap.Collections[j].Name })
+
+	if err := json.NewEncoder(w).Encode(snap); err != nil {
+		return fmt.Errorf("encoding store: %w", err)
+	}
+	return nil
+}
+
+// Load replaces all collections with the ones written by Save.
+func (s *Store) Load(r io.Reader) error {
+	var snap snapshot
+	dec := json.NewDecoder(r)
+	// Keep metadata numbers exact, filters compare them by value.
+	dec.UseNumber()
+	if err := dec.Decode(&snap); err != nil {
+		return fmt.Errorf("decoding store: %w", err)
+	}
+	if snap.Version != snapshotVersion {
+		return fmt.Errorf("unsupported store version %d, want %d", snap.Version, snapshotVersion)
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	collections := make(map[string]*Collection, len(snap.Collections))
+	for _, cs := range snap.Collections {
+		c, err := newCollection(s, cs.ID, cs.Name, cs.Metadata)
+		if err != nil {
+			return fmt.Errorf("loading collection %q: %w", cs.Name, err)
+		}
+		for _, r := range cs.Records {
+			if c.dimension == 0 {
+				c.dimension = len(r.Embedding)
+			}
+			c.records = append(c.records, r)
+			c.byID[r.ID] = r
+		}
+		if c.index != nil {
+			if cs.Index != nil {
+				index, err := restoreHNSWIndex(c.distance, c.params, cs.Index, c.byID)
+				if err != nil {
+					return fmt.Errorf("loading collection %q: %w", cs.Name, err)
+				}
+				c.index = index
+			} else {
+				c.rebuildIndex()
+			}
+		}
+		collections[cs.Name] = c
+	}
+	s.collections = collections
+	return nil
+}
+
+// SaveFile writes all collections to the file at path.
+func (s *Store) SaveFile(path string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.saveFile(path)
+}
+
+// Flush saves the changes not yet saved to a persisted store.
+func (s *Store) Flush() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.saveTimer != nil {
+		s.saveTimer.Stop()
+		s.saveTimer = nil
+	}
+	s.persistErr = nil
+	return s.flush()
+}
+
+// persist saves the store if it is persisted, or schedules the save with a
+// persist interval, s.lock must be held.
+func (s *Store) persist() error {
+	if s.conf.path == "" {
+		return nil
+	}
+	s.dirty = true
+	if s.conf.persistInterval <= 0 {
+		return s.flush()
+	}
+
+	if s.saveTimer == nil {
+		s.saveTimer = time.AfterFunc(s.conf.persistInterval, s.flushPending)
+	}
+	err := s.persistErr
+	s.persistErr = nil
+	return err
+}
+
+// flushPending runs the save scheduled by persist.
+func (s *Store) flushPending() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.saveTimer = nil
+	if err := s.flush(); err != nil {
+		s.persistErr = err
+	}
+}
+
+// flush saves the store if the file lags behind it, s.lock must be held.
+func (s *Store) flush() error {
+	if !s.dirty {
+		return nil
+	}
+	if err := s.saveFile(s.conf.path); err != nil {
+		return fmt.Errorf("%w: %w", ErrNotPersisted, err)
+	}
+	s.dirty = false
+	return nil
+}
+
+func (s *Store) saveFile(path string) error {
+	// Write and rename so a crash never leaves a half written store.
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("writing store: %w", err)
+	}
+	if err := s.save(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("writing store: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("writing store: %w", err)
+	}
+	return nil
+}
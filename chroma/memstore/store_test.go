@@ -0,0 +1,145 @@
+package memstore_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/kristofferostlund/chroma-go/chroma"
+	"github.com/kristofferostlund/chroma-go/chroma/memstore"
+)
+
+func newStore(t *testing.T, opts ...memstore.Opt) *memstore.Store {
+	t.Helper()
+	store, err := memstore.New(opts...)
+	if err != nil {
+		t.Fatalf("creating store: %v", err)
+	}
+	return store
+}
+
+func nearest(t *testing.T, store *memstore.Store, query chroma.Embedding) []chroma.ID {
+	t.Helper()
+	c, err := store.GetCollection("docs")
+	if err != nil {
+		t.Fatalf("getting collection: %v", err)
+	}
+	res, err := c.Query(memstore.QueryRequest{Embeddings: []chroma.Embedding{query}, NResults: 3, Where: nil, WhereDocument: nil, Include: nil})
+	if err != nil {
+		t.Fatalf("querying: %v", err)
+	}
+	return res.IDs[0]
+}
+
+func TestPersistExactSearch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.json")
+	store := newStore(t, memstore.Persist(path), memstore.ExactSearch())
+	c, err := store.CreateCollection("docs", chroma.Metadata{"hnsw:space": "cosine"}, false)
+	if err != nil {
+		t.Fatalf("creating collection: %v", err)
+	}
+	if err := c.Add(
+		[]chroma.ID{"a", "b", "c", "d"},
+		[]chroma.Embedding{{1, 0}, {0, 1}, {1, 1}, {-1, 0}},
+		[]chroma.Metadata{{"n": 1}, nil, nil, nil},
+		[]chroma.Document{"one", "two", "three", "four"},
+	); err != nil {
+		t.Fatalf("adding: %v", err)
+	}
+	if _, err := c.Delete([]chroma.ID{"d"}, nil, nil); err != nil {
+		t.Fatalf("deleting: %v", err)
+	}
+	want := nearest(t, store, chroma.Embedding{1, 0.1})
+
+	// Reloaded with exact search, and with an index built from the records.
+	for _, opts := range [][]memstore.Opt{
+		{memstore.Persist(path), memstore.ExactSearch()},
+		{memstore.Persist(path)},
+	} {
+		loaded := newStore(t, opts...)
+		if got := nearest(t, loaded, chroma.Embedding{1, 0.1}); !reflect.DeepEqual(got, want) {
+			t.Errorf("got nearest %v after loading, want %v", got, want)
+		}
+		c, err := loaded.GetCollection("docs")
+		if err != nil {
+			t.Fatalf("getting collection: %v", err)
+		}
+		if c.Count() != 3 || c.Space() != memstore.SpaceCosine {
+			t.Errorf("got %d records in space %s, want 3 in cosine", c.Count(), c.Space())
+		}
+		got, err := c.Get(memstore.GetRequest{IDs: []chroma.ID{"a"}, Where: nil, WhereDocument: nil, Limit: 0, Offset: 0, Include: nil})
+		if err != nil {
+			t.Fatalf("getting: %v", err)
+		}
+		if got.Documents[0] != "one" || got.Metadatas[0]["n"] == nil {
+			t.Errorf("got document %q and metadata %v, want them loaded", got.Documents[0], got.Metadatas[0])
+		}
+	}
+}
+
+func TestPersistFailure(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "missing")
+	path := filepath.Join(dir, "store.json")
+	store := newStore(t, memstore.Persist(path))
+
+	if _, err := store.CreateCollection("docs", nil, false); !errors.Is(err, memstore.ErrNotPersisted) {
+		t.Fatalf("got error %v saving to a missing directory, want ErrNotPersisted", err)
+	}
+	// The change is kept in memory, and saved once saving works again.
+	if _, err := store.GetCollection("docs"); err != nil {
+		t.Fatalf("getting the unsaved collection: %v", err)
+	}
+	if err := os.Mkdir(dir, 0o700); err != nil {
+		t.Fatalf("creating directory: %v", err)
+	}
+	if err := store.Flush(); err != nil {
+		t.Fatalf("flushing: %v", err)
+	}
+	if _, err := newStore(t, memstore.Persist(path)).GetCollection("docs"); err != nil {
+		t.Errorf("getting the collection after loading: %v", err)
+	}
+}
+
+func TestPersistInterval(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.json")
+	store := newStore(t, memstore.Persist(path), memstore.PersistInterval(time.Hour))
+	if _, err := store.CreateCollection("docs", nil, false); err != nil {
+		t.Fatalf("creating collection: %v", err)
+	}
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("got the store saved before the interval, error %v", err)
+	}
+	if err := store.Flush(); err != nil {
+		t.Fatalf("flushing: %v", err)
+	}
+	if _, err := newStore(t, memstore.Persist(path)).GetCollection("docs"); err != nil {
+		t.Errorf("getting the collection after flushing: %v", err)
+	}
+}
+
+func TestPersistIntervalFailure(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing", "store.json")
+	store := newStore(t, memstore.Persist(path), memstore.PersistInterval(time.Millisecond))
+
+	// The failed save in the background is returned by a later change.
+	deadline := time.Now().Add(5 * time.Second)
+	for i := 0; ; i++ {
+		_, err := store.CreateCollection("docs", chroma.Metadata{"i": i}, true)
+		if errors.Is(err, memstore.ErrNotPersisted) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("got error %v, want ErrNotPersisted", err)
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("got no ErrNotPersisted from failed saves")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if err := store.Flush(); !errors.Is(err, memstore.ErrNotPersisted) {
+		t.Errorf("got error %v flushing, want ErrNotPersisted", err)
+	}
+}
@@ -0,0 +1,371 @@
+package chroma
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// Doer sends HTTP requests, like *http.Client. It is the same interface as
+// chromaclient.HttpRequestDoer.
+type Doer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// DoerFunc adapts a function to a Doer.
+type DoerFunc func(req *http.Request) (*http.Response, error)
+
+func (f DoerFunc) Do(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// Middleware wraps the Doer used to talk to the server, seeing every request
+// and response. For example, to send a tenant header with every request:
+//
+//	tenant := func(next chroma.Doer) chroma.Doer {
+//		return chroma.DoerFunc(func(req *http.Request) (*http.Response, error) {
+//			req.Header.Set("X-Tenant", "acme")
+//			return next.Do(req)
+//		})
+//	}
+//	client := chroma.NewClient(url, chroma.WithMiddleware(tenant))
+type Middleware func(next Doer) Doer
+
+// WithMiddleware adds middlewares to the client. The first middleware sees
+// requests first and responses last. Requests are logged and traced after
+// all middlewares, so every retry is logged separately.
+func WithMiddleware(middlewares ...Middleware) ClientOpts {
+	return func(c *clientOpts) {
+		c.middlewares = append(c.middlewares, middlewares...)
+	}
+}
+
+// chain wraps doer in middlewares, the first being the outermost.
+func chain(doer Doer, middlewares ...Middleware) Doer {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		doer = middlewares[i](doer)
+	}
+	return doer
+}
+
+// LoggingMiddleware logs requests at debug level with logger, or with the
+// logger from the request's context if nil. Clients created WithLogger
+// already log every request.
+func LoggingMiddleware(logger *slog.Logger) Middleware {
+	return func(next Doer) Doer {
+		return requestLogger{next: next, logger: logger}
+	}
+}
+
+// MetricsMiddleware records the duration of every request, by method and
+// status, and counts requests failing without a response.
+func MetricsMiddleware(mp metric.MeterProvider) (Middleware, error) {
+	meter := mp.Meter(instrumentationName)
+	duration, err := meter.Float64Histogram("chroma.http.request.duration",
+		metric.WithUnit("s"),
+		metric.WithDescription("Duration of HTTP requests to the chroma server."),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("creating histogram: %w", err)
+	}
+	failures, err := meter.Int64Counter("chroma.http.request.failures",
+		metric.WithDescription("Number of HTTP requests to the chroma server failing without a response."),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("creating counter: %w", err)
+	}
+
+	return func(next Doer) Doer {
+		return DoerFunc(func(req *http.Request) (*http.Response, error) {
+			start := time.Now()
+			res, err := next.Do(req)
+			method := attribute.String("http.request.method", req.Method)
+			if err != nil {
+				failures.Add(req.Context(), 1, metric.WithAttributes(method))
+				return res, err
+			}
+			duration.Record(req.Context(), time.Since(start).Seconds(), metric.WithAttributes(method, AttributeHTTPStatusCode.Int(res.StatusCode)))
+			return res, nil
+		})
+	}, nil
+}
+
+type retryOpts struct {
+	maxAttempts int
+	baseDelay   time.Duration
+	maxDelay    time.Duration
+	retryable   func(res *http.Response, err error) bool
+}
+
+type RetryOpts func(*retryOpts)
+
+// WithRetryMaxAttempts sets how many times a request is sent at most,
+// including the first attempt. Defaults to 3.
+func WithRetryMaxAttempts(maxAttempts int) RetryOpts {
+	return func(o *retryOpts) {
+		o.maxAttempts = maxAttempts
+	}
+}
+
+// WithRetryBackoff sets the delay before the first retry, doubled for every
+// following retry up to maxDelay, which also caps Retry-After delays.
+// Defaults to 100ms and 5s. baseDelay must be positive and at most maxDelay.
+func WithRetryBackoff(baseDelay, maxDelay time.Duration) RetryOpts {
+	return func(o *retryOpts) {
+		o.baseDelay = baseDelay
+		o.maxDelay = maxDelay
+	}
+}
+
+// WithRetryPolicy decides which responses or errors are retried, replacing
+// DefaultRetryPolicy.
+func WithRetryPolicy(retryable func(res *http.Response, err error) bool) RetryOpts {
+	return func(o *retryOpts) {
+		o.retryable = retryable
+	}
+}
+
+// DefaultRetryPolicy retries transport errors, except for cancellation, and
+// the statuses 429, 502, 503 and 504. Note that a request may have been
+// processed even though the response was a 502 or 504, which makes retrying
+// Add fail on duplicate IDs.
+func DefaultRetryPolicy(res *http.Response, err error) bool {
+	if err != nil {
+		return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+	}
+	switch res.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// RetryMiddleware resends failed requests with exponential backoff and
+// jitter, honouring Retry-After headers. Retries are logged at warn level
+// with the logger from the request's context. Requests with bodies which
+// can't be replayed, see http.Request.GetBody, are not retried.
+func RetryMiddleware(opts ...RetryOpts) (Middleware, error) {
+	rOpts := &retryOpts{maxAttempts: 3, baseDelay: 100 * time.Millisecond, maxDelay: 5 * time.Second, retryable: DefaultRetryPolicy}
+	for _, opt := range opts {
+		opt(rOpts)
+	}
+	if rOpts.maxAttempts <= 0 {
+		return nil, fmt.Errorf("%w: max attempts must be positive, got %d", ErrInvalidInput, rOpts.maxAttempts)
+	}
+	if rOpts.baseDelay <= 0 || rOpts.maxDelay < rOpts.baseDelay {
+		return nil, fmt.Errorf("%w: retry backoff must have 0 < base delay <= max delay, got %s and %s", ErrInvalidInput, rOpts.baseDelay, rOpts.maxDelay)
+	}
+	if rOpts.retryable == nil {
+		return nil, fmt.Errorf("%w: no retry policy", ErrInvalidInput)
+	}
+
+	return func(next Doer) Doer {
+		return DoerFunc(func(req *http.Request) (*http.Response, error) {
+			ctx := req.Context()
+			for attempt := 1; ; attempt++ {
+				res, err := next.Do(req)
+				if attempt >= rOpts.maxAttempts || !rOpts.retryable(res, err) {
+					return res, err
+				}
+				if req.Body != nil && req.GetBody == nil {
+					return res, err
+				}
+
+				delay := rOpts.backoff(attempt, res)
+				logger := LoggerFromContext(ctx)
+				if err != nil {
+					logger.Warn("retrying chroma request", "method", req.Method, "path", req.URL.Path, "attempt", attempt, "delay", delay, "error", err)
+				} else {
+					logger.Warn("retrying chroma request", "method", req.Method, "path", req.URL.Path, "attempt", attempt, "delay", delay, "status", res.StatusCode)
+					// Drain so the connection can be reused.
+					_, _ = io.Copy(io.Discard, res.Body)
+					res.Body.Close()
+				}
+
+				select {
+				case <-time.After(delay):
+				case <-ctx.Done():
+					return nil, ctx.Err()
+				}
+
+				if req.GetBody != nil {
+					body, err := req.GetBody()
+					if err != nil {
+						return nil, fmt.Errorf("replaying request body: %w", err)
+					}
+					req = req.Clone(ctx)
+					req.Body = body
+				}
+			}
+		})
+	}, nil
+}
+
+func (o *retryOpts) backoff(attempt int, res *http.Response) time.Duration {
+	if res != nil {
+		if s, err := strconv.Atoi(res.Header.Get("Retry-After")); err == nil && s >= 0 {
+			// Compared in seconds, as a huge Retry-After would overflow.
+			if s > int(o.maxDelay/time.Second) {
+				return o.maxDelay
+			}
+			return time.Duration(s) * time.Second
+		}
+	}
+
+	delay := o.baseDelay << (attempt - 1)
+	if delay > o.maxDelay || delay <= 0 {
+		delay = o.maxDelay
+	}
+	// Jitter within the upper half, so concurrent clients spread out.
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// DefaultRequestIDHeader is the header set by RequestIDMiddleware.
+const DefaultRequestIDHeader = "X-Request-ID"
+
+// RequestIDMiddleware sets a random UUID as request ID in header, or in
+// DefaultRequestIDHeader if empty, unless the request already has one.
+// Placed before RetryMiddleware, retries keep the request ID of the first attempt.
+func RequestIDMiddleware(header string) Middleware {
+	if header == "" {
+		header = DefaultRequestIDHeader
+	}
+	return func(next Doer) Doer {
+		return DoerFunc(func(req *http.Request) (*http.Response, error) {
+			if req.Header.Get(header) == "" {
+				id, err := UUIDv4IDs("", nil)
+				if err != nil {
+					return nil, fmt.Errorf("generating request ID: %w", err)
+				}
+				req = req.Clone(req.Context())
+				req.Header.Set(header, id)
+			}
+			return next.Do(req)
+		})
+	}
+}
+
+// GzipMiddleware compresses request bodies of at least minSize bytes, which
+// mostly pays off for large batches of embeddings. The server, or a proxy
+// in front of it, must accept gzip encoded requests.
+func GzipMiddleware(minSize int) Middleware {
+	return func(next Doer) Doer {
+		return DoerFunc(func(req *http.Request) (*http.Response, error) {
+			if req.Body == nil || req.Header.Get("Content-Encoding") != "" {
+				return next.Do(req)
+			}
+			if req.ContentLength > 0 && req.ContentLength < int64(minSize) {
+				return next.Do(req)
+			}
+
+			body, err := io.ReadAll(req.Body)
+			req.Body.Close()
+			if err != nil {
+				return nil, fmt.Errorf("reading request body: %w", err)
+			}
+			if len(body) < minSize {
+				return next.Do(withBody(req, body))
+			}
+
+			var buf bytes.Buffer
+			zw := gzip.NewWriter(&buf)
+			if _, err := zw.Write(body); err != nil {
+				return nil, fmt.Errorf("compressing request body: %w", err)
+			}
+			if err := zw.Close(); err != nil {
+				return nil, fmt.Errorf("compressing request body: %w", err)
+			}
+
+			req = withBody(req, buf.Bytes())
+			req.Header.Set("Content-Encoding", "gzip")
+			return next.Do(req)
+		})
+	}
+}
+
+// withBody returns a copy of req sending body, which can be replayed.
+func withBody(req *http.Request, body []byte) *http.Request {
+	req = req.Clone(req.Context())
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	req.ContentLength = int64(len(body))
+	return req
+}
+
+// Exchange is a request and its response, as seen by CaptureMiddleware.
+// Credentials are redacted from the request headers.
+type Exchange struct {
+	Method         string
+	URL            string
+	RequestHeader  http.Header
+	RequestBody    []byte
+	StatusCode     int
+	ResponseHeader http.Header
+	ResponseBody   []byte
+	Duration       time.Duration
+	Err            error
+}
+
+// CaptureMiddleware calls capture with every request and its response, for
+// debugging. Bodies are buffered in memory, so avoid it for large exports.
+func CaptureMiddleware(capture func(Exchange)) Middleware {
+	return func(next Doer) Doer {
+		return DoerFunc(func(req *http.Request) (*http.Response, error) {
+			ex := Exchange{Method: req.Method, URL: req.URL.String(), RequestHeader: redactHeader(req.Header)}
+			if req.Body != nil {
+				body, err := io.ReadAll(req.Body)
+				req.Body.Close()
+				if err != nil {
+					return nil, fmt.Errorf("reading request body: %w", err)
+				}
+				ex.RequestBody = body
+				req = withBody(req, body)
+			}
+
+			start := time.Now()
+			res, err := next.Do(req)
+			ex.Duration = time.Since(start)
+			if err != nil {
+				ex.Err = err
+				capture(ex)
+				return res, err
+			}
+
+			body, err := io.ReadAll(res.Body)
+			res.Body.Close()
+			if err != nil {
+				return nil, fmt.Errorf("reading response body: %w", err)
+			}
+			res.Body = io.NopCloser(bytes.NewReader(body))
+			ex.StatusCode = res.StatusCode
+			ex.ResponseHeader = res.Header.Clone()
+			ex.ResponseBody = body
+			capture(ex)
+			return res, nil
+		})
+	}
+}
+
+func redactHeader(h http.Header) http.Header {
+	redacted := h.Clone()
+	for k := range redacted {
+		if sensitiveHeaders[http.CanonicalHeaderKey(k)] {
+			redacted[k] = []string{"REDACTED"}
+		}
+	}
+	return redacted
+}
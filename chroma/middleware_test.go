@@ -0,0 +1,206 @@
+package chroma_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kristofferostlund/chroma-go/chroma"
+	"github.com/kristofferostlund/chroma-go/chroma/memstore"
+)
+
+func TestRetryMiddleware(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+		want     int
+		wantHits int64
+	}{
+		{"succeeds", []int{http.StatusOK}, http.StatusOK, 1},
+		{"retries", []int{http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusOK}, http.StatusOK, 3},
+		{"gives up", []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusOK}, http.StatusServiceUnavailable, 3},
+		{"not retryable", []int{http.StatusInternalServerError, http.StatusOK}, http.StatusInternalServerError, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var hits int64
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				hit := atomic.AddInt64(&hits, 1)
+				if body, _ := io.ReadAll(r.Body); string(body) != "payload" {
+					t.Errorf("got body %q on attempt %d, want it replayed", body, hit)
+				}
+				// An hour, which the backoff caps.
+				w.Header().Set("Retry-After", "3600")
+				w.WriteHeader(tt.statuses[hit-1])
+			}))
+			defer srv.Close()
+
+			retry, err := chroma.RetryMiddleware(chroma.WithRetryBackoff(time.Millisecond, 20*time.Millisecond))
+			if err != nil {
+				t.Fatalf("creating middleware: %v", err)
+			}
+			req, err := http.NewRequest(http.MethodPost, srv.URL, strings.NewReader("payload"))
+			if err != nil {
+				t.Fatalf("creating request: %v", err)
+			}
+			start := time.Now()
+			res, err := retry(http.DefaultClient).Do(req)
+			if err != nil {
+				t.Fatalf("sending: %v", err)
+			}
+			res.Body.Close()
+			if res.StatusCode != tt.want {
+				t.Errorf("got status %d, want %d", res.StatusCode, tt.want)
+			}
+			if got := atomic.LoadInt64(&hits); got != tt.wantHits {
+				t.Errorf("got %d attempts, want %d", got, tt.wantHits)
+			}
+			if elapsed := time.Since(start); elapsed > 5*time.Second {
+				t.Errorf("got %s retrying, want Retry-After capped by the max delay", elapsed)
+			}
+		})
+	}
+}
+
+func TestRetryMiddlewareCancel(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	retry, err := chroma.RetryMiddleware(chroma.WithRetryBackoff(time.Hour, time.Hour))
+	if err != nil {
+		t.Fatalf("creating middleware: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	if err != nil {
+		t.Fatalf("creating request: %v", err)
+	}
+	if _, err := retry(http.DefaultClient).Do(req); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got error %v waiting to retry, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestRetryMiddlewareInvalid(t *testing.T) {
+	for _, opts := range [][]chroma.RetryOpts{
+		{chroma.WithRetryMaxAttempts(0)},
+		{chroma.WithRetryBackoff(0, time.Second)},
+		{chroma.WithRetryBackoff(time.Second, time.Millisecond)},
+		{chroma.WithRetryPolicy(nil)},
+	} {
+		if _, err := chroma.RetryMiddleware(opts...); !errors.Is(err, chroma.ErrInvalidInput) {
+			t.Errorf("got error %v, want ErrInvalidInput", err)
+		}
+	}
+}
+
+// gunzip decompresses gzip encoded request bodies before calling next.
+func gunzip(t *testing.T, next http.Handler, compressed *int64) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			atomic.AddInt64(compressed, 1)
+			zr, err := gzip.NewReader(r.Body)
+			if err != nil {
+				t.Errorf("decompressing request: %v", err)
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			r.Body = zr
+			r.ContentLength = -1
+			r.Header.Del("Content-Encoding")
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func TestGzipMiddleware(t *testing.T) {
+	store, err := memstore.New()
+	if err != nil {
+		t.Fatalf("creating store: %v", err)
+	}
+	var compressed int64
+	srv := httptest.NewServer(gunzip(t, store.Handler(), &compressed))
+	defer srv.Close()
+
+	ctx := context.Background()
+	client := chroma.NewClient(srv.URL, chroma.WithMiddleware(chroma.GzipMiddleware(1024)))
+	coll, err := client.CreateCollection(ctx, "docs", chroma.WithEmbeddingFunc(lengthEmbeddings{}))
+	if err != nil {
+		t.Fatalf("creating collection: %v", err)
+	}
+	if got := atomic.LoadInt64(&compressed); got != 0 {
+		t.Errorf("got %d compressed requests below the minimum size, want none", got)
+	}
+
+	ids := make([]chroma.ID, 0, 100)
+	documents := make([]chroma.Document, 0, 100)
+	for i := 0; i < 100; i++ {
+		ids = append(ids, chroma.ID(strings.Repeat("x", i+1)))
+		documents = append(documents, chroma.Document(strings.Repeat("y", i+1)))
+	}
+	if _, err := coll.Add(ctx, ids, nil, nil, documents); err != nil {
+		t.Fatalf("adding: %v", err)
+	}
+	if got := atomic.LoadInt64(&compressed); got != 1 {
+		t.Errorf("got %d compressed requests, want the add", got)
+	}
+	if n, err := coll.Count(ctx); err != nil || n != 100 {
+		t.Errorf("got count %d and error %v, want 100", n, err)
+	}
+}
+
+func TestCaptureMiddleware(t *testing.T) {
+	store, err := memstore.New()
+	if err != nil {
+		t.Fatalf("creating store: %v", err)
+	}
+	srv := httptest.NewServer(store.Handler())
+	defer srv.Close()
+
+	var exchanges []chroma.Exchange
+	capture := chroma.CaptureMiddleware(func(ex chroma.Exchange) {
+		exchanges = append(exchanges, ex)
+	})
+	auth := func(next chroma.Doer) chroma.Doer {
+		return chroma.DoerFunc(func(req *http.Request) (*http.Response, error) {
+			req.Header.Set("Authorization", "Bearer secret-token")
+			return next.Do(req)
+		})
+	}
+	client := chroma.NewClient(srv.URL, chroma.WithMiddleware(auth, capture))
+
+	ctx := context.Background()
+	coll, err := client.CreateCollection(ctx, "docs")
+	if err != nil {
+		t.Fatalf("creating collection: %v", err)
+	}
+	if coll.Name != "docs" {
+		t.Errorf("got collection %q, want the response read after capturing", coll.Name)
+	}
+	if len(exchanges) != 1 {
+		t.Fatalf("got %d exchanges, want 1", len(exchanges))
+	}
+	ex := exchanges[0]
+	if ex.Method != http.MethodPost || !strings.HasSuffix(ex.URL, "/api/v1/collections") {
+		t.Errorf("got %s %s, want the create request", ex.Method, ex.URL)
+	}
+	if got := ex.RequestHeader.Get("Authorization"); got != "REDACTED" {
+		t.Errorf("got Authorization %q, want it redacted", got)
+	}
+	if !bytes.Contains(ex.RequestBody, []byte(`"docs"`)) || !bytes.Contains(ex.ResponseBody, []byte(`"docs"`)) {
+		t.Errorf("got request %s and response %s, want both bodies", ex.RequestBody, ex.ResponseBody)
+	}
+	if ex.StatusCode != http.StatusOK || ex.Err != nil {
+		t.Errorf("got status %d and error %v, want 200", ex.StatusCode, ex.Err)
+	}
+}
@@ -0,0 +1,180 @@
+package chroma
+
+import (
+	"context"
+	"fmt"
+	"math"
+)
+
+// QueryMMR embeds queryTexts with the collection's query embedding generator
+// and returns k results for each, selected by QueryEmbeddingsMMR.
+func (c *Collection) QueryMMR(ctx context.Context, queryTexts []Document, k, fetchK int, lambda float64, opts ...QueryOpts) (_ *QueryResult, err error) {
+	ctx, op := c.client.telemetry.start(ctx, "Collection.QueryMMR", AttributeCollectionName.String(c.Name), AttributeBatchSize.Int(len(queryTexts)))
+	defer func() { op.end(err) }()
+
+	if len(queryTexts) == 0 {
+		return nil, fmt.Errorf("%w: no query texts", ErrInvalidInput)
+	}
+
+	queryEmbeddings, err := c.generateQueryEmbeddings(ctx, queryTexts)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.QueryEmbeddingsMMR(ctx, queryEmbeddings, k, fetchK, lambda, opts...)
+}
+
+// QueryEmbeddingsMMR fetches the fetchK nearest neighbours of each of
+// queryEmbeddings and selects k of them by Maximal Marginal Relevance,
+// trading similarity to the query for dissimilarity to the results already
+// selected to avoid near duplicates. A lambda of 1 ranks by similarity
+// only, 0 by diversity only, 0.5 is a common choice.
+//
+// Results are in selection order with the distances returned by the query.
+// Embeddings are always fetched but only returned if included.
+func (c *Collection) QueryEmbeddingsMMR(ctx context.Context, queryEmbeddings []Embedding, k, fetchK int, lambda float64, opts ...QueryOpts) (_ *QueryResult, err error) {
+	ctx, op := c.client.telemetry.start(ctx, "Collection.QueryEmbeddingsMMR", AttributeCollectionName.String(c.Name), AttributeBatchSize.Int(len(queryEmbeddings)))
+	defer func() { op.end(err) }()
+
+	if k <= 0 {
+		return nil, fmt.Errorf("%w: k must be positive, got %d", ErrInvalidInput, k)
+	}
+	if fetchK < k {
+		return nil, fmt.Errorf("%w: fetchK must be at least k, got %d < %d", ErrInvalidInput, fetchK, k)
+	}
+	if lambda < 0 || lambda > 1 {
+		return nil, fmt.Errorf("%w: lambda must be between 0 and 1, got %v", ErrInvalidInput, lambda)
+	}
+
+	include := queryOptsOf(opts).include
+	if include == nil {
+		// The server's default for queries.
+		include = []Include{IncludeDocuments, IncludeMetadatas, IncludeDistances}
+	}
+	includeEmbeddings := false
+	for _, inc := range include {
+		includeEmbeddings = includeEmbeddings || inc == IncludeEmbeddings
+	}
+	fetchOpts := append([]QueryOpts{}, opts...)
+	if !includeEmbeddings {
+		fetchOpts = append(fetchOpts, WithInclude(append(append([]Include{}, include...), IncludeEmbeddings)...))
+	}
+
+	candidates, err := c.QueryEmbeddings(ctx, queryEmbeddings, fetchK, fetchOpts...)
+	if err != nil {
+		return nil, err
+	}
+	if len(candidates.Embeddings) != len(candidates.IDs) {
+		return nil, fmt.Errorf("querying: got embeddings for %d of %d queries", len(candidates.Embeddings), len(candidates.IDs))
+	}
+
+	result := &QueryResult{IDs: make([][]ID, 0, len(candidates.IDs)), Embeddings: nil, Documents: nil, Metadatas: nil, Distances: nil}
+	results := 0
+	for i, query := range queryEmbeddings {
+		selected := maxMarginalRelevance(query, candidates.Embeddings[i], k, lambda)
+		results += len(selected)
+
+		ids := make([]ID, 0, len(selected))
+		embeddings := make([]Embedding, 0, len(selected))
+		documents := make([]Document, 0, len(selected))
+		metadatas := make([]Metadata, 0, len(selected))
+		distances := make([]float64, 0, len(selected))
+		for _, j := range selected {
+			ids = append(ids, candidates.IDs[i][j])
+			embeddings = append(embeddings, candidates.Embeddings[i][j])
+			if candidates.Documents != nil {
+				documents = append(documents, candidates.Documents[i][j])
+			}
+			if candidates.Metadatas != nil {
+				metadatas = append(metadatas, candidates.Metadatas[i][j])
+			}
+			if candidates.Distances != nil {
+				distances = append(distances, candidates.Distances[i][j])
+			}
+		}
+
+		result.IDs = append(result.IDs, ids)
+		if includeEmbeddings {
+			result.Embeddings = append(result.Embeddings, embeddings)
+		}
+		if candidates.Documents != nil {
+			result.Documents = append(result.Documents, documents)
+		}
+		if candidates.Metadatas != nil {
+			result.Metadatas = append(result.Metadatas, metadatas)
+		}
+		if candidates.Distances != nil {
+			result.Distances = append(result.Distances, distances)
+		}
+	}
+	op.setResultCount(results)
+
+	return result, nil
+}
+
+// maxMarginalRelevance returns the indexes of up to k candidates in
+// selection order, each maximizing
+//
+//	lambda * sim(query, candidate) - (1 - lambda) * max(sim(candidate, selected))
+//
+// with sim being the cosine similarity. Candidates scoring NaN, such as ones
+// with NaN values, are never selected.
+func maxMarginalRelevance(query Embedding, candidates []Embedding, k int, lambda float64) []int {
+	relevance := make([]float64, len(candidates))
+	for i, c := range candidates {
+		relevance[i] = cosineSimilarity(query, c)
+	}
+	// redundancy is the highest similarity of each candidate to a selected one.
+	redundancy := make([]float64, len(candidates))
+	for i := range redundancy {
+		redundancy[i] = math.Inf(-1)
+	}
+	chosen := make([]bool, len(candidates))
+
+	selected := make([]int, 0, k)
+	for len(selected) < k && len(selected) < len(candidates) {
+		best, bestScore := -1, math.Inf(-1)
+		for i := range candidates {
+			if chosen[i] {
+				continue
+			}
+			score := lambda * relevance[i]
+			if len(selected) > 0 {
+				score -= (1 - lambda) * redundancy[i]
+			}
+			if score > bestScore {
+				best, bestScore = i, score
+			}
+		}
+		if best < 0 {
+			// Only candidates scoring NaN are left, which are never selected.
+			break
+		}
+
+		chosen[best] = true
+		selected = append(selected, best)
+		for i, c := range candidates {
+			if !chosen[i] {
+				redundancy[i] = math.Max(redundancy[i], cosineSimilarity(c, candidates[best]))
+			}
+		}
+	}
+	return selected
+}
+
+// cosineSimilarity is 0 if either embedding is zero or their dimensions differ.
+func cosineSimilarity(a, b Embedding) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += a[i] * b[i]
+		normA += a[i] * a[i]
+		normB += b[i] * b[i]
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
@@ -0,0 +1,19 @@
+package chroma
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestMaxMarginalRelevanceNaN(t *testing.T) {
+	nan := math.NaN()
+	candidates := []Embedding{{nan, 1}, {1, 0}, {0, 1}, {nan, nan}}
+
+	if got, want := maxMarginalRelevance(Embedding{1, 0.1}, candidates, 4, 0.5), []int{1, 2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want the candidates without NaN %v", got, want)
+	}
+	if got := maxMarginalRelevance(Embedding{nan, 0}, candidates, 2, 0.5); len(got) != 0 {
+		t.Errorf("got %v for a NaN query, want none", got)
+	}
+}
@@ -0,0 +1,151 @@
+package chroma_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/kristofferostlund/chroma-go/chroma"
+	"github.com/kristofferostlund/chroma-go/chroma/memstore"
+)
+
+// clusteredCollection has two clusters of near duplicates, cluster a being
+// slightly nearer to mmrQuery than the opposite cluster b.
+func clusteredCollection(t *testing.T) *chroma.Collection {
+	t.Helper()
+	store, err := memstore.New(memstore.ExactSearch())
+	if err != nil {
+		t.Fatalf("creating store: %v", err)
+	}
+	coll, err := memstore.NewClient(store).CreateCollection(context.Background(), "clusters",
+		chroma.WithMetadata(chroma.Metadata{memstore.MetadataSpace: string(memstore.SpaceCosine)}),
+	)
+	if err != nil {
+		t.Fatalf("creating collection: %v", err)
+	}
+
+	_, err = coll.Add(context.Background(),
+		[]chroma.ID{"a1", "a2", "a3", "b1", "b2"},
+		[]chroma.Embedding{{1, 0.9, 0}, {1, 0.9, 0.01}, {1, 0.9, 0.02}, {1, -1.1, 0}, {1, -1.1, 0.01}},
+		nil,
+		[]chroma.Document{"a1", "a2", "a3", "b1", "b2"},
+	)
+	if err != nil {
+		t.Fatalf("adding: %v", err)
+	}
+	return coll
+}
+
+var mmrQuery = []chroma.Embedding{{1, 0, 0}}
+
+func TestQueryEmbeddingsMMRSimilarityOnly(t *testing.T) {
+	ctx := context.Background()
+	coll := clusteredCollection(t)
+
+	got, err := coll.QueryEmbeddingsMMR(ctx, mmrQuery, 4, 5, 1)
+	if err != nil {
+		t.Fatalf("querying: %v", err)
+	}
+	want, err := coll.QueryEmbeddings(ctx, mmrQuery, 4)
+	if err != nil {
+		t.Fatalf("querying: %v", err)
+	}
+	if !reflect.DeepEqual(got.IDs, want.IDs) {
+		t.Errorf("got %v with lambda 1, want the similarity order %v", got.IDs, want.IDs)
+	}
+	if !reflect.DeepEqual(got.Distances, want.Distances) {
+		t.Errorf("got distances %v, want %v", got.Distances, want.Distances)
+	}
+}
+
+func TestQueryEmbeddingsMMRDiversifies(t *testing.T) {
+	ctx := context.Background()
+	coll := clusteredCollection(t)
+
+	for _, lambda := range []float64{0, 0.5} {
+		got, err := coll.QueryEmbeddingsMMR(ctx, mmrQuery, 2, 5, lambda)
+		if err != nil {
+			t.Fatalf("querying: %v", err)
+		}
+		if len(got.IDs[0]) != 2 || got.IDs[0][0][0] == got.IDs[0][1][0] {
+			t.Errorf("got %v with lambda %v, want one result from each cluster", got.IDs[0], lambda)
+		}
+	}
+
+	got, err := coll.QueryEmbeddingsMMR(ctx, mmrQuery, 2, 5, 0.5)
+	if err != nil {
+		t.Fatalf("querying: %v", err)
+	}
+	if want := []chroma.ID{"a1", "b1"}; !reflect.DeepEqual(got.IDs[0], want) {
+		t.Errorf("got %v, want the nearest of each cluster %v", got.IDs[0], want)
+	}
+	if want := []chroma.Document{"a1", "b1"}; !reflect.DeepEqual(got.Documents[0], want) {
+		t.Errorf("got documents %v, want %v", got.Documents[0], want)
+	}
+}
+
+func TestQueryEmbeddingsMMRFewerCandidates(t *testing.T) {
+	coll := clusteredCollection(t)
+
+	got, err := coll.QueryEmbeddingsMMR(context.Background(), mmrQuery, 10, 20, 0.5)
+	if err != nil {
+		t.Fatalf("querying: %v", err)
+	}
+	if len(got.IDs[0]) != 5 {
+		t.Fatalf("got %d results, want all 5 candidates", len(got.IDs[0]))
+	}
+	seen := make(map[chroma.ID]bool)
+	for _, id := range got.IDs[0] {
+		if seen[id] {
+			t.Errorf("got %q twice", id)
+		}
+		seen[id] = true
+	}
+}
+
+func TestQueryEmbeddingsMMRInclude(t *testing.T) {
+	ctx := context.Background()
+	coll := clusteredCollection(t)
+
+	got, err := coll.QueryEmbeddingsMMR(ctx, mmrQuery, 2, 5, 0.5, chroma.WithInclude(chroma.IncludeDocuments))
+	if err != nil {
+		t.Fatalf("querying: %v", err)
+	}
+	if got.Embeddings != nil {
+		t.Errorf("got embeddings %v, want none since they weren't included", got.Embeddings)
+	}
+	if got.Distances != nil || got.Metadatas != nil {
+		t.Errorf("got distances %v and metadatas %v, want none since they weren't included", got.Distances, got.Metadatas)
+	}
+	if len(got.Documents) != 1 || len(got.Documents[0]) != 2 {
+		t.Errorf("got documents %v, want 2", got.Documents)
+	}
+
+	got, err = coll.QueryEmbeddingsMMR(ctx, mmrQuery, 2, 5, 0.5, chroma.WithInclude(chroma.IncludeEmbeddings))
+	if err != nil {
+		t.Fatalf("querying: %v", err)
+	}
+	if want := [][]chroma.Embedding{{{1, 0.9, 0}, {1, -1.1, 0}}}; !reflect.DeepEqual(got.Embeddings, want) {
+		t.Errorf("got embeddings %v, want %v", got.Embeddings, want)
+	}
+}
+
+func TestQueryEmbeddingsMMRInvalidInput(t *testing.T) {
+	coll := clusteredCollection(t)
+
+	for name, args := range map[string]struct {
+		k, fetchK int
+		lambda    float64
+	}{
+		"fetchK below k":   {k: 3, fetchK: 2, lambda: 0.5},
+		"zero k":           {k: 0, fetchK: 5, lambda: 0.5},
+		"negative lambda":  {k: 2, fetchK: 5, lambda: -0.1},
+		"lambda above one": {k: 2, fetchK: 5, lambda: 1.1},
+	} {
+		_, err := coll.QueryEmbeddingsMMR(context.Background(), mmrQuery, args.k, args.fetchK, args.lambda)
+		if !errors.Is(err, chroma.ErrInvalidInput) {
+			t.Errorf("%s: got error %v, want ErrInvalidInput", name, err)
+		}
+	}
+}
@@ -0,0 +1,358 @@
+package chroma
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// ReembedPhase is how far a re-embedding has come.
+type ReembedPhase string
+
+const (
+	ReembedCopying       ReembedPhase = "copying"
+	ReembedVerified      ReembedPhase = "verified"
+	ReembedSourceRenamed ReembedPhase = "source-renamed"
+	ReembedDone          ReembedPhase = "done"
+)
+
+// ReembedState is the checkpointed progress of a re-embedding.
+type ReembedState struct {
+	SourceID   string       `json:"source_id"`
+	SourceName string       `json:"source_name"`
+	ShadowName string       `json:"shadow_name"`
+	OldName    string       `json:"old_name"`
+	Offset     int          `json:"offset"`
+	Phase      ReembedPhase `json:"phase"`
+	// OldDeleted is set once the renamed original collection is deleted.
+	OldDeleted bool `json:"old_deleted,omitempty"`
+}
+
+// ReembedCheckpoint stores the progress of a re-embedding so that it can be
+// resumed after a crash.
+type ReembedCheckpoint interface {
+	// Load returns the stored state, or nil if there is none.
+	Load(ctx context.Context) (*ReembedState, error)
+	Save(ctx context.Context, state ReembedState) error
+}
+
+// FileCheckpoint stores re-embedding progress as JSON in a file.
+type FileCheckpoint string
+
+func (f FileCheckpoint) Load(ctx context.Context) (*ReembedState, error) {
+	b, err := os.ReadFile(string(f))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading checkpoint: %w", err)
+	}
+
+	var state ReembedState
+	if err := json.Unmarshal(b, &state); err != nil {
+		return nil, fmt.Errorf("decoding checkpoint: %w", err)
+	}
+	return &state, nil
+}
+
+func (f FileCheckpoint) Save(ctx context.Context, state ReembedState) error {
+	b, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("encoding checkpoint: %w", err)
+	}
+
+	// Write and rename so a crash never leaves a half written checkpoint.
+	tmp := string(f) + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return fmt.Errorf("writing checkpoint: %w", err)
+	}
+	if err := os.Rename(tmp, string(f)); err != nil {
+		return fmt.Errorf("writing checkpoint: %w", err)
+	}
+	return nil
+}
+
+// memoryCheckpoint is used when no checkpoint is given, so progress is only
+// kept for the duration of the call.
+type memoryCheckpoint struct {
+	state *ReembedState
+}
+
+func (m *memoryCheckpoint) Load(ctx context.Context) (*ReembedState, error) {
+	return m.state, nil
+}
+
+func (m *memoryCheckpoint) Save(ctx context.Context, state ReembedState) error {
+	m.state = &state
+	return nil
+}
+
+type reembedOpts struct {
+	shadowName string
+	oldName    string
+	pageSize   int
+	checkpoint ReembedCheckpoint
+	deleteOld  bool
+	onProgress func(copied int)
+}
+
+type ReembedOpts func(*reembedOpts)
+
+// WithShadowName sets the name of the collection the new embeddings are
+// written to before the swap, defaults to the collection name with a
+// "-reembed" suffix.
+func WithShadowName(name string) ReembedOpts {
+	return func(o *reembedOpts) {
+		o.shadowName = name
+	}
+}
+
+// WithOldName sets the name the original collection is renamed to during
+// the swap, defaults to the collection name with an "-old-" and timestamp suffix.
+func WithOldName(name string) ReembedOpts {
+	return func(o *reembedOpts) {
+		o.oldName = name
+	}
+}
+
+// WithReembedPageSize sets how many records are re-embedded at a time.
+func WithReembedPageSize(pageSize int) ReembedOpts {
+	return func(o *reembedOpts) {
+		o.pageSize = pageSize
+	}
+}
+
+// WithCheckpoint stores progress after every page, so that calling Reembed
+// again with the same checkpoint resumes where it left off.
+func WithCheckpoint(checkpoint ReembedCheckpoint) ReembedOpts {
+	return func(o *reembedOpts) {
+		o.checkpoint = checkpoint
+	}
+}
+
+// WithDeleteOld deletes the original collection after a successful swap.
+func WithDeleteOld() ReembedOpts {
+	return func(o *reembedOpts) {
+		o.deleteOld = true
+	}
+}
+
+// WithReembedProgress sets a callback called with the number of re-embedded
+// records after every page.
+func WithReembedProgress(onProgress func(copied int)) ReembedOpts {
+	return func(o *reembedOpts) {
+		o.onProgress = onProgress
+	}
+}
+
+// Reembed regenerates the embeddings of every document in the collection
+// with embeddingFunc, for migrating to a new model. The new embeddings are
+// written to a shadow collection, and once its count matches, the original
+// collection is renamed away and the shadow collection takes its name.
+//
+// The returned collection is the new collection using embeddingFunc. Use
+// WithCheckpoint to be able to resume after a failure, by calling Reembed
+// again on the original collection. Once the checkpoint has reached
+// ReembedSourceRenamed, the original collection is only found by its old
+// name, see WithOldName, so resume on the collection got by
+// ReembedState.OldName rather than by its former name.
+func (c *Collection) Reembed(ctx context.Context, embeddingFunc EmbeddingGenerator, opts ...ReembedOpts) (_ *Collection, err error) {
+	ctx, op := c.client.telemetry.start(ctx, "Collection.Reembed", AttributeCollectionName.String(c.Name))
+	defer func() { op.end(err) }()
+
+	rOpts := &reembedOpts{
+		shadowName: c.Name + "-reembed",
+		oldName:    fmt.Sprintf("%s-old-%d", c.Name, time.Now().Unix()),
+		pageSize:   500,
+		checkpoint: &memoryCheckpoint{},
+		deleteOld:  false,
+		onProgress: nil,
+	}
+	for _, opt := range opts {
+		opt(rOpts)
+	}
+	if rOpts.pageSize <= 0 {
+		return nil, fmt.Errorf("%w: page size must be positive, got %d", ErrInvalidInput, rOpts.pageSize)
+	}
+
+	state, err := rOpts.checkpoint.Load(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("re-embedding: %w", err)
+	}
+	if state != nil && state.SourceID != c.ID {
+		return nil, fmt.Errorf("%w: checkpoint is for collection %s, not %s", ErrInvalidInput, state.SourceID, c.ID)
+	}
+	if state == nil {
+		state = &ReembedState{
+			SourceID:   c.ID,
+			SourceName: c.Name,
+			ShadowName: rOpts.shadowName,
+			OldName:    rOpts.oldName,
+			Offset:     0,
+			Phase:      ReembedCopying,
+		}
+	}
+	save := func() error {
+		if err := rOpts.checkpoint.Save(ctx, *state); err != nil {
+			return fmt.Errorf("re-embedding: %w", err)
+		}
+		return nil
+	}
+
+	genOpts := []CollectionOpts{WithEmbeddingFunc(embeddingFunc)}
+	if c.queryEmbeddingGen != nil {
+		genOpts = append(genOpts, WithQueryEmbeddingFunc(c.queryEmbeddingGen))
+	}
+	shadowOpts := append([]CollectionOpts{WithMetadata(c.Metadata)}, genOpts...)
+	if state.Phase == ReembedSourceRenamed || state.Phase == ReembedDone {
+		// The shadow collection might already have been renamed, in which
+		// case a new shadow collection mustn't be created.
+		renamed, err := c.client.collectionExists(ctx, state.SourceName)
+		if err != nil {
+			return nil, fmt.Errorf("re-embedding: %w", err)
+		}
+		if renamed {
+			shadow, err := c.client.GetCollection(ctx, state.SourceName, genOpts...)
+			if err != nil {
+				return nil, fmt.Errorf("re-embedding: getting renamed shadow collection: %w", err)
+			}
+			if shadow.ID == c.ID {
+				return nil, fmt.Errorf("%w: collection %s still has the name %q after being renamed", ErrInvalidInput, c.ID, state.SourceName)
+			}
+			return c.finishReembed(ctx, shadow, state, rOpts, save)
+		}
+	}
+	shadow, err := c.client.GetOrCreateCollection(ctx, state.ShadowName, shadowOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("re-embedding: creating shadow collection: %w", err)
+	}
+
+	if state.Phase == ReembedCopying {
+		if err := c.reembedInto(ctx, shadow, embeddingFunc, state, rOpts, save); err != nil {
+			return nil, err
+		}
+
+		srcCount, err := c.Count(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("re-embedding: counting source: %w", err)
+		}
+		shadowCount, err := shadow.Count(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("re-embedding: counting shadow: %w", err)
+		}
+		if srcCount != shadowCount {
+			return nil, fmt.Errorf("re-embedding: source has %d records, shadow has %d", srcCount, shadowCount)
+		}
+
+		state.Phase = ReembedVerified
+		if err := save(); err != nil {
+			return nil, err
+		}
+	}
+
+	if state.Phase == ReembedVerified {
+		if err := c.Modify(ctx, state.OldName, nil); err != nil {
+			return nil, fmt.Errorf("re-embedding: renaming source: %w", err)
+		}
+		state.Phase = ReembedSourceRenamed
+		if err := save(); err != nil {
+			return nil, err
+		}
+	}
+
+	return c.finishReembed(ctx, shadow, state, rOpts, save)
+}
+
+func (c *Collection) reembedInto(ctx context.Context, shadow *Collection, embeddingFunc EmbeddingGenerator, state *ReembedState, rOpts *reembedOpts, save func() error) error {
+	for {
+		page, err := c.Get(ctx, nil,
+			WithInclude(IncludeDocuments, IncludeMetadatas),
+			WithLimit(rOpts.pageSize),
+			WithOffset(state.Offset),
+		)
+		if err != nil {
+			return fmt.Errorf("re-embedding: getting page at offset %d: %w", state.Offset, err)
+		}
+		if len(page.IDs) == 0 {
+			return nil
+		}
+
+		records := recordsOf(page)
+		if err := reembedRecords(ctx, c.client.telemetry, embeddingFunc, records); err != nil {
+			return fmt.Errorf("re-embedding: page at offset %d: %w", state.Offset, err)
+		}
+		if err := shadow.upsertSnapshotRecords(ctx, records); err != nil {
+			return fmt.Errorf("re-embedding: writing page at offset %d: %w", state.Offset, err)
+		}
+
+		state.Offset += len(records)
+		if err := save(); err != nil {
+			return err
+		}
+		if rOpts.onProgress != nil {
+			rOpts.onProgress(state.Offset)
+		}
+		if len(page.IDs) < rOpts.pageSize {
+			return nil
+		}
+	}
+}
+
+func (c *Collection) finishReembed(ctx context.Context, shadow *Collection, state *ReembedState, rOpts *reembedOpts, save func() error) (*Collection, error) {
+	if shadow.Name != state.SourceName {
+		if err := shadow.Modify(ctx, state.SourceName, nil); err != nil {
+			return nil, fmt.Errorf("re-embedding: renaming shadow: %w", err)
+		}
+	}
+	if state.Phase != ReembedDone {
+		state.Phase = ReembedDone
+		if err := save(); err != nil {
+			return nil, err
+		}
+	}
+
+	if rOpts.deleteOld && !state.OldDeleted {
+		if err := c.deleteOldCollection(ctx, state.OldName); err != nil {
+			return nil, err
+		}
+		state.OldDeleted = true
+		if err := save(); err != nil {
+			return nil, err
+		}
+	}
+	return shadow, nil
+}
+
+// deleteOldCollection deletes the renamed original collection, unless it is
+// already gone after a crash before the deletion was checkpointed.
+func (c *Collection) deleteOldCollection(ctx context.Context, name string) error {
+	exists, err := c.client.collectionExists(ctx, name)
+	if err != nil {
+		return fmt.Errorf("re-embedding: %w", err)
+	}
+	if !exists {
+		return nil
+	}
+	if err := c.client.DeleteCollection(ctx, name); err != nil {
+		return fmt.Errorf("re-embedding: deleting old collection: %w", err)
+	}
+	return nil
+}
+
+// collectionExists lists the collections rather than getting the one named,
+// since servers don't tell a missing collection apart from other errors.
+func (c *Client) collectionExists(ctx context.Context, name string) (bool, error) {
+	collections, err := c.ListCollections(ctx)
+	if err != nil {
+		return false, err
+	}
+	for _, coll := range collections {
+		if coll.Name == name {
+			return true, nil
+		}
+	}
+	return false, nil
+}
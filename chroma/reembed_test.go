@@ -0,0 +1,256 @@
+package chroma_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/kristofferostlund/chroma-go/chroma"
+	"github.com/kristofferostlund/chroma-go/chroma/memstore"
+)
+
+type squaredLengthEmbeddings struct{}
+
+func (squaredLengthEmbeddings) Generate(ctx context.Context, documents []chroma.Document) ([]chroma.Embedding, error) {
+	embeddings := make([]chroma.Embedding, 0, len(documents))
+	for _, d := range documents {
+		embeddings = append(embeddings, chroma.Embedding{float64(len(d) * len(d)), 1})
+	}
+	return embeddings, nil
+}
+
+var errCrash = errors.New("crash")
+
+// crashingCheckpoint fails the first save of a state matching crashAt, as if
+// the process crashed right after the save, or right before it unless stored.
+type crashingCheckpoint struct {
+	state   *chroma.ReembedState
+	crashAt func(state chroma.ReembedState) bool
+	stored  bool
+}
+
+func (c *crashingCheckpoint) Load(ctx context.Context) (*chroma.ReembedState, error) {
+	return c.state, nil
+}
+
+func (c *crashingCheckpoint) Save(ctx context.Context, state chroma.ReembedState) error {
+	if c.crashAt != nil && c.crashAt(state) {
+		c.crashAt = nil
+		if c.stored {
+			c.state = &state
+		}
+		return errCrash
+	}
+	c.state = &state
+	return nil
+}
+
+var reembedDocuments = []chroma.Document{"a", "bb", "ccc", "dddd", "eeeee"}
+
+func reembedSource(t *testing.T, opts ...chroma.ClientOpts) (*chroma.Client, *chroma.Collection) {
+	t.Helper()
+	store, err := memstore.New()
+	if err != nil {
+		t.Fatalf("creating store: %v", err)
+	}
+	client := memstore.NewClient(store, opts...)
+	coll, err := client.CreateCollection(context.Background(), "docs", chroma.WithEmbeddingFunc(lengthEmbeddings{}))
+	if err != nil {
+		t.Fatalf("creating collection: %v", err)
+	}
+	if _, err := coll.Add(context.Background(), []chroma.ID{"a", "b", "c", "d", "e"}, nil, nil, reembedDocuments); err != nil {
+		t.Fatalf("adding: %v", err)
+	}
+	return client, coll
+}
+
+func collectionNames(t *testing.T, client *chroma.Client) []string {
+	t.Helper()
+	collections, err := client.ListCollections(context.Background())
+	if err != nil {
+		t.Fatalf("listing collections: %v", err)
+	}
+	names := make([]string, 0, len(collections))
+	for _, c := range collections {
+		names = append(names, c.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func checkReembedded(t *testing.T, client *chroma.Client, source *chroma.Collection, got *chroma.Collection) {
+	t.Helper()
+	ctx := context.Background()
+
+	if got.Name != "docs" || got.ID == source.ID {
+		t.Errorf("got collection %s named %q, want a new collection named docs", got.ID, got.Name)
+	}
+	coll, err := client.GetCollection(ctx, "docs")
+	if err != nil {
+		t.Fatalf("getting collection: %v", err)
+	}
+	if coll.ID != got.ID {
+		t.Errorf("got collection %s named docs, want the returned %s", coll.ID, got.ID)
+	}
+	records, err := coll.Get(ctx, nil, chroma.WithInclude(chroma.IncludeEmbeddings, chroma.IncludeDocuments))
+	if err != nil {
+		t.Fatalf("getting records: %v", err)
+	}
+	if len(records.IDs) != len(reembedDocuments) {
+		t.Fatalf("got %d records, want %d", len(records.IDs), len(reembedDocuments))
+	}
+	for i, d := range records.Documents {
+		if want := (chroma.Embedding{float64(len(d) * len(d)), 1}); !reflect.DeepEqual(records.Embeddings[i], want) {
+			t.Errorf("got embedding %v for %q, want the re-embedded %v", records.Embeddings[i], d, want)
+		}
+	}
+}
+
+func TestReembed(t *testing.T) {
+	client, source := reembedSource(t)
+
+	got, err := source.Reembed(context.Background(), squaredLengthEmbeddings{}, chroma.WithOldName("docs-old"), chroma.WithReembedPageSize(2))
+	if err != nil {
+		t.Fatalf("re-embedding: %v", err)
+	}
+	checkReembedded(t, client, source, got)
+	if got, want := collectionNames(t, client), []string{"docs", "docs-old"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got collections %v, want %v", got, want)
+	}
+}
+
+func TestReembedResume(t *testing.T) {
+	tests := []struct {
+		name    string
+		crashAt func(state chroma.ReembedState) bool
+		stored  bool
+		// resumeOnOld resumes on the original collection got by its old
+		// name, rather than the one Reembed was first called on.
+		resumeOnOld bool
+		deleteOld   bool
+	}{
+		{
+			name:    "copying",
+			crashAt: func(s chroma.ReembedState) bool { return s.Phase == chroma.ReembedCopying && s.Offset == 2 },
+			stored:  true,
+		},
+		{
+			name:    "verified",
+			crashAt: func(s chroma.ReembedState) bool { return s.Phase == chroma.ReembedVerified },
+			stored:  true,
+		},
+		{
+			name:        "source renamed",
+			crashAt:     func(s chroma.ReembedState) bool { return s.Phase == chroma.ReembedSourceRenamed },
+			stored:      true,
+			resumeOnOld: true,
+		},
+		{
+			name:        "shadow renamed",
+			crashAt:     func(s chroma.ReembedState) bool { return s.Phase == chroma.ReembedDone },
+			stored:      false,
+			resumeOnOld: true,
+		},
+		{
+			name:        "done",
+			crashAt:     func(s chroma.ReembedState) bool { return s.Phase == chroma.ReembedDone },
+			stored:      true,
+			resumeOnOld: true,
+			deleteOld:   true,
+		},
+		{
+			name:      "old deleted",
+			crashAt:   func(s chroma.ReembedState) bool { return s.OldDeleted },
+			stored:    false,
+			deleteOld: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			client, source := reembedSource(t)
+			checkpoint := &crashingCheckpoint{state: nil, crashAt: tt.crashAt, stored: tt.stored}
+			opts := []chroma.ReembedOpts{chroma.WithOldName("docs-old"), chroma.WithReembedPageSize(2), chroma.WithCheckpoint(checkpoint)}
+			if tt.deleteOld {
+				opts = append(opts, chroma.WithDeleteOld())
+			}
+
+			if _, err := source.Reembed(ctx, squaredLengthEmbeddings{}, opts...); !errors.Is(err, errCrash) {
+				t.Fatalf("got error %v, want the crash", err)
+			}
+
+			resumeOn := source
+			if tt.resumeOnOld {
+				var err error
+				if resumeOn, err = client.GetCollection(ctx, checkpoint.state.OldName); err != nil {
+					t.Fatalf("getting the original collection by its old name: %v", err)
+				}
+			}
+			got, err := resumeOn.Reembed(ctx, squaredLengthEmbeddings{}, opts...)
+			if err != nil {
+				t.Fatalf("resuming: %v", err)
+			}
+
+			checkReembedded(t, client, source, got)
+			want := []string{"docs", "docs-old"}
+			if tt.deleteOld {
+				want = []string{"docs"}
+			}
+			if got := collectionNames(t, client); !reflect.DeepEqual(got, want) {
+				t.Errorf("got collections %v, want %v", got, want)
+			}
+			if checkpoint.state.Phase != chroma.ReembedDone || checkpoint.state.OldDeleted != tt.deleteOld {
+				t.Errorf("got checkpoint %+v, want it done", *checkpoint.state)
+			}
+		})
+	}
+}
+
+func TestReembedResumeLookupFails(t *testing.T) {
+	ctx := context.Background()
+	failLookups := false
+	client, source := reembedSource(t, chroma.WithMiddleware(func(next chroma.Doer) chroma.Doer {
+		return chroma.DoerFunc(func(req *http.Request) (*http.Response, error) {
+			failing := strings.HasSuffix(req.URL.Path, "/collections") || strings.HasSuffix(req.URL.Path, "/collections/docs")
+			if failLookups && failing && req.Method == http.MethodGet {
+				return &http.Response{StatusCode: http.StatusInternalServerError, Body: io.NopCloser(strings.NewReader("{}")), Request: req}, nil
+			}
+			return next.Do(req)
+		})
+	}))
+
+	checkpoint := &crashingCheckpoint{
+		state:   nil,
+		crashAt: func(s chroma.ReembedState) bool { return s.Phase == chroma.ReembedDone },
+		stored:  false,
+	}
+	opts := []chroma.ReembedOpts{chroma.WithOldName("docs-old"), chroma.WithCheckpoint(checkpoint)}
+	if _, err := source.Reembed(ctx, squaredLengthEmbeddings{}, opts...); !errors.Is(err, errCrash) {
+		t.Fatalf("got error %v, want the crash", err)
+	}
+	old, err := client.GetCollection(ctx, "docs-old")
+	if err != nil {
+		t.Fatalf("getting the original collection: %v", err)
+	}
+
+	failLookups = true
+	if _, err := old.Reembed(ctx, squaredLengthEmbeddings{}, opts...); err == nil {
+		t.Fatal("got no error resuming while looking up collections fails")
+	}
+	failLookups = false
+
+	// Failing to find the renamed shadow collection mustn't create a new one.
+	if got, want := collectionNames(t, client), []string{"docs", "docs-old"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got collections %v, want %v", got, want)
+	}
+	got, err := old.Reembed(ctx, squaredLengthEmbeddings{}, opts...)
+	if err != nil {
+		t.Fatalf("resuming: %v", err)
+	}
+	checkReembedded(t, client, source, got)
+}
@@ -0,0 +1,381 @@
+package chroma
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// SnapshotFormat is the encoding of a collection snapshot.
+type SnapshotFormat string
+
+const (
+	// SnapshotJSONL writes a header line followed by one JSON line per record
+	// and a trailer line with the number of records.
+	SnapshotJSONL SnapshotFormat = "jsonl"
+	// SnapshotBinary writes a compact binary format with float32 embeddings.
+	SnapshotBinary SnapshotFormat = "binary"
+)
+
+const snapshotVersion = 1
+
+// snapshotMagic starts every binary snapshot.
+var snapshotMagic = [8]byte{'C', 'H', 'R', 'G', 'S', 'N', 'A', 'P'}
+
+// SnapshotHeader describes the collection a snapshot was taken of.
+type SnapshotHeader struct {
+	Version    int       `json:"version"`
+	Name       string    `json:"name"`
+	Metadata   Metadata  `json:"metadata,omitempty"`
+	ExportedAt time.Time `json:"exported_at"`
+}
+
+// SnapshotRecord is a single embedding in a snapshot.
+type SnapshotRecord struct {
+	ID        ID        `json:"id"`
+	Embedding Embedding `json:"embedding"`
+	Document  Document  `json:"document,omitempty"`
+	Metadata  Metadata  `json:"metadata,omitempty"`
+}
+
+// SnapshotWriter encodes a snapshot record by record.
+type SnapshotWriter interface {
+	WriteRecord(record SnapshotRecord) error
+	// Close finishes the snapshot, without closing the underlying writer.
+	Close() error
+}
+
+// SnapshotReader decodes a snapshot record by record.
+type SnapshotReader interface {
+	Header() SnapshotHeader
+	// Next returns the next record, or io.EOF after the last one.
+	Next() (SnapshotRecord, error)
+}
+
+// NewSnapshotWriter writes header to w and returns a writer for the records.
+func NewSnapshotWriter(w io.Writer, format SnapshotFormat, header SnapshotHeader) (SnapshotWriter, error) {
+	header.Version = snapshotVersion
+	switch format {
+	case SnapshotJSONL:
+		return newJSONLSnapshotWriter(w, header)
+	case SnapshotBinary:
+		return newBinarySnapshotWriter(w, header)
+	default:
+		return nil, fmt.Errorf("%w: unknown snapshot format %q", ErrInvalidInput, format)
+	}
+}
+
+// NewSnapshotReader reads a snapshot's header from r, detecting its format.
+func NewSnapshotReader(r io.Reader) (SnapshotReader, error) {
+	br := bufio.NewReader(r)
+	peek, err := br.Peek(len(snapshotMagic))
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("reading snapshot: %w", err)
+	}
+	if bytes.Equal(peek, snapshotMagic[:]) {
+		return newBinarySnapshotReader(br)
+	}
+	return newJSONLSnapshotReader(br)
+}
+
+// jsonlLine is a record line or, with End set, the trailer ending a JSONL
+// snapshot, so truncated snapshots can be told apart from complete ones.
+type jsonlLine struct {
+	SnapshotRecord
+	End *jsonlTrailer `json:"end,omitempty"`
+}
+
+type jsonlTrailer struct {
+	Records uint64 `json:"records"`
+}
+
+type jsonlSnapshotWriter struct {
+	enc   *json.Encoder
+	count uint64
+}
+
+func newJSONLSnapshotWriter(w io.Writer, header SnapshotHeader) (*jsonlSnapshotWriter, error) {
+	enc := json.NewEncoder(w)
+	if err := enc.Encode(header); err != nil {
+		return nil, fmt.Errorf("writing header: %w", err)
+	}
+	return &jsonlSnapshotWriter{enc: enc, count: 0}, nil
+}
+
+func (s *jsonlSnapshotWriter) WriteRecord(record SnapshotRecord) error {
+	if err := s.enc.Encode(record); err != nil {
+		return fmt.Errorf("writing record %q: %w", record.ID, err)
+	}
+	s.count++
+	return nil
+}
+
+func (s *jsonlSnapshotWriter) Close() error {
+	if err := s.enc.Encode(map[string]jsonlTrailer{"end": {Records: s.count}}); err != nil {
+		return fmt.Errorf("writing trailer: %w", err)
+	}
+	return nil
+}
+
+type jsonlSnapshotReader struct {
+	dec    *json.Decoder
+	header SnapshotHeader
+	count  uint64
+	done   bool
+}
+
+func newJSONLSnapshotReader(r io.Reader) (*jsonlSnapshotReader, error) {
+	dec := json.NewDecoder(r)
+	var header SnapshotHeader
+	if err := dec.Decode(&header); err != nil {
+		return nil, fmt.Errorf("reading header: %w", err)
+	}
+	if header.Version != snapshotVersion {
+		return nil, fmt.Errorf("unsupported snapshot version %d, want %d", header.Version, snapshotVersion)
+	}
+	return &jsonlSnapshotReader{dec: dec, header: header, count: 0, done: false}, nil
+}
+
+func (s *jsonlSnapshotReader) Header() SnapshotHeader {
+	return s.header
+}
+
+func (s *jsonlSnapshotReader) Next() (SnapshotRecord, error) {
+	if s.done {
+		return SnapshotRecord{}, io.EOF
+	}
+
+	var line jsonlLine
+	if err := s.dec.Decode(&line); err != nil {
+		return SnapshotRecord{}, fmt.Errorf("reading record: %w", unexpectedEOF(err))
+	}
+	if line.End == nil {
+		s.count++
+		return line.SnapshotRecord, nil
+	}
+
+	if line.End.Records != s.count {
+		return SnapshotRecord{}, fmt.Errorf("snapshot has %d records, want %d", s.count, line.End.Records)
+	}
+	if s.dec.More() {
+		return SnapshotRecord{}, errors.New("reading snapshot: data after the trailer")
+	}
+	s.done = true
+	return SnapshotRecord{}, io.EOF
+}
+
+// The binary format is the magic bytes, a uint32 version and a length
+// prefixed JSON header, followed by records. Each record is a marker byte,
+// a length prefixed ID, a uvarint dimension and that many little endian
+// float32 values, and a length prefixed document and JSON metadata. The
+// snapshot ends with an end marker and the uint64 number of records, so
+// truncated snapshots can be told apart from complete ones.
+const (
+	binaryRecordMarker byte = 1
+	binaryEndMarker    byte = 0
+)
+
+// Limits on lengths in binary snapshots, so a corrupt snapshot fails to read
+// instead of allocating whatever its lengths claim.
+const (
+	// maxSnapshotFieldSize is the size limit of the header, an ID, a
+	// document or metadata, in bytes.
+	maxSnapshotFieldSize = 64 << 20
+	maxSnapshotDimension = 1 << 16
+)
+
+type binarySnapshotWriter struct {
+	w     *bufio.Writer
+	count uint64
+	buf   []byte
+}
+
+func newBinarySnapshotWriter(w io.Writer, header SnapshotHeader) (*binarySnapshotWriter, error) {
+	s := &binarySnapshotWriter{w: bufio.NewWriter(w), count: 0, buf: make([]byte, 0, 1024)}
+
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		return nil, fmt.Errorf("encoding header: %w", err)
+	}
+	s.buf = append(s.buf, snapshotMagic[:]...)
+	s.buf = binary.LittleEndian.AppendUint32(s.buf, snapshotVersion)
+	s.buf = appendBytes(s.buf, headerJSON)
+	if _, err := s.w.Write(s.buf); err != nil {
+		return nil, fmt.Errorf("writing header: %w", err)
+	}
+	return s, nil
+}
+
+func (s *binarySnapshotWriter) WriteRecord(record SnapshotRecord) error {
+	var metaJSON []byte
+	if len(record.Metadata) > 0 {
+		var err error
+		if metaJSON, err = json.Marshal(record.Metadata); err != nil {
+			return fmt.Errorf("encoding metadata of %q: %w", record.ID, err)
+		}
+	}
+	if len(record.Embedding) > maxSnapshotDimension {
+		return fmt.Errorf("%w: embedding of %q has dimension %d, limit %d", ErrInvalidInput, record.ID, len(record.Embedding), maxSnapshotDimension)
+	}
+	for _, size := range []int{len(record.ID), len(record.Document), len(metaJSON)} {
+		if size > maxSnapshotFieldSize {
+			return fmt.Errorf("%w: record %q has a field of %d bytes, limit %d", ErrInvalidInput, record.ID, size, maxSnapshotFieldSize)
+		}
+	}
+
+	s.buf = append(s.buf[:0], binaryRecordMarker)
+	s.buf = appendBytes(s.buf, []byte(record.ID))
+	s.buf = binary.AppendUvarint(s.buf, uint64(len(record.Embedding)))
+	for _, v := range record.Embedding {
+		s.buf = binary.LittleEndian.AppendUint32(s.buf, math.Float32bits(float32(v)))
+	}
+	s.buf = appendBytes(s.buf, []byte(record.Document))
+	s.buf = appendBytes(s.buf, metaJSON)
+
+	if _, err := s.w.Write(s.buf); err != nil {
+		return fmt.Errorf("writing record %q: %w", record.ID, err)
+	}
+	s.count++
+	return nil
+}
+
+func (s *binarySnapshotWriter) Close() error {
+	s.buf = append(s.buf[:0], binaryEndMarker)
+	s.buf = binary.LittleEndian.AppendUint64(s.buf, s.count)
+	if _, err := s.w.Write(s.buf); err != nil {
+		return fmt.Errorf("writing end marker: %w", err)
+	}
+	return s.w.Flush()
+}
+
+func appendBytes(buf []byte, b []byte) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(b)))
+	return append(buf, b...)
+}
+
+type binarySnapshotReader struct {
+	r      *bufio.Reader
+	header SnapshotHeader
+	count  uint64
+	done   bool
+}
+
+func newBinarySnapshotReader(r *bufio.Reader) (*binarySnapshotReader, error) {
+	var magic [8]byte
+	if _, err := io.ReadFull(r, magic[:]); err != nil {
+		return nil, fmt.Errorf("reading magic: %w", err)
+	}
+	var version uint32
+	if err := binary.Read(r, binary.LittleEndian, &version); err != nil {
+		return nil, fmt.Errorf("reading version: %w", err)
+	}
+	if version != snapshotVersion {
+		return nil, fmt.Errorf("unsupported snapshot version %d, want %d", version, snapshotVersion)
+	}
+
+	headerJSON, err := readBytes(r)
+	if err != nil {
+		return nil, fmt.Errorf("reading header: %w", err)
+	}
+	var header SnapshotHeader
+	if err := json.Unmarshal(headerJSON, &header); err != nil {
+		return nil, fmt.Errorf("decoding header: %w", err)
+	}
+	return &binarySnapshotReader{r: r, header: header, count: 0, done: false}, nil
+}
+
+func (s *binarySnapshotReader) Header() SnapshotHeader {
+	return s.header
+}
+
+func (s *binarySnapshotReader) Next() (SnapshotRecord, error) {
+	if s.done {
+		return SnapshotRecord{}, io.EOF
+	}
+
+	marker, err := s.r.ReadByte()
+	if err != nil {
+		return SnapshotRecord{}, fmt.Errorf("reading record marker: %w", unexpectedEOF(err))
+	}
+	if marker == binaryEndMarker {
+		var count uint64
+		if err := binary.Read(s.r, binary.LittleEndian, &count); err != nil {
+			return SnapshotRecord{}, fmt.Errorf("reading record count: %w", unexpectedEOF(err))
+		}
+		if count != s.count {
+			return SnapshotRecord{}, fmt.Errorf("snapshot has %d records, want %d", s.count, count)
+		}
+		s.done = true
+		return SnapshotRecord{}, io.EOF
+	}
+	if marker != binaryRecordMarker {
+		return SnapshotRecord{}, fmt.Errorf("invalid record marker %d", marker)
+	}
+
+	id, err := readBytes(s.r)
+	if err != nil {
+		return SnapshotRecord{}, fmt.Errorf("reading id: %w", err)
+	}
+	dim, err := binary.ReadUvarint(s.r)
+	if err != nil {
+		return SnapshotRecord{}, fmt.Errorf("reading dimension: %w", unexpectedEOF(err))
+	}
+	if dim > maxSnapshotDimension {
+		return SnapshotRecord{}, fmt.Errorf("reading embedding: dimension %d exceeds limit %d", dim, maxSnapshotDimension)
+	}
+	raw := make([]byte, 4*dim)
+	if _, err := io.ReadFull(s.r, raw); err != nil {
+		return SnapshotRecord{}, fmt.Errorf("reading embedding: %w", unexpectedEOF(err))
+	}
+	embedding := make(Embedding, dim)
+	for i := range embedding {
+		embedding[i] = float64(math.Float32frombits(binary.LittleEndian.Uint32(raw[4*i:])))
+	}
+	doc, err := readBytes(s.r)
+	if err != nil {
+		return SnapshotRecord{}, fmt.Errorf("reading document: %w", err)
+	}
+	metaJSON, err := readBytes(s.r)
+	if err != nil {
+		return SnapshotRecord{}, fmt.Errorf("reading metadata: %w", err)
+	}
+	var meta Metadata
+	if len(metaJSON) > 0 {
+		if err := json.Unmarshal(metaJSON, &meta); err != nil {
+			return SnapshotRecord{}, fmt.Errorf("decoding metadata: %w", err)
+		}
+	}
+
+	s.count++
+	return SnapshotRecord{ID: string(id), Embedding: embedding, Document: string(doc), Metadata: meta}, nil
+}
+
+func readBytes(r *bufio.Reader) ([]byte, error) {
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	if n > maxSnapshotFieldSize {
+		return nil, fmt.Errorf("length %d exceeds limit %d", n, maxSnapshotFieldSize)
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	return b, nil
+}
+
+// unexpectedEOF turns io.EOF into io.ErrUnexpectedEOF, since a snapshot
+// only ends after its end marker or trailer.
+func unexpectedEOF(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
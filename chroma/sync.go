@@ -0,0 +1,294 @@
+package chroma
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+)
+
+// MetadataContentHash is the metadata key holding the hash of a document's
+// content, used by Sync to tell whether a document has changed.
+const MetadataContentHash = "content_hash"
+
+// SyncPlan is the difference between a source corpus and a collection.
+type SyncPlan struct {
+	// Adds are documents missing from the collection.
+	Adds []SourceDoc
+	// Updates are documents whose content changed and must be embedded again.
+	Updates []SourceDoc
+	// MetadataUpdates are documents where only the metadata changed.
+	MetadataUpdates []SourceDoc
+	// Deletes are IDs in the collection which are missing from the source.
+	Deletes []ID
+	// Unchanged is the number of documents which are already up to date.
+	Unchanged int
+}
+
+// Empty reports whether there is nothing to do.
+func (p *SyncPlan) Empty() bool {
+	return len(p.Adds) == 0 && len(p.Updates) == 0 && len(p.MetadataUpdates) == 0 && len(p.Deletes) == 0
+}
+
+// Print writes a human readable summary of the plan to w.
+func (p *SyncPlan) Print(w io.Writer) error {
+	_, err := fmt.Fprintf(w, "sync plan: %d to add, %d to update, %d metadata only, %d to delete, %d unchanged\n",
+		len(p.Adds), len(p.Updates), len(p.MetadataUpdates), len(p.Deletes), p.Unchanged)
+	if err != nil {
+		return err
+	}
+
+	lines := make([]string, 0, len(p.Adds)+len(p.Updates)+len(p.MetadataUpdates)+len(p.Deletes))
+	for _, d := range p.Adds {
+		lines = append(lines, "+ "+d.ID)
+	}
+	for _, d := range p.Updates {
+		lines = append(lines, "~ "+d.ID)
+	}
+	for _, d := range p.MetadataUpdates {
+		lines = append(lines, "m "+d.ID)
+	}
+	for _, id := range p.Deletes {
+		lines = append(lines, "- "+id)
+	}
+	for _, l := range lines {
+		if _, err := fmt.Fprintln(w, l); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type syncOpts struct {
+	scope     Where
+	pageSize  int
+	batchSize int
+	dryRun    io.Writer
+}
+
+type SyncOpts func(*syncOpts)
+
+// WithSyncScope limits the sync to documents in the collection matching where,
+// so documents from other sources aren't deleted.
+func WithSyncScope(where Where) SyncOpts {
+	return func(o *syncOpts) {
+		o.scope = where
+	}
+}
+
+// WithSyncPageSize sets how many documents are read from the collection at a time.
+func WithSyncPageSize(pageSize int) SyncOpts {
+	return func(o *syncOpts) {
+		o.pageSize = pageSize
+	}
+}
+
+// WithSyncBatchSize sets how many documents are written to the collection at a time.
+func WithSyncBatchSize(batchSize int) SyncOpts {
+	return func(o *syncOpts) {
+		o.batchSize = batchSize
+	}
+}
+
+// WithDryRun makes Sync print the plan to w instead of applying it.
+func WithDryRun(w io.Writer) SyncOpts {
+	return func(o *syncOpts) {
+		o.dryRun = w
+	}
+}
+
+func syncOptsOf(opts []SyncOpts) *syncOpts {
+	sOpts := &syncOpts{scope: nil, pageSize: 1000, batchSize: 100, dryRun: nil}
+	for _, opt := range opts {
+		opt(sOpts)
+	}
+	return sOpts
+}
+
+// SourceContentHash returns the content hash of doc, taken from its
+// MetadataContentHash if set, such as by the loaders package, or else
+// computed as the hex encoded SHA-256 of its text.
+func SourceContentHash(doc SourceDoc) string {
+	if h, ok := doc.Metadata[MetadataContentHash].(string); ok && h != "" {
+		return h
+	}
+	sum := sha256.Sum256([]byte(doc.Text))
+	return hex.EncodeToString(sum[:])
+}
+
+// Sync makes the collection mirror records, only writing what changed so
+// that unchanged documents aren't embedded again. Documents in the collection
+// but not in records are deleted, use WithSyncScope to limit which.
+// With WithDryRun, the plan is printed but not applied.
+func (c *Collection) Sync(ctx context.Context, records []SourceDoc, opts ...SyncOpts) (*SyncPlan, error) {
+	plan, err := c.PlanSync(ctx, records, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	if w := syncOptsOf(opts).dryRun; w != nil {
+		if err := plan.Print(w); err != nil {
+			return nil, fmt.Errorf("printing plan: %w", err)
+		}
+		return plan, nil
+	}
+
+	if err := c.ApplySync(ctx, plan, opts...); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// PlanSync compares records with the collection, reading its metadata page by page.
+func (c *Collection) PlanSync(ctx context.Context, records []SourceDoc, opts ...SyncOpts) (_ *SyncPlan, err error) {
+	ctx, op := c.client.telemetry.start(ctx, "Collection.PlanSync", AttributeCollectionName.String(c.Name), AttributeBatchSize.Int(len(records)))
+	defer func() { op.end(err) }()
+
+	sOpts := syncOptsOf(opts)
+
+	source := make(map[ID]SourceDoc, len(records))
+	for i, r := range records {
+		if r.ID == "" {
+			return nil, fmt.Errorf("%w: record %d has no ID", ErrInvalidInput, i)
+		}
+		if _, ok := source[r.ID]; ok {
+			return nil, fmt.Errorf("%w: duplicate record ID %q", ErrInvalidInput, r.ID)
+		}
+		source[r.ID] = withContentHash(r)
+	}
+
+	plan := &SyncPlan{}
+	seen := make(map[ID]struct{}, len(records))
+
+	getOpts := []QueryOpts{WithInclude(IncludeMetadatas)}
+	if len(sOpts.scope) > 0 {
+		getOpts = append(getOpts, WithWhere(sOpts.scope))
+	}
+	err = c.GetPages(ctx, sOpts.pageSize, func(page *EmbeddingResponse) error {
+		for i, id := range page.IDs {
+			record, ok := source[id]
+			if !ok {
+				plan.Deletes = append(plan.Deletes, id)
+				continue
+			}
+			seen[id] = struct{}{}
+
+			var stored Metadata
+			if i < len(page.Metadatas) {
+				stored = page.Metadatas[i]
+			}
+			switch {
+			case stored[MetadataContentHash] != record.Metadata[MetadataContentHash]:
+				plan.Updates = append(plan.Updates, record)
+			case !sameMetadata(stored, record.Metadata):
+				plan.MetadataUpdates = append(plan.MetadataUpdates, record)
+			default:
+				plan.Unchanged++
+			}
+		}
+		return nil
+	}, getOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("reading collection: %w", err)
+	}
+
+	for _, r := range records {
+		if _, ok := seen[r.ID]; !ok {
+			plan.Adds = append(plan.Adds, source[r.ID])
+		}
+	}
+	sort.Strings(plan.Deletes)
+
+	return plan, nil
+}
+
+// ApplySync applies a plan from PlanSync.
+func (c *Collection) ApplySync(ctx context.Context, plan *SyncPlan, opts ...SyncOpts) (err error) {
+	ctx, op := c.client.telemetry.start(ctx, "Collection.ApplySync", AttributeCollectionName.String(c.Name))
+	defer func() { op.end(err) }()
+
+	sOpts := syncOptsOf(opts)
+	if sOpts.batchSize <= 0 {
+		return fmt.Errorf("%w: batch size must be positive, got %d", ErrInvalidInput, sOpts.batchSize)
+	}
+
+	upserts := append(append([]SourceDoc{}, plan.Adds...), plan.Updates...)
+	for start := 0; start < len(upserts); start += sOpts.batchSize {
+		batch := upserts[start:minInt(start+sOpts.batchSize, len(upserts))]
+		ids, docs, metas := splitSourceDocs(batch)
+		if _, err := c.Upsert(ctx, ids, nil, metas, docs); err != nil {
+			return fmt.Errorf("upserting: %w", err)
+		}
+	}
+
+	for start := 0; start < len(plan.MetadataUpdates); start += sOpts.batchSize {
+		batch := plan.MetadataUpdates[start:minInt(start+sOpts.batchSize, len(plan.MetadataUpdates))]
+		ids, _, metas := splitSourceDocs(batch)
+		if _, err := c.Update(ctx, ids, nil, metas, nil); err != nil {
+			return fmt.Errorf("updating metadata: %w", err)
+		}
+	}
+
+	for start := 0; start < len(plan.Deletes); start += sOpts.batchSize {
+		batch := plan.Deletes[start:minInt(start+sOpts.batchSize, len(plan.Deletes))]
+		if _, err := c.Delete(ctx, batch, nil, nil); err != nil {
+			return fmt.Errorf("deleting: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// withContentHash returns a copy of doc with its content hash in the metadata.
+func withContentHash(doc SourceDoc) SourceDoc {
+	meta := make(Metadata, len(doc.Metadata)+1)
+	for k, v := range doc.Metadata {
+		meta[k] = v
+	}
+	meta[MetadataContentHash] = SourceContentHash(doc)
+	doc.Metadata = meta
+	return doc
+}
+
+// sameMetadata compares metadata as it would be stored, so that for example
+// an int in the source equals the float64 decoded from the server.
+func sameMetadata(a, b Metadata) bool {
+	normalize := func(m Metadata) interface{} {
+		var out interface{}
+		raw, err := json.Marshal(m)
+		if err != nil {
+			return nil
+		}
+		if err := json.Unmarshal(raw, &out); err != nil {
+			return nil
+		}
+		if obj, ok := out.(map[string]interface{}); ok && len(obj) == 0 {
+			return nil
+		}
+		return out
+	}
+	return reflect.DeepEqual(normalize(a), normalize(b))
+}
+
+func splitSourceDocs(docs []SourceDoc) ([]ID, []Document, []Metadata) {
+	ids := make([]ID, 0, len(docs))
+	texts := make([]Document, 0, len(docs))
+	metas := make([]Metadata, 0, len(docs))
+	for _, d := range docs {
+		ids = append(ids, d.ID)
+		texts = append(texts, d.Text)
+		metas = append(metas, d.Metadata)
+	}
+	return ids, texts, metas
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
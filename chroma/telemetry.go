@@ -0,0 +1,174 @@
+package chroma
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/kristofferostlund/chroma-go/chroma"
+
+// Attribute keys set on spans. Embedding generators may set
+// AttributeCacheHitRatio on the span found in the context they're given.
+const (
+	AttributeOperation        = attribute.Key("chroma.operation")
+	AttributeCollectionName   = attribute.Key("chroma.collection.name")
+	AttributeBatchSize        = attribute.Key("chroma.batch_size")
+	AttributeResultCount      = attribute.Key("chroma.result_count")
+	AttributeEmbeddingModel   = attribute.Key("chroma.embedding.model")
+	AttributeEmbeddingPurpose = attribute.Key("chroma.embedding.purpose")
+	AttributeCacheHitRatio    = attribute.Key("chroma.embedding.cache_hit_ratio")
+	AttributeHTTPStatusCode   = attribute.Key("http.response.status_code")
+)
+
+// WithTracerProvider records a span for every client and collection
+// operation and every embedding generation. Tracing is disabled by default.
+func WithTracerProvider(tp trace.TracerProvider) ClientOpts {
+	return func(c *clientOpts) {
+		c.tracerProvider = tp
+	}
+}
+
+// WithMeterProvider records latency histograms and error counters for every
+// client and collection operation and every embedding generation.
+// Metrics are disabled by default.
+func WithMeterProvider(mp metric.MeterProvider) ClientOpts {
+	return func(c *clientOpts) {
+		c.meterProvider = mp
+	}
+}
+
+type telemetry struct {
+	logger       *slog.Logger
+	tracer       trace.Tracer
+	opDuration   metric.Float64Histogram
+	opErrors     metric.Int64Counter
+	embDuration  metric.Float64Histogram
+	embErrors    metric.Int64Counter
+	embDocuments metric.Int64Counter
+}
+
+func newTelemetry(tp trace.TracerProvider, mp metric.MeterProvider, logger *slog.Logger) (*telemetry, error) {
+	if tp == nil {
+		tp = trace.NewNoopTracerProvider()
+	}
+	if mp == nil {
+		mp = noop.NewMeterProvider()
+	}
+
+	meter := mp.Meter(instrumentationName)
+	t := &telemetry{logger: logger, tracer: tp.Tracer(instrumentationName)}
+	var err error
+	if t.opDuration, err = meter.Float64Histogram("chroma.client.operation.duration",
+		metric.WithUnit("s"),
+		metric.WithDescription("Duration of chroma client and collection operations."),
+	); err != nil {
+		return nil, err
+	}
+	if t.opErrors, err = meter.Int64Counter("chroma.client.operation.errors",
+		metric.WithDescription("Number of failed chroma client and collection operations."),
+	); err != nil {
+		return nil, err
+	}
+	if t.embDuration, err = meter.Float64Histogram("chroma.embedding.duration",
+		metric.WithUnit("s"),
+		metric.WithDescription("Duration of embedding generation."),
+	); err != nil {
+		return nil, err
+	}
+	if t.embErrors, err = meter.Int64Counter("chroma.embedding.errors",
+		metric.WithDescription("Number of failed embedding generations."),
+	); err != nil {
+		return nil, err
+	}
+	if t.embDocuments, err = meter.Int64Counter("chroma.embedding.documents",
+		metric.WithDescription("Number of documents embedded."),
+	); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// operation is an instrumented operation, started with telemetry.start and
+// finished with end.
+type operation struct {
+	ctx        context.Context
+	span       trace.Span
+	start      time.Time
+	attrs      attribute.Set
+	duration   metric.Float64Histogram
+	errCounter metric.Int64Counter
+}
+
+func (t *telemetry) start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, *operation) {
+	return t.startWith(ctx, name, t.opDuration, t.opErrors, attrs...)
+}
+
+func (t *telemetry) startWith(ctx context.Context, name string, duration metric.Float64Histogram, errCounter metric.Int64Counter, attrs ...attribute.KeyValue) (context.Context, *operation) {
+	if _, ok := ctx.Value(loggerKey{}).(*slog.Logger); !ok && t.logger != nil {
+		ctx = ContextWithLogger(ctx, t.logger)
+	}
+	attrs = append(attrs, AttributeOperation.String(name))
+	ctx, span := t.tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+	// Metrics only get the operation, the other attributes have unbounded cardinality.
+	return ctx, &operation{
+		ctx:        ctx,
+		span:       span,
+		start:      time.Now(),
+		attrs:      attribute.NewSet(AttributeOperation.String(name)),
+		duration:   duration,
+		errCounter: errCounter,
+	}
+}
+
+func (o *operation) setResultCount(n int) {
+	o.span.SetAttributes(AttributeResultCount.Int(n))
+}
+
+func (o *operation) end(err error) {
+	o.duration.Record(o.ctx, time.Since(o.start).Seconds(), metric.WithAttributeSet(o.attrs))
+	if err != nil {
+		o.errCounter.Add(o.ctx, 1, metric.WithAttributeSet(o.attrs))
+		o.span.RecordError(err)
+		o.span.SetStatus(codes.Error, err.Error())
+	}
+	o.span.End()
+}
+
+// generateEmbeddings is GenerateEmbeddings wrapped in a span.
+func (t *telemetry) generateEmbeddings(ctx context.Context, gen EmbeddingGenerator, purpose EmbeddingPurpose, documents []Document) (_ []Embedding, err error) {
+	ctx, op := t.startWith(ctx, "EmbeddingGenerator.Generate", t.embDuration, t.embErrors,
+		AttributeEmbeddingModel.String(EmbeddingModel(gen)),
+		AttributeEmbeddingPurpose.String(string(purpose)),
+		AttributeBatchSize.Int(len(documents)),
+	)
+	defer func() { op.end(err) }()
+
+	t.embDocuments.Add(ctx, int64(len(documents)), metric.WithAttributeSet(op.attrs))
+	embeddings, err := GenerateEmbeddings(ctx, gen, purpose, documents)
+	if err != nil {
+		return nil, err
+	}
+	op.setResultCount(len(embeddings))
+	return embeddings, nil
+}
+
+// statusRecorder sets the HTTP status of responses on the operation's span.
+type statusRecorder struct {
+	next Doer
+}
+
+func (s statusRecorder) Do(req *http.Request) (*http.Response, error) {
+	res, err := s.next.Do(req)
+	if res != nil {
+		trace.SpanFromContext(req.Context()).SetAttributes(AttributeHTTPStatusCode.Int(res.StatusCode))
+	}
+	return res, err
+}
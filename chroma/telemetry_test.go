@@ -0,0 +1,235 @@
+package chroma_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/kristofferostlund/chroma-go/chroma"
+	"github.com/kristofferostlund/chroma-go/chroma/embeddings/cached"
+	"github.com/kristofferostlund/chroma-go/chroma/memstore"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+type lengthEmbeddings struct{}
+
+func (lengthEmbeddings) Generate(ctx context.Context, documents []chroma.Document) ([]chroma.Embedding, error) {
+	embeddings := make([]chroma.Embedding, 0, len(documents))
+	for _, d := range documents {
+		embeddings = append(embeddings, chroma.Embedding{float64(len(d)), 1})
+	}
+	return embeddings, nil
+}
+
+// instrumentedClient returns a memstore client recording spans and metrics.
+func instrumentedClient(t *testing.T) (*chroma.Client, *tracetest.SpanRecorder, *sdkmetric.ManualReader) {
+	t.Helper()
+	store, err := memstore.New()
+	if err != nil {
+		t.Fatalf("creating store: %v", err)
+	}
+	spans := tracetest.NewSpanRecorder()
+	reader := sdkmetric.NewManualReader()
+	client := memstore.NewClient(store,
+		chroma.WithTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans))),
+		chroma.WithMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))),
+	)
+	return client, spans, reader
+}
+
+func endedSpans(spans *tracetest.SpanRecorder, name string) []sdktrace.ReadOnlySpan {
+	found := make([]sdktrace.ReadOnlySpan, 0)
+	for _, s := range spans.Ended() {
+		if s.Name() == name {
+			found = append(found, s)
+		}
+	}
+	return found
+}
+
+func spanAttribute(span sdktrace.ReadOnlySpan, key attribute.Key) (attribute.Value, bool) {
+	for _, kv := range span.Attributes() {
+		if kv.Key == key {
+			return kv.Value, true
+		}
+	}
+	return attribute.Value{}, false
+}
+
+func collectMetric(t *testing.T, reader *sdkmetric.ManualReader, name string) (metricdata.Metrics, bool) {
+	t.Helper()
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("collecting metrics: %v", err)
+	}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name == name {
+				return m, true
+			}
+		}
+	}
+	return metricdata.Metrics{}, false
+}
+
+func TestTelemetrySpans(t *testing.T) {
+	ctx := context.Background()
+	client, spans, _ := instrumentedClient(t)
+
+	coll, err := client.CreateCollection(ctx, "docs", chroma.WithEmbeddingFunc(lengthEmbeddings{}))
+	if err != nil {
+		t.Fatalf("creating collection: %v", err)
+	}
+	if _, err := coll.Add(ctx, []chroma.ID{"a", "b", "c"}, nil, nil, []chroma.Document{"x", "yy", "zzz"}); err != nil {
+		t.Fatalf("adding: %v", err)
+	}
+	if _, err := coll.Query(ctx, []chroma.Document{"yy"}, 2); err != nil {
+		t.Fatalf("querying: %v", err)
+	}
+
+	adds := endedSpans(spans, "Collection.Add")
+	if len(adds) != 1 {
+		t.Fatalf("got %d Collection.Add spans, want 1", len(adds))
+	}
+	wantAdd := map[attribute.Key]attribute.Value{
+		chroma.AttributeOperation:      attribute.StringValue("Collection.Add"),
+		chroma.AttributeCollectionName: attribute.StringValue("docs"),
+		chroma.AttributeBatchSize:      attribute.IntValue(3),
+		chroma.AttributeHTTPStatusCode: attribute.IntValue(200),
+	}
+	for key, want := range wantAdd {
+		if got, ok := spanAttribute(adds[0], key); !ok || got != want {
+			t.Errorf("got Collection.Add attribute %s = %v, want %v", key, got.Emit(), want.Emit())
+		}
+	}
+
+	queries := endedSpans(spans, "Collection.QueryEmbeddings")
+	if len(queries) != 1 {
+		t.Fatalf("got %d Collection.QueryEmbeddings spans, want 1", len(queries))
+	}
+	if got, ok := spanAttribute(queries[0], chroma.AttributeResultCount); !ok || got.AsInt64() != 2 {
+		t.Errorf("got result count %v, want 2", got.Emit())
+	}
+
+	generations := endedSpans(spans, "EmbeddingGenerator.Generate")
+	if len(generations) != 2 {
+		t.Fatalf("got %d EmbeddingGenerator.Generate spans, want 2", len(generations))
+	}
+	for i, want := range []chroma.EmbeddingPurpose{chroma.EmbeddingPurposeDocument, chroma.EmbeddingPurposeQuery} {
+		if got, _ := spanAttribute(generations[i], chroma.AttributeEmbeddingPurpose); got.AsString() != string(want) {
+			t.Errorf("got purpose %q for generation %d, want %q", got.AsString(), i, want)
+		}
+	}
+	// Embedding generation is part of the operation generating embeddings.
+	if got, want := generations[0].Parent().SpanID(), adds[0].SpanContext().SpanID(); got != want {
+		t.Errorf("got embedding span parent %s, want the Collection.Add span %s", got, want)
+	}
+}
+
+func TestTelemetryErrors(t *testing.T) {
+	ctx := context.Background()
+	client, spans, reader := instrumentedClient(t)
+
+	if _, err := client.GetCollection(ctx, "missing"); err == nil {
+		t.Fatal("got no error getting a missing collection")
+	}
+
+	gets := endedSpans(spans, "Client.GetCollection")
+	if len(gets) != 1 {
+		t.Fatalf("got %d Client.GetCollection spans, want 1", len(gets))
+	}
+	if got := gets[0].Status().Code; got != codes.Error {
+		t.Errorf("got span status %v, want %v", got, codes.Error)
+	}
+	if got, ok := spanAttribute(gets[0], chroma.AttributeHTTPStatusCode); !ok || got.AsInt64() != 404 {
+		t.Errorf("got HTTP status %v, want 404", got.Emit())
+	}
+
+	m, ok := collectMetric(t, reader, "chroma.client.operation.errors")
+	if !ok {
+		t.Fatal("got no error counter")
+	}
+	sum, ok := m.Data.(metricdata.Sum[int64])
+	if !ok {
+		t.Fatalf("got error counter of type %T, want metricdata.Sum[int64]", m.Data)
+	}
+	if len(sum.DataPoints) != 1 {
+		t.Fatalf("got %d error data points, want 1", len(sum.DataPoints))
+	}
+	point := sum.DataPoints[0]
+	if point.Value != 1 {
+		t.Errorf("got %d errors, want 1", point.Value)
+	}
+	if got, _ := point.Attributes.Value(chroma.AttributeOperation); got.AsString() != "Client.GetCollection" {
+		t.Errorf("got error operation %q, want Client.GetCollection", got.AsString())
+	}
+}
+
+func TestTelemetryLatency(t *testing.T) {
+	ctx := context.Background()
+	client, _, reader := instrumentedClient(t)
+
+	for i := 0; i < 3; i++ {
+		if _, err := client.ListCollections(ctx); err != nil {
+			t.Fatalf("listing collections: %v", err)
+		}
+	}
+
+	m, ok := collectMetric(t, reader, "chroma.client.operation.duration")
+	if !ok {
+		t.Fatal("got no latency histogram")
+	}
+	if m.Unit != "s" {
+		t.Errorf("got unit %q, want s", m.Unit)
+	}
+	hist, ok := m.Data.(metricdata.Histogram[float64])
+	if !ok {
+		t.Fatalf("got latency of type %T, want metricdata.Histogram[float64]", m.Data)
+	}
+	var count uint64
+	for _, p := range hist.DataPoints {
+		if op, _ := p.Attributes.Value(chroma.AttributeOperation); op.AsString() == "Client.ListCollections" {
+			count += p.Count
+		}
+	}
+	if count != 3 {
+		t.Errorf("got %d Client.ListCollections latencies, want 3", count)
+	}
+
+	if _, ok := collectMetric(t, reader, "chroma.client.operation.errors"); ok {
+		t.Error("got an error counter without errors")
+	}
+}
+
+func TestTelemetryCacheHitRatio(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	client, spans, _ := instrumentedClient(t)
+
+	gen := cached.NewEmbeddingsGenerator(ctx, lengthEmbeddings{})
+	coll, err := client.CreateCollection(ctx, "docs", chroma.WithEmbeddingFunc(gen))
+	if err != nil {
+		t.Fatalf("creating collection: %v", err)
+	}
+	if _, err := coll.Upsert(ctx, []chroma.ID{"a", "b"}, nil, nil, []chroma.Document{"x", "yy"}); err != nil {
+		t.Fatalf("upserting: %v", err)
+	}
+	if _, err := coll.Upsert(ctx, []chroma.ID{"a", "b", "c", "d"}, nil, nil, []chroma.Document{"x", "yy", "zzz", "wwww"}); err != nil {
+		t.Fatalf("upserting: %v", err)
+	}
+
+	generations := endedSpans(spans, "EmbeddingGenerator.Generate")
+	if len(generations) != 2 {
+		t.Fatalf("got %d EmbeddingGenerator.Generate spans, want 2", len(generations))
+	}
+	for i, want := range []float64{0, 0.5} {
+		got, ok := spanAttribute(generations[i], chroma.AttributeCacheHitRatio)
+		if !ok || got.AsFloat64() != want {
+			t.Errorf("got hit ratio %v for generation %d, want %v", got.Emit(), i, want)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package vecmath
+
+import (
+	"container/heap"
+	"sort"
+
+	"github.com/kristofferostlund/chroma-go/chroma"
+)
+
+// Neighbor is a candidate found near a query.
+type Neighbor struct {
+	// Index is the candidate's index in the candidates searched.
+	Index    int
+	Distance float64
+}
+
+// TopK returns the k candidates nearest to each query by exhaustive search,
+// nearest first, with ties in candidate order.
+func TopK(queries, candidates []chroma.Embedding, k int, distance DistanceFunc) [][]Neighbor {
+	results := make([][]Neighbor, 0, len(queries))
+	for _, q := range queries {
+		results = append(results, topK(q, candidates, k, distance))
+	}
+	return results
+}
+
+func topK(query chroma.Embedding, candidates []chroma.Embedding, k int, distance DistanceFunc) []Neighbor {
+	if k <= 0 {
+		return []Neighbor{}
+	}
+
+	// Keep the k nearest in a max heap, so each candidate is compared with
+	// the farthest kept one.
+	nearest := make(neighborHeap, 0, minInt(k, len(candidates))+1)
+	for i, c := range candidates {
+		n := Neighbor{Index: i, Distance: distance(query, c)}
+		if len(nearest) < k {
+			heap.Push(&nearest, n)
+		} else if n.Distance < nearest[0].Distance {
+			nearest[0] = n
+			heap.Fix(&nearest, 0)
+		}
+	}
+
+	sorted := []Neighbor(nearest)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Distance != sorted[j].Distance {
+			return sorted[i].Distance < sorted[j].Distance
+		}
+		return sorted[i].Index < sorted[j].Index
+	})
+	return sorted
+}
+
+// Dedup returns the indexes of the embeddings to keep when dropping those
+// within threshold distance of an earlier kept one, in order. Pass query
+// results nearest first to keep the best match of each group.
+func Dedup(embeddings []chroma.Embedding, threshold float64, distance DistanceFunc) []int {
+	kept := make([]int, 0, len(embeddings))
+	for i, e := range embeddings {
+		duplicate := false
+		for _, j := range kept {
+			if distance(e, embeddings[j]) <= threshold {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			kept = append(kept, i)
+		}
+	}
+	return kept
+}
+
+// neighborHeap is a max heap by distance, breaking ties by the larger index
+// so the earlier candidate is kept.
+type neighborHeap []Neighbor
+
+func (h neighborHeap) Len() int { return len(h) }
+func (h neighborHeap) Less(i, j int) bool {
+	if h[i].Distance != h[j].Distance {
+		return h[i].Distance > h[j].Distance
+	}
+	return h[i].Index > h[j].Index
+}
+func (h neighborHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *neighborHeap) Push(x interface{}) {
+	*h = append(*h, x.(Neighbor))
+}
+
+func (h *neighborHeap) Pop() interface{} {
+	last := (*h)[len(*h)-1]
+	*h = (*h)[:len(*h)-1]
+	return last
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,209 @@
+// Package vecmath provides distance functions matching Chroma's and helpers
+// for working with embeddings client-side, such as re-ranking and
+// deduplicating query results or validating the distances a server returns.
+//
+// Functions taking two embeddings panic if their dimensions differ, like
+// indexing out of range, since that is a programming error. Embeddings from
+// untrusted input, such as a server or a file, should be validated first with
+// CheckDimensions, or compared with distance functions wrapped by Checked.
+package vecmath
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/kristofferostlund/chroma-go/chroma"
+)
+
+// Space is a distance function as selected by the hnsw:space collection
+// metadata, with smaller distances being nearer.
+type Space string
+
+const (
+	// SpaceL2 is the squared euclidean distance, Chroma's default.
+	SpaceL2 Space = "l2"
+	// SpaceCosine is one minus the cosine similarity.
+	SpaceCosine Space = "cosine"
+	// SpaceIP is one minus the inner product.
+	SpaceIP Space = "ip"
+)
+
+// DistanceFunc returns the distance between two embeddings.
+type DistanceFunc func(a, b chroma.Embedding) float64
+
+// CheckedDistanceFunc returns the distance between two embeddings, or an
+// error if their dimensions differ.
+type CheckedDistanceFunc func(a, b chroma.Embedding) (float64, error)
+
+// Checked returns distance as a CheckedDistanceFunc, for untrusted input.
+func Checked(distance DistanceFunc) CheckedDistanceFunc {
+	return func(a, b chroma.Embedding) (float64, error) {
+		if len(a) != len(b) {
+			return 0, fmt.Errorf("%w: dimension mismatch, %d and %d", chroma.ErrInvalidInput, len(a), len(b))
+		}
+		return distance(a, b), nil
+	}
+}
+
+// CheckDimensions returns an error unless all embeddings in groups have the
+// same dimension, validating untrusted input to TopK and Dedup with
+//
+//	err := vecmath.CheckDimensions(queries, candidates)
+func CheckDimensions(groups ...[]chroma.Embedding) error {
+	dimension := -1
+	for i, embeddings := range groups {
+		for j, e := range embeddings {
+			if dimension < 0 {
+				dimension = len(e)
+			}
+			if len(e) != dimension {
+				return fmt.Errorf("%w: embedding %d of group %d has dimension %d, want %d", chroma.ErrInvalidInput, j, i, len(e), dimension)
+			}
+		}
+	}
+	return nil
+}
+
+// Distance returns the distance function of space.
+func Distance(space Space) (DistanceFunc, error) {
+	switch space {
+	case SpaceL2:
+		return L2, nil
+	case SpaceCosine:
+		return Cosine, nil
+	case SpaceIP:
+		return InnerProduct, nil
+	default:
+		return nil, fmt.Errorf("%w: unknown space %q, want one of l2, cosine, ip", chroma.ErrInvalidInput, space)
+	}
+}
+
+// L2 returns the squared euclidean distance, which like Chroma's isn't
+// square rooted.
+func L2(a, b chroma.Embedding) float64 {
+	mustMatchDimensions(a, b)
+	b = b[:len(a)]
+
+	// Unrolled with independent sums, which the compiler can keep in
+	// registers and the CPU can pipeline.
+	var s0, s1, s2, s3 float64
+	i := 0
+	for ; i+4 <= len(a); i += 4 {
+		d0 := a[i] - b[i]
+		d1 := a[i+1] - b[i+1]
+		d2 := a[i+2] - b[i+2]
+		d3 := a[i+3] - b[i+3]
+		s0 += d0 * d0
+		s1 += d1 * d1
+		s2 += d2 * d2
+		s3 += d3 * d3
+	}
+	for ; i < len(a); i++ {
+		d := a[i] - b[i]
+		s0 += d * d
+	}
+	return s0 + s1 + s2 + s3
+}
+
+// Cosine returns one minus the cosine similarity, from 0 for embeddings
+// pointing the same way to 2 for opposite ones. It is 1 if either is zero.
+func Cosine(a, b chroma.Embedding) float64 {
+	mustMatchDimensions(a, b)
+	b = b[:len(a)]
+
+	var dot0, dot1, na0, na1, nb0, nb1 float64
+	i := 0
+	for ; i+2 <= len(a); i += 2 {
+		dot0 += a[i] * b[i]
+		dot1 += a[i+1] * b[i+1]
+		na0 += a[i] * a[i]
+		na1 += a[i+1] * a[i+1]
+		nb0 += b[i] * b[i]
+		nb1 += b[i+1] * b[i+1]
+	}
+	for ; i < len(a); i++ {
+		dot0 += a[i] * b[i]
+		na0 += a[i] * a[i]
+		nb0 += b[i] * b[i]
+	}
+
+	normA, normB := na0+na1, nb0+nb1
+	if normA == 0 || normB == 0 {
+		return 1
+	}
+	return 1 - (dot0+dot1)/(math.Sqrt(normA)*math.Sqrt(normB))
+}
+
+// InnerProduct returns one minus the inner product, which equals Cosine
+// for normalized embeddings.
+func InnerProduct(a, b chroma.Embedding) float64 {
+	return 1 - Dot(a, b)
+}
+
+// Dot returns the inner product.
+func Dot(a, b chroma.Embedding) float64 {
+	mustMatchDimensions(a, b)
+	b = b[:len(a)]
+
+	var s0, s1, s2, s3 float64
+	i := 0
+	for ; i+4 <= len(a); i += 4 {
+		s0 += a[i] * b[i]
+		s1 += a[i+1] * b[i+1]
+		s2 += a[i+2] * b[i+2]
+		s3 += a[i+3] * b[i+3]
+	}
+	for ; i < len(a); i++ {
+		s0 += a[i] * b[i]
+	}
+	return s0 + s1 + s2 + s3
+}
+
+// Norm returns the euclidean length.
+func Norm(v chroma.Embedding) float64 {
+	return math.Sqrt(Dot(v, v))
+}
+
+// Normalize returns a copy of v scaled to unit length, or a copy of v if
+// it is zero.
+func Normalize(v chroma.Embedding) chroma.Embedding {
+	normalized := make(chroma.Embedding, len(v))
+	norm := Norm(v)
+	if norm == 0 {
+		copy(normalized, v)
+		return normalized
+	}
+	for i, x := range v {
+		normalized[i] = x / norm
+	}
+	return normalized
+}
+
+// Centroid returns the mean of the embeddings, which must all have the same
+// dimension.
+func Centroid(embeddings []chroma.Embedding) (chroma.Embedding, error) {
+	if len(embeddings) == 0 {
+		return nil, fmt.Errorf("%w: no embeddings", chroma.ErrInvalidInput)
+	}
+
+	centroid := make(chroma.Embedding, len(embeddings[0]))
+	for i, e := range embeddings {
+		if len(e) != len(centroid) {
+			return nil, fmt.Errorf("%w: embedding %d has dimension %d, want %d", chroma.ErrInvalidInput, i, len(e), len(centroid))
+		}
+		for j, x := range e {
+			centroid[j] += x
+		}
+	}
+	n := float64(len(embeddings))
+	for j := range centroid {
+		centroid[j] /= n
+	}
+	return centroid, nil
+}
+
+func mustMatchDimensions(a, b chroma.Embedding) {
+	if len(a) != len(b) {
+		panic(fmt.Sprintf("vecmath: dimension mismatch, %d and %d", len(a), len(b)))
+	}
+}
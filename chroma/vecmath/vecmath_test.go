@@ -0,0 +1,235 @@
+package vecmath_test
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/kristofferostlund/chroma-go/chroma"
+	"github.com/kristofferostlund/chroma-go/chroma/vecmath"
+)
+
+const epsilon = 1e-12
+
+func randomEmbedding(rng *rand.Rand, dim int) chroma.Embedding {
+	e := make(chroma.Embedding, dim)
+	for i := range e {
+		e[i] = rng.NormFloat64()
+	}
+	return e
+}
+
+func TestDistances(t *testing.T) {
+	tests := []struct {
+		name     string
+		distance vecmath.DistanceFunc
+		a, b     chroma.Embedding
+		want     float64
+	}{
+		// L2 is squared, like Chroma's.
+		{"l2", vecmath.L2, chroma.Embedding{0, 0}, chroma.Embedding{3, 4}, 25},
+		{"l2 identical", vecmath.L2, chroma.Embedding{1, 2, 3}, chroma.Embedding{1, 2, 3}, 0},
+		{"l2 past unrolling", vecmath.L2, chroma.Embedding{1, 1, 1, 1, 1}, chroma.Embedding{0, 0, 0, 0, 3}, 8},
+		{"l2 empty", vecmath.L2, chroma.Embedding{}, chroma.Embedding{}, 0},
+
+		{"cosine same direction", vecmath.Cosine, chroma.Embedding{1, 2, 3}, chroma.Embedding{2, 4, 6}, 0},
+		{"cosine orthogonal", vecmath.Cosine, chroma.Embedding{1, 0, 0}, chroma.Embedding{0, 5, 0}, 1},
+		{"cosine opposite", vecmath.Cosine, chroma.Embedding{1, -1}, chroma.Embedding{-2, 2}, 2},
+		{"cosine zero", vecmath.Cosine, chroma.Embedding{0, 0, 0}, chroma.Embedding{1, 2, 3}, 1},
+		{"cosine both zero", vecmath.Cosine, chroma.Embedding{0, 0}, chroma.Embedding{0, 0}, 1},
+
+		{"ip", vecmath.InnerProduct, chroma.Embedding{1, 2, 3}, chroma.Embedding{4, 5, 6}, 1 - 32},
+		{"ip orthogonal", vecmath.InnerProduct, chroma.Embedding{1, 0}, chroma.Embedding{0, 1}, 1},
+		{"ip unit", vecmath.InnerProduct, chroma.Embedding{0.6, 0.8}, chroma.Embedding{0.6, 0.8}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.distance(tt.a, tt.b); math.Abs(got-tt.want) > epsilon {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+			if got := tt.distance(tt.b, tt.a); math.Abs(got-tt.want) > epsilon {
+				t.Errorf("got %v with arguments swapped, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestDistancesMatchDefinitions compares the unrolled loops with the plain
+// definitions, for every remainder of the unrolling.
+func TestDistancesMatchDefinitions(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for dim := 1; dim <= 9; dim++ {
+		a, b := randomEmbedding(rng, dim), randomEmbedding(rng, dim)
+
+		var l2, dot, normA, normB float64
+		for i := range a {
+			l2 += (a[i] - b[i]) * (a[i] - b[i])
+			dot += a[i] * b[i]
+			normA += a[i] * a[i]
+			normB += b[i] * b[i]
+		}
+		cosine := 1 - dot/(math.Sqrt(normA)*math.Sqrt(normB))
+
+		for name, c := range map[string]struct{ got, want float64 }{
+			"L2":           {vecmath.L2(a, b), l2},
+			"Cosine":       {vecmath.Cosine(a, b), cosine},
+			"Dot":          {vecmath.Dot(a, b), dot},
+			"InnerProduct": {vecmath.InnerProduct(a, b), 1 - dot},
+			"Norm":         {vecmath.Norm(a), math.Sqrt(normA)},
+		} {
+			if math.Abs(c.got-c.want) > epsilon {
+				t.Errorf("%s of dimension %d: got %v, want %v", name, dim, c.got, c.want)
+			}
+		}
+	}
+}
+
+func TestInnerProductOfNormalizedIsCosine(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	a, b := randomEmbedding(rng, 17), randomEmbedding(rng, 17)
+
+	got := vecmath.InnerProduct(vecmath.Normalize(a), vecmath.Normalize(b))
+	if want := vecmath.Cosine(a, b); math.Abs(got-want) > epsilon {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestDistance(t *testing.T) {
+	a, b := chroma.Embedding{1, 0}, chroma.Embedding{0, 2}
+	for space, want := range map[vecmath.Space]float64{
+		vecmath.SpaceL2:     5,
+		vecmath.SpaceCosine: 1,
+		vecmath.SpaceIP:     1,
+	} {
+		distance, err := vecmath.Distance(space)
+		if err != nil {
+			t.Fatalf("getting %s: %v", space, err)
+		}
+		if got := distance(a, b); got != want {
+			t.Errorf("got %s distance %v, want %v", space, got, want)
+		}
+	}
+
+	if _, err := vecmath.Distance("manhattan"); !errors.Is(err, chroma.ErrInvalidInput) {
+		t.Errorf("got error %v for an unknown space, want ErrInvalidInput", err)
+	}
+}
+
+func TestDimensionMismatch(t *testing.T) {
+	a, b := chroma.Embedding{1, 2}, chroma.Embedding{1, 2, 3}
+
+	for name, distance := range map[string]vecmath.DistanceFunc{"L2": vecmath.L2, "Cosine": vecmath.Cosine, "InnerProduct": vecmath.InnerProduct} {
+		t.Run(name, func(t *testing.T) {
+			func() {
+				defer func() {
+					if recover() == nil {
+						t.Error("got no panic")
+					}
+				}()
+				distance(a, b)
+			}()
+
+			checked := vecmath.Checked(distance)
+			if _, err := checked(a, b); !errors.Is(err, chroma.ErrInvalidInput) {
+				t.Errorf("got error %v from checked, want ErrInvalidInput", err)
+			}
+			got, err := checked(a, a)
+			if err != nil {
+				t.Fatalf("got error %v from checked with matching dimensions", err)
+			}
+			if want := distance(a, a); got != want {
+				t.Errorf("got %v from checked, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestCheckDimensions(t *testing.T) {
+	tests := []struct {
+		name    string
+		groups  [][]chroma.Embedding
+		wantErr bool
+	}{
+		{"none", nil, false},
+		{"empty groups", [][]chroma.Embedding{{}, {}}, false},
+		{"matching", [][]chroma.Embedding{{{1, 2}}, {{3, 4}, {5, 6}}}, false},
+		{"mismatch within group", [][]chroma.Embedding{{{1, 2}, {3}}}, true},
+		{"mismatch across groups", [][]chroma.Embedding{{{1, 2}}, {{3, 4}, {5, 6, 7}}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := vecmath.CheckDimensions(tt.groups...)
+			if tt.wantErr && !errors.Is(err, chroma.ErrInvalidInput) {
+				t.Errorf("got error %v, want ErrInvalidInput", err)
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("got error %v, want none", err)
+			}
+		})
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	v := chroma.Embedding{3, 4}
+	got := vecmath.Normalize(v)
+	if got[0] != 0.6 || got[1] != 0.8 {
+		t.Errorf("got %v, want [0.6 0.8]", got)
+	}
+	if v[0] != 3 {
+		t.Errorf("got %v, want the input unchanged", v)
+	}
+
+	zero := chroma.Embedding{0, 0}
+	got = vecmath.Normalize(zero)
+	got[0] = 1
+	if zero[0] != 0 {
+		t.Error("got the zero input returned instead of a copy")
+	}
+}
+
+func TestCentroid(t *testing.T) {
+	got, err := vecmath.Centroid([]chroma.Embedding{{1, 2}, {3, 6}})
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if got[0] != 2 || got[1] != 4 {
+		t.Errorf("got %v, want [2 4]", got)
+	}
+
+	for name, embeddings := range map[string][]chroma.Embedding{"none": nil, "mismatch": {{1, 2}, {3}}} {
+		if _, err := vecmath.Centroid(embeddings); !errors.Is(err, chroma.ErrInvalidInput) {
+			t.Errorf("%s: got error %v, want ErrInvalidInput", name, err)
+		}
+	}
+}
+
+var benchmarkDimensions = []int{384, 768, 1536}
+
+func benchmarkDistance(b *testing.B, distance vecmath.DistanceFunc) {
+	for _, dim := range benchmarkDimensions {
+		b.Run(fmt.Sprint(dim), func(b *testing.B) {
+			rng := rand.New(rand.NewSource(1))
+			x, y := randomEmbedding(rng, dim), randomEmbedding(rng, dim)
+			b.ResetTimer()
+			var sink float64
+			for i := 0; i < b.N; i++ {
+				sink += distance(x, y)
+			}
+			_ = sink
+		})
+	}
+}
+
+func BenchmarkL2(b *testing.B) {
+	benchmarkDistance(b, vecmath.L2)
+}
+
+func BenchmarkCosine(b *testing.B) {
+	benchmarkDistance(b, vecmath.Cosine)
+}
+
+func BenchmarkDot(b *testing.B) {
+	benchmarkDistance(b, vecmath.Dot)
+}
@@ -0,0 +1,401 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/kristofferostlund/chroma-go/chroma"
+)
+
+func (a *app) version(ctx context.Context) error {
+	version, err := a.client.Version(ctx)
+	if err != nil {
+		return err
+	}
+	return a.printValue(version)
+}
+
+func (a *app) heartbeat(ctx context.Context) error {
+	heartbeat, err := a.client.Heartbeat(ctx)
+	if err != nil {
+		return err
+	}
+	return a.printValue(heartbeat)
+}
+
+func (a *app) collections(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return errors.New("missing collections subcommand, want one of list, create, get, rename, delete")
+	}
+
+	switch sub, args := args[0], args[1:]; sub {
+	case "list":
+		fs := a.flagSet("collections list", "")
+		if _, err := parseArgs(fs, args, 0, 0); err != nil {
+			return err
+		}
+		colls, err := a.client.ListCollections(ctx)
+		if err != nil {
+			return err
+		}
+		return a.printCollections(colls)
+	case "create":
+		fs := a.flagSet("collections create", "<name>")
+		metadata := fs.String("metadata", "", "collection metadata as a JSON object")
+		getOrCreate := fs.Bool("get-or-create", false, "get the collection if it already exists")
+		pos, err := parseArgs(fs, args, 1, 1)
+		if err != nil {
+			return err
+		}
+		var meta chroma.Metadata
+		if err := parseJSONFlag("metadata", *metadata, &meta); err != nil {
+			return err
+		}
+		create := a.client.CreateCollection
+		if *getOrCreate {
+			create = a.client.GetOrCreateCollection
+		}
+		coll, err := create(ctx, pos[0], chroma.WithMetadata(meta))
+		if err != nil {
+			return err
+		}
+		return a.printCollections([]chroma.SimpleCollection{simpleOf(coll)})
+	case "get":
+		fs := a.flagSet("collections get", "<name>")
+		pos, err := parseArgs(fs, args, 1, 1)
+		if err != nil {
+			return err
+		}
+		coll, err := a.client.GetCollection(ctx, pos[0])
+		if err != nil {
+			return err
+		}
+		return a.printCollections([]chroma.SimpleCollection{simpleOf(coll)})
+	case "rename":
+		fs := a.flagSet("collections rename", "<name> <new-name>")
+		pos, err := parseArgs(fs, args, 2, 2)
+		if err != nil {
+			return err
+		}
+		coll, err := a.client.GetCollection(ctx, pos[0])
+		if err != nil {
+			return err
+		}
+		if err := coll.Modify(ctx, pos[1], nil); err != nil {
+			return fmt.Errorf("renaming collection: %w", err)
+		}
+		return a.printCollections([]chroma.SimpleCollection{simpleOf(coll)})
+	case "delete":
+		fs := a.flagSet("collections delete", "<name>")
+		pos, err := parseArgs(fs, args, 1, 1)
+		if err != nil {
+			return err
+		}
+		if err := a.client.DeleteCollection(ctx, pos[0]); err != nil {
+			return err
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown collections subcommand %q, want one of list, create, get, rename, delete", sub)
+	}
+}
+
+func (a *app) count(ctx context.Context, args []string) error {
+	fs := a.flagSet("count", "<collection>")
+	pos, err := parseArgs(fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+	coll, err := a.client.GetCollection(ctx, pos[0])
+	if err != nil {
+		return err
+	}
+	count, err := coll.Count(ctx)
+	if err != nil {
+		return err
+	}
+	return a.printValue(count)
+}
+
+func (a *app) get(ctx context.Context, args []string) error {
+	fs := a.flagSet("get", "<collection>")
+	ids := fs.String("ids", "", "comma separated IDs to get")
+	f := addFilterFlags(fs)
+	limit := fs.Int("limit", 0, "maximum number of records to get, 0 for no limit")
+	offset := fs.Int("offset", 0, "number of records to skip")
+	include := fs.String("include", "documents,metadatas", "comma separated fields to include: documents, metadatas, embeddings")
+	pos, err := parseArgs(fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+
+	opts, err := f.queryOpts(*include)
+	if err != nil {
+		return err
+	}
+	if *limit > 0 {
+		opts = append(opts, chroma.WithLimit(*limit))
+	}
+	if *offset > 0 {
+		opts = append(opts, chroma.WithOffset(*offset))
+	}
+
+	coll, err := a.client.GetCollection(ctx, pos[0])
+	if err != nil {
+		return err
+	}
+	res, err := coll.Get(ctx, splitList(*ids), opts...)
+	if err != nil {
+		return fmt.Errorf("getting records: %w", err)
+	}
+
+	rows := make([][]string, 0, len(res.IDs))
+	for i, id := range res.IDs {
+		row := []string{id, "", ""}
+		if i < len(res.Documents) {
+			row[1] = cell(res.Documents[i])
+		}
+		if i < len(res.Metadatas) {
+			row[2] = cell(res.Metadatas[i])
+		}
+		rows = append(rows, row)
+	}
+	return a.print(res, []string{"ID", "DOCUMENT", "METADATA"}, rows)
+}
+
+func (a *app) query(ctx context.Context, args []string) error {
+	fs := a.flagSet("query", "<collection> <text>...")
+	nResults := fs.Int("n", 10, "number of results per query text")
+	f := addFilterFlags(fs)
+	include := fs.String("include", "documents,metadatas,distances", "comma separated fields to include: documents, metadatas, embeddings, distances")
+	e := addEmbeddingFlags(fs)
+	pos, err := parseArgs(fs, args, 2, -1)
+	if err != nil {
+		return err
+	}
+
+	opts, err := f.queryOpts(*include)
+	if err != nil {
+		return err
+	}
+	gen, err := e.generator()
+	if err != nil {
+		return err
+	}
+
+	coll, err := a.client.GetCollection(ctx, pos[0], chroma.WithEmbeddingFunc(gen))
+	if err != nil {
+		return err
+	}
+	queryTexts := make([]chroma.Document, 0, len(pos)-1)
+	queryTexts = append(queryTexts, pos[1:]...)
+	res, err := coll.Query(ctx, queryTexts, *nResults, opts...)
+	if err != nil {
+		return err
+	}
+
+	rows := make([][]string, 0)
+	for q, ids := range res.IDs {
+		for i, id := range ids {
+			row := []string{queryTexts[q], id, "", "", ""}
+			if q < len(res.Distances) && i < len(res.Distances[q]) {
+				row[2] = strconv.FormatFloat(res.Distances[q][i], 'f', 4, 64)
+			}
+			if q < len(res.Documents) && i < len(res.Documents[q]) {
+				row[3] = cell(res.Documents[q][i])
+			}
+			if q < len(res.Metadatas) && i < len(res.Metadatas[q]) {
+				row[4] = cell(res.Metadatas[q][i])
+			}
+			rows = append(rows, row)
+		}
+	}
+	return a.print(res, []string{"QUERY", "ID", "DISTANCE", "DOCUMENT", "METADATA"}, rows)
+}
+
+func (a *app) export(ctx context.Context, args []string) error {
+	fs := a.flagSet("export", "<collection>")
+	format := fs.String("format", string(chroma.SnapshotJSONL), "snapshot format, jsonl or binary")
+	out := fs.String("out", "-", "file to write the snapshot to, - for stdout")
+	pageSize := fs.Int("page-size", 500, "number of records fetched per request")
+	pos, err := parseArgs(fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+
+	coll, err := a.client.GetCollection(ctx, pos[0])
+	if err != nil {
+		return err
+	}
+
+	w := a.stdout
+	if *out != "-" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return fmt.Errorf("creating file: %w", err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	bw := bufio.NewWriter(w)
+	if err := coll.Export(ctx, bw, chroma.SnapshotFormat(*format), chroma.WithExportPageSize(*pageSize)); err != nil {
+		return fmt.Errorf("exporting collection: %w", err)
+	}
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("writing snapshot: %w", err)
+	}
+	if f, ok := w.(*os.File); ok && f != os.Stdout {
+		if err := f.Close(); err != nil {
+			return fmt.Errorf("closing file: %w", err)
+		}
+	}
+	return nil
+}
+
+func (a *app) importCollection(ctx context.Context, args []string) error {
+	fs := a.flagSet("import", "")
+	in := fs.String("in", "-", "file to read the snapshot from, - for stdin")
+	name := fs.String("name", "", "import under this name instead of the snapshot's")
+	batchSize := fs.Int("batch-size", 100, "number of records upserted per request")
+	concurrency := fs.Int("concurrency", 4, "number of concurrent requests")
+	resumeOffset := fs.Int("resume-offset", 0, "skip this many records, to resume a failed import")
+	if _, err := parseArgs(fs, args, 0, 0); err != nil {
+		return err
+	}
+
+	r := a.stdin
+	if *in != "-" {
+		f, err := os.Open(*in)
+		if err != nil {
+			return fmt.Errorf("opening file: %w", err)
+		}
+		defer f.Close()
+		r = f
+	}
+
+	opts := []chroma.ImportOpts{
+		chroma.WithImportBatchSize(*batchSize),
+		chroma.WithImportConcurrency(*concurrency),
+		chroma.WithResumeOffset(*resumeOffset),
+	}
+	if *name != "" {
+		opts = append(opts, chroma.WithImportName(*name))
+	}
+
+	res, err := a.client.ImportCollection(ctx, bufio.NewReader(r), opts...)
+	if err != nil {
+		var importErr *chroma.ImportError
+		if errors.As(err, &importErr) {
+			return fmt.Errorf("importing collection: %w (resume with -resume-offset %d)", err, importErr.Offset)
+		}
+		return fmt.Errorf("importing collection: %w", err)
+	}
+
+	result := struct {
+		Collection chroma.SimpleCollection `json:"collection"`
+		Imported   int                     `json:"imported"`
+	}{simpleOf(res.Collection), res.Imported}
+	return a.print(result, []string{"NAME", "ID", "IMPORTED"}, [][]string{
+		{result.Collection.Name, result.Collection.ID, strconv.Itoa(result.Imported)},
+	})
+}
+
+func (a *app) reset(ctx context.Context, args []string) error {
+	fs := a.flagSet("reset", "")
+	yes := fs.Bool("yes", false, "do not ask for confirmation")
+	if _, err := parseArgs(fs, args, 0, 0); err != nil {
+		return err
+	}
+
+	if !*yes {
+		ok, err := confirm(a.stdin, a.stderr, "This deletes all collections on the server. Type 'yes' to continue: ")
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return errors.New("reset aborted")
+		}
+	}
+
+	if err := a.client.Reset(ctx); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (a *app) printCollections(colls []chroma.SimpleCollection) error {
+	rows := make([][]string, 0, len(colls))
+	for _, c := range colls {
+		rows = append(rows, []string{c.Name, c.ID, cell(c.Metadata)})
+	}
+	return a.print(colls, []string{"NAME", "ID", "METADATA"}, rows)
+}
+
+func simpleOf(coll *chroma.Collection) chroma.SimpleCollection {
+	return chroma.SimpleCollection{ID: coll.ID, Name: coll.Name, Metadata: coll.Metadata}
+}
+
+func confirm(r io.Reader, w io.Writer, prompt string) (bool, error) {
+	fmt.Fprint(w, prompt)
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return false, fmt.Errorf("reading confirmation: %w", err)
+	}
+	return strings.TrimSpace(line) == "yes", nil
+}
+
+// filterFlags are the filter flags shared by get and query.
+type filterFlags struct {
+	where         *string
+	whereDocument *string
+}
+
+func addFilterFlags(fs *flag.FlagSet) *filterFlags {
+	return &filterFlags{
+		where:         fs.String("where", "", `metadata filter as JSON, e.g. {"source": {"$eq": "a.md"}}`),
+		whereDocument: fs.String("where-document", "", `document filter as JSON, e.g. {"$contains": "chroma"}`),
+	}
+}
+
+func (f *filterFlags) queryOpts(include string) ([]chroma.QueryOpts, error) {
+	var where, whereDocument chroma.Where
+	if err := parseJSONFlag("where", *f.where, &where); err != nil {
+		return nil, err
+	}
+	if err := parseJSONFlag("where-document", *f.whereDocument, &whereDocument); err != nil {
+		return nil, err
+	}
+
+	includes := make([]chroma.Include, 0)
+	for _, s := range splitList(include) {
+		includes = append(includes, chroma.Include(s))
+	}
+
+	opts := []chroma.QueryOpts{chroma.WithInclude(includes...)}
+	if where != nil {
+		opts = append(opts, chroma.WithWhere(where))
+	}
+	if whereDocument != nil {
+		opts = append(opts, chroma.WithWhereDocument(whereDocument))
+	}
+	return opts, nil
+}
+
+func parseJSONFlag(name, value string, out interface{}) error {
+	if value == "" {
+		return nil
+	}
+	if err := json.Unmarshal([]byte(value), out); err != nil {
+		return fmt.Errorf("parsing -%s: %w", name, err)
+	}
+	return nil
+}
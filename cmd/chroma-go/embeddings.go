@@ -0,0 +1,77 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/kristofferostlund/chroma-go/chroma"
+	"github.com/kristofferostlund/chroma-go/chroma/embeddings/cohere"
+	"github.com/kristofferostlund/chroma-go/chroma/embeddings/local"
+	"github.com/kristofferostlund/chroma-go/chroma/embeddings/openai"
+	goopenai "github.com/sashabaranov/go-openai"
+)
+
+// embeddingFlags select the embedding provider used to embed query texts,
+// which must match the one the collection was populated with.
+type embeddingFlags struct {
+	provider        *string
+	model           *string
+	openaiAuthToken *string
+	cohereAuthToken *string
+	dimension       *int
+	tfidfModel      *string
+}
+
+func addEmbeddingFlags(fs *flag.FlagSet) *embeddingFlags {
+	return &embeddingFlags{
+		provider:        fs.String("embedding", "local", "embedding provider, one of openai, cohere, local"),
+		model:           fs.String("model", "", "openai or cohere model, defaults to the provider's default"),
+		openaiAuthToken: fs.String("openai-auth-token", os.Getenv("OPENAI_API_KEY"), "OpenAI API auth token, defaults to $OPENAI_API_KEY"),
+		cohereAuthToken: fs.String("cohere-auth-token", os.Getenv("COHERE_API_KEY"), "Cohere API auth token, defaults to $COHERE_API_KEY"),
+		dimension:       fs.Int("dimension", local.DefaultDimension, "dimension of local hashing embeddings"),
+		tfidfModel:      fs.String("tfidf-model", "", "file of a saved local TF-IDF model, used instead of hashing embeddings"),
+	}
+}
+
+func (e *embeddingFlags) generator() (chroma.EmbeddingGenerator, error) {
+	switch *e.provider {
+	case "openai":
+		if *e.openaiAuthToken == "" {
+			return nil, fmt.Errorf("missing OpenAI auth token, set -openai-auth-token or $OPENAI_API_KEY")
+		}
+		opts := make([]openai.Opt, 0)
+		if *e.model != "" {
+			var model goopenai.EmbeddingModel
+			// Unknown models unmarshal to goopenai.Unknown rather than failing.
+			if err := model.UnmarshalText([]byte(*e.model)); err != nil || model == goopenai.Unknown {
+				return nil, fmt.Errorf("unknown OpenAI model %q", *e.model)
+			}
+			opts = append(opts, openai.Model(model))
+		}
+		return openai.NewEmbeddingGenerator(*e.openaiAuthToken, opts...), nil
+	case "cohere":
+		if *e.cohereAuthToken == "" {
+			return nil, fmt.Errorf("missing Cohere auth token, set -cohere-auth-token or $COHERE_API_KEY")
+		}
+		opts := make([]cohere.Opt, 0)
+		if *e.model != "" {
+			opts = append(opts, cohere.Model(*e.model))
+		}
+		return cohere.NewEmbeddingGenerator(*e.cohereAuthToken, opts...), nil
+	case "local":
+		if *e.model != "" {
+			return nil, fmt.Errorf("-model is not supported by the local provider, use -dimension or -tfidf-model")
+		}
+		if *e.tfidfModel != "" {
+			gen, err := local.LoadTFIDFFile(*e.tfidfModel)
+			if err != nil {
+				return nil, fmt.Errorf("loading TF-IDF model: %w", err)
+			}
+			return gen, nil
+		}
+		return local.NewEmbeddingGenerator(local.Dimension(*e.dimension)), nil
+	default:
+		return nil, fmt.Errorf("unknown embedding provider %q, want one of openai, cohere, local", *e.provider)
+	}
+}
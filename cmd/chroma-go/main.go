@@ -0,0 +1,173 @@
+// Command chroma-go operates a Chroma server from the command line.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/kristofferostlund/chroma-go/chroma"
+)
+
+const usage = `Usage: chroma-go [flags] <command> [args]
+
+Commands:
+  version                               print the server version
+  heartbeat                             print the server heartbeat
+  collections list                      list collections
+  collections create <name>             create a collection
+  collections get <name>                show a collection
+  collections rename <name> <new-name>  rename a collection
+  collections delete <name>             delete a collection
+  count <collection>                    count the records of a collection
+  get <collection>                      get records, filtered by flags
+  query <collection> <text>...          query by text
+  export <collection>                   export a collection snapshot
+  import                                import a collection snapshot
+  reset                                 delete everything on the server
+
+Run chroma-go <command> -h for the flags of a command.
+
+Flags:
+`
+
+// app holds what every command needs.
+type app struct {
+	client *chroma.Client
+	output string
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
+
+func main() {
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	if err := run(ctx, os.Args[1:], os.Stdin, os.Stdout, os.Stderr); err != nil {
+		if !errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		}
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("chroma-go", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	chromaURL := fs.String("url", envOr("CHROMA_URL", "http://localhost:8000"), "URL to chromadb server, defaults to $CHROMA_URL")
+	output := fs.String("o", "table", "output format, table or json")
+	verbose := fs.Bool("v", false, "log requests to stderr")
+	fs.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *output != "table" && *output != "json" {
+		return fmt.Errorf("unknown output format %q, want table or json", *output)
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+
+	clientOpts := make([]chroma.ClientOpts, 0)
+	if *verbose {
+		logger := slog.New(slog.NewTextHandler(stderr, &slog.HandlerOptions{Level: slog.LevelDebug}))
+		clientOpts = append(clientOpts, chroma.WithLogger(logger))
+	}
+
+	a := &app{
+		client: chroma.NewClient(*chromaURL, clientOpts...),
+		output: *output,
+		stdin:  stdin,
+		stdout: stdout,
+		stderr: stderr,
+	}
+
+	cmd, cmdArgs := fs.Arg(0), fs.Args()[1:]
+	switch cmd {
+	case "version":
+		return a.version(ctx)
+	case "heartbeat":
+		return a.heartbeat(ctx)
+	case "collections":
+		return a.collections(ctx, cmdArgs)
+	case "count":
+		return a.count(ctx, cmdArgs)
+	case "get":
+		return a.get(ctx, cmdArgs)
+	case "query":
+		return a.query(ctx, cmdArgs)
+	case "export":
+		return a.export(ctx, cmdArgs)
+	case "import":
+		return a.importCollection(ctx, cmdArgs)
+	case "reset":
+		return a.reset(ctx, cmdArgs)
+	default:
+		fs.Usage()
+		return fmt.Errorf("unknown command %q", cmd)
+	}
+}
+
+func (a *app) flagSet(name, argsUsage string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(a.stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(a.stderr, "Usage: chroma-go %s %s\n", name, argsUsage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseArgs parses flags, which may come before or after the positional
+// arguments, and checks the number of positional arguments.
+func parseArgs(fs *flag.FlagSet, args []string, minArgs, maxArgs int) ([]string, error) {
+	positional := make([]string, 0)
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+
+	if len(positional) < minArgs || (maxArgs >= 0 && len(positional) > maxArgs) {
+		fs.Usage()
+		return nil, fmt.Errorf("got %d arguments", len(positional))
+	}
+	return positional, nil
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+func splitList(s string) []string {
+	if s == "" {
+		return nil
+	}
+	parts := strings.Split(s, ",")
+	out := make([]string, 0, len(parts))
+	for _, p := range parts {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
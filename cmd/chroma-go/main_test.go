@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kristofferostlund/chroma-go/chroma"
+	"github.com/kristofferostlund/chroma-go/chroma/embeddings/local"
+	"github.com/kristofferostlund/chroma-go/chroma/memstore"
+)
+
+// newServer serves a store with a docs collection embedded with 256
+// dimensional local embeddings.
+func newServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	store, err := memstore.New()
+	if err != nil {
+		t.Fatalf("creating store: %v", err)
+	}
+	srv := httptest.NewServer(store.Handler())
+	t.Cleanup(srv.Close)
+
+	ctx := context.Background()
+	coll, err := memstore.NewClient(store).CreateCollection(ctx, "docs", chroma.WithEmbeddingFunc(local.NewEmbeddingGenerator(local.Dimension(256))))
+	if err != nil {
+		t.Fatalf("creating collection: %v", err)
+	}
+	if _, err := coll.Add(ctx, []chroma.ID{"cat", "bread", "revenue"}, nil, nil, []chroma.Document{
+		"The cat sat on the mat.",
+		"Bake the bread at two hundred degrees.",
+		"Quarterly revenue grew by ten percent.",
+	}); err != nil {
+		t.Fatalf("adding: %v", err)
+	}
+	return srv
+}
+
+func runCLI(t *testing.T, srv *httptest.Server, stdin string, args ...string) (string, error) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	err := run(context.Background(), append([]string{"-url", srv.URL}, args...), strings.NewReader(stdin), &stdout, &stderr)
+	return stdout.String(), err
+}
+
+func TestRun(t *testing.T) {
+	srv := newServer(t)
+
+	tests := []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{"version", []string{"version"}, []string{memstore.Version}},
+		{"list", []string{"collections", "list"}, []string{"docs"}},
+		{"count", []string{"-o", "json", "count", "docs"}, []string{"3"}},
+		{"get", []string{"get", "docs", "-ids", "bread"}, []string{"bread", "Bake the bread"}},
+		{"query", []string{"-o", "json", "query", "docs", "-dimension", "256", "-n", "1", "the cat on the mat"}, []string{`"cat"`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := runCLI(t, srv, "", tt.args...)
+			if err != nil {
+				t.Fatalf("running %v: %v", tt.args, err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(out, want) {
+					t.Errorf("got output %q, want it to contain %q", out, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRunExportImport(t *testing.T) {
+	srv := newServer(t)
+
+	snapshot, err := runCLI(t, srv, "", "export", "docs")
+	if err != nil {
+		t.Fatalf("exporting: %v", err)
+	}
+	if _, err := runCLI(t, srv, snapshot, "import", "-name", "copy"); err != nil {
+		t.Fatalf("importing: %v", err)
+	}
+	if out, err := runCLI(t, srv, "", "count", "copy"); err != nil || strings.TrimSpace(out) != "3" {
+		t.Errorf("got count %q and error %v for the imported collection, want 3", out, err)
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	srv := newServer(t)
+
+	if _, err := runCLI(t, srv, ""); !errors.Is(err, flag.ErrHelp) {
+		t.Errorf("got error %v without a command, want flag.ErrHelp", err)
+	}
+	for _, args := range [][]string{
+		{"unknown"},
+		{"-o", "yaml", "version"},
+		{"count"},
+		{"count", "missing"},
+		{"query", "docs", "-model", "some-model", "text"},
+		{"query", "docs", "-embedding", "openai", "-openai-auth-token", "token", "-model", "some-model", "text"},
+		{"query", "docs", "-embedding", "other", "text"},
+	} {
+		if _, err := runCLI(t, srv, "", args...); err == nil {
+			t.Errorf("got no error running %v", args)
+		}
+	}
+}
@@ -9,6 +9,7 @@ import (
 
 	"github.com/kristofferostlund/chroma-go/chroma"
 	"github.com/kristofferostlund/chroma-go/chroma/embeddings/cached"
+	"github.com/kristofferostlund/chroma-go/chroma/embeddings/local"
 	"github.com/kristofferostlund/chroma-go/chroma/embeddings/openai"
 	"golang.org/x/sync/errgroup"
 )
@@ -53,7 +54,12 @@ func main() {
 	collName := fmt.Sprintf("coll-%d", time.Now().UnixMilli())
 	meta := map[string]interface{}{"hnsw:space": "cosine", "updated_at": time.Now().Format(time.RFC3339Nano)}
 	// embeddingFunc := openai.NewEmbeddingGenerator(*openaiAuthToken)
-	embeddingFunc := cached.NewEmbeddingsGenerator(ctx, openai.NewEmbeddingGenerator(*openaiAuthToken))
+	var embeddingGen chroma.EmbeddingGenerator = openai.NewEmbeddingGenerator(*openaiAuthToken)
+	if *openaiAuthToken == "" {
+		log.Printf("no OpenAI auth token given, using local embeddings")
+		embeddingGen = local.NewEmbeddingGenerator()
+	}
+	embeddingFunc := cached.NewEmbeddingsGenerator(ctx, embeddingGen)
 
 	coll, err := client.CreateCollection(
 		ctx,
@@ -141,6 +147,14 @@ func main() {
 	}
 	log.Printf("there are %d documents in the collection", count)
 
+	queryRes, err := coll.Query(ctx, []chroma.Document{"Hi 42"}, 3)
+	if err != nil {
+		log.Fatalf("querying documents: %v", err)
+	}
+	for i, id := range queryRes.IDs[0] {
+		log.Printf("  - query result %d: %s (distance %f)", i, id, queryRes.Distances[0][i])
+	}
+
 	// Delete it
 	if err := client.DeleteCollection(ctx, collName); err != nil {
 		log.Fatalf("deleting collection: %v", err)